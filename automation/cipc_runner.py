@@ -1,32 +1,105 @@
 import asyncio
+import hashlib
 import json
 import os
+import signal
+import sys
 from datetime import datetime
-from playwright.async_api import async_playwright
+from playwright.async_api import async_playwright, TimeoutError as PlaywrightTimeoutError
+
+# Version of the JSON-lines protocol spoken with the Go worker (temporal/runner_protocol.go).
+PROTOCOL_VERSION = 1
+
+ARTIFACT_DIR = os.getenv("CIPC_ARTIFACT_DIR", "/tmp/cipc-artifacts")
+
+# CIPC_RUNNER_STUB=1 answers status checks locally without opening the portal, for tests and
+# local development. References containing "REJ" are rejected, "PEND" stay pending and anything
+# else is approved.
+STUB = os.getenv("CIPC_RUNNER_STUB") == "1"
+
+
+class RunnerError(Exception):
+    """A typed failure reported to the worker. Codes are listed in runner_protocol.go."""
+
+    def __init__(self, code, message, retryable=False):
+        super().__init__(message)
+        self.code = code
+        self.message = message
+        self.retryable = retryable
+
+
+class EventWriter:
+    """Writes protocol events to stdout, one JSON object per line.
+
+    In protocol mode everything else (logs, library noise) must go to stderr.
+    """
+
+    def __init__(self, stream=None):
+        self.stream = stream or sys.stdout
+
+    def emit(self, event_type, **fields):
+        event = {"v": PROTOCOL_VERSION, "type": event_type}
+        event.update(fields)
+        self.stream.write(json.dumps(event) + "\n")
+        self.stream.flush()
+
+    def progress(self, step, message, **details):
+        self.emit("progress", step=step, message=message, details=details or None)
+
+    def artifact(self, kind, path, content_type):
+        with open(path, "rb") as f:
+            digest = hashlib.sha256(f.read()).hexdigest()
+        self.emit("artifact", artifact={"kind": kind, "path": path, "content_type": content_type, "sha256": digest})
+
+    def result(self, result):
+        self.emit("result", result=result)
+
+    def error(self, code, message, retryable=False):
+        self.emit("error", error={"code": code, "message": message, "retryable": retryable})
+
+
+class NullEventWriter(EventWriter):
+    """Discards events; used by the legacy CLI mode."""
+
+    def emit(self, event_type, **fields):
+        pass
+
+    def artifact(self, kind, path, content_type):
+        pass
+
 
 class CIPCRunner:
-    def __init__(self):
+    def __init__(self, events=None):
         self.credentials = {
             "username": os.getenv("CIPC_USERNAME", "demo_user"),
             "password": os.getenv("CIPC_PASSWORD", "demo_pass")
         }
-        
-    async def file_annual_return(self, client_data):
+        self.events = events or NullEventWriter()
+
+    async def _screenshot(self, page, request_id, name):
+        os.makedirs(ARTIFACT_DIR, exist_ok=True)
+        path = os.path.join(ARTIFACT_DIR, f"{request_id or 'run'}-{name}.png")
+        await page.screenshot(path=path)
+        self.events.artifact("screenshot", path, "image/png")
+
+    async def file_annual_return(self, client_data, request_id=None):
         """Automate Annual Return filing"""
         playwright = await async_playwright().start()
         browser = await playwright.chromium.launch(headless=True)
         page = await browser.new_page()
-        
+
         try:
             # Mock CIPC filing process
+            self.events.progress("logged_in", "Opened CIPC e-services session")
             await page.goto("https://httpbin.org/delay/2")  # Simulate processing time
-            
+
             # Simulate form filling
+            self.events.progress("form_page", "Completed annual return form", page=1)
+            self.events.progress("submitting", "Submitting annual return")
             ref_number = f"AR{datetime.now().strftime('%Y%m%d%H%M%S')}"
-            
-            await browser.close()
-            await playwright.stop()
-            
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Annual return submitted", reference=ref_number)
+
             return {
                 "status": "success",
                 "reference_number": ref_number,
@@ -34,72 +107,346 @@ class CIPCRunner:
                 "company": client_data.get("company_name", "Unknown"),
                 "timestamp": datetime.now().isoformat()
             }
-            
-        except Exception as e:
+
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
             await browser.close()
             await playwright.stop()
-            
-            return {
-                "status": "failed",
-                "error": str(e),
-                "service_type": "annual_return"
-            }
-    
-    async def file_beneficial_ownership(self, client_data):
+
+    async def file_beneficial_ownership(self, client_data, request_id=None):
         """Automate BO filing"""
         playwright = await async_playwright().start()
         browser = await playwright.chromium.launch(headless=True)
         page = await browser.new_page()
-        
+
         try:
             # Mock BO filing
+            self.events.progress("logged_in", "Opened CIPC e-services session")
             await page.goto("https://httpbin.org/delay/3")
-            
+
+            self.events.progress("form_page", "Completed beneficial ownership declaration", page=1)
+            self.events.progress("submitting", "Submitting beneficial ownership declaration")
             ref_number = f"BO{datetime.now().strftime('%Y%m%d%H%M%S')}"
-            
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Beneficial ownership declaration submitted", reference=ref_number)
+
+            return {
+                "status": "success",
+                "reference_number": ref_number,
+                "service_type": "beneficial_ownership",
+                "company": client_data.get("company_name", "Unknown"),
+                "timestamp": datetime.now().isoformat()
+            }
+
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
             await browser.close()
             await playwright.stop()
-            
+
+    async def reserve_name(self, client_data, request_id=None):
+        """Automate a name reservation with up to four names in order of preference"""
+        names = client_data.get("names") or []
+        if not 1 <= len(names) <= 4:
+            raise RunnerError("validation_failed", "Between one and four names are required for a name reservation")
+
+        playwright = await async_playwright().start()
+        browser = await playwright.chromium.launch(headless=True)
+        page = await browser.new_page()
+
+        try:
+            # Mock CIPC name reservation
+            self.events.progress("logged_in", "Opened CIPC e-services session")
+            await page.goto("https://httpbin.org/delay/2")
+
+            self.events.progress("form_page", "Entered proposed names", page=1, names=len(names))
+            self.events.progress("submitting", "Submitting name reservation")
+            ref_number = f"NR{datetime.now().strftime('%Y%m%d%H%M%S')}"
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Name reservation submitted", reference=ref_number)
+
             return {
                 "status": "success",
                 "reference_number": ref_number,
-                "service_type": "beneficial_ownership",
+                "service_type": "name_reservation",
+                "timestamp": datetime.now().isoformat()
+            }
+
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
+            await browser.close()
+            await playwright.stop()
+
+    async def register_company(self, client_data, request_id=None):
+        """Automate a CoR14.1 incorporation of a private company with a standard MOI"""
+        directors = client_data.get("directors") or []
+        if not directors:
+            raise RunnerError("validation_failed", "At least one director is required to register a company")
+
+        playwright = await async_playwright().start()
+        browser = await playwright.chromium.launch(headless=True)
+        page = await browser.new_page()
+
+        try:
+            # Mock CIPC company registration
+            self.events.progress("logged_in", "Opened CIPC e-services session")
+            await page.goto("https://httpbin.org/delay/3")
+
+            self.events.progress("form_page", "Entered company name and MOI", page=1, moi=client_data.get("moi"))
+            self.events.progress("form_page", "Entered incorporators and directors", page=2, directors=len(directors))
+            self.events.progress("submitting", "Submitting company registration")
+            ref_number = f"CR{datetime.now().strftime('%Y%m%d%H%M%S')}"
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Company registration submitted", reference=ref_number)
+
+            return {
+                "status": "success",
+                "reference_number": ref_number,
+                "service_type": "company_registration",
+                "timestamp": datetime.now().isoformat()
+            }
+
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
+            await browser.close()
+            await playwright.stop()
+
+    async def file_afs_submission(self, client_data, request_id=None):
+        """Automate lodging annual financial statements as the XBRL instance the worker staged"""
+        xbrl_path = client_data.get("xbrl_path")
+        if not xbrl_path or not os.path.exists(xbrl_path):
+            raise RunnerError("validation_failed", "The XBRL instance document to upload is missing")
+
+        playwright = await async_playwright().start()
+        browser = await playwright.chromium.launch(headless=True)
+        page = await browser.new_page()
+
+        try:
+            # Mock CIPC XBRL submission
+            self.events.progress("logged_in", "Opened CIPC e-services session")
+            await page.goto("https://httpbin.org/delay/3")
+
+            self.events.progress("form_page", "Uploaded XBRL instance document", page=1,
+                                 size=os.path.getsize(xbrl_path), financial_year_end=client_data.get("financial_year_end"))
+            self.events.progress("submitting", "Submitting financial statements")
+            ref_number = f"AFS{datetime.now().strftime('%Y%m%d%H%M%S')}"
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Financial statements submitted", reference=ref_number)
+
+            return {
+                "status": "success",
+                "reference_number": ref_number,
+                "service_type": "afs_submission",
                 "company": client_data.get("company_name", "Unknown"),
                 "timestamp": datetime.now().isoformat()
             }
-            
-        except Exception as e:
+
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
             await browser.close()
             await playwright.stop()
-            
+
+    async def reinstate_company(self, client_data, request_id=None):
+        """Automate applying to reinstate a company deregistered for not filing annual returns"""
+        playwright = await async_playwright().start()
+        browser = await playwright.chromium.launch(headless=True)
+        page = await browser.new_page()
+
+        try:
+            # Mock CIPC reinstatement application
+            self.events.progress("logged_in", "Opened CIPC e-services session")
+            await page.goto("https://httpbin.org/delay/2")
+
+            self.events.progress("form_page", "Completed reinstatement application", page=1)
+            self.events.progress("submitting", "Submitting reinstatement application")
+            ref_number = f"RI{datetime.now().strftime('%Y%m%d%H%M%S')}"
+            await self._screenshot(page, request_id, "submitted")
+            self.events.progress("submitted", "Reinstatement application submitted", reference=ref_number)
+
             return {
-                "status": "failed",
-                "error": str(e),
-                "service_type": "beneficial_ownership"
+                "status": "success",
+                "reference_number": ref_number,
+                "service_type": "company_reinstatement",
+                "company": client_data.get("company_name", "Unknown"),
+                "timestamp": datetime.now().isoformat()
             }
 
+        except PlaywrightTimeoutError as e:
+            raise RunnerError("portal_timeout", str(e), retryable=True)
+        finally:
+            await browser.close()
+            await playwright.stop()
+
+    async def verify_submission(self, service_type, client_data, resume):
+        """Checks whether an interrupted attempt's filing reached CIPC, without submitting again.
+
+        The worker calls this when the previous attempt's last checkpoint was "submitting" or
+        "submitted", so a crash mid-submission never produces a duplicate filing.
+        """
+        resume = resume or {}
+        self.events.progress("verifying", "Checking CIPC for an earlier submission", step_checked=resume.get("step"))
+
+        # Mock: the portal's filing history would be searched by company and submission time here.
+        reference = resume.get("reference")
+        if not reference:
+            raise RunnerError("not_submitted", "No submission found on CIPC for the interrupted attempt")
+
+        self.events.progress("submitted", "Found earlier submission on CIPC", reference=reference)
+        return {
+            "status": "success",
+            "reference_number": reference,
+            "service_type": service_type,
+            "company": client_data.get("company_name", "Unknown"),
+            "timestamp": datetime.now().isoformat()
+        }
+
+    def _confirmation_artifact(self, request_id, reference, cipc_reference):
+        """Saves the confirmation CIPC issues for an approved filing and reports it as an artifact."""
+        os.makedirs(ARTIFACT_DIR, exist_ok=True)
+        path = os.path.join(ARTIFACT_DIR, f"{request_id or reference}-confirmation.pdf")
+        text = f"CIPC filing confirmation {cipc_reference} for submission {reference}"
+        content = f"BT /F1 12 Tf 72 720 Td ({text}) Tj ET".encode("latin-1")
+        with open(path, "wb") as f:
+            f.write(b"%%PDF-1.4\n1 0 obj << /Length %d >>\nstream\n" % len(content))
+            f.write(content)
+            f.write(b"\nendstream\nendobj\ntrailer << >>\n%%EOF\n")
+        self.events.artifact("cipc_confirmation", path, "application/pdf")
+
+    async def check_status(self, service_type, client_data, request_id=None):
+        """Looks up the outcome of a submitted filing on CIPC, without changing anything.
+
+        Returns status "pending", "approved" or "rejected". Approved filings come with CIPC's
+        reference and a cipc_confirmation artifact; rejected ones with CIPC's reason. Until the
+        portal enquiry is automated only the stub decides filings; otherwise they stay pending.
+        """
+        reference = client_data.get("reference")
+        if not reference:
+            raise RunnerError("validation_failed", "A submission reference is required to check its status")
+        self.events.progress("checking_status", "Checking CIPC for the filing outcome", reference=reference)
+
+        if STUB:
+            outcome = "rejected" if "REJ" in reference else "pending" if "PEND" in reference else "approved"
+            rejection_reason = "Stub rejection: supporting documents incomplete" if outcome == "rejected" else None
+        else:
+            # The portal's filing enquiry is not automated yet. Report the filing as pending so it
+            # keeps being followed up, and escalated, instead of being taken as approved.
+            self.events.progress("status_unavailable", "CIPC status lookup is not automated yet", reference=reference)
+            outcome, rejection_reason = "pending", None
+
+        result = {
+            "status": outcome,
+            "reference_number": reference,
+            "service_type": service_type,
+            "timestamp": datetime.now().isoformat(),
+        }
+        if outcome == "approved" and service_type == "name_reservation":
+            # Stub: the enquiry would show which of the proposed names CIPC reserved.
+            names = client_data.get("names") or []
+            if names:
+                result["approved_name"] = names[0]
+        elif outcome == "approved" and service_type == "company_registration":
+            # Stub: the enquiry would show the registration number CIPC issued.
+            result["cipc_reference"] = f"CIPC-{reference}"
+            result["registration_number"] = f"{datetime.now().year}/{int(hashlib.sha256(reference.encode()).hexdigest(), 16) % 1000000:06d}/07"
+            self._confirmation_artifact(request_id, reference, result["cipc_reference"])
+        elif outcome == "approved":
+            result["cipc_reference"] = f"CIPC-{reference}"
+            self._confirmation_artifact(request_id, reference, result["cipc_reference"])
+        if rejection_reason:
+            result["rejection_reason"] = rejection_reason
+        self.events.progress("status_checked", f"CIPC status is {outcome}", reference=reference)
+        return result
+
+    async def run(self, service_type, client_data, request_id=None, action="file", resume=None):
+        if action == "verify":
+            return await self.verify_submission(service_type, client_data, resume)
+        if action == "status":
+            return await self.check_status(service_type, client_data, request_id)
+        if resume:
+            self.events.progress("resuming", f"Resuming after checkpoint {resume.get('step')}")
+        if service_type == "annual_return":
+            return await self.file_annual_return(client_data, request_id)
+        if service_type == "beneficial_ownership":
+            return await self.file_beneficial_ownership(client_data, request_id)
+        if service_type == "name_reservation":
+            return await self.reserve_name(client_data, request_id)
+        if service_type == "company_registration":
+            return await self.register_company(client_data, request_id)
+        if service_type == "afs_submission":
+            return await self.file_afs_submission(client_data, request_id)
+        if service_type == "company_reinstatement":
+            return await self.reinstate_company(client_data, request_id)
+        raise RunnerError("unsupported_service", f"Unknown service type: {service_type}")
+
+
+def run_protocol():
+    """Reads one request line from stdin and answers with protocol events on stdout."""
+    events = EventWriter(sys.stdout)
+    # Keep stray prints from libraries off the protocol stream.
+    sys.stdout = sys.stderr
+
+    try:
+        request = json.loads(sys.stdin.readline())
+    except json.JSONDecodeError as e:
+        events.error("protocol_error", f"Invalid request: {e}")
+        return 1
+
+    if request.get("v") != PROTOCOL_VERSION:
+        events.error("protocol_error", f"Unsupported protocol version {request.get('v')}, expected {PROTOCOL_VERSION}")
+        return 1
+
+    runner = CIPCRunner(events)
+
+    async def main():
+        task = asyncio.current_task()
+        # The worker sends SIGTERM on cancellation; cancel the filing so the browser is closed.
+        asyncio.get_running_loop().add_signal_handler(signal.SIGTERM, task.cancel)
+        return await runner.run(
+            request.get("service_type"),
+            request.get("client_data") or {},
+            request.get("request_id"),
+            request.get("action") or "file",
+            request.get("resume"),
+        )
+
+    try:
+        events.result(asyncio.run(main()))
+        return 0
+    except RunnerError as e:
+        events.error(e.code, e.message, e.retryable)
+        return 1
+    except asyncio.CancelledError:
+        events.error("cancelled", "Filing cancelled by worker", retryable=True)
+        return 1
+    except Exception as e:
+        events.error("runner_crashed", str(e), retryable=True)
+        return 1
+
+
 # CLI interface
 if __name__ == "__main__":
-    import sys
-    
+    if len(sys.argv) == 2 and sys.argv[1] == "--jsonl":
+        sys.exit(run_protocol())
+
     runner = CIPCRunner()
-    
+
     if len(sys.argv) < 3:
         print("Usage: python cipc_runner.py <service_type> '<client_data_json>'")
+        print("       python cipc_runner.py --jsonl < request.json")
         print("Example: python cipc_runner.py annual_return '{\"company_name\": \"Test Co\", \"reg_number\": \"123456789\"}'")
         sys.exit(1)
-    
+
     service_type = sys.argv[1]
     client_data = json.loads(sys.argv[2])
-    
+
     async def main():
-        if service_type == "annual_return":
-            result = await runner.file_annual_return(client_data)
-        elif service_type == "beneficial_ownership":
-            result = await runner.file_beneficial_ownership(client_data)
-        else:
-            result = {"status": "failed", "error": "Unknown service type"}
-        
-        print(json.dumps(result, indent=2))
-    
-    asyncio.run(main())
\ No newline at end of file
+        try:
+            return await runner.run(service_type, client_data)
+        except Exception as e:
+            return {"status": "failed", "error": str(e), "service_type": service_type}
+
+    print(json.dumps(asyncio.run(main()), indent=2))
@@ -0,0 +1,22 @@
+-- Company Directors
+-- Migration: 0004_company_directors
+
+CREATE TABLE IF NOT EXISTS company_directors (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id VARCHAR NOT NULL REFERENCES companies(id),
+    full_name TEXT NOT NULL,
+    id_number TEXT NOT NULL,
+    residential_address TEXT,
+    email TEXT,
+    status TEXT DEFAULT 'active' CHECK (status IN ('active', 'resigned')),
+    appointment_date DATE,
+    resignation_date DATE,
+    cipc_filing_id VARCHAR REFERENCES cipc_filings(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_directors_active ON company_directors(company_id, id_number) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_directors_company ON company_directors(company_id);
+
+CREATE TRIGGER update_company_directors_updated_at BEFORE UPDATE ON company_directors FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,51 @@
+-- Automation Canary Rollout
+-- Migration: 0005_automation_rollout
+
+CREATE TABLE IF NOT EXISTS automation_rollout (
+    service_type TEXT PRIMARY KEY,
+    percentage INTEGER NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100),
+    bucket_by TEXT NOT NULL DEFAULT 'company' CHECK (bucket_by IN ('transaction', 'company')),
+    failure_threshold DECIMAL(4,3) NOT NULL DEFAULT 0.2 CHECK (failure_threshold BETWEEN 0 AND 1),
+    min_sample INTEGER NOT NULL DEFAULT 20,
+    rollback_percentage INTEGER NOT NULL DEFAULT 0 CHECK (rollback_percentage BETWEEN 0 AND 100),
+    rolled_back_at TIMESTAMP,
+    rollback_reason TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- One row per routed transaction; outcome is filled in when the filing finishes
+CREATE TABLE IF NOT EXISTS automation_rollout_outcomes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id TEXT NOT NULL,
+    service_type TEXT NOT NULL,
+    cohort TEXT NOT NULL CHECK (cohort IN ('automated', 'manual')),
+    bucket INTEGER NOT NULL,
+    percentage INTEGER NOT NULL,
+    outcome TEXT NOT NULL DEFAULT 'pending' CHECK (outcome IN ('pending', 'success', 'failure')),
+    error_code TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    UNIQUE (transaction_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rollout_outcomes_service ON automation_rollout_outcomes(service_type, cohort, completed_at);
+
+CREATE OR REPLACE VIEW automation_rollout_cohort_stats AS
+SELECT service_type,
+       cohort,
+       percentage,
+       COUNT(*) FILTER (WHERE outcome = 'success') AS successes,
+       COUNT(*) FILTER (WHERE outcome = 'failure') AS failures,
+       COUNT(*) FILTER (WHERE outcome = 'pending') AS pending,
+       ROUND(COUNT(*) FILTER (WHERE outcome = 'failure')::DECIMAL / NULLIF(COUNT(*) FILTER (WHERE outcome <> 'pending'), 0), 3) AS failure_rate
+FROM automation_rollout_outcomes
+GROUP BY service_type, cohort, percentage;
+
+CREATE TRIGGER update_automation_rollout_updated_at BEFORE UPDATE ON automation_rollout FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Start automation on 10% of companies for the services the runner supports
+INSERT INTO automation_rollout (service_type, percentage, bucket_by) VALUES
+('annual_return', 10, 'company'),
+('beneficial_ownership', 10, 'company')
+ON CONFLICT (service_type) DO NOTHING;
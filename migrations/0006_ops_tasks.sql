@@ -0,0 +1,39 @@
+-- Operations Work Queue
+-- Migration: 0006_ops_tasks
+
+CREATE TABLE IF NOT EXISTS ops_tasks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_type TEXT NOT NULL CHECK (task_type IN ('automation_failure', 'manual_filing')),
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'completed', 'rejected')),
+    transaction_id TEXT NOT NULL,
+    service_type TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    company_reg_number TEXT,
+    client_data JSONB,
+    error_code TEXT,
+    error_message TEXT,
+    workflow_id TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    claimed_by TEXT,
+    claimed_at TIMESTAMP,
+    manual_reference TEXT,
+    resolution_note TEXT,
+    resolved_by TEXT,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ops_task_notes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id UUID NOT NULL REFERENCES ops_tasks(id) ON DELETE CASCADE,
+    author TEXT NOT NULL,
+    note TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ops_tasks_status ON ops_tasks(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_ops_tasks_transaction ON ops_tasks(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ops_task_notes_task ON ops_task_notes(task_id, created_at);
+
+CREATE TRIGGER update_ops_tasks_updated_at BEFORE UPDATE ON ops_tasks FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
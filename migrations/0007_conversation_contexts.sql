@@ -0,0 +1,19 @@
+-- WhatsApp Conversation Contexts
+-- Migration: 0007_conversation_contexts
+
+-- A workflow waiting on a WhatsApp reply registers what it is waiting for, so inbound
+-- messages can be routed to it
+CREATE TABLE IF NOT EXISTS conversation_contexts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    phone_number TEXT NOT NULL,
+    awaiting TEXT NOT NULL CHECK (awaiting IN ('consent', 'otp', 'file')),
+    description TEXT NOT NULL,
+    workflow_id TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_contexts_active ON conversation_contexts(phone_number, created_at) WHERE closed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_conversation_contexts_workflow ON conversation_contexts(workflow_id);
@@ -0,0 +1,8 @@
+-- Filing Workflow Links
+-- Migration: 0008_filing_workflows
+
+-- The filing workflow processing a transaction, so a customer's filings can be looked up
+-- and queried for progress
+ALTER TABLE payg_transactions ADD COLUMN IF NOT EXISTS workflow_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_payg_transactions_user_created ON payg_transactions(user_id, created_at DESC) WHERE workflow_id IS NOT NULL;
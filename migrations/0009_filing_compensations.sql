@@ -0,0 +1,47 @@
+-- Filing Compensations
+-- Migration: 0009_filing_compensations
+
+-- Transactions whose filing could not be completed are refunded, credited or retried
+ALTER TABLE payg_transactions DROP CONSTRAINT IF EXISTS payg_transactions_status_check;
+ALTER TABLE payg_transactions ADD CONSTRAINT payg_transactions_status_check
+    CHECK (status IN ('pending', 'paid', 'failed', 'refund_pending', 'refunded', 'credited', 'retry_scheduled'));
+
+-- Filing workflows can now wait on the customer's compensation choice
+ALTER TABLE conversation_contexts DROP CONSTRAINT IF EXISTS conversation_contexts_awaiting_check;
+ALTER TABLE conversation_contexts ADD CONSTRAINT conversation_contexts_awaiting_check
+    CHECK (awaiting IN ('consent', 'otp', 'file', 'compensation'));
+
+-- Account credit customers can spend on later filings
+CREATE TABLE IF NOT EXISTS account_credits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    source_transaction_id UUID REFERENCES payg_transactions(id),
+    redeemed_transaction_id UUID REFERENCES payg_transactions(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    redeemed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_credits_user ON account_credits(user_id) WHERE redeemed_at IS NULL;
+
+-- One row per compensated filing, for finance. Refunds stay pending until finance has paid
+-- them out through the payment provider
+CREATE TABLE IF NOT EXISTS filing_compensations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES payg_transactions(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    workflow_id TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('submission_failed', 'otp_timeout')),
+    failure TEXT,
+    choice TEXT NOT NULL CHECK (choice IN ('refund', 'credit', 'retry')),
+    chosen_by TEXT NOT NULL CHECK (chosen_by IN ('customer', 'default')),
+    amount DECIMAL(10,2) NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'completed')),
+    account_credit_id UUID REFERENCES account_credits(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    UNIQUE (workflow_id, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_filing_compensations_pending ON filing_compensations(created_at) WHERE status = 'pending';
@@ -0,0 +1,12 @@
+-- Document Vault
+-- Migration: 0010_document_vault
+
+-- Documents are stored encrypted under their SHA-256 (file_path holds the blob key) and can be
+-- linked to the CIPC filing they support
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash VARCHAR;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS size_bytes BIGINT;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS cipc_filing_id VARCHAR REFERENCES cipc_filings(id);
+
+CREATE INDEX IF NOT EXISTS idx_documents_company ON documents(company_id);
+CREATE INDEX IF NOT EXISTS idx_documents_cipc_filing ON documents(cipc_filing_id) WHERE cipc_filing_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_content_hash ON documents(content_hash);
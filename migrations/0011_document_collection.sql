@@ -0,0 +1,12 @@
+-- Document Collection
+-- Migration: 0011_document_collection
+
+-- Document collection workflows wait on WhatsApp attachments
+ALTER TABLE conversation_contexts DROP CONSTRAINT IF EXISTS conversation_contexts_awaiting_check;
+ALTER TABLE conversation_contexts ADD CONSTRAINT conversation_contexts_awaiting_check
+    CHECK (awaiting IN ('consent', 'otp', 'file', 'compensation', 'documents'));
+
+-- Collections that pass their deadline are escalated to ops, who chase the customer
+ALTER TABLE ops_tasks DROP CONSTRAINT IF EXISTS ops_tasks_task_type_check;
+ALTER TABLE ops_tasks ADD CONSTRAINT ops_tasks_task_type_check
+    CHECK (task_type IN ('automation_failure', 'manual_filing', 'document_collection'));
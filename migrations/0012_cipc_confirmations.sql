@@ -0,0 +1,10 @@
+-- CIPC Confirmations
+-- Migration: 0012_cipc_confirmations
+
+-- Submitted filings are followed up until CIPC approves or rejects them. cipc_reference is
+-- replaced by CIPC's own reference on approval and the confirmation is kept in the vault
+ALTER TABLE cipc_filings ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+ALTER TABLE cipc_filings ADD COLUMN IF NOT EXISTS confirmation_document_id VARCHAR REFERENCES documents(id);
+ALTER TABLE cipc_filings ADD COLUMN IF NOT EXISTS decided_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_cipc_filings_reference ON cipc_filings(cipc_reference, filing_type);
@@ -0,0 +1,25 @@
+-- CIPC Portal Sessions
+-- Migration: 0013_cipc_portal_sessions
+
+-- Leases on CIPC e-services sessions, capped globally and per customer code. A lease that is
+-- not renewed expires, so a crashed worker cannot hold a session forever
+CREATE TABLE IF NOT EXISTS cipc_portal_sessions (
+    holder TEXT PRIMARY KEY,
+    customer_code TEXT NOT NULL,
+    urgent BOOLEAN NOT NULL DEFAULT FALSE,
+    acquired_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cipc_portal_sessions_customer ON cipc_portal_sessions(customer_code);
+
+-- Activities waiting for a session, served urgent first and then in arrival order
+CREATE TABLE IF NOT EXISTS cipc_portal_session_waiters (
+    holder TEXT PRIMARY KEY,
+    customer_code TEXT NOT NULL,
+    urgent BOOLEAN NOT NULL DEFAULT FALSE,
+    enqueued_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cipc_portal_session_waiters_order ON cipc_portal_session_waiters(urgent DESC, enqueued_at);
@@ -0,0 +1,32 @@
+-- Urgent Filing SLAs
+-- Migration: 0014_urgent_filing_sla
+
+-- Urgent filings running out of their SLA are escalated to ops
+ALTER TABLE ops_tasks DROP CONSTRAINT IF EXISTS ops_tasks_task_type_check;
+ALTER TABLE ops_tasks ADD CONSTRAINT ops_tasks_task_type_check
+    CHECK (task_type IN ('automation_failure', 'manual_filing', 'document_collection', 'urgent_sla'));
+
+-- The urgency fee of a filing that breached its SLA is credited back once
+ALTER TABLE payg_transactions ADD COLUMN IF NOT EXISTS sla_credit_id UUID REFERENCES account_credits(id);
+
+-- One row per urgent filing run, for SLA attainment reporting. Elapsed time excludes time spent
+-- waiting on the customer
+CREATE TABLE IF NOT EXISTS filing_slas (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES payg_transactions(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    workflow_id TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    service_type TEXT NOT NULL,
+    sla_seconds INTEGER NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    elapsed_seconds INTEGER NOT NULL,
+    customer_wait_seconds INTEGER NOT NULL DEFAULT 0,
+    outcome TEXT NOT NULL CHECK (outcome IN ('met', 'breached', 'failed')),
+    escalation_task_id UUID REFERENCES ops_tasks(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (workflow_id, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_filing_slas_finished ON filing_slas(finished_at, service_type);
@@ -0,0 +1,37 @@
+-- Company Name Reservations
+-- Migration: 0015_name_reservations
+
+-- Cached copy of CIPC's register of company names, loaded from CIPC's register extracts. Names
+-- are looked up by their distinctive words (CompanyNameLookupTokens) and scored for similarity
+-- in the worker. Approved reservations are added as 'reserved' until they lapse
+CREATE TABLE IF NOT EXISTS cipc_registered_names (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    normalized_name TEXT NOT NULL UNIQUE,
+    tokens TEXT[] NOT NULL,
+    registration_number TEXT,
+    status TEXT NOT NULL DEFAULT 'registered' CHECK (status IN ('registered', 'reserved')),
+    reserved_until TIMESTAMP,
+    refreshed_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cipc_registered_names_tokens ON cipc_registered_names USING GIN (tokens);
+
+-- One row per NameReservationWorkflow
+CREATE TABLE IF NOT EXISTS name_reservations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    workflow_id TEXT NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    company_type TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('checking', 'no_available_names', 'submitted', 'approved', 'rejected', 'failed', 'undecided')),
+    checks JSONB NOT NULL DEFAULT '[]',
+    submitted_names TEXT[],
+    reference TEXT,
+    approved_name TEXT,
+    rejection_reason TEXT,
+    reserved_until TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_name_reservations_user ON name_reservations(user_id, created_at DESC);
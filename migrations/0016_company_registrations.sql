@@ -0,0 +1,15 @@
+-- Company Registrations
+-- Migration: 0016_company_registrations
+
+-- New private companies are registered as a pay-as-you-go service
+ALTER TABLE payg_transactions DROP CONSTRAINT IF EXISTS payg_transactions_service_type_check;
+ALTER TABLE payg_transactions ADD CONSTRAINT payg_transactions_service_type_check
+    CHECK (service_type IN ('beneficial_ownership', 'director_amendment', 'annual_return', 'bbee_certificate', 'afs_submission', 'company_update', 'company_registration'));
+
+INSERT INTO pricing_config (service_type, base_price, urgency_multiplier, subscription_tiers) VALUES
+('company_registration', 499.00, 1.5, '{}')
+ON CONFLICT (service_type) DO NOTHING;
+
+-- ID copies for a registration are collected before CIPC registers the company; they are filed
+-- against it once it has been onboarded
+ALTER TABLE documents ALTER COLUMN company_id DROP NOT NULL;
@@ -0,0 +1,25 @@
+-- B-BBEE Sworn Affidavits
+-- Migration: 0017_bbee_affidavits
+
+-- One row per affidavit generated by BBEEAffidavitWorkflow; the PDF is kept in the vault
+CREATE TABLE IF NOT EXISTS bbee_affidavits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id TEXT NOT NULL UNIQUE,
+    company_id VARCHAR NOT NULL REFERENCES companies(id),
+    sector_code TEXT NOT NULL,
+    enterprise_size TEXT NOT NULL CHECK (enterprise_size IN ('EME', 'QSE')),
+    level INTEGER NOT NULL CHECK (level IN (1, 2, 4)),
+    annual_turnover DECIMAL(15,2) NOT NULL,
+    financial_year_end DATE NOT NULL,
+    ownership JSONB NOT NULL,
+    document_id VARCHAR REFERENCES documents(id),
+    valid_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bbee_affidavits_company ON bbee_affidavits(company_id, created_at DESC);
+
+-- An affidavit's expiry is tracked like a statutory deadline
+ALTER TABLE compliance_deadlines DROP CONSTRAINT IF EXISTS compliance_deadlines_deadline_type_check;
+ALTER TABLE compliance_deadlines ADD CONSTRAINT compliance_deadlines_deadline_type_check
+    CHECK (deadline_type IN ('annual_return', 'beneficial_ownership', 'afs_submission', 'tax_clearance', 'bbee_certificate'));
@@ -0,0 +1,17 @@
+-- AFS Obligations
+-- Migration: 0018_afs_obligations
+
+-- A company's Public Interest Score and audit or review decision per financial year; annual
+-- returns lodge the AFS or financial accountability supplement recorded here
+CREATE TABLE IF NOT EXISTS afs_obligations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_reg_number TEXT NOT NULL,
+    financial_year_end DATE NOT NULL,
+    public_interest_score INTEGER NOT NULL,
+    assurance TEXT NOT NULL CHECK (assurance IN ('audit', 'independent_review', 'exempt')),
+    lodge TEXT NOT NULL CHECK (lodge IN ('afs', 'fas')),
+    facts JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (company_reg_number, financial_year_end)
+);
@@ -0,0 +1,24 @@
+-- Company Status and Reinstatement
+-- Migration: 0019_company_status
+
+-- Where a company stands with CIPC: in business, with an annual return outstanding, in CIPC's
+-- AR deregistration process or deregistered. cipc_status is the enterprise status CIPC last
+-- reported, e.g. 'AR Deregistration Process'.
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS company_status TEXT NOT NULL DEFAULT 'in_business'
+    CHECK (company_status IN ('in_business', 'ar_non_compliant', 'in_deregistration', 'deregistered'));
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS cipc_status TEXT;
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_companies_status ON companies(company_status) WHERE company_status <> 'in_business';
+
+ALTER TABLE compliance_alerts ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'info'
+    CHECK (severity IN ('info', 'warning', 'high', 'critical'));
+
+-- Deregistered companies are reinstated as a pay-as-you-go service
+ALTER TABLE payg_transactions DROP CONSTRAINT IF EXISTS payg_transactions_service_type_check;
+ALTER TABLE payg_transactions ADD CONSTRAINT payg_transactions_service_type_check
+    CHECK (service_type IN ('beneficial_ownership', 'director_amendment', 'annual_return', 'bbee_certificate', 'afs_submission', 'company_update', 'company_registration', 'company_reinstatement'));
+
+INSERT INTO pricing_config (service_type, base_price, urgency_multiplier, subscription_tiers) VALUES
+('company_reinstatement', 799.00, 1.5, '{}')
+ON CONFLICT (service_type) DO NOTHING;
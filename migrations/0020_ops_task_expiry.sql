@@ -0,0 +1,7 @@
+-- Ops Task Expiry
+-- Migration: 0020_ops_task_expiry
+
+-- A task expires when the filing waiting on it gives up, so it can no longer be resolved
+ALTER TABLE ops_tasks DROP CONSTRAINT IF EXISTS ops_tasks_status_check;
+ALTER TABLE ops_tasks ADD CONSTRAINT ops_tasks_status_check
+    CHECK (status IN ('open', 'claimed', 'completed', 'rejected', 'expired'));
@@ -0,0 +1,7 @@
+-- CIPC Outcome Overdue Tasks
+-- Migration: 0021_cipc_outcome_overdue
+
+-- Ops chase CIPC for filings it has not decided by the time CIPCConfirmationWorkflow gives up
+ALTER TABLE ops_tasks DROP CONSTRAINT IF EXISTS ops_tasks_task_type_check;
+ALTER TABLE ops_tasks ADD CONSTRAINT ops_tasks_task_type_check
+    CHECK (task_type IN ('automation_failure', 'manual_filing', 'document_collection', 'urgent_sla', 'cipc_outcome_overdue'));
@@ -0,0 +1,5 @@
+-- Director Nationality
+-- Migration: 0022_director_nationality
+
+-- Foreign directors are identified by passport number in id_number, with their nationality here
+ALTER TABLE company_directors ADD COLUMN IF NOT EXISTS nationality CHAR(2);
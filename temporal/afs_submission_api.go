@@ -0,0 +1,52 @@
+package temporal
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// AFSSubmissionResponse is returned by POST /afs-submissions.
+type AFSSubmissionResponse struct {
+	WorkflowID string `json:"workflow_id"`
+	// Amounts are the mapped and calculated taxonomy amounts the preview will show.
+	Amounts map[string]float64 `json:"amounts"`
+}
+
+func (s *APIServer) registerAFSSubmissionRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /afs-submissions", requireInternalAPIKey(s.createAFSSubmissionHandler))
+}
+
+// createAFSSubmissionHandler maps the trial balance to the CIPC taxonomy and starts an
+// AFSSubmissionWorkflow, which sends the customer the XBRL preview to approve before anything
+// is charged or filed.
+func (s *APIServer) createAFSSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	var input AFSSubmissionInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.PhoneNumber == "" || input.CompanyID == "" || input.CompanyRegNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id, phone_number, company_id and company_reg_number are required")
+		return
+	}
+	instance, problems := ValidateAFSSubmission(input)
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("afs-submission-%d", time.Now().UnixNano()),
+		TaskQueue: TaskQueue,
+	}, AFSSubmissionWorkflow, input)
+	if err != nil {
+		log.Printf("Error starting AFS submission for %s: %s", input.CompanyRegNumber, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start AFS submission")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, AFSSubmissionResponse{WorkflowID: run.GetID(), Amounts: instance.Amounts})
+}
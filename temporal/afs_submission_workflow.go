@@ -0,0 +1,271 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// AFSSubmissionServiceType is the pay-as-you-go service that lodges audited financial
+// statements with CIPC in XBRL.
+const AFSSubmissionServiceType = "afs_submission"
+
+// AFSApprovalTimeout is how long the customer has to approve the XBRL preview.
+const AFSApprovalTimeout = 14 * 24 * time.Hour
+
+// afsPreviewLinkTTL keeps the preview link working for the whole approval window.
+const afsPreviewLinkTTL = AFSApprovalTimeout + 24*time.Hour
+
+// AFSSubmissionInput is the input for AFSSubmissionWorkflow.
+type AFSSubmissionInput struct {
+	UserID           string              `json:"user_id"`
+	PhoneNumber      string              `json:"phone_number"`
+	CompanyID        string              `json:"company_id"`
+	CompanyRegNumber string              `json:"company_reg_number"`
+	Statements       FinancialStatements `json:"statements"`
+	IsUrgent         bool                `json:"is_urgent,omitempty"`
+}
+
+// AFSSubmissionResult is the result of AFSSubmissionWorkflow.
+type AFSSubmissionResult struct {
+	Success            bool     `json:"success"`
+	Status             string   `json:"status"`
+	InstanceDocumentID string   `json:"instance_document_id,omitempty"`
+	PreviewDocumentID  string   `json:"preview_document_id,omitempty"`
+	TransactionID      string   `json:"transaction_id,omitempty"`
+	FilingID           string   `json:"filing_workflow_id,omitempty"`
+	Problems           []string `json:"problems,omitempty"`
+}
+
+// XBRLPackage is a validated instance document and its preview, stored in the vault.
+type XBRLPackage struct {
+	InstanceDocumentID string   `json:"instance_document_id,omitempty"`
+	PreviewDocumentID  string   `json:"preview_document_id,omitempty"`
+	PreviewURL         string   `json:"preview_url,omitempty"`
+	Problems           []string `json:"problems,omitempty"`
+}
+
+// ValidateAFSSubmission returns the problems with an AFS submission, as customer-facing messages.
+func ValidateAFSSubmission(input AFSSubmissionInput) (*XBRLInstance, []string) {
+	instance, problems := BuildXBRLInstance(input.Statements)
+	if strings.TrimSpace(input.Statements.RegistrationNumber) != strings.TrimSpace(input.CompanyRegNumber) {
+		problems = append(problems, fmt.Sprintf("the financial statements are for %s, not %s", input.Statements.RegistrationNumber, input.CompanyRegNumber))
+	}
+	return instance, problems
+}
+
+// AFSSubmissionWorkflow lodges a company's annual financial statements with CIPC: it maps the
+// trial balance to an XBRL instance document, validates it against the taxonomy, sends the
+// customer a preview and, once they reply FILE, charges for and files the submission.
+func AFSSubmissionWorkflow(ctx workflow.Context, input AFSSubmissionInput) (*AFSSubmissionResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting AFSSubmissionWorkflow", "CompanyRegNumber", input.CompanyRegNumber)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	notify := func(message string) {
+		if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send WhatsApp message", "error", err)
+		}
+	}
+	invalid := func(problems []string) (*AFSSubmissionResult, error) {
+		notify("⚠️ *We can't prepare your financial statements for CIPC yet:*\n\n• " + strings.Join(problems, "\n• "))
+		return &AFSSubmissionResult{Status: "invalid", Problems: problems}, nil
+	}
+
+	// Step 1: Map the trial balance to the taxonomy
+	instance, problems := ValidateAFSSubmission(input)
+	if len(problems) > 0 {
+		return invalid(problems)
+	}
+
+	// Step 2: Generate and validate the instance document and its preview
+	var pkg XBRLPackage
+	if err := workflow.ExecuteActivity(ctx, GenerateXBRLPackageActivity, input.CompanyID, instance).Get(ctx, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to generate XBRL package: %w", err)
+	}
+	if len(pkg.Problems) > 0 {
+		return invalid(pkg.Problems)
+	}
+	result := &AFSSubmissionResult{InstanceDocumentID: pkg.InstanceDocumentID, PreviewDocumentID: pkg.PreviewDocumentID}
+
+	// Step 3: Send the preview and wait for the customer to approve it
+	notify(fmt.Sprintf("📊 *Your financial statements are ready for CIPC*\n\nCompany: %s\nYear ended: %s\nTotal assets: R%s\nProfit for the year: R%s\n\nPreview (link valid for %d days): %s\n\nCheck it against your signed AFS and reply 'FILE' to lodge it with CIPC.",
+		instance.CompanyName, instance.PeriodEnd.Format("2 January 2006"), formatXBRLAmount(instance.Amounts["Assets"]),
+		formatXBRLAmount(instance.Amounts["ProfitLoss"]), int(AFSApprovalTimeout.Hours()/24), pkg.PreviewURL))
+	expiresAt := workflow.Now(ctx).Add(AFSApprovalTimeout)
+	conversationID := openConversation(ctx, ConversationPrompt{
+		PhoneNumber: input.PhoneNumber,
+		Awaiting:    ConversationAwaitingFile,
+		Description: fmt.Sprintf("Financial statements for %s (reply FILE)", input.CompanyRegNumber),
+		ExpiresAt:   expiresAt,
+	})
+	approved, _ := workflow.GetSignalChannel(ctx, FileConfirmSignalName).ReceiveWithTimeout(ctx, AFSApprovalTimeout, nil)
+	endConversation(ctx, conversationID)
+	if !approved {
+		logger.Info("Customer did not approve the XBRL preview", "CompanyRegNumber", input.CompanyRegNumber)
+		result.Status = "not_approved"
+		return result, nil
+	}
+
+	// Step 4: Create the transaction and hand over to the filing workflow
+	txInput := CreatePaygTransactionInput{
+		UserID:      input.UserID,
+		ServiceType: AFSSubmissionServiceType,
+		IsUrgent:    input.IsUrgent,
+		FilingData: map[string]interface{}{
+			"company_id":          input.CompanyID,
+			"company_name":        instance.CompanyName,
+			"financial_year_end":  instance.PeriodEnd.Format("2006-01-02"),
+			"xbrl_document_id":    pkg.InstanceDocumentID,
+			"preview_document_id": pkg.PreviewDocumentID,
+		},
+	}
+	if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &result.TransactionID); err != nil {
+		return nil, fmt.Errorf("failed to create AFS submission transaction: %w", err)
+	}
+
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID:        "filing-afs-submission-" + result.TransactionID,
+		TaskQueue:         FilingTaskQueue(input.IsUrgent),
+		ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+	}
+	filingInput := FilingWorkflowInput{
+		TransactionID:    result.TransactionID,
+		UserID:           input.UserID,
+		ServiceType:      AFSSubmissionServiceType,
+		FilingData:       txInput.FilingData,
+		CompanyRegNumber: input.CompanyRegNumber,
+		IsUrgent:         input.IsUrgent,
+	}
+	child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CombinedFilingWorkflow, filingInput)
+	var execution workflow.Execution
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		return nil, fmt.Errorf("failed to start AFS submission filing: %w", err)
+	}
+
+	result.Success, result.Status, result.FilingID = true, "filing", execution.ID
+	return result, nil
+}
+
+// GenerateXBRLPackageActivity renders the instance document, validates it against the taxonomy
+// and stores it in the vault with its PDF preview. Validation problems are returned in the
+// package and nothing is stored.
+func GenerateXBRLPackageActivity(ctx context.Context, companyID string, instance XBRLInstance) (*XBRLPackage, error) {
+	data, err := instance.Marshal()
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidXBRLInstance", err)
+	}
+	if problems := ValidateXBRLInstance(data); len(problems) > 0 {
+		return &XBRLPackage{Problems: problems}, nil
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	vault := NewDocumentVault(db, store)
+
+	yearEnd := instance.PeriodEnd.Format("2006-01-02")
+	instanceDoc, err := vault.Upload(ctx, DocumentUpload{
+		CompanyID:    companyID,
+		FileName:     fmt.Sprintf("afs-%s.xbrl", yearEnd),
+		DocumentType: DocumentTypeXBRLInstance,
+	}, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store XBRL instance: %w", err)
+	}
+	previewDoc, err := vault.Upload(ctx, DocumentUpload{
+		CompanyID:    companyID,
+		FileName:     fmt.Sprintf("afs-%s-preview.pdf", yearEnd),
+		DocumentType: DocumentTypeXBRLPreview,
+	}, RenderXBRLPreview(&instance))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store XBRL preview: %w", err)
+	}
+
+	activity.GetLogger(ctx).Info("Generated XBRL package", "company_id", companyID, "instance_document_id", instanceDoc.ID)
+	return &XBRLPackage{
+		InstanceDocumentID: instanceDoc.ID,
+		PreviewDocumentID:  previewDoc.ID,
+		PreviewURL:         vault.SignedURLWithTTL(previewDoc.ID, time.Now(), afsPreviewLinkTTL),
+	}, nil
+}
+
+// stageXBRLUpload writes the instance document named by the filing's xbrl_document_id to a
+// temporary file and passes its path to the runner as xbrl_path. The document must belong to the
+// company being filed for. The returned function removes the file.
+func stageXBRLUpload(ctx context.Context, companyRegNumber string, clientData map[string]interface{}) (func(), error) {
+	documentID, _ := clientData["xbrl_document_id"].(string)
+	if documentID == "" {
+		return func() {}, nil
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	vault := NewDocumentVault(db, store)
+	doc, err := vault.Get(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	var companyID string
+	err = db.QueryRowContext(ctx, `SELECT id FROM companies WHERE registration_number = $1`, companyRegNumber).Scan(&companyID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if companyID == "" || doc.CompanyID != companyID {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("XBRL document %s does not belong to company %s", documentID, companyRegNumber), ErrorTypeDocumentRejected, nil)
+	}
+	data, err := vault.Open(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "xbrl-*.xbrl")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		cleanup()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	clientData["xbrl_path"] = file.Name()
+	return cleanup, nil
+}
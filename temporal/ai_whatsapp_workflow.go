@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"go.temporal.io/sdk/activity"
@@ -55,11 +54,16 @@ func SendWhatsAppActivity(ctx context.Context, to string, message string) error
 	logger := activity.GetLogger(ctx)
 	logger.Info("Sending WhatsApp message", "to", to, "message", message)
 
-	internalAPIKey := os.Getenv("INTERNAL_API_KEY")
-	if internalAPIKey == "" {
-		internalAPIKey = "your-secret-key" // Fallback for local development
-	}
+	return postWhatsAppMessage(ctx, to, message)
+}
+
+// sendWhatsAppMessage sends a message from code that does not run inside an activity.
+func sendWhatsAppMessage(to string, message string) error {
+	return postWhatsAppMessage(context.Background(), to, message)
+}
 
+// postWhatsAppMessage delivers a message through the internal Node.js WhatsApp endpoint.
+func postWhatsAppMessage(ctx context.Context, to string, message string) error {
 	nodeServerURL := "http://localhost:3000/api/_internal/whatsapp/send"
 
 	requestBody, err := json.Marshal(map[string]string{
@@ -76,7 +80,7 @@ func SendWhatsAppActivity(ctx context.Context, to string, message string) error
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Internal-API-Key", internalAPIKey)
+	req.Header.Set(InternalAPIKeyHeader, getInternalAPIKey())
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
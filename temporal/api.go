@@ -0,0 +1,95 @@
+package temporal
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go.temporal.io/sdk/client"
+)
+
+// APIServer serves the HTTP endpoints that sit next to the worker: operations tooling and
+// filing lookups that need both the database and the Temporal client.
+type APIServer struct {
+	DB        *sql.DB
+	Temporal  client.Client
+	Documents *DocumentVault
+}
+
+// NewAPIServer connects to the application database and returns a server using the given client.
+func NewAPIServer(c client.Client) (*APIServer, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	return &APIServer{DB: db, Temporal: c, Documents: NewDocumentVault(db, store)}, nil
+}
+
+// ListenAndServe serves the API on the configured address until it fails.
+func (s *APIServer) ListenAndServe() error {
+	addr := getAPIAddr()
+	log.Println("HTTP API listening on", addr)
+	return http.ListenAndServe(addr, s.Routes())
+}
+
+// Routes returns the server's handler.
+func (s *APIServer) Routes() http.Handler {
+	mux := http.NewServeMux()
+	s.registerOpsQueueRoutes(mux)
+	s.registerWhatsAppRoutes(mux)
+	s.registerFilingRoutes(mux)
+	s.registerDocumentRoutes(mux)
+	s.registerFilingBatchRoutes(mux)
+	s.registerFilingSLARoutes(mux)
+	s.registerFilingDryRunRoutes(mux)
+	s.registerNameReservationRoutes(mux)
+	s.registerCompanyRegistrationRoutes(mux)
+	s.registerBBEEAffidavitRoutes(mux)
+	s.registerAFSObligationRoutes(mux)
+	s.registerAFSSubmissionRoutes(mux)
+	s.registerCompanyStatusRoutes(mux)
+	s.registerBeneficialOwnershipRoutes(mux)
+	s.registerDirectorAmendmentRoutes(mux)
+	return mux
+}
+
+// InternalAPIKeyHeader carries the key services on the internal network, such as the WhatsApp
+// bridge, present to each other.
+const InternalAPIKeyHeader = "X-Internal-API-Key"
+
+// requireInternalAPIKey only lets requests carrying the internal API key through to next.
+func requireInternalAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(InternalAPIKeyHeader)), []byte(getInternalAPIKey())) != 1 {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error writing response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package temporal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireInternalAPIKey(t *testing.T) {
+	t.Setenv("INTERNAL_API_KEY", "internal-key")
+	handler := requireInternalAPIKey(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for key, status := range map[string]int{"": http.StatusUnauthorized, "wrong-key": http.StatusUnauthorized, "internal-key": http.StatusNoContent} {
+		req := httptest.NewRequest(http.MethodPost, "/whatsapp/inbound", nil)
+		if key != "" {
+			req.Header.Set(InternalAPIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, status, rec.Code, key)
+	}
+}
+
+func TestRequireOpsAgent(t *testing.T) {
+	t.Setenv("OPS_AGENT_TOKENS", "thandi@ops=token-1, pieter@ops=token-2")
+	var agent string
+	handler := requireOpsAgent(func(w http.ResponseWriter, r *http.Request) {
+		agent = opsAgent(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for header, want := range map[string]string{"": "", "Bearer wrong": "", "token-2": "", "Bearer token-2": "pieter@ops"} {
+		agent = ""
+		req := httptest.NewRequest(http.MethodPost, "/ops/tasks/task-1/claim", nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, want, agent, header)
+		if want == "" {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, header)
+		}
+	}
+}
@@ -1,28 +1,39 @@
-package main
+package temporal
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
-	"os/exec"
 	"time"
 
+	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
 type AutomatedFilingInput struct {
-	TransactionID string                 `json:"transaction_id"`
-	ServiceType   string                 `json:"service_type"`
-	ClientData    map[string]interface{} `json:"client_data"`
-	UserID        string                 `json:"user_id"`
+	TransactionID    string                 `json:"transaction_id"`
+	ServiceType      string                 `json:"service_type"`
+	ClientData       map[string]interface{} `json:"client_data"`
+	UserID           string                 `json:"user_id"`
+	CompanyRegNumber string                 `json:"company_reg_number,omitempty"`
+	IsUrgent         bool                   `json:"is_urgent,omitempty"`
 }
 
 type FilingResult struct {
-	Status          string `json:"status"`
-	ReferenceNumber string `json:"reference_number"`
-	Error           string `json:"error,omitempty"`
-	Timestamp       string `json:"timestamp"`
+	Status          string           `json:"status"`
+	ReferenceNumber string           `json:"reference_number"`
+	Error           string           `json:"error,omitempty"`
+	ErrorCode       string           `json:"error_code,omitempty"`
+	Timestamp       string           `json:"timestamp"`
+	Artifacts       []RunnerArtifact `json:"artifacts,omitempty"`
+	CIPCReference   string           `json:"cipc_reference,omitempty"`
+	RejectionReason string           `json:"rejection_reason,omitempty"` // set by RunnerActionStatus
+	ApprovedName    string           `json:"approved_name,omitempty"`    // set by RunnerActionStatus for name reservations
+	// RegistrationNumber is set by RunnerActionStatus for approved company registrations.
+	RegistrationNumber string `json:"registration_number,omitempty"`
 }
 
 // AutomatedFilingWorkflow orchestrates the automated CIPC filing
@@ -32,7 +43,8 @@ func AutomatedFilingWorkflow(ctx workflow.Context, input AutomatedFilingInput) (
 
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		StartToCloseTimeout: 10 * time.Minute,
-		RetryPolicy: &workflow.RetryPolicy{
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
 			InitialInterval:    30 * time.Second,
 			BackoffCoefficient: 2.0,
 			MaximumInterval:    5 * time.Minute,
@@ -50,62 +62,137 @@ func AutomatedFilingWorkflow(ctx workflow.Context, input AutomatedFilingInput) (
 		}, nil
 	}
 
-	// Step 2: Execute automated filing
+	// Step 2: Decide whether this transaction is in the automation canary
+	var decision CanaryDecision
+	err = workflow.ExecuteActivity(ctx, CanaryRolloutActivity, input).Get(ctx, &decision)
+	if err != nil {
+		logger.Warn("Canary decision failed, routing to manual filing", "error", err)
+		decision = CanaryDecision{Cohort: CanaryCohortManual}
+	}
+
+	// Step 3: Execute automated filing, or queue it for ops outside the canary
 	var filingResult FilingResult
-	err = workflow.ExecuteActivity(ctx, ExecuteAutomatedFilingActivity, input.ServiceType, input.ClientData).Get(ctx, &filingResult)
+	var taskID string
+	if decision.Automated {
+		portalCtx := withPortalSession(ctx, portalCustomerCode(input.ClientData), portalPriority(input.IsUrgent))
+		err = workflow.ExecuteActivity(portalCtx, ExecuteAutomatedFilingActivity, input.ServiceType, input.ClientData).Get(ctx, &filingResult)
+		if err != nil {
+			filingResult = FilingResult{
+				Status: "failed",
+				Error:  fmt.Sprintf("Filing execution failed: %v", err),
+			}
+			var appErr *temporal.ApplicationError
+			if errors.As(err, &appErr) {
+				filingResult.ErrorCode = appErr.Type()
+			}
+		}
+		recordCanaryOutcome(ctx, input, filingResult)
+
+		if filingResult.Status != "success" {
+			// Handle failure - alert operations team
+			err = workflow.ExecuteActivity(ctx, AlertOperationsTeamActivity, input, filingResult).Get(ctx, &taskID)
+		}
+	} else {
+		err = workflow.ExecuteActivity(ctx, RouteToManualFilingActivity, input, decision).Get(ctx, &taskID)
+	}
 	if err != nil {
 		return &FilingResult{
 			Status: "failed",
-			Error:  fmt.Sprintf("Filing execution failed: %v", err),
+			Error:  fmt.Sprintf("Failed to queue ops task: %v", err),
 		}, nil
 	}
 
-	// Step 3: Update records and notify user
+	// Step 4: Wait for ops to file manually
+	if taskID != "" {
+		logger.Info("Waiting for ops to resolve task", "task_id", taskID)
+		var resolution OpsTaskResolution
+		resolved := workflow.GetSignalChannel(ctx, OpsTaskResolvedSignalName)
+		received, _ := resolved.ReceiveWithTimeout(ctx, opsTaskTimeout, &resolution)
+		if !received {
+			// Close the task so ops can't resolve a filing nobody is waiting for, unless they
+			// resolved it while it was being closed.
+			if err := workflow.ExecuteActivity(ctx, ExpireOpsTaskActivity, taskID).Get(ctx, nil); err != nil {
+				logger.Warn("Failed to expire ops task", "task_id", taskID, "error", err)
+			}
+			received = resolved.ReceiveAsync(&resolution)
+		}
+		switch {
+		case !received:
+			filingResult = FilingResult{Status: "failed", Error: "Ops task was not resolved in time", ErrorCode: filingResult.ErrorCode}
+		case resolution.Status == OpsTaskCompleted:
+			filingResult = FilingResult{
+				Status:          "success",
+				ReferenceNumber: resolution.Reference,
+				Timestamp:       workflow.Now(ctx).Format(time.RFC3339),
+			}
+		default:
+			filingResult = FilingResult{Status: "failed", Error: "Filing rejected by operations: " + resolution.Note, ErrorCode: filingResult.ErrorCode}
+		}
+		if !decision.Automated {
+			recordCanaryOutcome(ctx, input, filingResult)
+		}
+	}
+
+	// Step 5: Update records and notify user
 	if filingResult.Status == "success" {
 		err = workflow.ExecuteActivity(ctx, UpdateFilingRecordsActivity, input.TransactionID, filingResult.ReferenceNumber).Get(ctx, nil)
 		if err != nil {
 			logger.Warn("Failed to update records", "error", err)
 		}
 
-		err = workflow.ExecuteActivity(ctx, SendFilingConfirmationActivity, input.UserID, filingResult).Get(ctx, nil)
+		err = workflow.ExecuteActivity(ctx, SendFilingSubmittedActivity, input.UserID, input.ServiceType, filingResult).Get(ctx, nil)
 		if err != nil {
 			logger.Warn("Failed to send confirmation", "error", err)
 		}
-	} else {
-		// Handle failure - alert operations team
-		err = workflow.ExecuteActivity(ctx, AlertOperationsTeamActivity, input, filingResult).Get(ctx, nil)
-		if err != nil {
-			logger.Warn("Failed to alert operations team", "error", err)
+
+		// Step 6: Follow the filing until CIPC decides, outliving this workflow
+		cwo := workflow.ChildWorkflowOptions{
+			WorkflowID:        "cipc-confirmation-" + input.TransactionID,
+			ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+		}
+		child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CIPCConfirmationWorkflow, CIPCConfirmationInput{
+			TransactionID:    input.TransactionID,
+			UserID:           input.UserID,
+			ServiceType:      input.ServiceType,
+			CompanyRegNumber: input.CompanyRegNumber,
+			Reference:        filingResult.ReferenceNumber,
+		})
+		if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+			logger.Warn("Failed to start CIPC confirmation polling", "error", err)
 		}
 	}
 
 	return &filingResult, nil
 }
 
-// ExecuteAutomatedFilingActivity calls the Python CIPC Runner
-func ExecuteAutomatedFilingActivity(ctx context.Context, serviceType string, clientData map[string]interface{}) (FilingResult, error) {
-	logger := activity.GetLogger(ctx)
-	logger.Info("Executing automated filing", "service_type", serviceType)
+// opsTaskTimeout is how long a filing waits for ops before giving up.
+const opsTaskTimeout = 7 * 24 * time.Hour
 
-	// Convert client data to JSON
-	clientDataJSON, err := json.Marshal(clientData)
+// recordCanaryOutcome records the filing outcome for its cohort; this may roll the canary percentage back.
+func recordCanaryOutcome(ctx workflow.Context, input AutomatedFilingInput, result FilingResult) {
+	err := workflow.ExecuteActivity(ctx, RecordCanaryOutcomeActivity, input.TransactionID, input.ServiceType, result).Get(ctx, nil)
 	if err != nil {
-		return FilingResult{Status: "failed", Error: "Failed to marshal client data"}, err
+		workflow.GetLogger(ctx).Warn("Failed to record canary outcome", "error", err)
 	}
+}
 
-	// Execute Python CIPC Runner
-	cmd := exec.Command("python3", "/app/automation/cipc_runner.py", serviceType, string(clientDataJSON))
-	output, err := cmd.Output()
+// ExecuteAutomatedFilingActivity calls the Python CIPC Runner over the JSON-lines protocol,
+// heartbeating its progress so a retry resumes instead of resubmitting
+func ExecuteAutomatedFilingActivity(ctx context.Context, serviceType string, clientData map[string]interface{}) (FilingResult, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Executing automated filing", "service_type", serviceType, "attempt", activity.GetInfo(ctx).Attempt)
+
+	outcome, err := runCheckpointedFiling(ctx, serviceType, clientData)
 	if err != nil {
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) {
+			return FilingResult{Status: "failed", Error: appErr.Message(), ErrorCode: appErr.Type()}, err
+		}
 		return FilingResult{Status: "failed", Error: fmt.Sprintf("CIPC Runner execution failed: %v", err)}, err
 	}
 
-	// Parse result
-	var result FilingResult
-	err = json.Unmarshal(output, &result)
-	if err != nil {
-		return FilingResult{Status: "failed", Error: "Failed to parse CIPC Runner output"}, err
-	}
+	result := outcome.Result
+	result.Artifacts = append(result.Artifacts, outcome.Artifacts...)
 
 	logger.Info("Filing completed", "status", result.Status, "reference", result.ReferenceNumber)
 	return result, nil
@@ -123,46 +210,60 @@ func UpdateFilingRecordsActivity(ctx context.Context, transactionID, referenceNu
 	return nil
 }
 
-// SendFilingConfirmationActivity sends WhatsApp confirmation
-func SendFilingConfirmationActivity(ctx context.Context, userID string, result FilingResult) error {
+// SendFilingSubmittedActivity tells the user their filing was submitted. CIPC's decision
+// follows from CIPCConfirmationWorkflow.
+func SendFilingSubmittedActivity(ctx context.Context, userID, serviceType string, result FilingResult) error {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Sending filing confirmation", "user_id", userID)
 
-	message := fmt.Sprintf(`✅ *Filing Complete!*
+	message := fmt.Sprintf(`📨 *Filing Submitted*
 
 Service: %s
 Reference: %s
-Status: Successfully submitted to CIPC
+Status: Submitted to CIPC
 
-Your filing has been processed automatically. You'll receive official CIPC confirmation within 24-48 hours.
+We're checking with CIPC and will message you as soon as they approve or reject it.
 
-Need anything else? Just reply!`, 
-		result.ReferenceNumber[:2], // Service type from reference
+Need anything else? Just reply!`,
+		serviceDisplayName(serviceType),
 		result.ReferenceNumber)
 
-	// Send via WhatsApp API
-	logger.Info("Confirmation sent", "message_preview", message[:50])
+	return SendWhatsAppMessageActivity(ctx, userID, message)
+}
+
+// ExpireOpsTaskActivity closes an ops task its workflow has stopped waiting for.
+func ExpireOpsTaskActivity(ctx context.Context, taskID string) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE ops_tasks
+		SET status = 'expired', resolution_note = 'Filing stopped waiting for ops', resolved_by = 'system', resolved_at = NOW()
+		WHERE id = $1 AND status IN ('open', 'claimed')
+	`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to expire ops task: %w", err)
+	}
 	return nil
 }
 
-// AlertOperationsTeamActivity alerts team when automation fails
-func AlertOperationsTeamActivity(ctx context.Context, input AutomatedFilingInput, result FilingResult) error {
+// AlertOperationsTeamActivity alerts team when automation fails by queueing an ops task for
+// the waiting workflow. Returns the task ID.
+func AlertOperationsTeamActivity(ctx context.Context, input AutomatedFilingInput, result FilingResult) (string, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Error("Automated filing failed - alerting operations team", 
+	logger.Error("Automated filing failed - alerting operations team",
 		"transaction_id", input.TransactionID,
 		"error", result.Error)
 
-	// Send alert to operations team
-	// Could be Slack, email, or internal dashboard notification
-	
-	return nil
-}
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
 
-// CanaryRolloutActivity determines if transaction should use automation
-func CanaryRolloutActivity(ctx context.Context, transactionID string) (bool, error) {
-	// Implement canary rollout logic
-	// Start with 10% of transactions, gradually increase
-	
-	// For now, return true for all (100% automation)
-	return true, nil
-}
\ No newline at end of file
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return createOpsTask(ctx, db, OpsTaskAutomationFailure, input, result, execution.ID, execution.RunID)
+}
@@ -0,0 +1,258 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BBEEServiceType is the payg_transactions service type of a B-BBEE sworn affidavit.
+const BBEEServiceType = "bbee_certificate"
+
+// DocumentTypeBBEEAffidavit is the vault document type of a generated sworn affidavit.
+const DocumentTypeBBEEAffidavit = "bbee_affidavit"
+
+// Enterprise sizes under the B-BBEE codes of good practice.
+const (
+	BBEEExemptMicroEnterprise = "EME"
+	BBEEQualifyingSmall       = "QSE"
+	BBEELargeEnterprise       = "large"
+)
+
+// BBEESectorCode is a gazetted code of good practice and the turnover thresholds it sets.
+type BBEESectorCode struct {
+	Name string
+	// EMEThreshold and QSEThreshold are the highest annual turnovers, in rand, of an EME and a QSE.
+	EMEThreshold float64
+	QSEThreshold float64
+}
+
+// bbeeSectorCodes are the codes we generate affidavits under, keyed by the code customers choose.
+var bbeeSectorCodes = map[string]BBEESectorCode{
+	"generic":           {Name: "Amended Codes of Good Practice", EMEThreshold: 10_000_000, QSEThreshold: 50_000_000},
+	"tourism":           {Name: "Amended Tourism Sector Code", EMEThreshold: 5_000_000, QSEThreshold: 45_000_000},
+	"construction":      {Name: "Amended Construction Sector Code (Contractors)", EMEThreshold: 3_000_000, QSEThreshold: 35_000_000},
+	"built_environment": {Name: "Amended Construction Sector Code (Built Environment Professionals)", EMEThreshold: 1_500_000, QSEThreshold: 15_000_000},
+	"ict":               {Name: "Amended ICT Sector Code", EMEThreshold: 10_000_000, QSEThreshold: 50_000_000},
+	"agri":              {Name: "Amended AgriBEE Sector Code", EMEThreshold: 10_000_000, QSEThreshold: 50_000_000},
+	"financial":         {Name: "Amended Financial Sector Code", EMEThreshold: 10_000_000, QSEThreshold: 50_000_000},
+}
+
+// BBEESectorCodes returns the keys of the sector codes we support, sorted.
+func BBEESectorCodes() []string {
+	codes := make([]string, 0, len(bbeeSectorCodes))
+	for code := range bbeeSectorCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// BBEEOwnership is the percentage of a company's shares held by each group. BlackWomen and
+// BlackYouth are subsets of Black.
+type BBEEOwnership struct {
+	Black      float64 `json:"black"`
+	BlackWomen float64 `json:"black_women"`
+	BlackYouth float64 `json:"black_youth"`
+}
+
+// BBEEAffidavitFacts are what a sworn affidavit is based on: turnover for the most recent
+// financial year and the ownership at the time of signing.
+type BBEEAffidavitFacts struct {
+	SectorCode       string        `json:"sector_code"`
+	AnnualTurnover   float64       `json:"annual_turnover"`
+	FinancialYearEnd time.Time     `json:"financial_year_end"`
+	Ownership        BBEEOwnership `json:"ownership"`
+}
+
+// BBEEAssessment is the B-BBEE status a sworn affidavit claims.
+type BBEEAssessment struct {
+	SectorCode     string `json:"sector_code"`
+	SectorCodeName string `json:"sector_code_name"`
+	EnterpriseSize string `json:"enterprise_size"`
+	// Level is the B-BBEE status level, 0 when an affidavit cannot be used.
+	Level int `json:"level,omitempty"`
+	// ProcurementRecognition is the percentage of spend a customer may recognise.
+	ProcurementRecognition int `json:"procurement_recognition,omitempty"`
+	// Reason explains why an affidavit cannot be used when Level is 0.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Eligible reports whether the company may use a sworn affidavit instead of a verification.
+func (a BBEEAssessment) Eligible() bool {
+	return a.Level > 0
+}
+
+// bbeeProcurementRecognition is the procurement recognition of each level open to affidavits.
+var bbeeProcurementRecognition = map[int]int{1: 135, 2: 125, 4: 100}
+
+// ValidateBBEEAffidavitFacts checks affidavit facts and returns every problem found.
+func ValidateBBEEAffidavitFacts(facts BBEEAffidavitFacts, now time.Time) []string {
+	var problems []string
+	if _, ok := bbeeSectorCodes[facts.SectorCode]; !ok {
+		problems = append(problems, fmt.Sprintf("unknown sector code %q; choose one of %s", facts.SectorCode, strings.Join(BBEESectorCodes(), ", ")))
+	}
+	if facts.AnnualTurnover < 0 {
+		problems = append(problems, "annual turnover cannot be negative")
+	}
+	switch {
+	case facts.FinancialYearEnd.IsZero():
+		problems = append(problems, "the financial year end is missing")
+	case facts.FinancialYearEnd.After(now):
+		problems = append(problems, "the financial year end is in the future; use your most recent completed financial year")
+	case !facts.FinancialYearEnd.After(now.AddDate(-1, 0, 0)):
+		problems = append(problems, "the financial year end is more than a year ago; use your most recent completed financial year")
+	}
+
+	o := facts.Ownership
+	for _, p := range []struct {
+		name string
+		pct  float64
+	}{{"black", o.Black}, {"black women", o.BlackWomen}, {"black youth", o.BlackYouth}} {
+		if p.pct < 0 || p.pct > 100 {
+			problems = append(problems, fmt.Sprintf("%s ownership must be between 0%% and 100%%", p.name))
+		}
+	}
+	if o.BlackWomen > o.Black {
+		problems = append(problems, "black women ownership cannot exceed black ownership")
+	}
+	if o.BlackYouth > o.Black {
+		problems = append(problems, "black youth ownership cannot exceed black ownership")
+	}
+	return problems
+}
+
+// AssessBBEE determines the B-BBEE level a company can claim by sworn affidavit under its sector
+// code. EMEs are level 1 when wholly black owned, level 2 when at least 51% black owned and level
+// 4 otherwise. QSEs may only use an affidavit at 51% black ownership or more; other QSEs and
+// large enterprises need a verification by an accredited agency.
+func AssessBBEE(facts BBEEAffidavitFacts) (BBEEAssessment, error) {
+	code, ok := bbeeSectorCodes[facts.SectorCode]
+	if !ok {
+		return BBEEAssessment{}, fmt.Errorf("unknown sector code %q", facts.SectorCode)
+	}
+	assessment := BBEEAssessment{SectorCode: facts.SectorCode, SectorCodeName: code.Name}
+
+	switch {
+	case facts.AnnualTurnover <= code.EMEThreshold:
+		assessment.EnterpriseSize = BBEEExemptMicroEnterprise
+	case facts.AnnualTurnover <= code.QSEThreshold:
+		assessment.EnterpriseSize = BBEEQualifyingSmall
+	default:
+		assessment.EnterpriseSize = BBEELargeEnterprise
+		assessment.Reason = fmt.Sprintf("turnover above R%s makes this a large enterprise under the %s, which needs a verification certificate",
+			formatRand(code.QSEThreshold), code.Name)
+		return assessment, nil
+	}
+
+	black := facts.Ownership.Black
+	switch {
+	case black >= 100:
+		assessment.Level = 1
+	case black >= 51:
+		assessment.Level = 2
+	case assessment.EnterpriseSize == BBEEExemptMicroEnterprise:
+		assessment.Level = 4
+	default:
+		assessment.Reason = "a QSE that is less than 51% black owned needs a verification certificate"
+		return assessment, nil
+	}
+	assessment.ProcurementRecognition = bbeeProcurementRecognition[assessment.Level]
+	return assessment, nil
+}
+
+// formatRand formats a whole rand amount with thousands separators, e.g. 10 000 000.
+func formatRand(amount float64) string {
+	digits := fmt.Sprintf("%.0f", amount)
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	return strings.Join(append([]string{digits}, groups...), " ")
+}
+
+// BBEEAffidavit is everything printed on a sworn affidavit.
+type BBEEAffidavit struct {
+	CompanyName      string             `json:"company_name"`
+	CompanyRegNumber string             `json:"company_reg_number"`
+	Deponent         BBEEDeponent       `json:"deponent"`
+	Facts            BBEEAffidavitFacts `json:"facts"`
+	Assessment       BBEEAssessment     `json:"assessment"`
+	GeneratedAt      time.Time          `json:"generated_at"`
+}
+
+// BBEEDeponent is the director or member who swears the affidavit.
+type BBEEDeponent struct {
+	FullName    string `json:"full_name"`
+	IDNumber    string `json:"id_number"`
+	Designation string `json:"designation"`
+}
+
+// ErrBBEEAffidavitNotEligible is returned when rendering an affidavit for a company that needs a
+// verification certificate.
+var ErrBBEEAffidavitNotEligible = errors.New("company cannot use a B-BBEE sworn affidavit")
+
+// ValidUntil is when the affidavit lapses if it is commissioned on the day it was generated.
+func (a BBEEAffidavit) ValidUntil() time.Time {
+	return a.GeneratedAt.AddDate(1, 0, 0)
+}
+
+// RenderBBEEAffidavit renders the sworn affidavit as a PDF for the deponent to sign in front of
+// a commissioner of oaths.
+func RenderBBEEAffidavit(a BBEEAffidavit) ([]byte, error) {
+	if !a.Assessment.Eligible() {
+		return nil, ErrBBEEAffidavitNotEligible
+	}
+	size := "EXEMPTED MICRO ENTERPRISE"
+	if a.Assessment.EnterpriseSize == BBEEQualifyingSmall {
+		size = "QUALIFYING SMALL ENTERPRISE"
+	}
+	o := a.Facts.Ownership
+
+	doc := NewPDFDocument()
+	doc.Heading(14, "SWORN AFFIDAVIT - B-BBEE "+size)
+	doc.Paragraph(10, a.Assessment.SectorCodeName)
+	doc.Space(12)
+	doc.Paragraph(11, fmt.Sprintf("I, the undersigned, %s (identity number %s), hereby declare under oath as follows:", a.Deponent.FullName, a.Deponent.IDNumber))
+	doc.Space(6)
+	doc.Paragraph(11, "1. The contents of this statement are to the best of my knowledge a true reflection of the facts.")
+	doc.Paragraph(11, fmt.Sprintf("2. I am a %s of the enterprise below and have the authority to bind it.", strings.ToLower(a.Deponent.Designation)))
+	doc.Paragraph(11, fmt.Sprintf("3. The enterprise is %s, registration number %s.", a.CompanyName, a.CompanyRegNumber))
+	doc.Paragraph(11, fmt.Sprintf("4. Based on its financial statements or management accounts, its annual total revenue for the financial year ended %s was R%s, which is within the threshold for an %s under the %s.",
+		a.Facts.FinancialYearEnd.Format("2 January 2006"), formatRand(a.Facts.AnnualTurnover), strings.ToLower(size), a.Assessment.SectorCodeName))
+	doc.Paragraph(11, "5. The enterprise's ownership is as follows:")
+	doc.Paragraph(11, fmt.Sprintf("   Black ownership: %.2f%%", o.Black))
+	doc.Paragraph(11, fmt.Sprintf("   Black female ownership: %.2f%%", o.BlackWomen))
+	doc.Paragraph(11, fmt.Sprintf("   Black youth ownership: %.2f%%", o.BlackYouth))
+	doc.Paragraph(11, fmt.Sprintf("6. The enterprise therefore has a B-BBEE status of Level %d with procurement recognition of %d%%.",
+		a.Assessment.Level, a.Assessment.ProcurementRecognition))
+	doc.Paragraph(11, "7. I know and understand the contents of this affidavit, I have no objection to taking the prescribed oath and I consider the oath binding on my conscience.")
+	doc.Space(24)
+	doc.Paragraph(11, "Deponent signature: ______________________________    Date: ______________")
+	doc.Paragraph(11, fmt.Sprintf("%s, %s", a.Deponent.FullName, a.Deponent.Designation))
+	doc.Space(24)
+	doc.Heading(11, "Commissioner of Oaths")
+	doc.Paragraph(11, "Signature and stamp: ______________________________    Date: ______________")
+	doc.Space(12)
+	doc.Paragraph(9, fmt.Sprintf("Generated on %s. This affidavit is valid for 12 months from the date it is commissioned. Misrepresenting B-BBEE status is an offence under section 13O of the B-BBEE Act.",
+		a.GeneratedAt.Format("2 January 2006")))
+	return doc.Bytes(), nil
+}
+
+// validateBBEEDeponent checks the deponent of a sworn affidavit.
+func validateBBEEDeponent(d BBEEDeponent) []string {
+	var problems []string
+	if strings.TrimSpace(d.FullName) == "" {
+		problems = append(problems, "the deponent's full name is missing")
+	}
+	if err := ValidateSAIDNumber(d.IDNumber); err != nil {
+		problems = append(problems, fmt.Sprintf("deponent %s: %v", d.FullName, err))
+	}
+	if strings.TrimSpace(d.Designation) == "" {
+		problems = append(problems, "the deponent's designation (e.g. director) is missing")
+	}
+	return problems
+}
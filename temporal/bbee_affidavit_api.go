@@ -0,0 +1,60 @@
+package temporal
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// BBEEAffidavitResponse is returned by POST /bbee-affidavits.
+type BBEEAffidavitResponse struct {
+	WorkflowID string         `json:"workflow_id,omitempty"`
+	Assessment BBEEAssessment `json:"assessment"`
+}
+
+func (s *APIServer) registerBBEEAffidavitRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /bbee-affidavits", requireInternalAPIKey(s.createBBEEAffidavitHandler))
+}
+
+// createBBEEAffidavitHandler assesses the company's B-BBEE level and, when a sworn affidavit can
+// be used, starts a BBEEAffidavitWorkflow to charge for and generate it. Companies that need a
+// verification certificate get their assessment back with 422 and nothing is started.
+func (s *APIServer) createBBEEAffidavitHandler(w http.ResponseWriter, r *http.Request) {
+	var input BBEEAffidavitInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.PhoneNumber == "" || input.CompanyID == "" || input.CompanyRegNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id, phone_number, company_id and company_reg_number are required")
+		return
+	}
+	problems := append(ValidateBBEEAffidavitFacts(input.Facts, time.Now()), validateBBEEDeponent(input.Deponent)...)
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	assessment, err := AssessBBEE(input.Facts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !assessment.Eligible() {
+		writeJSON(w, http.StatusUnprocessableEntity, BBEEAffidavitResponse{Assessment: assessment})
+		return
+	}
+
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("bbee-affidavit-%d", time.Now().UnixNano()),
+		TaskQueue: TaskQueue,
+	}, BBEEAffidavitWorkflow, input)
+	if err != nil {
+		log.Printf("Error starting B-BBEE affidavit for company %s: %s", input.CompanyID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start B-BBEE affidavit")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, BBEEAffidavitResponse{WorkflowID: run.GetID(), Assessment: assessment})
+}
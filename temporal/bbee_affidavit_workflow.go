@@ -0,0 +1,218 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// BBEEAffidavitInput is the input for BBEEAffidavitWorkflow.
+type BBEEAffidavitInput struct {
+	UserID           string             `json:"user_id"`
+	PhoneNumber      string             `json:"phone_number"`
+	CompanyID        string             `json:"company_id"`
+	CompanyRegNumber string             `json:"company_reg_number"`
+	Deponent         BBEEDeponent       `json:"deponent"`
+	Facts            BBEEAffidavitFacts `json:"facts"`
+}
+
+// BBEEAffidavitResult is the result of BBEEAffidavitWorkflow.
+type BBEEAffidavitResult struct {
+	Success       bool           `json:"success"`
+	Status        string         `json:"status"`
+	TransactionID string         `json:"transaction_id,omitempty"`
+	Assessment    BBEEAssessment `json:"assessment"`
+	DocumentID    string         `json:"document_id,omitempty"`
+	ValidUntil    *time.Time     `json:"valid_until,omitempty"`
+	Problems      []string       `json:"problems,omitempty"`
+	ErrorMessage  string         `json:"error_message,omitempty"`
+}
+
+// GeneratedBBEEAffidavit is a rendered affidavit stored in the vault.
+type GeneratedBBEEAffidavit struct {
+	DocumentID  string    `json:"document_id"`
+	DownloadURL string    `json:"download_url"`
+	ValidUntil  time.Time `json:"valid_until"`
+}
+
+// bbeeAffidavitLinkTTL keeps the affidavit link working while the customer finds a commissioner
+// of oaths.
+const bbeeAffidavitLinkTTL = 30 * 24 * time.Hour
+
+// BBEEAffidavitWorkflow generates a B-BBEE sworn affidavit for an EME or QSE: it determines the
+// company's level under its sector code, takes payment, renders the affidavit for commissioning
+// and tracks its expiry as a bbee_certificate compliance deadline. Companies that need a
+// verification certificate are told so before they are charged.
+func BBEEAffidavitWorkflow(ctx workflow.Context, input BBEEAffidavitInput) (*BBEEAffidavitResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting BBEEAffidavitWorkflow", "CompanyID", input.CompanyID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	notify := func(message string) {
+		if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send WhatsApp message", "error", err)
+		}
+	}
+
+	// Step 1: Validate the facts the affidavit swears to
+	problems := append(ValidateBBEEAffidavitFacts(input.Facts, workflow.Now(ctx)), validateBBEEDeponent(input.Deponent)...)
+	if len(problems) > 0 {
+		notify("⚠️ *We can't prepare your B-BBEE affidavit yet:*\n\n• " + strings.Join(problems, "\n• "))
+		return &BBEEAffidavitResult{Status: "invalid", Problems: problems}, nil
+	}
+
+	// Step 2: Determine the level under the sector code
+	assessment, err := AssessBBEE(input.Facts)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidBBEEAffidavit", err)
+	}
+	result := &BBEEAffidavitResult{Assessment: assessment}
+	if !assessment.Eligible() {
+		notify(fmt.Sprintf("ℹ️ *A sworn affidavit won't work for your company*\n\nAs a %s, %s. Reply 'HELP' and we'll put you in touch with a verification agency.",
+			assessment.EnterpriseSize, assessment.Reason))
+		result.Status = "verification_required"
+		return result, nil
+	}
+
+	// Step 3: Take payment
+	txInput := CreatePaygTransactionInput{
+		UserID:      input.UserID,
+		ServiceType: BBEEServiceType,
+		FilingData: map[string]interface{}{
+			"company_id": input.CompanyID,
+			"facts":      input.Facts,
+			"assessment": assessment,
+		},
+	}
+	if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &result.TransactionID); err != nil {
+		return nil, fmt.Errorf("failed to create B-BBEE affidavit transaction: %w", err)
+	}
+	var paymentValid bool
+	if err := workflow.ExecuteActivity(ctx, ValidatePaymentActivity, result.TransactionID).Get(ctx, &paymentValid); err != nil {
+		return nil, fmt.Errorf("payment validation activity failed: %w", err)
+	}
+	if !paymentValid {
+		result.Status, result.ErrorMessage = "failed", "Payment not confirmed"
+		return result, nil
+	}
+
+	// Step 4: Render the affidavit and track its expiry
+	affidavit := BBEEAffidavit{
+		CompanyRegNumber: input.CompanyRegNumber,
+		Deponent:         input.Deponent,
+		Facts:            input.Facts,
+		Assessment:       assessment,
+		GeneratedAt:      workflow.Now(ctx),
+	}
+	var generated GeneratedBBEEAffidavit
+	if err := workflow.ExecuteActivity(ctx, GenerateBBEEAffidavitActivity, input, result.TransactionID, affidavit).Get(ctx, &generated); err != nil {
+		return nil, fmt.Errorf("failed to generate B-BBEE affidavit: %w", err)
+	}
+	result.DocumentID, result.ValidUntil = generated.DocumentID, &generated.ValidUntil
+
+	// Step 5: Send it to the customer for commissioning
+	notify(fmt.Sprintf("✅ *Your B-BBEE sworn affidavit is ready*\n\nLevel %d %s (%d%% procurement recognition)\n\nDownload it here (link valid for %d days): %s\n\nPrint it and sign it in front of a commissioner of oaths, e.g. at a police station. It's valid for 12 months from commissioning and we'll remind you before %s to renew it. It's also saved with your company documents.",
+		assessment.Level, assessment.EnterpriseSize, assessment.ProcurementRecognition, int(bbeeAffidavitLinkTTL.Hours()/24), generated.DownloadURL, generated.ValidUntil.Format("2 January 2006")))
+	result.Success, result.Status = true, "generated"
+	return result, nil
+}
+
+// GenerateBBEEAffidavitActivity renders the affidavit for the company, stores it in the vault,
+// records it in bbee_affidavits and replaces the company's bbee_certificate deadline with the
+// new affidavit's expiry.
+func GenerateBBEEAffidavitActivity(ctx context.Context, input BBEEAffidavitInput, transactionID string, affidavit BBEEAffidavit) (*GeneratedBBEEAffidavit, error) {
+	logger := activity.GetLogger(ctx)
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.QueryRowContext(ctx, `SELECT name FROM companies WHERE id = $1`, input.CompanyID).Scan(&affidavit.CompanyName); err != nil {
+		return nil, fmt.Errorf("failed to load company: %w", err)
+	}
+	data, err := RenderBBEEAffidavit(affidavit)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidBBEEAffidavit", err)
+	}
+
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	vault := NewDocumentVault(db, store)
+	doc, err := vault.Upload(ctx, DocumentUpload{
+		CompanyID:    input.CompanyID,
+		FileName:     fmt.Sprintf("bbee-affidavit-%s.pdf", affidavit.GeneratedAt.Format("2006-01-02")),
+		DocumentType: DocumentTypeBBEEAffidavit,
+	}, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store B-BBEE affidavit: %w", err)
+	}
+	validUntil := affidavit.ValidUntil()
+
+	ownership, err := json.Marshal(affidavit.Facts.Ownership)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ownership: %w", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bbee_affidavits (transaction_id, company_id, sector_code, enterprise_size, level, annual_turnover,
+		                             financial_year_end, ownership, document_id, valid_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (transaction_id) DO UPDATE SET document_id = EXCLUDED.document_id
+	`, transactionID, input.CompanyID, affidavit.Assessment.SectorCode, affidavit.Assessment.EnterpriseSize, affidavit.Assessment.Level,
+		affidavit.Facts.AnnualTurnover, affidavit.Facts.FinancialYearEnd, ownership, doc.ID, validUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record B-BBEE affidavit: %w", err)
+	}
+
+	// The new affidavit replaces the one the open deadline was tracking.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE compliance_deadlines SET status = 'completed'
+		WHERE company_reg_number = $1 AND deadline_type = 'bbee_certificate' AND status IN ('pending', 'overdue') AND due_date < $2
+	`, input.CompanyRegNumber, validUntil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO compliance_deadlines (user_id, company_reg_number, deadline_type, due_date, status)
+		SELECT $1, $2, 'bbee_certificate', $3, 'pending'
+		WHERE NOT EXISTS (
+			SELECT 1 FROM compliance_deadlines
+			WHERE company_reg_number = $2 AND deadline_type = 'bbee_certificate' AND status = 'pending'
+		)
+	`, input.UserID, input.CompanyRegNumber, validUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create B-BBEE deadline: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Generated B-BBEE affidavit", "company_id", input.CompanyID, "level", affidavit.Assessment.Level, "document_id", doc.ID)
+	return &GeneratedBBEEAffidavit{DocumentID: doc.ID, DownloadURL: vault.SignedURLWithTTL(doc.ID, time.Now(), bbeeAffidavitLinkTTL), ValidUntil: validUntil}, nil
+}
@@ -0,0 +1,191 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestAssessBBEE(t *testing.T) {
+	tests := []struct {
+		name     string
+		sector   string
+		turnover float64
+		black    float64
+		size     string
+		level    int
+	}{
+		{"wholly black owned EME", "generic", 2_000_000, 100, BBEEExemptMicroEnterprise, 1},
+		{"majority black owned EME", "generic", 2_000_000, 51, BBEEExemptMicroEnterprise, 2},
+		{"other EME", "generic", 10_000_000, 0, BBEEExemptMicroEnterprise, 4},
+		{"majority black owned QSE", "generic", 30_000_000, 60, BBEEQualifyingSmall, 2},
+		{"other QSE", "generic", 30_000_000, 50, BBEEQualifyingSmall, 0},
+		{"large enterprise", "generic", 60_000_000, 100, BBEELargeEnterprise, 0},
+		{"tourism has a lower EME threshold", "tourism", 6_000_000, 100, BBEEQualifyingSmall, 1},
+		{"construction contractors", "construction", 3_500_000, 0, BBEEQualifyingSmall, 0},
+	}
+	for _, tt := range tests {
+		assessment, err := AssessBBEE(BBEEAffidavitFacts{SectorCode: tt.sector, AnnualTurnover: tt.turnover, Ownership: BBEEOwnership{Black: tt.black}})
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.size, assessment.EnterpriseSize, tt.name)
+		assert.Equal(t, tt.level, assessment.Level, tt.name)
+		assert.Equal(t, tt.level > 0, assessment.Eligible(), tt.name)
+		if !assessment.Eligible() {
+			assert.NotEmpty(t, assessment.Reason, tt.name)
+		}
+	}
+
+	assessment, _ := AssessBBEE(BBEEAffidavitFacts{SectorCode: "generic", Ownership: BBEEOwnership{Black: 100}})
+	assert.Equal(t, 135, assessment.ProcurementRecognition)
+
+	_, err := AssessBBEE(BBEEAffidavitFacts{SectorCode: "mining"})
+	assert.Error(t, err)
+}
+
+func TestValidateBBEEAffidavitFacts(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	facts := BBEEAffidavitFacts{
+		SectorCode:       "generic",
+		AnnualTurnover:   1_200_000,
+		FinancialYearEnd: time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC),
+		Ownership:        BBEEOwnership{Black: 100, BlackWomen: 50, BlackYouth: 25},
+	}
+	assert.Empty(t, ValidateBBEEAffidavitFacts(facts, now))
+
+	facts.SectorCode = "mining"
+	facts.FinancialYearEnd = time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC)
+	facts.Ownership = BBEEOwnership{Black: 40, BlackWomen: 50, BlackYouth: 120}
+	problems := ValidateBBEEAffidavitFacts(facts, now)
+	assert.Len(t, problems, 5)
+	assert.Contains(t, problems[0], "unknown sector code")
+	assert.Contains(t, problems[1], "more than a year ago")
+	assert.Equal(t, "black youth ownership must be between 0% and 100%", problems[2])
+}
+
+func TestRenderBBEEAffidavit(t *testing.T) {
+	facts := BBEEAffidavitFacts{
+		SectorCode:       "generic",
+		AnnualTurnover:   1_200_000,
+		FinancialYearEnd: time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC),
+		Ownership:        BBEEOwnership{Black: 100, BlackWomen: 50, BlackYouth: 25},
+	}
+	assessment, err := AssessBBEE(facts)
+	require.NoError(t, err)
+	affidavit := BBEEAffidavit{
+		CompanyName:      "Umoya Coffee (Pty) Ltd",
+		CompanyRegNumber: "2020/123456/07",
+		Deponent:         BBEEDeponent{FullName: "Thandi Mokoena", IDNumber: "8001015009087", Designation: "Director"},
+		Facts:            facts,
+		Assessment:       assessment,
+		GeneratedAt:      time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC),
+	}
+	assert.Equal(t, time.Date(2027, time.October, 19, 9, 0, 0, 0, time.UTC), affidavit.ValidUntil())
+
+	data, err := RenderBBEEAffidavit(affidavit)
+	require.NoError(t, err)
+	text, err := ExtractPDFText(data)
+	require.NoError(t, err)
+	assert.Contains(t, text, "SWORN AFFIDAVIT - B-BBEE EXEMPTED MICRO ENTERPRISE")
+	assert.Contains(t, text, "Umoya Coffee (Pty) Ltd, registration number 2020/123456/07")
+	assert.Contains(t, text, "R1 200 000")
+	assert.Contains(t, text, "Black female ownership: 50.00%")
+	assert.Contains(t, text, "B-BBEE status of Level 1 with procurement recognition")
+
+	affidavit.Assessment = BBEEAssessment{EnterpriseSize: BBEELargeEnterprise}
+	_, err = RenderBBEEAffidavit(affidavit)
+	assert.ErrorIs(t, err, ErrBBEEAffidavitNotEligible)
+}
+
+// BBEEAffidavitWorkflowTestSuite tests BBEEAffidavitWorkflow.
+type BBEEAffidavitWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestBBEEAffidavitWorkflowTestSuite runs the test suite.
+func TestBBEEAffidavitWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(BBEEAffidavitWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *BBEEAffidavitWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, "+27721234567", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *BBEEAffidavitWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *BBEEAffidavitWorkflowTestSuite) input(turnover, black float64) BBEEAffidavitInput {
+	return BBEEAffidavitInput{
+		UserID:           "user-1",
+		PhoneNumber:      "+27721234567",
+		CompanyID:        "company-1",
+		CompanyRegNumber: "2020/123456/07",
+		Deponent:         BBEEDeponent{FullName: "Thandi Mokoena", IDNumber: "8001015009087", Designation: "Director"},
+		Facts: BBEEAffidavitFacts{
+			SectorCode:       "generic",
+			AnnualTurnover:   turnover,
+			FinancialYearEnd: time.Now().AddDate(0, -3, 0),
+			Ownership:        BBEEOwnership{Black: black},
+		},
+	}
+}
+
+func (s *BBEEAffidavitWorkflowTestSuite) result() BBEEAffidavitResult {
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result BBEEAffidavitResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+// Test_GeneratesAffidavit tests that an eligible company is charged and sent its affidavit.
+func (s *BBEEAffidavitWorkflowTestSuite) Test_GeneratesAffidavit() {
+	validUntil := time.Now().AddDate(1, 0, 0)
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.ServiceType == BBEEServiceType
+	})).Return("tx-1", nil).Once()
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, "tx-1").Return(true, nil).Once()
+	s.env.OnActivity(GenerateBBEEAffidavitActivity, mock.Anything, mock.Anything, "tx-1", mock.MatchedBy(func(a BBEEAffidavit) bool {
+		return a.Assessment.Level == 2 && a.Assessment.EnterpriseSize == BBEEExemptMicroEnterprise
+	})).Return(&GeneratedBBEEAffidavit{DocumentID: "doc-1", DownloadURL: "https://example.test/doc-1", ValidUntil: validUntil}, nil).Once()
+
+	s.env.ExecuteWorkflow(BBEEAffidavitWorkflow, s.input(4_000_000, 51))
+
+	result := s.result()
+	s.True(result.Success)
+	s.Equal("doc-1", result.DocumentID)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "Level 2 EME (125% procurement recognition)")
+	s.Contains(s.messages[0], "https://example.test/doc-1")
+}
+
+// Test_VerificationRequiredIsNotCharged tests that a QSE below 51% black ownership is told it
+// needs a verification certificate and is not charged.
+func (s *BBEEAffidavitWorkflowTestSuite) Test_VerificationRequiredIsNotCharged() {
+	s.env.ExecuteWorkflow(BBEEAffidavitWorkflow, s.input(20_000_000, 30))
+
+	result := s.result()
+	s.False(result.Success)
+	s.Equal("verification_required", result.Status)
+	s.Equal(BBEEQualifyingSmall, result.Assessment.EnterpriseSize)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "verification agency")
+}
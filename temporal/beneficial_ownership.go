@@ -0,0 +1,302 @@
+package temporal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// BeneficialOwnershipThreshold is the effective interest (in percent) at or above which a
+// natural person must be disclosed as a beneficial owner under the Companies Act.
+const BeneficialOwnershipThreshold = 5.0
+
+// Kinds of node that can appear in an ownership graph.
+const (
+	NodeNaturalPerson = "natural_person"
+	NodeCompany       = "company"
+	NodeTrust         = "trust"
+)
+
+// ownershipEpsilon absorbs floating point noise when comparing percentages.
+const ownershipEpsilon = 1e-9
+
+// OwnershipNode is a natural person or a legal entity (company, trust) in an ownership graph.
+type OwnershipNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	IDNumber string `json:"id_number,omitempty"` // SA ID/passport for persons, registration number for entities
+}
+
+// Shareholding records that HolderID holds Percentage of the shares in EntityID.
+type Shareholding struct {
+	HolderID   string  `json:"holder_id"`
+	EntityID   string  `json:"entity_id"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ControlInterest records that a natural person controls an entity by means other than
+// shareholding, e.g. a right to appoint the majority of directors or a trust founder's veto.
+type ControlInterest struct {
+	PersonID string `json:"person_id"`
+	EntityID string `json:"entity_id"`
+	Nature   string `json:"nature"`
+}
+
+// OwnershipGraph is the input to the beneficial ownership calculator. RootID is the
+// company whose beneficial owners are being determined.
+type OwnershipGraph struct {
+	RootID        string            `json:"root_id"`
+	Nodes         []OwnershipNode   `json:"nodes"`
+	Shareholdings []Shareholding    `json:"shareholdings"`
+	Controls      []ControlInterest `json:"controls,omitempty"`
+}
+
+// BeneficialOwner is a natural person's effective interest in the root company.
+type BeneficialOwner struct {
+	Name         string     `json:"name"`
+	IDNumber     string     `json:"id_num"`
+	Pct          float64    `json:"pct"`
+	DirectPct    float64    `json:"direct_pct"`
+	IndirectPct  float64    `json:"indirect_pct"`
+	HoldingPaths [][]string `json:"holding_paths"`
+}
+
+// SignificantControl is a natural person who controls the root company, directly or
+// through an entity in its ownership chain, by means other than shareholding.
+type SignificantControl struct {
+	Name     string `json:"name"`
+	IDNumber string `json:"id_num"`
+	Nature   string `json:"nature"`
+	Via      string `json:"via,omitempty"`
+}
+
+// BeneficialOwnershipResult holds the outcome of an ownership calculation.
+type BeneficialOwnershipResult struct {
+	BeneficialOwners   []BeneficialOwner    `json:"beneficial_owners"`
+	SignificantControl []SignificantControl `json:"significant_control"`
+	// AllOwners includes natural persons below the disclosure threshold.
+	AllOwners []BeneficialOwner `json:"all_owners"`
+	// Cycles lists circular holdings (entity IDs, first == last) found while walking the graph.
+	// The holding closing each cycle is not followed, so interests that only flow through a cycle
+	// are excluded.
+	Cycles [][]string `json:"cycles,omitempty"`
+}
+
+// CalculateBeneficialOwnership multiplies shareholdings through every holding chain above
+// graph.RootID, accumulating each entity's effective interest once, and returns the natural persons whose effective interest is at or above
+// threshold, together with everyone who has control by other means.
+func CalculateBeneficialOwnership(graph OwnershipGraph, threshold float64) (*BeneficialOwnershipResult, error) {
+	nodes := make(map[string]OwnershipNode, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		if n.ID == "" {
+			return nil, fmt.Errorf("ownership node %q has no id", n.Name)
+		}
+		switch n.Kind {
+		case NodeNaturalPerson, NodeCompany, NodeTrust:
+		default:
+			return nil, fmt.Errorf("ownership node %s has unknown kind %q", n.ID, n.Kind)
+		}
+		if _, ok := nodes[n.ID]; ok {
+			return nil, fmt.Errorf("ownership node %s appears more than once", n.ID)
+		}
+		nodes[n.ID] = n
+	}
+
+	root, ok := nodes[graph.RootID]
+	if !ok {
+		return nil, fmt.Errorf("root entity %s not found in ownership graph", graph.RootID)
+	}
+	if root.Kind == NodeNaturalPerson {
+		return nil, fmt.Errorf("root %s must be a company or trust", graph.RootID)
+	}
+
+	// Index holders by the entity they hold and check nobody holds more than 100% in total.
+	holders := make(map[string][]Shareholding)
+	issued := make(map[string]float64)
+	for _, sh := range graph.Shareholdings {
+		if _, ok := nodes[sh.HolderID]; !ok {
+			return nil, fmt.Errorf("shareholding references unknown holder %s", sh.HolderID)
+		}
+		entity, ok := nodes[sh.EntityID]
+		if !ok {
+			return nil, fmt.Errorf("shareholding references unknown entity %s", sh.EntityID)
+		}
+		if entity.Kind == NodeNaturalPerson {
+			return nil, fmt.Errorf("natural person %s cannot have shareholders", sh.EntityID)
+		}
+		if sh.Percentage <= 0 || sh.Percentage > 100 {
+			return nil, fmt.Errorf("shareholding of %s in %s must be between 0 and 100, got %.2f", sh.HolderID, sh.EntityID, sh.Percentage)
+		}
+		holders[sh.EntityID] = append(holders[sh.EntityID], sh)
+		issued[sh.EntityID] += sh.Percentage
+	}
+	for entityID, total := range issued {
+		if total > 100+ownershipEpsilon {
+			return nil, fmt.Errorf("shareholdings in %s add up to %.2f%%", entityID, total)
+		}
+	}
+
+	result := &BeneficialOwnershipResult{}
+
+	// Order the entities above the root so every entity comes after all entities it holds shares
+	// in. A holding that closes a cycle is recorded and left out, which makes the rest acyclic.
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	skipped := map[Shareholding]bool{}
+	var order, path []string
+	var visit func(entityID string)
+	visit = func(entityID string) {
+		state[entityID] = onPath
+		path = append(path, entityID)
+		for _, sh := range holders[entityID] {
+			if nodes[sh.HolderID].Kind == NodeNaturalPerson {
+				continue
+			}
+			switch state[sh.HolderID] {
+			case onPath:
+				cycle := append(append([]string{}, path[indexOf(path, sh.HolderID):]...), sh.HolderID)
+				result.Cycles = append(result.Cycles, cycle)
+				skipped[sh] = true
+			case unvisited:
+				visit(sh.HolderID)
+			}
+		}
+		path = path[:len(path)-1]
+		state[entityID] = done
+		order = append(order, entityID)
+	}
+	visit(graph.RootID)
+
+	// Push each entity's effective interest up to its holders, root first. Every holding is
+	// followed once, with one sample path from the holder down to the root.
+	effective := map[string]float64{graph.RootID: 100}
+	paths := map[string][]string{graph.RootID: {graph.RootID}}
+	owners := make(map[string]*BeneficialOwner)
+	var ownerOrder []string
+	for i := len(order) - 1; i >= 0; i-- {
+		entityID := order[i]
+		for _, sh := range holders[entityID] {
+			if skipped[sh] {
+				continue
+			}
+			holder := nodes[sh.HolderID]
+			share := effective[entityID] * sh.Percentage / 100
+
+			if holder.Kind == NodeNaturalPerson {
+				owner, ok := owners[holder.ID]
+				if !ok {
+					owner = &BeneficialOwner{Name: holder.Name, IDNumber: holder.IDNumber}
+					owners[holder.ID] = owner
+					ownerOrder = append(ownerOrder, holder.ID)
+				}
+				if entityID == graph.RootID {
+					owner.DirectPct += share
+				} else {
+					owner.IndirectPct += share
+				}
+				owner.Pct += share
+				owner.HoldingPaths = append(owner.HoldingPaths, reversedPath(paths[entityID], holder.ID))
+				continue
+			}
+
+			effective[holder.ID] += share
+			if _, ok := paths[holder.ID]; !ok {
+				paths[holder.ID] = append(append([]string{}, paths[entityID]...), holder.ID)
+			}
+		}
+	}
+
+	for _, id := range ownerOrder {
+		owner := owners[id]
+		owner.Pct = roundPct(owner.Pct)
+		owner.DirectPct = roundPct(owner.DirectPct)
+		owner.IndirectPct = roundPct(owner.IndirectPct)
+		result.AllOwners = append(result.AllOwners, *owner)
+		if owner.Pct+ownershipEpsilon >= threshold {
+			result.BeneficialOwners = append(result.BeneficialOwners, *owner)
+		}
+	}
+	sortOwners(result.AllOwners)
+	sortOwners(result.BeneficialOwners)
+
+	for _, c := range graph.Controls {
+		person, ok := nodes[c.PersonID]
+		if !ok || person.Kind != NodeNaturalPerson {
+			return nil, fmt.Errorf("control interest must be held by a natural person, got %s", c.PersonID)
+		}
+		if state[c.EntityID] != done {
+			continue
+		}
+		sc := SignificantControl{Name: person.Name, IDNumber: person.IDNumber, Nature: c.Nature}
+		if c.EntityID != graph.RootID {
+			sc.Via = nodes[c.EntityID].Name
+		}
+		result.SignificantControl = append(result.SignificantControl, sc)
+	}
+	sort.Slice(result.SignificantControl, func(i, j int) bool {
+		return result.SignificantControl[i].Name < result.SignificantControl[j].Name
+	})
+
+	return result, nil
+}
+
+// FilingJSON renders the beneficial_owners and significant_control columns of a
+// beneficial_ownership_filings row.
+func (r *BeneficialOwnershipResult) FilingJSON() (owners []byte, control []byte, err error) {
+	bo := r.BeneficialOwners
+	if bo == nil {
+		bo = []BeneficialOwner{}
+	}
+	owners, err = json.Marshal(bo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal beneficial owners: %w", err)
+	}
+
+	sc := r.SignificantControl
+	if sc == nil {
+		sc = []SignificantControl{}
+	}
+	control, err = json.Marshal(sc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal significant control: %w", err)
+	}
+	return owners, control, nil
+}
+
+// reversedPath turns a path from the root (root first) into a holding path (person first).
+func reversedPath(path []string, personID string) []string {
+	out := make([]string, 0, len(path)+1)
+	out = append(out, personID)
+	for i := len(path) - 1; i >= 0; i-- {
+		out = append(out, path[i])
+	}
+	return out
+}
+
+func indexOf(path []string, id string) int {
+	for i, p := range path {
+		if p == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func roundPct(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+func sortOwners(owners []BeneficialOwner) {
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].Pct != owners[j].Pct {
+			return owners[i].Pct > owners[j].Pct
+		}
+		return owners[i].Name < owners[j].Name
+	})
+}
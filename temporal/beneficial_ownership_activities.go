@@ -0,0 +1,85 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// BeneficialOwnershipInput identifies the company and the ownership structure to evaluate.
+type BeneficialOwnershipInput struct {
+	CompanyID        string         `json:"company_id"`
+	CompanyRegNumber string         `json:"company_reg_number"`
+	Graph            OwnershipGraph `json:"graph"`
+}
+
+// BeneficialOwnershipFiling is a pending beneficial_ownership_filings row produced by the calculator.
+type BeneficialOwnershipFiling struct {
+	FilingID string                    `json:"filing_id"`
+	Result   BeneficialOwnershipResult `json:"result"`
+}
+
+// CalculateBeneficialOwnershipActivity runs the ownership graph engine for a company, refreshes
+// its beneficial_owners register and stores a pending beneficial_ownership_filings row.
+func CalculateBeneficialOwnershipActivity(ctx context.Context, input BeneficialOwnershipInput) (*BeneficialOwnershipFiling, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Calculating beneficial ownership", "company_id", input.CompanyID, "reg_no", input.CompanyRegNumber)
+
+	result, err := CalculateBeneficialOwnership(input.Graph, BeneficialOwnershipThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ownership structure: %w", err)
+	}
+	for _, cycle := range result.Cycles {
+		logger.Warn("Circular shareholding detected", "company_id", input.CompanyID, "cycle", cycle)
+	}
+
+	ownersJSON, controlJSON, err := result.FilingJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The register keeps every disclosable owner with their effective (look-through) interest.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM beneficial_owners WHERE reg_no = $1`, input.CompanyRegNumber); err != nil {
+		return nil, err
+	}
+	for _, owner := range result.BeneficialOwners {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO beneficial_owners (reg_no, name, id_num, pct)
+			VALUES ($1, $2, $3, $4)
+		`, input.CompanyRegNumber, owner.Name, owner.IDNumber, owner.Pct)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var filingID string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO beneficial_ownership_filings (company_id, beneficial_owners, significant_control, compliance_status)
+		VALUES ($1, $2, $3, 'pending')
+		RETURNING id
+	`, input.CompanyID, ownersJSON, controlJSON).Scan(&filingID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Beneficial ownership calculated", "filing_id", filingID, "owners", len(result.BeneficialOwners), "controllers", len(result.SignificantControl))
+	return &BeneficialOwnershipFiling{FilingID: filingID, Result: *result}, nil
+}
@@ -0,0 +1,65 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// BeneficialOwnershipCheckResponse is returned by POST /beneficial-ownership/checks.
+type BeneficialOwnershipCheckResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+func (s *APIServer) registerBeneficialOwnershipRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /beneficial-ownership/checks", requireInternalAPIKey(s.createBeneficialOwnershipCheckHandler))
+}
+
+// createBeneficialOwnershipCheckHandler starts a BeneficialOwnershipChangeWorkflow to compare a
+// company's beneficial ownership register with its last filing, e.g. after the company reports a
+// change in its shareholding. When the request carries the company's ownership graph, the register
+// is first recalculated from it; an invalid graph is rejected before anything is started. A company
+// has at most one check running at a time.
+func (s *APIServer) createBeneficialOwnershipCheckHandler(w http.ResponseWriter, r *http.Request) {
+	var input BeneficialOwnershipChangeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.PhoneNumber == "" || input.CompanyID == "" || input.CompanyRegNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id, phone_number, company_id and company_reg_number are required")
+		return
+	}
+	if input.Graph != nil {
+		if _, err := CalculateBeneficialOwnership(*input.Graph, BeneficialOwnershipThreshold); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid ownership structure: "+err.Error())
+			return
+		}
+	}
+
+	run, err := s.startBeneficialOwnershipCheck(r.Context(), input)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		writeError(w, http.StatusConflict, "A beneficial ownership check is already in progress for this company")
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting beneficial ownership check for company %s: %s", input.CompanyID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start beneficial ownership check")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, BeneficialOwnershipCheckResponse{WorkflowID: run.GetID()})
+}
+
+// startBeneficialOwnershipCheck starts a company's BeneficialOwnershipChangeWorkflow, failing with
+// WorkflowExecutionAlreadyStarted if one is already running.
+func (s *APIServer) startBeneficialOwnershipCheck(ctx context.Context, input BeneficialOwnershipChangeInput) (client.WorkflowRun, error) {
+	return s.Temporal.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:                                       "beneficial-ownership-check-" + input.CompanyID,
+		TaskQueue:                                TaskQueue,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}, BeneficialOwnershipChangeWorkflow, input)
+}
@@ -0,0 +1,309 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// BeneficialOwnershipChangeInput identifies the company whose register should be checked.
+type BeneficialOwnershipChangeInput struct {
+	CompanyID        string `json:"company_id"`
+	CompanyRegNumber string `json:"company_reg_number"`
+	UserID           string `json:"user_id"`
+	PhoneNumber      string `json:"phone_number"`
+	// Graph, when set, is the company's current ownership structure. The register is recalculated
+	// from it before being compared with the last filing.
+	Graph *OwnershipGraph `json:"graph,omitempty"`
+}
+
+// BeneficialOwnershipSnapshot is the current register alongside the owners in the last filing.
+type BeneficialOwnershipSnapshot struct {
+	Current []BeneficialOwner `json:"current"`
+	Filed   []BeneficialOwner `json:"filed"`
+	// LastFilingID is empty when the company has never filed a BO declaration.
+	LastFilingID string `json:"last_filing_id,omitempty"`
+}
+
+// BeneficialOwnershipChangeResult reports what the workflow found and did.
+type BeneficialOwnershipChangeResult struct {
+	Changes       BeneficialOwnershipChanges `json:"changes"`
+	DueDate       time.Time                  `json:"due_date,omitempty"`
+	FilingStarted bool                       `json:"filing_started"`
+	FilingID      string                     `json:"filing_workflow_id,omitempty"`
+	// Submitted is set once the filing has been submitted to CIPC and recorded as the last filing.
+	Submitted bool `json:"submitted,omitempty"`
+	// Calculation is the calculator's result when the register was recalculated from Graph.
+	Calculation *BeneficialOwnershipFiling `json:"calculation,omitempty"`
+}
+
+// BeneficialOwnershipChangeWorkflow compares the current BO register, recalculated first when the
+// input carries an ownership graph, with the last filing, opens a statutory deadline when they
+// differ, tells the company what changed and files the update if the user replies FILE before the
+// deadline.
+func BeneficialOwnershipChangeWorkflow(ctx workflow.Context, input BeneficialOwnershipChangeInput) (*BeneficialOwnershipChangeResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Checking beneficial ownership for changes", "company_id", input.CompanyID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	// Step 1: Recalculate the register from the ownership structure, if given
+	var calculation *BeneficialOwnershipFiling
+	if input.Graph != nil {
+		calcInput := BeneficialOwnershipInput{CompanyID: input.CompanyID, CompanyRegNumber: input.CompanyRegNumber, Graph: *input.Graph}
+		if err := workflow.ExecuteActivity(ctx, CalculateBeneficialOwnershipActivity, calcInput).Get(ctx, &calculation); err != nil {
+			return nil, fmt.Errorf("failed to calculate beneficial ownership: %w", err)
+		}
+	}
+
+	// Step 2: Load the current register and the last filed declaration
+	var snapshot BeneficialOwnershipSnapshot
+	if err := workflow.ExecuteActivity(ctx, LoadBeneficialOwnershipSnapshotActivity, input).Get(ctx, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to load beneficial ownership snapshot: %w", err)
+	}
+
+	// Step 3: Diff
+	result := &BeneficialOwnershipChangeResult{
+		Changes:     DiffBeneficialOwners(snapshot.Filed, snapshot.Current),
+		Calculation: calculation,
+	}
+	if !result.Changes.HasChanges() {
+		logger.Info("Beneficial ownership unchanged since last filing", "company_id", input.CompanyID)
+		return result, nil
+	}
+
+	// Step 4: Open the statutory deadline
+	result.DueDate = AddBusinessDays(workflow.Now(ctx), BeneficialOwnershipUpdateBusinessDays)
+	if err := workflow.ExecuteActivity(ctx, CreateBeneficialOwnershipDeadlineActivity, input, result.DueDate).Get(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to create beneficial ownership deadline: %w", err)
+	}
+
+	// Step 5: Notify the company and offer to file
+	message := fmt.Sprintf("🧾 *Beneficial Ownership Change Detected*\n\nCompany: %s\n\n%s\n\nCIPC must be updated by *%s*. Reply 'FILE' and we'll submit the update for you.",
+		input.CompanyRegNumber, result.Changes.Summary(), result.DueDate.Format("2 January 2006"))
+	if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+		logger.Warn("Failed to send beneficial ownership change notice", "error", err)
+	}
+
+	// Step 6: Wait for the user to accept, until the deadline
+	conversationID := openConversation(ctx, ConversationPrompt{
+		PhoneNumber: input.PhoneNumber,
+		Awaiting:    ConversationAwaitingFile,
+		Description: fmt.Sprintf("Beneficial ownership update for %s (reply FILE)", input.CompanyRegNumber),
+		ExpiresAt:   result.DueDate,
+	})
+	fileRequested := false
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	timer := workflow.NewTimer(timerCtx, result.DueDate.Sub(workflow.Now(ctx)))
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(workflow.GetSignalChannel(ctx, FileConfirmSignalName), func(c workflow.ReceiveChannel, more bool) {
+		c.Receive(ctx, nil)
+		fileRequested = true
+		cancelTimer()
+	})
+	selector.AddFuture(timer, func(f workflow.Future) {})
+	selector.Select(ctx)
+	endConversation(ctx, conversationID)
+
+	if !fileRequested {
+		logger.Info("User did not request a beneficial ownership filing before the deadline", "company_id", input.CompanyID)
+		return result, nil
+	}
+
+	// Step 7: Create the transaction and hand over to the filing workflow
+	var transactionID string
+	txInput := CreatePaygTransactionInput{
+		UserID:      input.UserID,
+		ServiceType: "beneficial_ownership",
+		FilingData: map[string]interface{}{
+			"company_id":         input.CompanyID,
+			"beneficial_owners":  snapshot.Current,
+			"changes":            result.Changes,
+			"previous_filing_id": snapshot.LastFilingID,
+		},
+	}
+	if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &transactionID); err != nil {
+		return nil, fmt.Errorf("failed to create beneficial ownership transaction: %w", err)
+	}
+
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID:        "filing-beneficial-ownership-" + transactionID,
+		ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+	}
+	childCtx := workflow.WithChildOptions(ctx, cwo)
+	filingInput := FilingWorkflowInput{
+		TransactionID:    transactionID,
+		UserID:           input.UserID,
+		ServiceType:      "beneficial_ownership",
+		FilingData:       txInput.FilingData,
+		CompanyRegNumber: input.CompanyRegNumber,
+	}
+	child := workflow.ExecuteChildWorkflow(childCtx, CombinedFilingWorkflow, filingInput)
+	var execution workflow.Execution
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		return nil, fmt.Errorf("failed to start beneficial ownership filing: %w", err)
+	}
+	result.FilingStarted = true
+	result.FilingID = execution.ID
+
+	// Step 8: Record the submitted declaration, so the next check compares against it
+	var filing FilingWorkflowResult
+	if err := child.Get(ctx, &filing); err != nil {
+		return nil, fmt.Errorf("beneficial ownership filing failed: %w", err)
+	}
+	if !filing.Success {
+		logger.Warn("Beneficial ownership filing was not submitted", "company_id", input.CompanyID, "error", filing.ErrorMessage)
+		return result, nil
+	}
+	pendingFilingID := ""
+	if calculation != nil {
+		pendingFilingID = calculation.FilingID
+	}
+	if err := workflow.ExecuteActivity(ctx, RecordBeneficialOwnershipSubmittedActivity, input, pendingFilingID, snapshot.Current).Get(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to record beneficial ownership filing: %w", err)
+	}
+	result.Submitted = true
+	return result, nil
+}
+
+// LoadBeneficialOwnershipSnapshotActivity reads the beneficial_owners register for the company and
+// the owners recorded in its most recent submitted beneficial_ownership_filings row.
+func LoadBeneficialOwnershipSnapshotActivity(ctx context.Context, input BeneficialOwnershipChangeInput) (*BeneficialOwnershipSnapshot, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, id_num, pct FROM beneficial_owners WHERE reg_no = $1
+	`, input.CompanyRegNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := &BeneficialOwnershipSnapshot{}
+	for rows.Next() {
+		var owner BeneficialOwner
+		if err := rows.Scan(&owner.Name, &owner.IDNumber, &owner.Pct); err != nil {
+			return nil, err
+		}
+		snapshot.Current = append(snapshot.Current, owner)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var filedJSON []byte
+	err = db.QueryRowContext(ctx, `
+		SELECT id, beneficial_owners FROM beneficial_ownership_filings
+		WHERE company_id = $1 AND compliance_status IN ('submitted', 'compliant')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, input.CompanyID).Scan(&snapshot.LastFilingID, &filedJSON)
+	if err == sql.ErrNoRows {
+		// Never filed: every current owner counts as an addition.
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filedJSON, &snapshot.Filed); err != nil {
+		return nil, fmt.Errorf("failed to parse filed beneficial owners: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// RecordBeneficialOwnershipSubmittedActivity marks the company's declaration as submitted with the
+// owners that were filed: the pending row the calculator stored when pendingFilingID is set, or a
+// new row otherwise. It also completes the open beneficial_ownership deadline.
+func RecordBeneficialOwnershipSubmittedActivity(ctx context.Context, input BeneficialOwnershipChangeInput, pendingFilingID string, owners []BeneficialOwner) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Recording submitted beneficial ownership declaration", "company_id", input.CompanyID, "filing_id", pendingFilingID)
+
+	if owners == nil {
+		owners = []BeneficialOwner{}
+	}
+	ownersJSON, err := json.Marshal(owners)
+	if err != nil {
+		return fmt.Errorf("failed to marshal beneficial owners: %w", err)
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if pendingFilingID != "" {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE beneficial_ownership_filings
+			SET compliance_status = 'submitted', beneficial_owners = $2, last_updated = NOW()
+			WHERE id = $1 AND company_id = $3
+		`, pendingFilingID, ownersJSON, input.CompanyID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO beneficial_ownership_filings (company_id, beneficial_owners, compliance_status)
+			VALUES ($1, $2, 'submitted')
+		`, input.CompanyID, ownersJSON)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE compliance_deadlines SET status = 'completed'
+		WHERE company_reg_number = $1 AND deadline_type = 'beneficial_ownership' AND status IN ('pending', 'overdue')
+	`, input.CompanyRegNumber); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateBeneficialOwnershipDeadlineActivity records the statutory update deadline, unless the
+// company already has an open beneficial_ownership deadline.
+func CreateBeneficialOwnershipDeadlineActivity(ctx context.Context, input BeneficialOwnershipChangeInput, dueDate time.Time) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Creating beneficial ownership deadline", "reg_no", input.CompanyRegNumber, "due_date", dueDate)
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO compliance_deadlines (user_id, company_reg_number, deadline_type, due_date, status)
+		SELECT $1, $2, 'beneficial_ownership', $3, 'pending'
+		WHERE NOT EXISTS (
+			SELECT 1 FROM compliance_deadlines
+			WHERE company_reg_number = $2 AND deadline_type = 'beneficial_ownership' AND status = 'pending'
+		)
+	`, input.UserID, input.CompanyRegNumber, dueDate)
+	return err
+}
@@ -0,0 +1,98 @@
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BeneficialOwnershipUpdateBusinessDays is the number of business days a company has to
+// update its beneficial ownership register with CIPC after a change.
+const BeneficialOwnershipUpdateBusinessDays = 10
+
+// pctChangeTolerance ignores rounding differences between the register and a past filing.
+const pctChangeTolerance = 0.01
+
+// OwnerPctChange describes a beneficial owner whose effective interest moved.
+type OwnerPctChange struct {
+	Name     string  `json:"name"`
+	IDNumber string  `json:"id_num"`
+	OldPct   float64 `json:"old_pct"`
+	NewPct   float64 `json:"new_pct"`
+}
+
+// BeneficialOwnershipChanges is the difference between the last filed register and the current one.
+type BeneficialOwnershipChanges struct {
+	Added   []BeneficialOwner `json:"added,omitempty"`
+	Removed []BeneficialOwner `json:"removed,omitempty"`
+	Changed []OwnerPctChange  `json:"changed,omitempty"`
+}
+
+// HasChanges reports whether anything was added, removed or changed.
+func (c BeneficialOwnershipChanges) HasChanges() bool {
+	return len(c.Added) > 0 || len(c.Removed) > 0 || len(c.Changed) > 0
+}
+
+// DiffBeneficialOwners compares the owners in the last filing with the current register.
+// Owners are matched on ID number, falling back to a case-insensitive name match when a
+// record has no ID number.
+func DiffBeneficialOwners(filed, current []BeneficialOwner) BeneficialOwnershipChanges {
+	var changes BeneficialOwnershipChanges
+
+	previous := make(map[string]BeneficialOwner, len(filed))
+	for _, o := range filed {
+		previous[ownerKey(o)] = o
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, o := range current {
+		key := ownerKey(o)
+		seen[key] = true
+		old, ok := previous[key]
+		if !ok {
+			changes.Added = append(changes.Added, o)
+			continue
+		}
+		if math.Abs(old.Pct-o.Pct) >= pctChangeTolerance {
+			changes.Changed = append(changes.Changed, OwnerPctChange{
+				Name:     o.Name,
+				IDNumber: o.IDNumber,
+				OldPct:   old.Pct,
+				NewPct:   o.Pct,
+			})
+		}
+	}
+	for _, o := range filed {
+		if !seen[ownerKey(o)] {
+			changes.Removed = append(changes.Removed, o)
+		}
+	}
+
+	sort.Slice(changes.Added, func(i, j int) bool { return changes.Added[i].Name < changes.Added[j].Name })
+	sort.Slice(changes.Removed, func(i, j int) bool { return changes.Removed[i].Name < changes.Removed[j].Name })
+	sort.Slice(changes.Changed, func(i, j int) bool { return changes.Changed[i].Name < changes.Changed[j].Name })
+	return changes
+}
+
+// Summary renders the changes as WhatsApp-friendly bullet points.
+func (c BeneficialOwnershipChanges) Summary() string {
+	var lines []string
+	for _, o := range c.Added {
+		lines = append(lines, fmt.Sprintf("➕ %s (%.2f%%)", o.Name, o.Pct))
+	}
+	for _, o := range c.Removed {
+		lines = append(lines, fmt.Sprintf("➖ %s (was %.2f%%)", o.Name, o.Pct))
+	}
+	for _, o := range c.Changed {
+		lines = append(lines, fmt.Sprintf("🔁 %s: %.2f%% → %.2f%%", o.Name, o.OldPct, o.NewPct))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func ownerKey(o BeneficialOwner) string {
+	if id := strings.TrimSpace(o.IDNumber); id != "" {
+		return "id:" + id
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(o.Name))
+}
@@ -0,0 +1,212 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestDiffBeneficialOwners(t *testing.T) {
+	filed := []BeneficialOwner{
+		{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 50},
+		{Name: "Sipho Dlamini", IDNumber: "7505055009081", Pct: 30},
+		{Name: "Lerato Nkosi", IDNumber: "9002020109082", Pct: 20},
+	}
+	current := []BeneficialOwner{
+		{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 50.004},
+		{Name: "Sipho Dlamini", IDNumber: "7505055009081", Pct: 45},
+		{Name: "Ayanda Zulu", IDNumber: "8807070109083", Pct: 5},
+	}
+
+	changes := DiffBeneficialOwners(filed, current)
+
+	assert.True(t, changes.HasChanges())
+	assert.Equal(t, []BeneficialOwner{current[2]}, changes.Added)
+	assert.Equal(t, []BeneficialOwner{filed[2]}, changes.Removed)
+	assert.Equal(t, []OwnerPctChange{{Name: "Sipho Dlamini", IDNumber: "7505055009081", OldPct: 30, NewPct: 45}}, changes.Changed)
+	assert.Contains(t, changes.Summary(), "Sipho Dlamini: 30.00% → 45.00%")
+}
+
+func TestDiffBeneficialOwners_NoChanges(t *testing.T) {
+	owners := []BeneficialOwner{{Name: "Thandi Mokoena", Pct: 100}}
+	assert.False(t, DiffBeneficialOwners(owners, owners).HasChanges())
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	// Thursday 17 April 2025: Good Friday (18th), the weekend and Family Day (21st) are skipped.
+	from := time.Date(2025, time.April, 17, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.April, 22, 9, 0, 0, 0, time.UTC), AddBusinessDays(from, 1))
+
+	// Freedom Day 2025 falls on a Sunday, so Monday 28 April is also a holiday.
+	from = time.Date(2025, time.April, 25, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.April, 29, 9, 0, 0, 0, time.UTC), AddBusinessDays(from, 1))
+
+	from = time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.June, 17, 9, 0, 0, 0, time.UTC), AddBusinessDays(from, BeneficialOwnershipUpdateBusinessDays))
+}
+
+// BeneficialOwnershipChangeWorkflowTestSuite is the test suite for the BO change workflow.
+type BeneficialOwnershipChangeWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+// TestBeneficialOwnershipChangeWorkflowTestSuite runs the test suite.
+func TestBeneficialOwnershipChangeWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(BeneficialOwnershipChangeWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(CombinedFilingWorkflow)
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.Anything).Return("conversation-1", nil).Maybe()
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, "conversation-1").Return(nil).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var boChangeInput = BeneficialOwnershipChangeInput{
+	CompanyID:        "company-1",
+	CompanyRegNumber: "2020/123456/07",
+	UserID:           "user-1",
+	PhoneNumber:      "+27721234567",
+}
+
+// Test_NoChanges_DoesNothing tests that an unchanged register opens no deadline.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) Test_NoChanges_DoesNothing() {
+	owners := []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 100}}
+	s.env.OnActivity(LoadBeneficialOwnershipSnapshotActivity, mock.Anything, boChangeInput).
+		Return(&BeneficialOwnershipSnapshot{Current: owners, Filed: owners, LastFilingID: "filing-1"}, nil).Once()
+
+	s.env.ExecuteWorkflow(BeneficialOwnershipChangeWorkflow, boChangeInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result BeneficialOwnershipChangeResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.False(result.Changes.HasChanges())
+}
+
+// Test_Change_FileSignal_StartsFiling tests the happy path where the user replies FILE.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) Test_Change_FileSignal_StartsFiling() {
+	s.env.OnActivity(LoadBeneficialOwnershipSnapshotActivity, mock.Anything, boChangeInput).Return(&BeneficialOwnershipSnapshot{
+		Current: []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 60}},
+		Filed:   []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 40}},
+	}, nil).Once()
+	s.env.OnActivity(CreateBeneficialOwnershipDeadlineActivity, mock.Anything, boChangeInput, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, boChangeInput.PhoneNumber, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{Success: true}, nil).Once()
+	var recorded []BeneficialOwner
+	s.env.OnActivity(RecordBeneficialOwnershipSubmittedActivity, mock.Anything, boChangeInput, "", mock.Anything).Return(
+		func(_ context.Context, _ BeneficialOwnershipChangeInput, _ string, owners []BeneficialOwner) error {
+			recorded = owners
+			return nil
+		}).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FileConfirmSignalName, nil)
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(BeneficialOwnershipChangeWorkflow, boChangeInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result BeneficialOwnershipChangeResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.FilingStarted)
+	s.True(result.Submitted)
+	s.Equal("filing-beneficial-ownership-tx-1", result.FilingID)
+	s.Len(result.Changes.Changed, 1)
+
+	// The next check compares the register with what was just filed and finds nothing to do.
+	next := s.NewTestWorkflowEnvironment()
+	next.OnActivity(LoadBeneficialOwnershipSnapshotActivity, mock.Anything, boChangeInput).Return(&BeneficialOwnershipSnapshot{
+		Current:      []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 60}},
+		Filed:        recorded,
+		LastFilingID: "filing-2",
+	}, nil).Once()
+
+	next.ExecuteWorkflow(BeneficialOwnershipChangeWorkflow, boChangeInput)
+
+	s.NoError(next.GetWorkflowError())
+	var again BeneficialOwnershipChangeResult
+	s.NoError(next.GetWorkflowResult(&again))
+	s.False(again.Changes.HasChanges())
+	s.False(again.FilingStarted)
+	next.AssertExpectations(s.T())
+}
+
+// Test_FilingNotSubmitted_RecordsNothing tests that a filing that fails is not recorded as the
+// last declaration.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) Test_FilingNotSubmitted_RecordsNothing() {
+	s.env.OnActivity(LoadBeneficialOwnershipSnapshotActivity, mock.Anything, boChangeInput).Return(&BeneficialOwnershipSnapshot{
+		Current: []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 60}},
+	}, nil).Once()
+	s.env.OnActivity(CreateBeneficialOwnershipDeadlineActivity, mock.Anything, boChangeInput, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, boChangeInput.PhoneNumber, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{ErrorMessage: "payment failed"}, nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FileConfirmSignalName, nil)
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(BeneficialOwnershipChangeWorkflow, boChangeInput)
+
+	s.NoError(s.env.GetWorkflowError())
+	var result BeneficialOwnershipChangeResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.FilingStarted)
+	s.False(result.Submitted)
+}
+
+// Test_Graph_RecalculatesRegisterBeforeDiffing tests that an ownership graph in the input is run
+// through the calculator before the register is compared with the last filing.
+func (s *BeneficialOwnershipChangeWorkflowTestSuite) Test_Graph_RecalculatesRegisterBeforeDiffing() {
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany, IDNumber: boChangeInput.CompanyRegNumber},
+			{ID: "thandi", Name: "Thandi Mokoena", Kind: NodeNaturalPerson, IDNumber: "8001015009087"},
+		},
+		Shareholdings: []Shareholding{{HolderID: "thandi", EntityID: "co", Percentage: 100}},
+	}
+	input := boChangeInput
+	input.Graph = &graph
+	owners := []BeneficialOwner{{Name: "Thandi Mokoena", IDNumber: "8001015009087", Pct: 100}}
+
+	calculated := false
+	s.env.OnActivity(CalculateBeneficialOwnershipActivity, mock.Anything, BeneficialOwnershipInput{
+		CompanyID: input.CompanyID, CompanyRegNumber: input.CompanyRegNumber, Graph: graph,
+	}).Return(func(ctx context.Context, in BeneficialOwnershipInput) (*BeneficialOwnershipFiling, error) {
+		calculated = true
+		return &BeneficialOwnershipFiling{FilingID: "filing-2", Result: BeneficialOwnershipResult{BeneficialOwners: owners}}, nil
+	}).Once()
+	s.env.OnActivity(LoadBeneficialOwnershipSnapshotActivity, mock.Anything, input).
+		Return(func(ctx context.Context, in BeneficialOwnershipChangeInput) (*BeneficialOwnershipSnapshot, error) {
+			s.True(calculated, "register loaded before it was recalculated")
+			return &BeneficialOwnershipSnapshot{Current: owners, Filed: owners, LastFilingID: "filing-1"}, nil
+		}).Once()
+
+	s.env.ExecuteWorkflow(BeneficialOwnershipChangeWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result BeneficialOwnershipChangeResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.False(result.Changes.HasChanges())
+	s.Require().NotNil(result.Calculation)
+	s.Equal("filing-2", result.Calculation.FilingID)
+}
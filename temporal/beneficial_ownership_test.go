@@ -0,0 +1,195 @@
+package temporal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func holdingGroupGraph() OwnershipGraph {
+	return OwnershipGraph{
+		RootID: "opco",
+		Nodes: []OwnershipNode{
+			{ID: "opco", Name: "OpCo (Pty) Ltd", Kind: NodeCompany, IDNumber: "2020/123456/07"},
+			{ID: "holdco", Name: "HoldCo (Pty) Ltd", Kind: NodeCompany, IDNumber: "2018/654321/07"},
+			{ID: "trust", Name: "Family Trust", Kind: NodeTrust, IDNumber: "IT1234/2015"},
+			{ID: "thandi", Name: "Thandi Mokoena", Kind: NodeNaturalPerson, IDNumber: "8001015009087"},
+			{ID: "sipho", Name: "Sipho Dlamini", Kind: NodeNaturalPerson, IDNumber: "7505055009081"},
+			{ID: "lerato", Name: "Lerato Nkosi", Kind: NodeNaturalPerson, IDNumber: "9002020109082"},
+		},
+		Shareholdings: []Shareholding{
+			{HolderID: "holdco", EntityID: "opco", Percentage: 60},
+			{HolderID: "sipho", EntityID: "opco", Percentage: 40},
+			{HolderID: "trust", EntityID: "holdco", Percentage: 50},
+			{HolderID: "thandi", EntityID: "holdco", Percentage: 46},
+			{HolderID: "lerato", EntityID: "holdco", Percentage: 4},
+			{HolderID: "lerato", EntityID: "trust", Percentage: 100},
+		},
+		Controls: []ControlInterest{
+			{PersonID: "thandi", EntityID: "holdco", Nature: "appoints majority of directors"},
+		},
+	}
+}
+
+func TestCalculateBeneficialOwnership_MultipliesThroughChains(t *testing.T) {
+	result, err := CalculateBeneficialOwnership(holdingGroupGraph(), BeneficialOwnershipThreshold)
+	require.NoError(t, err)
+	require.Len(t, result.BeneficialOwners, 3)
+
+	sipho := result.BeneficialOwners[0]
+	assert.Equal(t, "Sipho Dlamini", sipho.Name)
+	assert.Equal(t, 40.0, sipho.Pct)
+	assert.Equal(t, 40.0, sipho.DirectPct)
+
+	// Lerato holds 4% of HoldCo directly and the whole trust that holds 50% of HoldCo.
+	lerato := result.BeneficialOwners[1]
+	assert.Equal(t, "Lerato Nkosi", lerato.Name)
+	assert.Equal(t, 32.4, lerato.Pct)
+	assert.Equal(t, 0.0, lerato.DirectPct)
+	assert.Len(t, lerato.HoldingPaths, 2)
+
+	thandi := result.BeneficialOwners[2]
+	assert.Equal(t, 27.6, thandi.Pct)
+	assert.Equal(t, []string{"thandi", "holdco", "opco"}, thandi.HoldingPaths[0])
+
+	require.Len(t, result.SignificantControl, 1)
+	assert.Equal(t, "Thandi Mokoena", result.SignificantControl[0].Name)
+	assert.Equal(t, "HoldCo (Pty) Ltd", result.SignificantControl[0].Via)
+	assert.Empty(t, result.Cycles)
+}
+
+func TestCalculateBeneficialOwnership_AppliesThreshold(t *testing.T) {
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany},
+			{ID: "a", Name: "A", Kind: NodeNaturalPerson},
+			{ID: "b", Name: "B", Kind: NodeNaturalPerson},
+			{ID: "c", Name: "C", Kind: NodeNaturalPerson},
+		},
+		Shareholdings: []Shareholding{
+			{HolderID: "a", EntityID: "co", Percentage: 91},
+			{HolderID: "b", EntityID: "co", Percentage: 5},
+			{HolderID: "c", EntityID: "co", Percentage: 4},
+		},
+	}
+
+	result, err := CalculateBeneficialOwnership(graph, BeneficialOwnershipThreshold)
+	require.NoError(t, err)
+	assert.Len(t, result.BeneficialOwners, 2)
+	assert.Len(t, result.AllOwners, 3)
+	assert.Equal(t, "B", result.BeneficialOwners[1].Name)
+}
+
+func TestCalculateBeneficialOwnership_DetectsCycles(t *testing.T) {
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany},
+			{ID: "x", Name: "X", Kind: NodeCompany},
+			{ID: "y", Name: "Y", Kind: NodeCompany},
+			{ID: "p", Name: "P", Kind: NodeNaturalPerson},
+		},
+		Shareholdings: []Shareholding{
+			{HolderID: "x", EntityID: "co", Percentage: 100},
+			{HolderID: "y", EntityID: "x", Percentage: 30},
+			{HolderID: "p", EntityID: "x", Percentage: 70},
+			{HolderID: "x", EntityID: "y", Percentage: 100},
+		},
+	}
+
+	result, err := CalculateBeneficialOwnership(graph, BeneficialOwnershipThreshold)
+	require.NoError(t, err)
+	require.Len(t, result.Cycles, 1)
+	assert.Equal(t, []string{"x", "y", "x"}, result.Cycles[0])
+	require.Len(t, result.BeneficialOwners, 1)
+	assert.Equal(t, 70.0, result.BeneficialOwners[0].Pct)
+}
+
+func TestCalculateBeneficialOwnership_RejectsOverIssuedShares(t *testing.T) {
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany},
+			{ID: "a", Name: "A", Kind: NodeNaturalPerson},
+			{ID: "b", Name: "B", Kind: NodeNaturalPerson},
+		},
+		Shareholdings: []Shareholding{
+			{HolderID: "a", EntityID: "co", Percentage: 60},
+			{HolderID: "b", EntityID: "co", Percentage: 50},
+		},
+	}
+
+	_, err := CalculateBeneficialOwnership(graph, BeneficialOwnershipThreshold)
+	assert.ErrorContains(t, err, "add up to 110.00%")
+}
+
+func TestCalculateBeneficialOwnership_RejectsDuplicateNodes(t *testing.T) {
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany},
+			{ID: "a", Name: "A", Kind: NodeNaturalPerson},
+			{ID: "a", Name: "A Holdings", Kind: NodeCompany},
+		},
+		Shareholdings: []Shareholding{
+			{HolderID: "a", EntityID: "co", Percentage: 100},
+		},
+	}
+
+	_, err := CalculateBeneficialOwnership(graph, BeneficialOwnershipThreshold)
+	assert.ErrorContains(t, err, "ownership node a appears more than once")
+}
+
+func TestCalculateBeneficialOwnership_SharedHoldersAreWalkedOnce(t *testing.T) {
+	// 40 layers of two holdcos, each holding half of both holdcos in the layer below, give 2^40
+	// distinct paths from the person at the top down to the root.
+	graph := OwnershipGraph{
+		RootID: "co",
+		Nodes: []OwnershipNode{
+			{ID: "co", Name: "Co", Kind: NodeCompany},
+			{ID: "p", Name: "P", IDNumber: "8001015009087", Kind: NodeNaturalPerson},
+		},
+	}
+	below := []string{"co"}
+	for layer := 0; layer < 40; layer++ {
+		ids := []string{fmt.Sprintf("l%da", layer), fmt.Sprintf("l%db", layer)}
+		for _, id := range ids {
+			graph.Nodes = append(graph.Nodes, OwnershipNode{ID: id, Name: id, Kind: NodeCompany})
+			for _, entity := range below {
+				graph.Shareholdings = append(graph.Shareholdings, Shareholding{HolderID: id, EntityID: entity, Percentage: 50})
+			}
+		}
+		below = ids
+	}
+	for _, entity := range below {
+		graph.Shareholdings = append(graph.Shareholdings, Shareholding{HolderID: "p", EntityID: entity, Percentage: 100})
+	}
+
+	result, err := CalculateBeneficialOwnership(graph, BeneficialOwnershipThreshold)
+	require.NoError(t, err)
+
+	require.Len(t, result.BeneficialOwners, 1)
+	p := result.BeneficialOwners[0]
+	assert.Equal(t, 100.0, p.Pct)
+	assert.Equal(t, 100.0, p.IndirectPct)
+	assert.Len(t, p.HoldingPaths, 2, "one sample path per holding")
+	assert.Len(t, p.HoldingPaths[0], 42)
+}
+
+func TestBeneficialOwnershipResult_FilingJSON(t *testing.T) {
+	result, err := CalculateBeneficialOwnership(holdingGroupGraph(), BeneficialOwnershipThreshold)
+	require.NoError(t, err)
+
+	owners, control, err := result.FilingJSON()
+	require.NoError(t, err)
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(owners, &rows))
+	assert.Len(t, rows, 3)
+	assert.Equal(t, "7505055009081", rows[0]["id_num"])
+	assert.Contains(t, string(control), "appoints majority of directors")
+}
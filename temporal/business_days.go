@@ -0,0 +1,79 @@
+package temporal
+
+import "time"
+
+// AddBusinessDays returns the date n South African business days after from, skipping
+// weekends and public holidays. The time of day is preserved.
+func AddBusinessDays(from time.Time, n int) time.Time {
+	d := from
+	for added := 0; added < n; {
+		d = d.AddDate(0, 0, 1)
+		if IsBusinessDay(d) {
+			added++
+		}
+	}
+	return d
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not a South African public holiday.
+func IsBusinessDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	y, m, d := t.Date()
+	for _, h := range southAfricanPublicHolidays(y) {
+		if h.Month() == m && h.Day() == d {
+			return false
+		}
+	}
+	return true
+}
+
+// southAfricanPublicHolidays lists the statutory public holidays for a year, including the
+// following Monday when a holiday falls on a Sunday (Public Holidays Act, section 2(1)).
+func southAfricanPublicHolidays(year int) []time.Time {
+	date := func(m time.Month, d int) time.Time { return time.Date(year, m, d, 0, 0, 0, 0, time.UTC) }
+
+	easter := easterSunday(year)
+	holidays := []time.Time{
+		date(time.January, 1),    // New Year's Day
+		date(time.March, 21),     // Human Rights Day
+		easter.AddDate(0, 0, -2), // Good Friday
+		easter.AddDate(0, 0, 1),  // Family Day
+		date(time.April, 27),     // Freedom Day
+		date(time.May, 1),        // Workers' Day
+		date(time.June, 16),      // Youth Day
+		date(time.August, 9),     // National Women's Day
+		date(time.September, 24), // Heritage Day
+		date(time.December, 16),  // Day of Reconciliation
+		date(time.December, 25),  // Christmas Day
+		date(time.December, 26),  // Day of Goodwill
+	}
+
+	observed := append([]time.Time{}, holidays...)
+	for _, h := range holidays {
+		if h.Weekday() == time.Sunday {
+			observed = append(observed, h.AddDate(0, 0, 1))
+		}
+	}
+	return observed
+}
+
+// easterSunday computes the Gregorian Easter date (anonymous Gregorian algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
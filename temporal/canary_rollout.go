@@ -0,0 +1,99 @@
+package temporal
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// Rollout bucketing keys, configured per service type.
+const (
+	CanaryBucketByTransaction = "transaction"
+	CanaryBucketByCompany     = "company"
+)
+
+// Cohorts a filing can be routed to.
+const (
+	CanaryCohortAutomated = "automated"
+	CanaryCohortManual    = "manual"
+)
+
+// CanaryConfig is a row of automation_rollout. Ops change Percentage in the database to widen or
+// narrow the rollout; workflows pick the new value up on their next decision.
+type CanaryConfig struct {
+	ServiceType string `json:"service_type"`
+	Percentage  int    `json:"percentage"`
+	BucketBy    string `json:"bucket_by"`
+	// FailureThreshold is the automated failure rate (0-1) that triggers an automatic rollback.
+	FailureThreshold float64 `json:"failure_threshold"`
+	// MinSample is how many automated outcomes are needed at the current percentage before the
+	// failure rate is trusted.
+	MinSample int `json:"min_sample"`
+	// RollbackPercentage is what Percentage is set to when the threshold is breached.
+	RollbackPercentage int `json:"rollback_percentage"`
+}
+
+// CanaryDecision records which cohort a transaction was routed to and why.
+type CanaryDecision struct {
+	Automated  bool   `json:"automated"`
+	Cohort     string `json:"cohort"`
+	BucketKey  string `json:"bucket_key"`
+	Bucket     int    `json:"bucket"`
+	Percentage int    `json:"percentage"`
+}
+
+// CohortStats are the automated outcomes recorded since the percentage last changed.
+type CohortStats struct {
+	Successes int `json:"successes"`
+	Failures  int `json:"failures"`
+}
+
+// FailureRate is the share of failed outcomes, or 0 when nothing has been recorded.
+func (s CohortStats) FailureRate() float64 {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(total)
+}
+
+// CanaryBucket maps a key to a stable bucket in [0, 100). The same key always lands in the same
+// bucket, so a company stays in its cohort as the percentage grows.
+func CanaryBucket(serviceType, key string) int {
+	h := fnv.New32a()
+	// Salting with the service type keeps the same companies from being first in every rollout.
+	h.Write([]byte(serviceType + ":" + strings.ToUpper(strings.TrimSpace(key))))
+	return int(h.Sum32() % 100)
+}
+
+// DecideCanary routes a transaction into the automated or manual cohort. Company bucketing falls
+// back to the transaction when the company registration number is unknown.
+func DecideCanary(config CanaryConfig, transactionID, companyRegNumber string) CanaryDecision {
+	key := transactionID
+	if config.BucketBy == CanaryBucketByCompany && companyRegNumber != "" {
+		key = companyRegNumber
+	}
+
+	decision := CanaryDecision{
+		BucketKey:  key,
+		Bucket:     CanaryBucket(config.ServiceType, key),
+		Percentage: config.Percentage,
+		Cohort:     CanaryCohortManual,
+	}
+	if decision.Bucket < config.Percentage {
+		decision.Automated = true
+		decision.Cohort = CanaryCohortAutomated
+	}
+	return decision
+}
+
+// ShouldRollBack reports whether the automated cohort has failed often enough to pull the
+// percentage back. Nothing happens until MinSample outcomes exist or when already rolled back.
+func ShouldRollBack(config CanaryConfig, stats CohortStats) bool {
+	if config.Percentage <= config.RollbackPercentage || config.FailureThreshold <= 0 {
+		return false
+	}
+	if stats.Successes+stats.Failures < config.MinSample {
+		return false
+	}
+	return stats.FailureRate() >= config.FailureThreshold
+}
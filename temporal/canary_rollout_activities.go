@@ -0,0 +1,153 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// CanaryRolloutActivity determines if transaction should use automation. The percentage for the
+// service type is read from automation_rollout on every call, so changes apply immediately.
+func CanaryRolloutActivity(ctx context.Context, input AutomatedFilingInput) (CanaryDecision, error) {
+	logger := activity.GetLogger(ctx)
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return CanaryDecision{}, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	config, err := loadCanaryConfig(ctx, db, input.ServiceType, false)
+	if err != nil {
+		return CanaryDecision{}, err
+	}
+
+	regNumber := input.CompanyRegNumber
+	if regNumber == "" {
+		regNumber, _ = input.ClientData["reg_number"].(string)
+	}
+	decision := DecideCanary(config, input.TransactionID, regNumber)
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO automation_rollout_outcomes (transaction_id, service_type, cohort, bucket, percentage)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (transaction_id) DO NOTHING
+	`, input.TransactionID, input.ServiceType, decision.Cohort, decision.Bucket, decision.Percentage)
+	if err != nil {
+		return CanaryDecision{}, fmt.Errorf("failed to record canary cohort: %w", err)
+	}
+
+	logger.Info("Canary decision", "transaction_id", input.TransactionID, "cohort", decision.Cohort, "bucket", decision.Bucket, "percentage", decision.Percentage)
+	return decision, nil
+}
+
+// RecordCanaryOutcomeActivity records how a filing in either cohort ended and rolls the service's
+// percentage back when the automated cohort's failure rate crosses the configured threshold.
+func RecordCanaryOutcomeActivity(ctx context.Context, transactionID, serviceType string, result FilingResult) error {
+	logger := activity.GetLogger(ctx)
+
+	outcome := "failure"
+	if result.Status == "success" {
+		outcome = "success"
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE automation_rollout_outcomes
+		SET outcome = $2, error_code = NULLIF($3, ''), completed_at = NOW()
+		WHERE transaction_id = $1
+	`, transactionID, outcome, result.ErrorCode)
+	if err != nil {
+		return fmt.Errorf("failed to record canary outcome: %w", err)
+	}
+
+	// Lock the config row so concurrent failures roll back once.
+	config, err := loadCanaryConfig(ctx, tx, serviceType, true)
+	if err != nil {
+		return err
+	}
+
+	var stats CohortStats
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE o.outcome = 'success'),
+		       COUNT(*) FILTER (WHERE o.outcome = 'failure')
+		FROM automation_rollout_outcomes o
+		JOIN automation_rollout r ON r.service_type = o.service_type
+		WHERE o.service_type = $1 AND o.cohort = 'automated'
+		  AND o.percentage = r.percentage AND o.completed_at >= r.updated_at
+	`, serviceType).Scan(&stats.Successes, &stats.Failures)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to load cohort stats: %w", err)
+	}
+
+	if ShouldRollBack(config, stats) {
+		reason := fmt.Sprintf("automated failure rate %.0f%% over %d filings at %d%% rollout exceeded %.0f%% threshold",
+			stats.FailureRate()*100, stats.Successes+stats.Failures, config.Percentage, config.FailureThreshold*100)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE automation_rollout
+			SET percentage = rollback_percentage, rolled_back_at = NOW(), rollback_reason = $2
+			WHERE service_type = $1
+		`, serviceType, reason)
+		if err != nil {
+			return fmt.Errorf("failed to roll back canary: %w", err)
+		}
+		logger.Warn("Canary rolled back", "service_type", serviceType, "from", config.Percentage, "to", config.RollbackPercentage, "reason", reason)
+	}
+
+	return tx.Commit()
+}
+
+// RouteToManualFilingActivity queues a transaction outside the canary for an agent to file by
+// hand. Returns the ops task ID.
+func RouteToManualFilingActivity(ctx context.Context, input AutomatedFilingInput, decision CanaryDecision) (string, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Routing filing to manual path", "transaction_id", input.TransactionID, "bucket", decision.Bucket, "percentage", decision.Percentage)
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return createOpsTask(ctx, db, OpsTaskManualFiling, input, FilingResult{}, execution.ID, execution.RunID)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// loadCanaryConfig reads a service's rollout row. Services without a row stay fully manual.
+func loadCanaryConfig(ctx context.Context, q queryRower, serviceType string, forUpdate bool) (CanaryConfig, error) {
+	query := `
+		SELECT percentage, bucket_by, failure_threshold, min_sample, rollback_percentage
+		FROM automation_rollout WHERE service_type = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	config := CanaryConfig{ServiceType: serviceType, BucketBy: CanaryBucketByTransaction}
+	err := q.QueryRowContext(ctx, query, serviceType).Scan(
+		&config.Percentage, &config.BucketBy, &config.FailureThreshold, &config.MinSample, &config.RollbackPercentage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return config, nil
+	}
+	if err != nil {
+		return CanaryConfig{}, fmt.Errorf("failed to load rollout config: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,186 @@
+package temporal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCanaryBucket_IsStable(t *testing.T) {
+	bucket := CanaryBucket("annual_return", "2020/123456/07")
+	assert.Equal(t, bucket, CanaryBucket("annual_return", " 2020/123456/07 "))
+	assert.GreaterOrEqual(t, bucket, 0)
+	assert.Less(t, bucket, 100)
+}
+
+func TestDecideCanary(t *testing.T) {
+	config := CanaryConfig{ServiceType: "annual_return", Percentage: 30, BucketBy: CanaryBucketByCompany}
+
+	automated := 0
+	for i := 0; i < 1000; i++ {
+		if DecideCanary(config, fmt.Sprintf("tx-%d", i), fmt.Sprintf("2020/%06d/07", i)).Automated {
+			automated++
+		}
+	}
+	assert.InDelta(t, 300, automated, 60)
+
+	// Every filing for the same company lands in the same cohort.
+	first := DecideCanary(config, "tx-a", "2020/123456/07")
+	second := DecideCanary(config, "tx-b", "2020/123456/07")
+	assert.Equal(t, first.Cohort, second.Cohort)
+	assert.Equal(t, "2020/123456/07", first.BucketKey)
+
+	// Without a registration number the transaction is the key.
+	assert.Equal(t, "tx-a", DecideCanary(config, "tx-a", "").BucketKey)
+
+	assert.False(t, DecideCanary(CanaryConfig{Percentage: 0}, "tx-a", "").Automated)
+	assert.True(t, DecideCanary(CanaryConfig{Percentage: 100}, "tx-a", "").Automated)
+}
+
+func TestShouldRollBack(t *testing.T) {
+	config := CanaryConfig{Percentage: 25, FailureThreshold: 0.2, MinSample: 10}
+
+	assert.False(t, ShouldRollBack(config, CohortStats{Successes: 2, Failures: 3}), "too few outcomes")
+	assert.False(t, ShouldRollBack(config, CohortStats{Successes: 9, Failures: 1}))
+	assert.True(t, ShouldRollBack(config, CohortStats{Successes: 8, Failures: 2}))
+
+	config.Percentage = 0
+	assert.False(t, ShouldRollBack(config, CohortStats{Failures: 20}), "already rolled back")
+}
+
+// AutomatedFilingWorkflowTestSuite is the test suite for the automated filing workflow.
+type AutomatedFilingWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+// TestAutomatedFilingWorkflowTestSuite runs the test suite.
+func TestAutomatedFilingWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(AutomatedFilingWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *AutomatedFilingWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(CIPCConfirmationWorkflow)
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *AutomatedFilingWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *AutomatedFilingWorkflowTestSuite) input() AutomatedFilingInput {
+	return AutomatedFilingInput{
+		TransactionID:    "tx-123",
+		ServiceType:      "annual_return",
+		UserID:           "user-1",
+		CompanyRegNumber: "2020/123456/07",
+	}
+}
+
+// Test_Canary_RunsAutomationAndRecordsOutcome tests that canary transactions are filed by the runner.
+func (s *AutomatedFilingWorkflowTestSuite) Test_Canary_RunsAutomationAndRecordsOutcome() {
+	input := s.input()
+	result := FilingResult{Status: "success", ReferenceNumber: "AR20250101"}
+
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, input.TransactionID).Return(true, nil)
+	s.env.OnActivity(CanaryRolloutActivity, mock.Anything, input).Return(CanaryDecision{Automated: true, Cohort: CanaryCohortAutomated}, nil)
+	s.env.OnActivity(ExecuteAutomatedFilingActivity, mock.Anything, input.ServiceType, input.ClientData).Return(result, nil)
+	s.env.OnActivity(RecordCanaryOutcomeActivity, mock.Anything, input.TransactionID, input.ServiceType, result).Return(nil).Once()
+	s.env.OnActivity(UpdateFilingRecordsActivity, mock.Anything, input.TransactionID, result.ReferenceNumber).Return(nil)
+	s.env.OnActivity(SendFilingSubmittedActivity, mock.Anything, input.UserID, input.ServiceType, result).Return(nil)
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.MatchedBy(func(in CIPCConfirmationInput) bool {
+		return in.Reference == "AR20250101" && in.CompanyRegNumber == input.CompanyRegNumber
+	})).Return(&CIPCFilingOutcome{Status: CIPCOutcomeApproved}, nil).Once()
+
+	s.env.ExecuteWorkflow(AutomatedFilingWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var got FilingResult
+	s.NoError(s.env.GetWorkflowResult(&got))
+	s.Equal("success", got.Status)
+}
+
+// Test_NonCanary_RoutesToManualPath tests that transactions outside the canary never reach the runner.
+func (s *AutomatedFilingWorkflowTestSuite) Test_NonCanary_RoutesToManualPath() {
+	input := s.input()
+	decision := CanaryDecision{Automated: false, Cohort: CanaryCohortManual, Bucket: 87, Percentage: 10}
+
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, input.TransactionID).Return(true, nil)
+	s.env.OnActivity(CanaryRolloutActivity, mock.Anything, input).Return(decision, nil)
+	s.env.OnActivity(RouteToManualFilingActivity, mock.Anything, input, decision).Return("task-1", nil).Once()
+	s.env.OnActivity(RecordCanaryOutcomeActivity, mock.Anything, input.TransactionID, input.ServiceType, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(UpdateFilingRecordsActivity, mock.Anything, input.TransactionID, "AR-MANUAL-1").Return(nil)
+	s.env.OnActivity(SendFilingSubmittedActivity, mock.Anything, input.UserID, input.ServiceType, mock.Anything).Return(nil)
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.MatchedBy(func(in CIPCConfirmationInput) bool {
+		return in.Reference == "AR-MANUAL-1"
+	})).Return(&CIPCFilingOutcome{Status: CIPCOutcomeApproved}, nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(OpsTaskResolvedSignalName, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, Reference: "AR-MANUAL-1", ResolvedBy: "agent@ops"})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(AutomatedFilingWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var got FilingResult
+	s.NoError(s.env.GetWorkflowResult(&got))
+	s.Equal("success", got.Status)
+	s.Equal("AR-MANUAL-1", got.ReferenceNumber)
+}
+
+// Test_AutomationFailure_WaitsForOps tests that a failed filing is handed to ops and resumes on their signal.
+func (s *AutomatedFilingWorkflowTestSuite) Test_AutomationFailure_WaitsForOps() {
+	input := s.input()
+	failed := FilingResult{Status: "failed", Error: "portal unavailable", ErrorCode: RunnerErrPortalUnavailable}
+
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, input.TransactionID).Return(true, nil)
+	s.env.OnActivity(CanaryRolloutActivity, mock.Anything, input).Return(CanaryDecision{Automated: true, Cohort: CanaryCohortAutomated}, nil)
+	s.env.OnActivity(ExecuteAutomatedFilingActivity, mock.Anything, input.ServiceType, input.ClientData).Return(failed, nil)
+	s.env.OnActivity(RecordCanaryOutcomeActivity, mock.Anything, input.TransactionID, input.ServiceType, failed).Return(nil).Once()
+	s.env.OnActivity(AlertOperationsTeamActivity, mock.Anything, input, failed).Return("task-2", nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(OpsTaskResolvedSignalName, OpsTaskResolution{TaskID: "task-2", Status: OpsTaskRejected, Note: "company deregistered", ResolvedBy: "agent@ops"})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(AutomatedFilingWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var got FilingResult
+	s.NoError(s.env.GetWorkflowResult(&got))
+	s.Equal("failed", got.Status)
+	s.Contains(got.Error, "company deregistered")
+}
+
+// Test_OpsTimeout_ExpiresTask tests that a filing giving up on ops closes its task.
+func (s *AutomatedFilingWorkflowTestSuite) Test_OpsTimeout_ExpiresTask() {
+	input := s.input()
+	decision := CanaryDecision{Automated: false, Cohort: CanaryCohortManual}
+
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, input.TransactionID).Return(true, nil)
+	s.env.OnActivity(CanaryRolloutActivity, mock.Anything, input).Return(decision, nil)
+	s.env.OnActivity(RouteToManualFilingActivity, mock.Anything, input, decision).Return("task-1", nil).Once()
+	s.env.OnActivity(ExpireOpsTaskActivity, mock.Anything, "task-1").Return(nil).Once()
+	s.env.OnActivity(RecordCanaryOutcomeActivity, mock.Anything, input.TransactionID, input.ServiceType, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(AutomatedFilingWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var got FilingResult
+	s.NoError(s.env.GetWorkflowResult(&got))
+	s.Equal("failed", got.Status)
+	s.Contains(got.Error, "not resolved in time")
+}
@@ -0,0 +1,234 @@
+package temporal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CIPCCertificateExtractor reads CIPC registration certificates (CoR14.3) and disclosure
+// certificates generated as text PDFs. Values found next to their label score higher than values
+// found elsewhere, and values that fail validation score lower still.
+type CIPCCertificateExtractor struct{}
+
+// Name implements Extractor.
+func (e *CIPCCertificateExtractor) Name() string {
+	return "cipc_certificate_text_pdf"
+}
+
+var (
+	certRegNumberPattern = regexp.MustCompile(`\b(\d{4})\s*/\s*(\d{6})\s*/\s*(\d{2})\b`)
+	certIDNumberPattern  = regexp.MustCompile(`\b\d{13}\b`)
+	certLabelPattern     = regexp.MustCompile(`^[A-Za-z][A-Za-z ()/.]{2,40}:`)
+
+	certRegNumberLabels = []string{"registration number", "enterprise number", "company number"}
+	certNameLabels      = []string{"enterprise name", "company name", "name of company"}
+	certDateLabels      = []string{"registration date", "date of registration", "incorporation date", "date of incorporation"}
+	certStatusLabels    = []string{"enterprise status", "company status", "status"}
+	certDirectorHeads   = regexp.MustCompile(`(?i)^(active\s+)?directors?(\s+details)?\s*:?$`)
+	certSectionEnd      = regexp.MustCompile(`(?i)^(auditors?|company secretary|secretary|members|shareholders|registered office|postal address|financial year end|this certificate|issued|date of issue)\b`)
+
+	certNameSuffix = regexp.MustCompile(`(?i)\b(\(PTY\)\s*LTD|PROPRIETARY LIMITED|LIMITED|LTD|NPC|INC|SOC LTD|CC)\.?$`)
+
+	certDateLayouts = []string{"2006-01-02", "2006/01/02", "02/01/2006", "2 January 2006", "02 January 2006", "January 2, 2006", "2 Jan 2006", "02-01-2006"}
+)
+
+// cipcStatuses are the enterprise statuses CIPC prints on disclosure certificates.
+var cipcStatuses = []string{
+	"in business",
+	"in deregistration process",
+	"ar deregistration process",
+	"final deregistration",
+	"deregistered",
+	"deregistration final",
+	"business rescue",
+	"voluntary liquidation",
+	"compulsory liquidation",
+	"in liquidation",
+	"dissolved",
+	"converted",
+	"amalgamated",
+	"reinstated",
+}
+
+// Extract implements Extractor.
+func (e *CIPCCertificateExtractor) Extract(ctx context.Context, mimeType string, data []byte) (*ExtractionResult, error) {
+	if mimeType != "application/pdf" {
+		return nil, fmt.Errorf("certificate extraction needs a PDF, got %s", mimeType)
+	}
+	text, err := ExtractPDFText(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCIPCCertificateText(text), nil
+}
+
+// ParseCIPCCertificateText extracts certificate fields from the text of a certificate.
+func ParseCIPCCertificateText(text string) *ExtractionResult {
+	lines := strings.Split(text, "\n")
+	result := &ExtractionResult{
+		Extractor:    (&CIPCCertificateExtractor{}).Name(),
+		DocumentKind: certificateKind(text),
+		Fields:       map[string]ExtractedField{},
+	}
+
+	result.Fields[FieldRegistrationNumber] = certRegistrationNumber(lines, text)
+	result.Fields[FieldCompanyName] = certCompanyName(lines)
+	result.Fields[FieldRegistrationDate] = certRegistrationDate(lines)
+	result.Fields[FieldCompanyStatus] = certStatus(lines)
+	result.Directors = certDirectors(lines)
+	return result
+}
+
+func certificateKind(text string) string {
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(lower, "disclosure certificate"):
+		return DocumentTypeDisclosureCertificate
+	case strings.Contains(lower, "cor14.3"), strings.Contains(lower, "registration certificate"):
+		return DocumentTypeRegistrationCert
+	}
+	return DocumentTypeCIPCCertificate
+}
+
+// labelledValue finds the value after one of labels, on the same line or the next.
+func labelledValue(lines []string, labels []string) (string, bool) {
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		for _, label := range labels {
+			if !strings.HasPrefix(lower, label) {
+				continue
+			}
+			value := strings.TrimSpace(line[len(label):])
+			value = strings.TrimSpace(strings.TrimLeft(value, ":-"))
+			if value == "" && i+1 < len(lines) && !certLabelPattern.MatchString(lines[i+1]) {
+				value = strings.TrimSpace(lines[i+1])
+			}
+			if value != "" {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func certRegistrationNumber(lines []string, text string) ExtractedField {
+	normalise := func(m []string) string { return m[1] + "/" + m[2] + "/" + m[3] }
+	confidence := func(regNumber string, base float64) ExtractedField {
+		if _, err := CompanyTypeFromRegNumber(regNumber); err != nil {
+			base -= 0.3
+		}
+		return ExtractedField{Value: regNumber, Confidence: base}
+	}
+
+	if value, ok := labelledValue(lines, certRegNumberLabels); ok {
+		if m := certRegNumberPattern.FindStringSubmatch(value); m != nil {
+			return confidence(normalise(m), 0.95)
+		}
+	}
+	if m := certRegNumberPattern.FindStringSubmatch(text); m != nil {
+		return confidence(normalise(m), 0.6)
+	}
+	return ExtractedField{}
+}
+
+func certCompanyName(lines []string) ExtractedField {
+	value, ok := labelledValue(lines, certNameLabels)
+	if !ok {
+		// Certificates print the name in capitals ending in the company type.
+		for _, line := range lines {
+			if certNameSuffix.MatchString(line) && strings.ToUpper(line) == line && !certLabelPattern.MatchString(line) {
+				return ExtractedField{Value: line, Confidence: 0.5}
+			}
+		}
+		return ExtractedField{}
+	}
+	if certNameSuffix.MatchString(value) {
+		return ExtractedField{Value: value, Confidence: 0.9}
+	}
+	return ExtractedField{Value: value, Confidence: 0.7}
+}
+
+func certRegistrationDate(lines []string) ExtractedField {
+	value, ok := labelledValue(lines, certDateLabels)
+	if !ok {
+		return ExtractedField{}
+	}
+	for _, layout := range certDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return ExtractedField{Value: t.Format("2006-01-02"), Confidence: 0.95}
+		}
+	}
+	return ExtractedField{Value: value, Confidence: 0.4}
+}
+
+func certStatus(lines []string) ExtractedField {
+	value, ok := labelledValue(lines, certStatusLabels)
+	if !ok {
+		return ExtractedField{}
+	}
+	lower := strings.ToLower(value)
+	for _, status := range cipcStatuses {
+		if lower == status {
+			return ExtractedField{Value: value, Confidence: 0.95}
+		}
+	}
+	return ExtractedField{Value: value, Confidence: 0.6}
+}
+
+// certDirectors reads the director section: one director per line, name and usually an ID number.
+func certDirectors(lines []string) []ExtractedDirector {
+	var directors []ExtractedDirector
+	inSection := false
+	for _, line := range lines {
+		if certDirectorHeads.MatchString(line) {
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if certSectionEnd.MatchString(line) || (certLabelPattern.MatchString(line) && !certIDNumberPattern.MatchString(line)) {
+			break
+		}
+		if isDirectorTableHeader(line) {
+			continue
+		}
+
+		director := ExtractedDirector{Confidence: 0.6}
+		name := line
+		if id := certIDNumberPattern.FindString(line); id != "" {
+			director.IDNumber = id
+			name = strings.Replace(name, id, " ", 1)
+			if ValidateSAIDNumber(id) == nil {
+				director.Confidence = 0.9
+			} else {
+				director.Confidence = 0.5
+			}
+		}
+		director.FullName = cleanDirectorName(name)
+		if director.FullName == "" {
+			continue
+		}
+		directors = append(directors, director)
+	}
+	return directors
+}
+
+func isDirectorTableHeader(line string) bool {
+	lower := strings.ToLower(line)
+	return strings.Contains(lower, "id number") || strings.Contains(lower, "surname") || lower == "name" || strings.HasPrefix(lower, "name ")
+}
+
+var directorNoise = regexp.MustCompile(`(?i)\(?\b(director|active|appointed|\d{4}-\d{2}-\d{2}|\d{2}/\d{2}/\d{4})\b\)?`)
+
+func cleanDirectorName(s string) string {
+	s = directorNoise.ReplaceAllString(s, " ")
+	s = strings.Trim(strings.Join(strings.Fields(s), " "), " ,-:")
+	if len(s) < 3 {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,407 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Outcomes of a submitted filing, as reported by RunnerActionStatus and stored in
+// cipc_filings.status once final.
+const (
+	CIPCOutcomePending  = "pending"
+	CIPCOutcomeApproved = "approved"
+	CIPCOutcomeRejected = "rejected"
+)
+
+// DocumentTypeCIPCConfirmation is the document type confirmations are stored under in the vault.
+const DocumentTypeCIPCConfirmation = "cipc_confirmation"
+
+const (
+	// cipcOutcomeDeadline is how long we wait for CIPC to approve or reject a submitted filing.
+	cipcOutcomeDeadline = 21 * 24 * time.Hour
+	// cipcFirstPollDelay gives CIPC time to pick a submission up before the first check.
+	cipcFirstPollDelay = time.Hour
+	// cipcPollInterval is how often CIPC is checked after that.
+	cipcPollInterval = 4 * time.Hour
+)
+
+// CIPCConfirmationInput is the input for CIPCConfirmationWorkflow.
+type CIPCConfirmationInput struct {
+	TransactionID    string `json:"transaction_id"`
+	UserID           string `json:"user_id"`
+	ServiceType      string `json:"service_type"`
+	CompanyRegNumber string `json:"company_reg_number,omitempty"`
+	// Reference is the submission reference the filing was given when it was submitted.
+	Reference string `json:"reference"`
+	// CIPCFilingID and CompanyID identify the cipc_filings row when the caller already recorded
+	// the submission; otherwise the workflow records it.
+	CIPCFilingID string `json:"cipc_filing_id,omitempty"`
+	CompanyID    string `json:"company_id,omitempty"`
+	// Silent leaves telling the customer about the outcome to the caller.
+	Silent bool `json:"silent,omitempty"`
+}
+
+// CIPCFilingOutcome is what CIPC decided about a submitted filing.
+type CIPCFilingOutcome struct {
+	Status          string `json:"status"`
+	Reference       string `json:"reference"`
+	CIPCReference   string `json:"cipc_reference,omitempty"`
+	RejectionReason string `json:"rejection_reason,omitempty"`
+	// ConfirmationDocumentID is the vault ID of CIPC's confirmation, when one was issued.
+	ConfirmationDocumentID string `json:"confirmation_document_id,omitempty"`
+	// ApprovedName is the name CIPC reserved, for approved name reservations.
+	ApprovedName string `json:"approved_name,omitempty"`
+	// RegistrationNumber is the number CIPC issued, for approved company registrations.
+	RegistrationNumber string `json:"registration_number,omitempty"`
+}
+
+// CIPCFilingRecord identifies a filing in cipc_filings.
+type CIPCFilingRecord struct {
+	ID        string `json:"id"`
+	CompanyID string `json:"company_id"`
+}
+
+// CIPCConfirmationWorkflow follows a submitted filing until CIPC approves or rejects it. It polls
+// CIPC through the runner, records the outcome and CIPC's reference on cipc_filings, stores the
+// confirmation document in the vault and tells the customer the outcome. Ops can also deliver
+// the outcome with CIPCOutcomeSignalName, e.g. for filings CIPC decided by email.
+func CIPCConfirmationWorkflow(ctx workflow.Context, input CIPCConfirmationInput) (*CIPCFilingOutcome, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting CIPCConfirmationWorkflow", "TransactionID", input.TransactionID, "Reference", input.Reference)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+	pollCtx := withPortalSession(workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    30 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    5 * time.Minute,
+			MaximumAttempts:    3,
+		},
+	}), "", PortalPriorityBackground)
+
+	// Step 1: Record the submission in cipc_filings. A company registration has no company to
+	// record it against until CIPC registers it, so its caller records it afterwards.
+	if input.CIPCFilingID == "" && input.CompanyRegNumber != "" {
+		var record CIPCFilingRecord
+		if err := workflow.ExecuteActivity(ctx, RecordCIPCSubmissionActivity, input).Get(ctx, &record); err != nil {
+			// Keep following the filing so the customer still hears the outcome.
+			logger.Error("Failed to record CIPC submission", "error", err)
+		}
+		input.CIPCFilingID, input.CompanyID = record.ID, record.CompanyID
+	}
+
+	// Step 2: Poll CIPC until it decides, or take the outcome from ops
+	outcome := CIPCFilingOutcome{Status: CIPCOutcomePending, Reference: input.Reference}
+	outcomeChan := workflow.GetSignalChannel(ctx, CIPCOutcomeSignalName)
+	deadline := workflow.Now(ctx).Add(cipcOutcomeDeadline)
+	wait := cipcFirstPollDelay
+
+	for outcome.Status == CIPCOutcomePending {
+		remaining := deadline.Sub(workflow.Now(ctx))
+		if remaining <= 0 {
+			logger.Warn("CIPC did not decide on filing in time", "reference", input.Reference)
+			handOverUndecidedFiling(ctx, input)
+			return &outcome, nil
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wait)
+		var signal CIPCOutcomeSignal
+		signalled := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(outcomeChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &signal)
+			signalled = true
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {})
+		selector.Select(ctx)
+		cancelTimer()
+		wait = cipcPollInterval
+
+		if signalled {
+			outcome = outcomeFromSignal(input, signal)
+			break
+		}
+		if !workflow.Now(ctx).Before(deadline) {
+			continue
+		}
+
+		var polled CIPCFilingOutcome
+		if err := workflow.ExecuteActivity(pollCtx, PollCIPCFilingActivity, input).Get(ctx, &polled); err != nil {
+			logger.Warn("Failed to check CIPC filing status", "reference", input.Reference, "error", err)
+			continue
+		}
+		outcome = polled
+	}
+
+	// Step 3: Record the outcome
+	if input.CIPCFilingID != "" {
+		if err := workflow.ExecuteActivity(ctx, RecordCIPCOutcomeActivity, input.CIPCFilingID, outcome).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to record CIPC outcome: %w", err)
+		}
+	}
+
+	// Step 4: Tell the customer
+	if !input.Silent {
+		notifyUser(ctx, input.UserID, cipcOutcomeMessage(input.ServiceType, outcome))
+	}
+	return &outcome, nil
+}
+
+// handOverUndecidedFiling opens an ops task to chase CIPC for a filing it has not decided by the
+// deadline and, unless the caller handles messaging, tells the customer it is being followed up.
+func handOverUndecidedFiling(ctx workflow.Context, input CIPCConfirmationInput) {
+	if err := workflow.ExecuteActivity(ctx, OpenUndecidedFilingTaskActivity, input).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to open ops task for undecided filing", "reference", input.Reference, "error", err)
+	}
+	if !input.Silent {
+		notifyUser(ctx, input.UserID, fmt.Sprintf("⏳ *CIPC hasn't decided on your %s yet*\n\nReference: %s\n\nOur team is following it up with CIPC and we'll message you as soon as it's decided.",
+			serviceDisplayName(input.ServiceType), input.Reference))
+	}
+}
+
+func outcomeFromSignal(input CIPCConfirmationInput, signal CIPCOutcomeSignal) CIPCFilingOutcome {
+	outcome := CIPCFilingOutcome{Status: CIPCOutcomeRejected, Reference: input.Reference, RejectionReason: signal.RejectionReason}
+	if signal.Approved {
+		outcome = CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: input.Reference, CIPCReference: signal.Reference,
+			RegistrationNumber: signal.RegistrationNumber}
+	}
+	return outcome
+}
+
+func cipcOutcomeMessage(serviceType string, outcome CIPCFilingOutcome) string {
+	service := serviceDisplayName(serviceType)
+	if outcome.Status == CIPCOutcomeRejected {
+		reason := outcome.RejectionReason
+		if reason == "" {
+			reason = "CIPC did not give a reason"
+		}
+		return fmt.Sprintf("❌ *CIPC rejected your %s*\n\nReference: %s\nReason: %s\n\nReply 'HELP' and we'll sort it out with you.",
+			service, outcome.Reference, reason)
+	}
+
+	reference := outcome.CIPCReference
+	if reference == "" {
+		reference = outcome.Reference
+	}
+	message := fmt.Sprintf("✅ *CIPC approved your %s*\n\nCIPC reference: %s", service, reference)
+	if outcome.ConfirmationDocumentID != "" {
+		message += "\n\nCIPC's confirmation has been saved with your company documents."
+	}
+	return message
+}
+
+// serviceDisplayName names a service type in customer messages.
+func serviceDisplayName(serviceType string) string {
+	switch serviceType {
+	case "annual_return":
+		return "annual return"
+	case "beneficial_ownership":
+		return "beneficial ownership declaration"
+	case "director_amendment":
+		return "director change"
+	case "afs_submission":
+		return "financial statements submission"
+	case "bbee_certificate":
+		return "B-BBEE certificate"
+	case "company_update":
+		return "company details update"
+	case "company_registration":
+		return "company registration"
+	case ReinstatementServiceType:
+		return "company reinstatement"
+	}
+	return "filing"
+}
+
+// RecordCIPCSubmissionActivity records a submitted filing in cipc_filings against the company
+// with the given registration number. Recording the same reference again returns the existing row.
+func RecordCIPCSubmissionActivity(ctx context.Context, input CIPCConfirmationInput) (*CIPCFilingRecord, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var record CIPCFilingRecord
+	err = db.QueryRowContext(ctx, `
+		SELECT id, company_id FROM cipc_filings WHERE cipc_reference = $1 AND filing_type = $2
+	`, input.Reference, input.ServiceType).Scan(&record.ID, &record.CompanyID)
+	if err == nil {
+		return &record, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to look up CIPC filing: %w", err)
+	}
+
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO cipc_filings (company_id, filing_type, status, submitted_at, cipc_reference)
+		SELECT id, $2, 'submitted', NOW(), $3 FROM companies WHERE registration_number = $1
+		RETURNING id, company_id
+	`, input.CompanyRegNumber, input.ServiceType, input.Reference).Scan(&record.ID, &record.CompanyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("no company with registration number %q", input.CompanyRegNumber), "CompanyNotFound", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record CIPC filing: %w", err)
+	}
+	return &record, nil
+}
+
+// PollCIPCFilingActivity asks the runner for a filing's outcome. When CIPC has approved it, the
+// confirmation the runner downloaded is stored in the vault and linked to the filing.
+func PollCIPCFilingActivity(ctx context.Context, input CIPCConfirmationInput) (*CIPCFilingOutcome, error) {
+	logger := activity.GetLogger(ctx)
+
+	sessionCtx, release, err := acquirePortalSession(ctx, func() { activity.RecordHeartbeat(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	runner := NewRunnerClient()
+	runner.OnLog = func(line string) { logger.Debug("runner", "line", line) }
+	status, err := runner.Run(sessionCtx, RunnerRequest{
+		RequestID:   activity.GetInfo(ctx).WorkflowExecution.ID,
+		ServiceType: input.ServiceType,
+		ClientData:  map[string]interface{}{"reference": input.Reference, "company_reg_number": input.CompanyRegNumber},
+		Action:      RunnerActionStatus,
+	})
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := CIPCOutcomeFromRunner(input.Reference, status)
+	logger.Info("Checked CIPC filing status", "reference", input.Reference, "status", outcome.Status)
+	if outcome.Status != CIPCOutcomeApproved || input.CompanyID == "" {
+		return outcome, nil
+	}
+
+	for _, artifact := range status.Artifacts {
+		if artifact.Kind != RunnerArtifactConfirmation {
+			continue
+		}
+		documentID, err := storeCIPCConfirmation(ctx, input, artifact)
+		if err != nil {
+			return nil, err
+		}
+		outcome.ConfirmationDocumentID = documentID
+	}
+	return outcome, nil
+}
+
+// CIPCOutcomeFromRunner reads the result of a RunnerActionStatus run.
+func CIPCOutcomeFromRunner(reference string, status *RunnerOutcome) *CIPCFilingOutcome {
+	outcome := &CIPCFilingOutcome{
+		Status:             status.Result.Status,
+		Reference:          reference,
+		CIPCReference:      status.Result.CIPCReference,
+		RejectionReason:    status.Result.RejectionReason,
+		ApprovedName:       status.Result.ApprovedName,
+		RegistrationNumber: status.Result.RegistrationNumber,
+	}
+	switch outcome.Status {
+	case CIPCOutcomeApproved, CIPCOutcomeRejected:
+	default:
+		outcome.Status = CIPCOutcomePending
+	}
+	return outcome
+}
+
+func storeCIPCConfirmation(ctx context.Context, input CIPCConfirmationInput, artifact RunnerArtifact) (string, error) {
+	data, err := os.ReadFile(artifact.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CIPC confirmation: %w", err)
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	store, err := NewBlobStore()
+	if err != nil {
+		return "", err
+	}
+	doc, err := NewDocumentVault(db, store).Upload(ctx, DocumentUpload{
+		CompanyID:    input.CompanyID,
+		CIPCFilingID: input.CIPCFilingID,
+		FileName:     filepath.Base(artifact.Path),
+		DocumentType: DocumentTypeCIPCConfirmation,
+	}, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to store CIPC confirmation: %w", err)
+	}
+	return doc.ID, nil
+}
+
+// OpenUndecidedFilingTaskActivity opens a cipc_outcome_overdue ops task for a filing CIPC has not
+// decided within cipcOutcomeDeadline. Nothing waits on it; ops chase CIPC and record the outcome.
+// Returns the task ID.
+func OpenUndecidedFilingTaskActivity(ctx context.Context, input CIPCConfirmationInput) (string, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	filing := AutomatedFilingInput{
+		TransactionID:    input.TransactionID,
+		ServiceType:      input.ServiceType,
+		UserID:           input.UserID,
+		CompanyRegNumber: input.CompanyRegNumber,
+		ClientData: map[string]interface{}{
+			"reference":      input.Reference,
+			"cipc_filing_id": input.CIPCFilingID,
+		},
+	}
+	result := FilingResult{
+		ErrorCode: "CIPC_NO_DECISION",
+		Error:     fmt.Sprintf("CIPC has not decided on %s after %d days", input.Reference, int(cipcOutcomeDeadline.Hours()/24)),
+	}
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return createOpsTask(ctx, db, OpsTaskCIPCOutcomeOverdue, filing, result, execution.ID, execution.RunID)
+}
+
+// RecordCIPCOutcomeActivity stores CIPC's decision and reference on cipc_filings.
+func RecordCIPCOutcomeActivity(ctx context.Context, cipcFilingID string, outcome CIPCFilingOutcome) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE cipc_filings
+		SET status = $2, cipc_reference = COALESCE(NULLIF($3, ''), cipc_reference), rejection_reason = NULLIF($4, ''),
+		    confirmation_document_id = NULLIF($5, ''), decided_at = NOW()
+		WHERE id = $1
+	`, cipcFilingID, outcome.Status, outcome.CIPCReference, outcome.RejectionReason, outcome.ConfirmationDocumentID)
+	if err != nil {
+		return fmt.Errorf("failed to update CIPC filing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCIPCOutcomeFromRunner(t *testing.T) {
+	runner := fakeRunner(t, `read request
+echo '{"v":1,"type":"progress","step":"checking_status"}'
+echo '{"v":1,"type":"result","result":{"status":"rejected","reference_number":"AR1","rejection_reason":"Financial year end does not match"}}'
+`)
+	status, err := runner.Run(context.Background(), RunnerRequest{ServiceType: "annual_return", Action: RunnerActionStatus})
+	require.NoError(t, err)
+
+	outcome := CIPCOutcomeFromRunner("AR1", status)
+	assert.Equal(t, CIPCOutcomeRejected, outcome.Status)
+	assert.Equal(t, "Financial year end does not match", outcome.RejectionReason)
+
+	// Anything but a decision keeps the filing pending.
+	outcome = CIPCOutcomeFromRunner("AR1", &RunnerOutcome{Result: FilingResult{Status: "in_review"}})
+	assert.Equal(t, CIPCOutcomePending, outcome.Status)
+}
+
+// CIPCConfirmationWorkflowTestSuite is the test suite for CIPCConfirmationWorkflow.
+type CIPCConfirmationWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestCIPCConfirmationWorkflowTestSuite runs the test suite.
+func TestCIPCConfirmationWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(CIPCConfirmationWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *CIPCConfirmationWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, "user-1", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *CIPCConfirmationWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) input() CIPCConfirmationInput {
+	return CIPCConfirmationInput{
+		TransactionID:    "tx-1",
+		UserID:           "user-1",
+		ServiceType:      "annual_return",
+		CompanyRegNumber: "2020/123456/07",
+		Reference:        "AR20250101",
+	}
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) recordsSubmission() CIPCConfirmationInput {
+	recorded := s.input()
+	recorded.CIPCFilingID, recorded.CompanyID = "filing-1", "company-1"
+	s.env.OnActivity(RecordCIPCSubmissionActivity, mock.Anything, s.input()).
+		Return(&CIPCFilingRecord{ID: "filing-1", CompanyID: "company-1"}, nil).Once()
+	return recorded
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) Test_PollsUntilApproved() {
+	recorded := s.recordsSubmission()
+	approved := CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: "AR20250101", CIPCReference: "CIPC-99", ConfirmationDocumentID: "doc-1"}
+	s.env.OnActivity(PollCIPCFilingActivity, mock.Anything, recorded).
+		Return(&CIPCFilingOutcome{Status: CIPCOutcomePending, Reference: "AR20250101"}, nil).Twice()
+	s.env.OnActivity(PollCIPCFilingActivity, mock.Anything, recorded).Return(&approved, nil).Once()
+	s.env.OnActivity(RecordCIPCOutcomeActivity, mock.Anything, "filing-1", approved).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(CIPCConfirmationWorkflow, s.input())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var outcome CIPCFilingOutcome
+	s.NoError(s.env.GetWorkflowResult(&outcome))
+	s.Equal(approved, outcome)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "CIPC approved your annual return")
+	s.Contains(s.messages[0], "CIPC-99")
+	s.Contains(s.messages[0], "saved with your company documents")
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) Test_RejectionReasonIsSentToCustomer() {
+	recorded := s.recordsSubmission()
+	rejected := CIPCFilingOutcome{Status: CIPCOutcomeRejected, Reference: "AR20250101", RejectionReason: "Turnover not declared"}
+	s.env.OnActivity(PollCIPCFilingActivity, mock.Anything, recorded).Return(&rejected, nil).Once()
+	s.env.OnActivity(RecordCIPCOutcomeActivity, mock.Anything, "filing-1", rejected).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(CIPCConfirmationWorkflow, s.input())
+
+	var outcome CIPCFilingOutcome
+	s.NoError(s.env.GetWorkflowResult(&outcome))
+	s.Equal(CIPCOutcomeRejected, outcome.Status)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "CIPC rejected your annual return")
+	s.Contains(s.messages[0], "Turnover not declared")
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) Test_OpsCanDeliverTheOutcome() {
+	input := s.input()
+	input.CIPCFilingID, input.CompanyID, input.Silent = "filing-1", "company-1", true
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(CIPCOutcomeSignalName, CIPCOutcomeSignal{Reference: "CIPC-7", Approved: true})
+	}, 30*time.Minute)
+	s.env.OnActivity(RecordCIPCOutcomeActivity, mock.Anything, "filing-1", CIPCFilingOutcome{
+		Status: CIPCOutcomeApproved, Reference: "AR20250101", CIPCReference: "CIPC-7",
+	}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(CIPCConfirmationWorkflow, input)
+
+	var outcome CIPCFilingOutcome
+	s.NoError(s.env.GetWorkflowResult(&outcome))
+	s.Equal(CIPCOutcomeApproved, outcome.Status)
+	s.Empty(s.messages, "silent confirmations leave messaging to the caller")
+}
+
+func (s *CIPCConfirmationWorkflowTestSuite) Test_StaysPendingAfterDeadline() {
+	recorded := s.recordsSubmission()
+	s.env.OnActivity(PollCIPCFilingActivity, mock.Anything, recorded).
+		Return(&CIPCFilingOutcome{Status: CIPCOutcomePending, Reference: "AR20250101"}, nil)
+	s.env.OnActivity(OpenUndecidedFilingTaskActivity, mock.Anything, recorded).Return("task-1", nil).Once()
+
+	s.env.ExecuteWorkflow(CIPCConfirmationWorkflow, s.input())
+
+	var outcome CIPCFilingOutcome
+	s.NoError(s.env.GetWorkflowResult(&outcome))
+	s.Equal(CIPCOutcomePending, outcome.Status)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "following it up with CIPC")
+}
@@ -1,11 +1,13 @@
-
 package temporal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
@@ -15,12 +17,19 @@ import (
 
 // FilingWorkflowInput represents the input for filing workflows
 type FilingWorkflowInput struct {
-	TransactionID   string                 `json:"transaction_id"`
-	UserID          string                 `json:"user_id"`
-	ServiceType     string                 `json:"service_type"`
-	FilingData      map[string]interface{} `json:"filing_data"`
-	IsUrgent        bool                   `json:"is_urgent"`
-	CompanyRegNumber string                `json:"company_reg_number"`
+	TransactionID    string                 `json:"transaction_id"`
+	UserID           string                 `json:"user_id"`
+	ServiceType      string                 `json:"service_type"`
+	FilingData       map[string]interface{} `json:"filing_data"`
+	CompanyRegNumber string                 `json:"company_reg_number"`
+	// IsUrgent filings run against the service's UrgentFilingSLA and must be started on
+	// FilingTaskQueue(true).
+	IsUrgent bool `json:"is_urgent"`
+	// RetryAttempt counts retries the customer asked for after a failed filing.
+	RetryAttempt int `json:"retry_attempt,omitempty"`
+	// CallerFollowsUp is set by parents that follow the submitted filing with their own
+	// CIPCConfirmationWorkflow; otherwise the filing starts one itself.
+	CallerFollowsUp bool `json:"caller_follows_up,omitempty"`
 }
 
 // FilingWorkflowResult represents the result of filing workflows
@@ -30,11 +39,25 @@ type FilingWorkflowResult struct {
 	ErrorMessage    string `json:"error_message,omitempty"`
 }
 
-// OTPSignal defines the structure for the OTP signal
+// OTPSignal defines the structure for the OTP signal. It is also the argument of the
+// SubmitOTPUpdateName Update.
 type OTPSignal struct {
-	OTP string
+	OTP OTPCode
+}
+
+// CIPCSubmissionInput is the input of SubmitToCIPCActivity.
+type CIPCSubmissionInput struct {
+	ServiceType string                 `json:"service_type"`
+	OTP         OTPCode                `json:"otp"`
+	Data        map[string]interface{} `json:"data"`
+	// CompanyRegNumber is the company being filed for. Documents uploaded with the filing must
+	// belong to it.
+	CompanyRegNumber string `json:"company_reg_number,omitempty"`
 }
 
+// OTPTimeoutError is the error type returned when no usable OTP arrives in time.
+const OTPTimeoutError = "OTPTimeout"
+
 // --- The Consolidated Workflow ---
 
 // CombinedFilingWorkflow is the single, authoritative workflow for the entire filing process.
@@ -53,49 +76,89 @@ func CombinedFilingWorkflow(ctx workflow.Context, params FilingWorkflowInput) (*
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
+	progress := newFilingProgress(ctx, params)
+	if params.IsUrgent {
+		progress.sla = startFilingSLA(ctx, params)
+		defer func() { progress.sla.finish(ctx, progress.state.Status) }()
+	}
+	if err := workflow.ExecuteActivity(ctx, RecordFilingWorkflowActivity, params.TransactionID).Get(ctx, nil); err != nil {
+		logger.Warn("Failed to link transaction to filing workflow", "error", err)
+	}
+
 	// Step 1: Validate Payment
+	progress.begin(ctx, FilingStepValidatePayment, FilingWaitingOnPayment)
 	var paymentValid bool
 	if err := workflow.ExecuteActivity(ctx, ValidatePaymentActivity, params.TransactionID).Get(ctx, &paymentValid); err != nil {
-		return nil, fmt.Errorf("payment validation activity failed: %w", err)
+		return nil, progress.fail(ctx, fmt.Errorf("payment validation activity failed: %w", err))
 	}
 	if !paymentValid {
+		progress.fail(ctx, errors.New("payment not confirmed"))
 		return &FilingWorkflowResult{Success: false, ErrorMessage: "Payment not confirmed"}, nil
 	}
 
 	// Step 2: Extract Document Data
+	progress.begin(ctx, FilingStepExtractDocuments, "")
 	var extractedData map[string]interface{}
 	if err := workflow.ExecuteActivity(ctx, ExtractDocumentDataActivity, params).Get(ctx, &extractedData); err != nil {
-		return nil, fmt.Errorf("document extraction activity failed: %w", err)
+		return nil, progress.fail(ctx, fmt.Errorf("document extraction activity failed: %w", err))
 	}
 
 	// Step 3: Request OTP from User
-	if err := workflow.ExecuteActivity(ctx, RequestOTPActivity, params.UserID).Get(ctx, nil); err != nil {
-		return nil, fmt.Errorf("RequestOTPActivity failed: %w", err)
+	progress.begin(ctx, FilingStepRequestOTP, "")
+	otpDescription := fmt.Sprintf("CIPC OTP for your %s filing", strings.ReplaceAll(params.ServiceType, "_", " "))
+	if params.CompanyRegNumber != "" {
+		// Accountants filing batches wait on many OTPs at once.
+		otpDescription += " for " + params.CompanyRegNumber
 	}
-
-	// Step 4: Wait for the OTP signal
-	var otpSignal OTPSignal
-	otpChan := workflow.GetSignalChannel(ctx, "UserSentOTP")
-	signalReceived := otpChan.ReceiveWithTimeout(ctx, time.Minute*10, &otpSignal)
-
-	if !signalReceived {
-		timeoutMessage := "We didn't receive the OTP in time. Please start the process again."
-		_ = workflow.ExecuteActivity(ctx, SendWhatsAppMessageActivity, params.UserID, timeoutMessage).Get(ctx, nil)
-		return nil, temporal.NewTimeoutError("user did not provide OTP in time", temporal.TimeoutTypeStartToClose)
+	otp := newOTPSession(ctx, params.UserID, otpDescription)
+	progress.otp = otp
+	if err := otp.request(ctx); err != nil {
+		return nil, progress.fail(ctx, fmt.Errorf("RequestOTPActivity failed: %w", err))
 	}
 
-	// Step 5: Submit to CIPC with OTP
+	submitCtx := withPortalSession(workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 15,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy:         ao.RetryPolicy,
+	}), portalCustomerCode(params.FilingData), portalPriority(params.IsUrgent))
 	var filingReference string
-	submissionInput := map[string]interface{}{
-		"ServiceType": params.ServiceType,
-		"OTP":         otpSignal.OTP,
-		"Data":        extractedData,
-	}
-	if err := workflow.ExecuteActivity(ctx, SubmitToCIPCActivity, submissionInput).Get(ctx, &filingReference); err != nil {
-		return nil, fmt.Errorf("CIPC submission activity failed: %w", err)
+	for {
+		// Step 4: Wait for a valid OTP; typos and expired codes keep the workflow waiting
+		progress.begin(ctx, FilingStepWaitForOTP, FilingWaitingOnOTP)
+		code, err := otp.wait(ctx)
+		otp.endConversation(ctx)
+		var timeoutErr *temporal.ApplicationError
+		if errors.As(err, &timeoutErr) && timeoutErr.Type() == OTPTimeoutError {
+			return compensateFiling(ctx, params, progress, CompensationReasonOTPTimeout, err)
+		}
+		if err != nil {
+			return nil, progress.fail(ctx, err)
+		}
+
+		// Step 5: Submit to CIPC with OTP
+		progress.begin(ctx, FilingStepSubmitToCIPC, FilingWaitingOnCIPC)
+		submissionInput := CIPCSubmissionInput{
+			ServiceType:      params.ServiceType,
+			OTP:              code,
+			Data:             extractedData,
+			CompanyRegNumber: params.CompanyRegNumber,
+		}
+		err = workflow.ExecuteActivity(submitCtx, SubmitToCIPCActivity, submissionInput).Get(ctx, &filingReference)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.Type() == RunnerErrOTPRejected {
+			logger.Info("CIPC rejected the OTP, waiting for another")
+			progress.recordError(ctx, "CIPC did not accept the OTP")
+			otp.rejected(ctx)
+			continue
+		}
+		if err != nil {
+			return compensateFiling(ctx, params, progress, CompensationReasonSubmissionFailed, fmt.Errorf("CIPC submission activity failed: %w", err))
+		}
+		break
 	}
 
 	// Step 6: Update User Records to PROCESSING_COMPLETE
+	progress.begin(ctx, FilingStepUpdateRecords, "")
 	updateRecordInput := map[string]interface{}{
 		"UserID":          params.UserID,
 		"TransactionID":   params.TransactionID,
@@ -104,18 +167,243 @@ func CombinedFilingWorkflow(ctx workflow.Context, params FilingWorkflowInput) (*
 	}
 	if err := workflow.ExecuteActivity(ctx, UpdateUserRecordsActivity, updateRecordInput).Get(ctx, nil); err != nil {
 		logger.Warn("Failed to update user records", "error", err)
+		progress.recordError(ctx, "Failed to update user records")
+	}
+	if params.RetryAttempt > 0 {
+		// The retry the customer chose instead of a refund or credit went through.
+		if err := workflow.ExecuteActivity(ctx, SettleRetriedFilingActivity, params.TransactionID).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to settle retried transaction", "error", err)
+			progress.recordError(ctx, "Failed to settle retried transaction")
+		}
 	}
 
 	// Step 7: Send Final Confirmation
+	progress.begin(ctx, FilingStepSendConfirmation, "")
 	confirmationMsg := fmt.Sprintf("✅ *Filing Complete!*\n\nService: %s\nReference: %s", params.ServiceType, filingReference)
 	_ = workflow.ExecuteActivity(ctx, SendWhatsAppMessageActivity, params.UserID, confirmationMsg).Get(ctx, nil)
 
+	// Step 8: Follow the filing until CIPC decides, outliving this workflow
+	if !params.CallerFollowsUp {
+		cwo := workflow.ChildWorkflowOptions{
+			WorkflowID: "cipc-confirmation-" + params.TransactionID,
+			// Urgent workers only file; the follow-up runs with the routine work.
+			TaskQueue:         TaskQueue,
+			ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+		}
+		child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CIPCConfirmationWorkflow, CIPCConfirmationInput{
+			TransactionID:    params.TransactionID,
+			UserID:           params.UserID,
+			ServiceType:      params.ServiceType,
+			CompanyRegNumber: params.CompanyRegNumber,
+			Reference:        filingReference,
+		})
+		if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+			logger.Warn("Failed to start CIPC confirmation polling", "error", err)
+			progress.recordError(ctx, "Failed to start following the filing up with CIPC")
+		}
+	}
+	progress.complete(ctx, filingReference)
+
 	return &FilingWorkflowResult{
 		Success:         true,
 		FilingReference: filingReference,
 	}, nil
 }
 
+// --- OTP Session ---
+
+// otpSession tracks the OTP the workflow is waiting for. Codes arrive through the
+// SubmitOTPUpdateName Update, whose validator rejects malformed or expired codes before they reach
+// history, or through the legacy OTPSignalName signal. Users can ask for a new code through
+// ResendOTPUpdateName up to MaxOTPResends times.
+type otpSession struct {
+	userID string
+	// description is shown when the reply router has to ask which prompt a reply is for.
+	description    string
+	conversationID string
+	code           OTPCode
+	waiting        bool
+	expiresAt      time.Time
+	resends        int
+	deadline       time.Time
+	// options are applied to activities run from Update handlers, whose context does not carry
+	// the workflow's activity options.
+	options workflow.ActivityOptions
+}
+
+func newOTPSession(ctx workflow.Context, userID, description string) *otpSession {
+	s := &otpSession{
+		userID:      userID,
+		description: description,
+		deadline:    workflow.Now(ctx).Add(OTPWaitTimeout),
+		options:     workflow.GetActivityOptions(ctx),
+	}
+	logger := workflow.GetLogger(ctx)
+
+	err := workflow.SetUpdateHandlerWithOptions(ctx, SubmitOTPUpdateName,
+		func(ctx workflow.Context, sig OTPSignal) (OTPUpdateResult, error) {
+			s.accept(NormalizeOTP(string(sig.OTP)))
+			return s.result(true, "OTP received, submitting to CIPC"), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, sig OTPSignal) error {
+				return s.validate(ctx, NormalizeOTP(string(sig.OTP)))
+			},
+		})
+	if err != nil {
+		logger.Error("Failed to register OTP update handler", "error", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, ResendOTPUpdateName,
+		func(ctx workflow.Context) (OTPUpdateResult, error) {
+			s.resends++
+			if err := s.request(workflow.WithActivityOptions(ctx, s.options)); err != nil {
+				s.resends--
+				return OTPUpdateResult{}, err
+			}
+			return s.result(false, "A new OTP has been requested"), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context) error {
+				if !s.waiting {
+					return errors.New("no OTP is needed right now")
+				}
+				if s.resends >= MaxOTPResends {
+					return fmt.Errorf("the OTP can only be resent %d times", MaxOTPResends)
+				}
+				return nil
+			},
+		})
+	if err != nil {
+		logger.Error("Failed to register OTP resend handler", "error", err)
+	}
+
+	otpChan := workflow.GetSignalChannel(ctx, OTPSignalName)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var sig OTPSignal
+			otpChan.Receive(ctx, &sig)
+			code := NormalizeOTP(string(sig.OTP))
+			if err := s.validate(ctx, code); err != nil {
+				s.notify(ctx, fmt.Sprintf("⚠️ That code didn't work: %s. Please send the 6-digit OTP again.", err))
+				continue
+			}
+			s.accept(code)
+		}
+	})
+	return s
+}
+
+// request asks CIPC to send an OTP and restarts the expiry clock.
+func (s *otpSession) request(ctx workflow.Context) error {
+	if err := workflow.ExecuteActivity(ctx, RequestOTPActivity, s.userID).Get(ctx, nil); err != nil {
+		return err
+	}
+	s.code = ""
+	s.waiting = true
+	s.expiresAt = workflow.Now(ctx).Add(OTPValidity)
+	s.openConversation(ctx)
+	return nil
+}
+
+// openConversation lets the reply router deliver OTP digits and RESEND to this workflow.
+func (s *otpSession) openConversation(ctx workflow.Context) {
+	if s.conversationID != "" {
+		return
+	}
+	s.conversationID = openConversation(ctx, ConversationPrompt{
+		UserID:      s.userID,
+		Awaiting:    ConversationAwaitingOTP,
+		Description: s.description,
+		ExpiresAt:   s.deadline,
+	})
+}
+
+func (s *otpSession) endConversation(ctx workflow.Context) {
+	endConversation(ctx, s.conversationID)
+	s.conversationID = ""
+}
+
+func (s *otpSession) validate(ctx workflow.Context, code string) error {
+	if !s.waiting {
+		return errors.New("no OTP is needed right now")
+	}
+	if err := ValidateOTPFormat(code); err != nil {
+		return err
+	}
+	if workflow.Now(ctx).After(s.expiresAt) {
+		return errors.New("the OTP has expired, reply RESEND for a new one")
+	}
+	return nil
+}
+
+func (s *otpSession) accept(code string) {
+	s.code = OTPCode(code)
+	s.waiting = false
+}
+
+// rejected is called when CIPC refuses the code; the user can send a corrected one.
+func (s *otpSession) rejected(ctx workflow.Context) {
+	s.code = ""
+	s.waiting = true
+	s.openConversation(ctx)
+	s.notify(ctx, "⚠️ CIPC did not accept that OTP. Please check the SMS and send the code again, or reply RESEND for a new one.")
+}
+
+// wait blocks until a valid OTP arrives. When the current code expires the user is told how to get
+// a new one; the workflow only gives up after OTPWaitTimeout, or at expiry when no resends are left.
+func (s *otpSession) wait(ctx workflow.Context) (OTPCode, error) {
+	expiryNotified := time.Time{}
+	for {
+		now := workflow.Now(ctx)
+		if s.code != "" {
+			return s.code, nil
+		}
+		if !now.Before(s.deadline) {
+			return "", s.timeout(ctx, "We didn't receive the OTP in time. Please start the process again.")
+		}
+
+		if !now.Before(s.expiresAt) && !expiryNotified.Equal(s.expiresAt) {
+			if s.resends >= MaxOTPResends {
+				return "", s.timeout(ctx, "Your OTP has expired and no more resends are available. Please start the process again.")
+			}
+			expiryNotified = s.expiresAt
+			s.notify(ctx, fmt.Sprintf("⏰ Your OTP has expired. Reply RESEND for a new one (%d left).", MaxOTPResends-s.resends))
+		}
+
+		wakeAt := s.deadline
+		if now.Before(s.expiresAt) && s.expiresAt.Before(wakeAt) {
+			wakeAt = s.expiresAt
+		}
+		expiresAt := s.expiresAt
+		if _, err := workflow.AwaitWithTimeout(ctx, wakeAt.Sub(now), func() bool {
+			return s.code != "" || !s.expiresAt.Equal(expiresAt)
+		}); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (s *otpSession) timeout(ctx workflow.Context, message string) error {
+	s.waiting = false
+	s.notify(ctx, message)
+	return temporal.NewApplicationError("user did not provide OTP in time", OTPTimeoutError)
+}
+
+func (s *otpSession) notify(ctx workflow.Context, message string) {
+	notifyUser(ctx, s.userID, message)
+}
+
+func (s *otpSession) result(accepted bool, message string) OTPUpdateResult {
+	return OTPUpdateResult{
+		Accepted:       accepted,
+		Message:        message,
+		ExpiresAt:      s.expiresAt,
+		ResendsLeft:    MaxOTPResends - s.resends,
+		ResendsAllowed: MaxOTPResends,
+	}
+}
+
 // --- Activity Implementations ---
 
 // ValidatePaymentActivity mocks payment validation.
@@ -125,23 +413,36 @@ func ValidatePaymentActivity(ctx context.Context, transactionID string) (bool, e
 	return true, nil
 }
 
-// ExtractDocumentDataActivity mocks document data extraction.
-func ExtractDocumentDataActivity(ctx context.Context, input FilingWorkflowInput) (map[string]interface{}, error) {
-	activity.GetLogger(ctx).Info("Extracting document data", "service_type", input.ServiceType)
-	return map[string]interface{}{"extracted_field": "extracted_value"}, nil
-}
-
 // RequestOTPActivity mocks sending an OTP request.
 func RequestOTPActivity(ctx context.Context, userID string) error {
 	message := "The CIPC portal requires an OTP to proceed. Please send us the code you receive via SMS."
 	return SendWhatsAppMessageActivity(ctx, userID, message)
 }
 
-// SubmitToCIPCActivity mocks the submission to CIPC.
-func SubmitToCIPCActivity(ctx context.Context, submissionInput map[string]interface{}) (string, error) {
-	activity.GetLogger(ctx).Info("Submitting to CIPC", "input", submissionInput)
-	time.Sleep(3 * time.Second)
-	return fmt.Sprintf("CIPC-REF-%d", time.Now().Unix()), nil
+// SubmitToCIPCActivity submits the filing through the CIPC Runner. Progress is heartbeated so
+// a retry after a worker crash verifies the earlier submission instead of filing twice.
+func SubmitToCIPCActivity(ctx context.Context, submissionInput CIPCSubmissionInput) (string, error) {
+	activity.GetLogger(ctx).Info("Submitting to CIPC", "service_type", submissionInput.ServiceType, "attempt", activity.GetInfo(ctx).Attempt)
+
+	clientData := map[string]interface{}{}
+	for k, v := range submissionInput.Data {
+		clientData[k] = v
+	}
+	clientData["otp"] = string(submissionInput.OTP)
+	cleanup, err := stageXBRLUpload(ctx, submissionInput.CompanyRegNumber, clientData)
+	if err != nil {
+		return "", fmt.Errorf("failed to stage XBRL instance for upload: %w", err)
+	}
+	defer cleanup()
+
+	outcome, err := runCheckpointedFiling(ctx, submissionInput.ServiceType, clientData)
+	if err != nil {
+		return "", err
+	}
+	if outcome.Result.Status != "success" {
+		return "", temporal.NewNonRetryableApplicationError(outcome.Result.Error, outcome.Result.ErrorCode, nil)
+	}
+	return outcome.Result.ReferenceNumber, nil
 }
 
 // UpdateUserRecordsActivity mocks updating the user's records.
@@ -0,0 +1,323 @@
+package temporal
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestOTPCode_IsSealedInPayloads(t *testing.T) {
+	payload, err := json.Marshal(CIPCSubmissionInput{ServiceType: "annual_return", OTP: "123456"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(payload), "123456")
+	assert.Contains(t, string(payload), sealedOTPPrefix)
+
+	var decoded CIPCSubmissionInput
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+	assert.Equal(t, OTPCode("123456"), decoded.OTP)
+	assert.Equal(t, "******", decoded.OTP.String())
+
+	// Legacy callers that send a plain code are still understood.
+	require.NoError(t, json.Unmarshal([]byte(`{"OTP":"654321"}`), &OTPSignal{}))
+}
+
+func TestValidateOTPFormat(t *testing.T) {
+	assert.NoError(t, ValidateOTPFormat(NormalizeOTP(" 123-456 ")))
+	assert.ErrorContains(t, ValidateOTPFormat("12345"), "6 digits")
+	assert.ErrorContains(t, ValidateOTPFormat("12a456"), "digits only")
+}
+
+// CombinedFilingWorkflowTestSuite is the test suite for the combined filing workflow.
+type CombinedFilingWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env       *testsuite.TestWorkflowEnvironment
+	followUps []CIPCConfirmationInput
+}
+
+// TestCombinedFilingWorkflowTestSuite runs the test suite.
+func TestCombinedFilingWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(CombinedFilingWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *CombinedFilingWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.followUps = nil
+	s.env.RegisterWorkflow(CIPCConfirmationWorkflow)
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.Anything).Return(
+		func(_ workflow.Context, input CIPCConfirmationInput) (*CIPCFilingOutcome, error) {
+			s.followUps = append(s.followUps, input)
+			return &CIPCFilingOutcome{Status: CIPCOutcomeApproved}, nil
+		}).Maybe()
+	s.env.OnActivity(RecordFilingWorkflowActivity, mock.Anything, "tx-1").Return(nil)
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, mock.Anything).Return(true, nil)
+	s.env.OnActivity(ExtractDocumentDataActivity, mock.Anything, mock.Anything).Return(map[string]interface{}{"company_name": "Acme"}, nil)
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.MatchedBy(func(p ConversationPrompt) bool {
+		return p.UserID == "user-1" && p.Awaiting == ConversationAwaitingOTP
+	})).Return("conversation-1", nil)
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, "conversation-1").Return(nil)
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *CombinedFilingWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *CombinedFilingWorkflowTestSuite) params() FilingWorkflowInput {
+	return FilingWorkflowInput{TransactionID: "tx-1", UserID: "user-1", ServiceType: "annual_return"}
+}
+
+func (s *CombinedFilingWorkflowTestSuite) sendOTP(code string, after time.Duration, wantAccepted bool) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(SubmitOTPUpdateName, "otp-"+code, &testsuite.TestUpdateCallback{
+			OnAccept: func() {
+				s.True(wantAccepted, "OTP %q should have been rejected", code)
+			},
+			OnReject: func(err error) {
+				s.False(wantAccepted, "OTP %q should have been accepted: %v", code, err)
+			},
+			OnComplete: func(interface{}, error) {},
+		}, OTPSignal{OTP: OTPCode(code)})
+	}, after)
+}
+
+func submittedOTP(code string) interface{} {
+	return mock.MatchedBy(func(input CIPCSubmissionInput) bool { return input.OTP == OTPCode(code) })
+}
+
+// Test_OTP_TypoAndCIPCRejectionKeepWaiting tests that bad codes are rejected without failing the filing.
+func (s *CombinedFilingWorkflowTestSuite) Test_OTP_TypoAndCIPCRejectionKeepWaiting() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123456")).
+		Return("", temporal.NewNonRetryableApplicationError("OTP invalid", RunnerErrOTPRejected, nil)).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123457")).Return("AR20250101", nil).Once()
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+
+	s.sendOTP("12a456", time.Minute, false)
+	s.sendOTP("123 456", 2*time.Minute, true)
+	s.sendOTP("123457", 5*time.Minute, true)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result FilingWorkflowResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("AR20250101", result.FilingReference)
+	s.Require().Len(s.followUps, 1)
+	s.Equal("AR20250101", s.followUps[0].Reference)
+	s.Equal("tx-1", s.followUps[0].TransactionID)
+}
+
+// Test_CallerFollowsUp_LeavesFollowUpToTheParent tests that a filing whose parent follows it up
+// with CIPC does not start a second follow-up.
+func (s *CombinedFilingWorkflowTestSuite) Test_CallerFollowsUp_LeavesFollowUpToTheParent() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123456")).Return("AR20250101", nil).Once()
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+
+	s.sendOTP("123456", time.Minute, true)
+
+	params := s.params()
+	params.CallerFollowsUp = true
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, params)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Empty(s.followUps)
+}
+
+// Test_RetriedFiling_SettlesTransaction tests that a retry the customer chose moves the
+// transaction off retry_scheduled once it is submitted.
+func (s *CombinedFilingWorkflowTestSuite) Test_RetriedFiling_SettlesTransaction() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123456")).Return("AR20250101", nil).Once()
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity(SettleRetriedFilingActivity, mock.Anything, "tx-1").Return(nil).Once()
+
+	s.sendOTP("123456", time.Minute, true)
+
+	params := s.params()
+	params.RetryAttempt = 1
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, params)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+// Test_OTP_ExpiredCodeNeedsResend tests that an expired code is refused until a new one is requested.
+func (s *CombinedFilingWorkflowTestSuite) Test_OTP_ExpiredCodeNeedsResend() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Twice()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("222222")).Return("AR20250102", nil).Once()
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+
+	s.sendOTP("111111", OTPValidity+time.Minute, false)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflowNoRejection(ResendOTPUpdateName, "resend-1", s.T())
+	}, OTPValidity+2*time.Minute)
+	s.sendOTP("222222", OTPValidity+3*time.Minute, true)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+// Test_OTP_TimesOut tests that the workflow gives up after the overall OTP wait and refunds a
+// customer who does not choose a compensation.
+func (s *CombinedFilingWorkflowTestSuite) Test_OTP_TimesOut() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(CompensateFilingActivity, mock.Anything, mock.MatchedBy(func(c FilingCompensation) bool {
+		return c.Reason == CompensationReasonOTPTimeout && c.Choice == CompensationRefund && c.ChosenBy == "default"
+	})).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.True(s.env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(s.env.GetWorkflowError(), &appErr))
+	s.Equal(OTPTimeoutError, appErr.Type())
+}
+
+func (s *CombinedFilingWorkflowTestSuite) queryProgress() FilingProgress {
+	value, err := s.env.QueryWorkflow(FilingProgressQueryName)
+	s.Require().NoError(err)
+	var progress FilingProgress
+	s.Require().NoError(value.Get(&progress))
+	return progress
+}
+
+// Test_Progress_TracksStepsAndLastError tests the progress query while waiting and after completion.
+func (s *CombinedFilingWorkflowTestSuite) Test_Progress_TracksStepsAndLastError() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123456")).
+		Return("", temporal.NewNonRetryableApplicationError("OTP invalid", RunnerErrOTPRejected, nil)).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123457")).Return("AR20250103", nil).Once()
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+
+	s.env.RegisterDelayedCallback(func() {
+		progress := s.queryProgress()
+		s.Equal(FilingStatusRunning, progress.Status)
+		s.Equal(FilingStepWaitForOTP, progress.Step)
+		s.Equal(FilingTotalSteps, progress.TotalSteps)
+		s.Equal(FilingWaitingOnOTP, progress.WaitingOn)
+		s.NotNil(progress.OTPExpiresAt)
+		s.Empty(progress.LastError)
+		s.Contains(progress.Summary(), "step 4 of 7")
+	}, time.Minute)
+	s.sendOTP("123456", 2*time.Minute, true)
+	s.env.RegisterDelayedCallback(func() {
+		progress := s.queryProgress()
+		s.Equal(FilingStepWaitForOTP, progress.Step)
+		s.Equal("CIPC did not accept the OTP", progress.LastError)
+	}, 3*time.Minute)
+	s.sendOTP("123457", 4*time.Minute, true)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	progress := s.queryProgress()
+	s.Equal(FilingStatusCompleted, progress.Status)
+	s.Equal(FilingStepSendConfirmation, progress.Step)
+	s.Equal("AR20250103", progress.FilingReference)
+	s.NotNil(progress.CompletedAt)
+	for _, step := range progress.Steps {
+		s.NotNil(step.CompletedAt, step.Name)
+	}
+}
+
+// Test_Progress_RecordsFailure tests that a failed filing reports the step and error it stopped on.
+func (s *CombinedFilingWorkflowTestSuite) Test_Progress_RecordsFailure() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(CompensateFilingActivity, mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.Error(s.env.GetWorkflowError())
+	progress := s.queryProgress()
+	s.Equal(FilingStatusFailed, progress.Status)
+	s.Equal(FilingStepWaitForOTP, progress.Step)
+	s.Empty(progress.WaitingOn)
+	s.Contains(progress.LastError, "did not provide OTP")
+	s.Equal(CompensationRefund, progress.Compensation)
+}
+
+func (s *CombinedFilingWorkflowTestSuite) failSubmission() {
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil).Once()
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, submittedOTP("123456")).
+		Return("", temporal.NewNonRetryableApplicationError("form rejected", RunnerErrValidationFailed, nil)).Once()
+	s.sendOTP("123456", time.Minute, true)
+}
+
+func (s *CombinedFilingWorkflowTestSuite) chooseCompensation(choice string, after time.Duration) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(CompensationChoiceSignalName, choice)
+	}, after)
+}
+
+// Test_Compensation_CustomerChoosesCredit tests that a failed submission is credited at the customer's request.
+func (s *CombinedFilingWorkflowTestSuite) Test_Compensation_CustomerChoosesCredit() {
+	s.failSubmission()
+	s.env.OnActivity(CompensateFilingActivity, mock.Anything, mock.MatchedBy(func(c FilingCompensation) bool {
+		return c.TransactionID == "tx-1" && c.UserID == "user-1" && c.Reason == CompensationReasonSubmissionFailed &&
+			strings.Contains(c.Failure, "form rejected") && c.Choice == CompensationCredit && c.ChosenBy == "customer"
+	})).Return(nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		progress := s.queryProgress()
+		s.Equal(FilingWaitingOnCompensation, progress.WaitingOn)
+		s.Equal(FilingStatusRunning, progress.Status)
+	}, time.Hour)
+	s.chooseCompensation("CREDIT", 2*time.Hour)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.Error(s.env.GetWorkflowError())
+	progress := s.queryProgress()
+	s.Equal(FilingStatusFailed, progress.Status)
+	s.Equal(CompensationCredit, progress.Compensation)
+}
+
+// Test_Compensation_RetryContinuesAsNew tests that a retry is scheduled by continuing as new.
+func (s *CombinedFilingWorkflowTestSuite) Test_Compensation_RetryContinuesAsNew() {
+	s.failSubmission()
+	s.env.OnActivity(CompensateFilingActivity, mock.Anything, mock.MatchedBy(func(c FilingCompensation) bool {
+		return c.Choice == CompensationRetry
+	})).Return(nil).Once()
+	s.chooseCompensation("retry", time.Hour)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+}
+
+// Test_Compensation_NoRetryAfterMaxAttempts tests that RETRY is refused once the retries are used up.
+func (s *CombinedFilingWorkflowTestSuite) Test_Compensation_NoRetryAfterMaxAttempts() {
+	s.failSubmission()
+	s.env.OnActivity(CompensateFilingActivity, mock.Anything, mock.MatchedBy(func(c FilingCompensation) bool {
+		return c.Choice == CompensationRefund && c.ChosenBy == "customer"
+	})).Return(nil).Once()
+	s.chooseCompensation("retry", time.Hour)
+	s.chooseCompensation("refund", 2*time.Hour)
+
+	params := s.params()
+	params.RetryAttempt = MaxFilingRetries
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, params)
+
+	s.Error(s.env.GetWorkflowError())
+	s.False(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+}
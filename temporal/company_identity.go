@@ -0,0 +1,115 @@
+package temporal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Company types, keyed by the entity code at the end of a CIPC registration number.
+const (
+	CompanyTypePrivate           = "private"            // (Pty) Ltd, code 07
+	CompanyTypePublic            = "public"             // Ltd, code 06
+	CompanyTypeNonProfit         = "non_profit"         // NPC, code 08
+	CompanyTypePersonalLiability = "personal_liability" // Inc, code 21
+	CompanyTypeStateOwned        = "state_owned"        // SOC Ltd, code 30
+)
+
+var regNumberPattern = regexp.MustCompile(`^(\d{4})/(\d{6})/(\d{2})$`)
+
+var companyTypeCodes = map[string]string{
+	"06": CompanyTypePublic,
+	"07": CompanyTypePrivate,
+	"08": CompanyTypeNonProfit,
+	"21": CompanyTypePersonalLiability,
+	"30": CompanyTypeStateOwned,
+}
+
+// CompanyTypeFromRegNumber derives the company type from a registration number such as 2020/123456/07.
+func CompanyTypeFromRegNumber(regNumber string) (string, error) {
+	m := regNumberPattern.FindStringSubmatch(strings.TrimSpace(regNumber))
+	if m == nil {
+		return "", fmt.Errorf("invalid registration number %q, expected YYYY/NNNNNN/NN", regNumber)
+	}
+	companyType, ok := companyTypeCodes[m[3]]
+	if !ok {
+		return "", fmt.Errorf("unsupported entity code %s in registration number %s", m[3], regNumber)
+	}
+	return companyType, nil
+}
+
+// MinimumDirectors returns the minimum board size required by section 66(2) of the Companies Act.
+func MinimumDirectors(companyType string) int {
+	switch companyType {
+	case CompanyTypePublic, CompanyTypeNonProfit, CompanyTypeStateOwned:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// ValidateSAIDNumber checks the format, date of birth and Luhn check digit of a South African ID number.
+func ValidateSAIDNumber(id string) error {
+	id = strings.TrimSpace(id)
+	if len(id) != 13 {
+		return fmt.Errorf("ID number must be 13 digits")
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("ID number must contain only digits")
+		}
+	}
+	if _, err := time.Parse("060102", id[:6]); err != nil {
+		return fmt.Errorf("ID number has an invalid date of birth")
+	}
+	if id[10] != '0' && id[10] != '1' {
+		return fmt.Errorf("ID number has an invalid citizenship digit")
+	}
+
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(id[i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	if check := (10 - sum%10) % 10; check != int(id[12]-'0') {
+		return fmt.Errorf("ID number check digit is invalid")
+	}
+	return nil
+}
+
+// ValidateDirectorIdentity checks a director's identity number. South African citizens, who have
+// no nationality recorded, must give their SA ID number. Foreign nationals give the ISO 3166-1
+// alpha-2 code of their nationality and either an SA ID number (permanent residents) or their
+// passport number.
+func ValidateDirectorIdentity(idNumber, nationality string) error {
+	if nationality == "" || nationality == "ZA" {
+		return ValidateSAIDNumber(idNumber)
+	}
+	if len(nationality) != 2 || strings.ToUpper(nationality) != nationality || strings.Trim(nationality, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("nationality must be a two-letter country code, got %q", nationality)
+	}
+	if ValidateSAIDNumber(idNumber) == nil {
+		return nil
+	}
+	return validatePassportNumber(idNumber)
+}
+
+// validatePassportNumber checks a passport number is 6 to 20 letters and digits.
+func validatePassportNumber(number string) error {
+	if len(number) < 6 || len(number) > 20 {
+		return fmt.Errorf("passport number must be 6 to 20 characters")
+	}
+	for _, r := range number {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+			return fmt.Errorf("passport number must contain only capital letters and digits")
+		}
+	}
+	return nil
+}
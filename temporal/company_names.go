@@ -0,0 +1,442 @@
+package temporal
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Name check statuses, from best to worst.
+const (
+	NameAvailable   = "available"
+	NameAtRisk      = "at_risk"
+	NameUnavailable = "unavailable"
+)
+
+const (
+	// nameTooSimilar is the similarity to a registered name at which CIPC refuses a name.
+	nameTooSimilar = 0.9
+	// nameSimilar is the similarity at which CIPC may refuse a name as confusingly similar.
+	nameSimilar = 0.7
+	// nameUnrelated is the similarity below which names have only chance letters in common.
+	nameUnrelated = 0.5
+	// nameAtRiskScore is the availability score below which a name is reported at risk.
+	nameAtRiskScore = 30
+	// consentWordPenalty is taken off the score of a name that needs a regulator's consent.
+	consentWordPenalty = 15
+)
+
+// companyNameSuffixes are the endings section 11(3) of the Companies Act requires per company type.
+var companyNameSuffixes = map[string]string{
+	CompanyTypePrivate:           "(Pty) Ltd",
+	CompanyTypePublic:            "Ltd",
+	CompanyTypeNonProfit:         "NPC",
+	CompanyTypePersonalLiability: "Inc",
+	CompanyTypeStateOwned:        "SOC Ltd",
+}
+
+// companyNameSuffixPatterns recognise the suffixes customers write, most specific first.
+var companyNameSuffixPatterns = []struct {
+	companyType string
+	pattern     *regexp.Regexp
+}{
+	{CompanyTypeStateOwned, regexp.MustCompile(`(?i)\s+soc\s+(ltd|limited)\.?$`)},
+	{CompanyTypePrivate, regexp.MustCompile(`(?i)\s+(\(pty\)|pty|proprietary)\.?\s+(ltd|limited)\.?$`)},
+	{CompanyTypePublic, regexp.MustCompile(`(?i)\s+(ltd|limited)\.?$`)},
+	{CompanyTypeNonProfit, regexp.MustCompile(`(?i)\s+npc\.?$`)},
+	{CompanyTypePersonalLiability, regexp.MustCompile(`(?i)\s+(inc|incorporated)\.?$`)},
+}
+
+// companyNameChars are the characters CIPC accepts in a name.
+var companyNameChars = regexp.MustCompile(`^[\p{L}0-9 &'\-.,()+!@]+$`)
+
+// restrictedNameWord is a word CIPC only accepts in a name under conditions.
+type restrictedNameWord struct {
+	reason string
+	// consent words are accepted with a regulator's written consent; the others are refused.
+	consent bool
+}
+
+// restrictedNameWords are matched as whole words against the normalised name.
+var restrictedNameWords = map[string]restrictedNameWord{
+	"bank":             {reason: "'Bank' needs the Prudential Authority's consent under the Banks Act", consent: true},
+	"banking":          {reason: "'Banking' needs the Prudential Authority's consent under the Banks Act", consent: true},
+	"insurance":        {reason: "'Insurance' needs the Prudential Authority's consent under the Insurance Act", consent: true},
+	"assurance":        {reason: "'Assurance' needs the Prudential Authority's consent under the Insurance Act", consent: true},
+	"reinsurance":      {reason: "'Reinsurance' needs the Prudential Authority's consent under the Insurance Act", consent: true},
+	"stock exchange":   {reason: "'Stock Exchange' needs the FSCA's consent under the Financial Markets Act", consent: true},
+	"olympic":          {reason: "'Olympic' needs SASCOC's consent", consent: true},
+	"national":         {reason: "'National' may be read as implying a link to the state and CIPC may ask for motivation", consent: true},
+	"reserve bank":     {reason: "'Reserve Bank' is reserved for the South African Reserve Bank"},
+	"government":       {reason: "'Government' falsely implies a link to the state"},
+	"republic":         {reason: "'Republic' falsely implies a link to the state"},
+	"parliament":       {reason: "'Parliament' falsely implies a link to the state"},
+	"presidential":     {reason: "'Presidential' falsely implies a link to the state"},
+	"municipality":     {reason: "'Municipality' falsely implies a link to the state"},
+	"municipal":        {reason: "'Municipal' falsely implies a link to the state"},
+	"university":       {reason: "'University' is reserved for institutions registered under the Higher Education Act"},
+	"cooperative":      {reason: "'Co-operative' is reserved for co-operatives registered under the Co-operatives Act"},
+	"co operative":     {reason: "'Co-operative' is reserved for co-operatives registered under the Co-operatives Act"},
+	"co op":            {reason: "'Co-op' is reserved for co-operatives registered under the Co-operatives Act"},
+	"red cross":        {reason: "'Red Cross' is protected under the Geneva Conventions"},
+	"chartered":        {reason: "'Chartered' is reserved for members of the professional bodies that charter them"},
+	"trust company":    {reason: "'Trust Company' needs the Master of the High Court's approval", consent: true},
+	"national lottery": {reason: "'National Lottery' is reserved under the Lotteries Act"},
+}
+
+// restrictedNameWordOrder lists restrictedNameWords in a fixed order, so that names are checked
+// the same way when a workflow replays.
+var restrictedNameWordOrder = func() []string {
+	words := make([]string, 0, len(restrictedNameWords))
+	for word := range restrictedNameWords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}()
+
+// genericNameWords describe a business rather than distinguish it, so they count for less when
+// comparing names and are not used to look names up.
+var genericNameWords = map[string]bool{
+	"africa": true, "african": true, "south": true, "sa": true, "global": true, "international": true,
+	"holdings": true, "group": true, "trading": true, "solutions": true, "services": true,
+	"ventures": true, "enterprises": true, "investments": true, "consulting": true, "consultants": true,
+	"projects": true, "properties": true, "logistics": true, "construction": true, "technologies": true,
+	"tech": true, "capital": true, "management": true, "systems": true, "industries": true,
+	"partners": true, "company": true,
+}
+
+// genericNameStems are genericNameWords as companyNameTokens returns them.
+var genericNameStems = func() map[string]bool {
+	stems := map[string]bool{}
+	for word := range genericNameWords {
+		stems[stemNameWord(word)] = true
+	}
+	return stems
+}()
+
+// nameStopWords are ignored when comparing names.
+var nameStopWords = map[string]bool{"the": true, "and": true, "of": true, "a": true}
+
+// nameSuggestionWords are added to a proposed name to suggest alternatives.
+var nameSuggestionWords = []string{"Holdings", "Group", "Ventures", "Solutions", "Enterprises", "Africa", "Investments", "Trading"}
+
+// RegisteredName is a name on our cached copy of CIPC's register.
+type RegisteredName struct {
+	Name               string `json:"name"`
+	RegistrationNumber string `json:"registration_number,omitempty"`
+	// Status is "registered" for companies and "reserved" for names under reservation.
+	Status string `json:"status"`
+}
+
+// SimilarName is a registered name a proposed name could be confused with.
+type SimilarName struct {
+	Name               string  `json:"name"`
+	RegistrationNumber string  `json:"registration_number,omitempty"`
+	Similarity         float64 `json:"similarity"`
+}
+
+// NameCheck is the result of checking a proposed name against CIPC's naming rules and register.
+type NameCheck struct {
+	Name string `json:"name"`
+	// FullName is the name with the suffix its company type requires.
+	FullName string `json:"full_name"`
+	// AvailabilityScore estimates from 0 to 100 how likely CIPC is to approve the name.
+	AvailabilityScore int           `json:"availability_score"`
+	Status            string        `json:"status"`
+	Blocking          []string      `json:"blocking,omitempty"`
+	Warnings          []string      `json:"warnings,omitempty"`
+	SimilarNames      []SimilarName `json:"similar_names,omitempty"`
+	Suggestions       []string      `json:"suggestions,omitempty"`
+}
+
+// SplitCompanyNameSuffix separates a name from its company suffix. companyType is "" when the name
+// has no suffix.
+func SplitCompanyNameSuffix(name string) (base, companyType string) {
+	name = strings.TrimSpace(name)
+	for _, s := range companyNameSuffixPatterns {
+		if loc := s.pattern.FindStringIndex(name); loc != nil {
+			return strings.TrimSpace(name[:loc[0]]), s.companyType
+		}
+	}
+	return name, ""
+}
+
+// normalizeCompanyName reduces a name to lower-case words without its suffix or punctuation, so
+// that "Blue-Sky & Co (Pty) Ltd" and "blue sky and co" compare equal.
+func normalizeCompanyName(name string) string {
+	base, _ := SplitCompanyNameSuffix(name)
+	base = strings.ToLower(base)
+	base = strings.NewReplacer("&", " and ", "+", " and ", "-", " ").Replace(base)
+	var b strings.Builder
+	for _, r := range base {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == ' ', r > 127:
+			b.WriteRune(r)
+		case r == '.' || r == '\'':
+			// Dropped so that "S.A." and "Joe's" read as "sa" and "joes".
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// companyNameTokens are the words of a normalised name that matter when comparing it, without
+// plural endings so that "Bakery" and "Bakeries" match.
+func companyNameTokens(normalized string) []string {
+	var tokens []string
+	for _, word := range strings.Fields(normalized) {
+		if !nameStopWords[word] {
+			tokens = append(tokens, stemNameWord(word))
+		}
+	}
+	return tokens
+}
+
+// stemNameWord strips an English plural ending from a word.
+func stemNameWord(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// CompanyNameLookupTokens returns the distinctive words of the given names, used to find the
+// registered names they could be confused with. It is also how names are indexed in
+// cipc_registered_names.tokens.
+func CompanyNameLookupTokens(names ...string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, name := range names {
+		all := companyNameTokens(normalizeCompanyName(name))
+		var distinctive []string
+		for _, token := range all {
+			if !genericNameStems[token] {
+				distinctive = append(distinctive, token)
+			}
+		}
+		if len(distinctive) == 0 {
+			// A name made only of generic words is looked up by them.
+			distinctive = all
+		}
+		for _, token := range distinctive {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// CompanyNameSimilarity scores from 0 to 1 how alike two names are, ignoring suffixes, case and
+// punctuation. It is the higher of a word overlap, in which generic words count half, and the
+// edit distance between the names with spaces removed, which catches misspellings and run-together
+// words.
+func CompanyNameSimilarity(a, b string) float64 {
+	ta, tb := companyNameTokens(normalizeCompanyName(a)), companyNameTokens(normalizeCompanyName(b))
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	weight := func(token string) float64 {
+		if genericNameStems[token] {
+			return 0.5
+		}
+		return 1
+	}
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, t := range ta {
+		inA[t] = true
+	}
+	for _, t := range tb {
+		inB[t] = true
+	}
+	var shared, union float64
+	for t := range inA {
+		union += weight(t)
+		if inB[t] {
+			shared += weight(t)
+		}
+	}
+	for t := range inB {
+		if !inA[t] {
+			union += weight(t)
+		}
+	}
+	overlap := shared / union
+
+	ca, cb := strings.Join(ta, ""), strings.Join(tb, "")
+	longest := math.Max(float64(len([]rune(ca))), float64(len([]rune(cb))))
+	edit := 1 - float64(levenshtein(ca, cb))/longest
+
+	return math.Round(math.Max(overlap, edit)*100) / 100
+}
+
+// levenshtein is the number of single-character edits between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// CheckCompanyName checks a proposed name for a company of the given type against CIPC's naming
+// rules and the registered names it could be confused with.
+func CheckCompanyName(name, companyType string, register []RegisteredName) NameCheck {
+	check := NameCheck{Name: strings.TrimSpace(name)}
+	base, written := SplitCompanyNameSuffix(check.Name)
+	required := companyNameSuffixes[companyType]
+	check.FullName = strings.TrimSpace(base + " " + required)
+
+	if base == "" {
+		check.Blocking = append(check.Blocking, "The name is empty")
+	} else if !companyNameChars.MatchString(base) {
+		check.Blocking = append(check.Blocking, "The name may only contain letters, numbers, spaces and & ' - . , ( ) + ! @")
+	} else if !strings.ContainsFunc(base, unicode.IsLetter) {
+		check.Blocking = append(check.Blocking, "The name must contain at least one letter")
+	}
+	if written != "" && written != companyType {
+		check.Blocking = append(check.Blocking, fmt.Sprintf("The name ends in '%s' but a %s company's name must end in '%s'",
+			companyNameSuffixes[written], strings.ReplaceAll(companyType, "_", " "), required))
+	}
+
+	score := 100.0
+	padded := " " + normalizeCompanyName(base) + " "
+	for _, word := range restrictedNameWordOrder {
+		if !strings.Contains(padded, " "+word+" ") {
+			continue
+		}
+		restricted := restrictedNameWords[word]
+		if restricted.consent {
+			check.Warnings = append(check.Warnings, restricted.reason)
+			score -= consentWordPenalty
+		} else if companyType != CompanyTypeStateOwned {
+			check.Blocking = append(check.Blocking, restricted.reason)
+		}
+	}
+
+	for _, registered := range register {
+		similarity := CompanyNameSimilarity(base, registered.Name)
+		if similarity >= nameUnrelated {
+			score = math.Min(score, 100*(1-similarity))
+		}
+		if similarity < nameSimilar {
+			continue
+		}
+		check.SimilarNames = append(check.SimilarNames, SimilarName{
+			Name: registered.Name, RegistrationNumber: registered.RegistrationNumber, Similarity: similarity,
+		})
+	}
+	sort.SliceStable(check.SimilarNames, func(i, j int) bool {
+		return check.SimilarNames[i].Similarity > check.SimilarNames[j].Similarity
+	})
+	if len(check.SimilarNames) > 3 {
+		check.SimilarNames = check.SimilarNames[:3]
+	}
+	if len(check.SimilarNames) > 0 {
+		closest := check.SimilarNames[0]
+		switch {
+		case closest.Similarity >= 1:
+			check.Blocking = append(check.Blocking, fmt.Sprintf("'%s' is already registered", closest.Name))
+		case closest.Similarity >= nameTooSimilar:
+			check.Blocking = append(check.Blocking, fmt.Sprintf("The name is too similar to '%s'", closest.Name))
+		default:
+			check.Warnings = append(check.Warnings, fmt.Sprintf("The name is similar to '%s' and CIPC may refuse it", closest.Name))
+		}
+	}
+
+	switch {
+	case len(check.Blocking) > 0:
+		check.Status, check.AvailabilityScore = NameUnavailable, 0
+	case score < nameAtRiskScore:
+		check.Status, check.AvailabilityScore = NameAtRisk, int(math.Max(score, 1))
+	default:
+		check.Status, check.AvailabilityScore = NameAvailable, int(score)
+	}
+	return check
+}
+
+// SuggestCompanyNames suggests up to n alternatives to a name CIPC is unlikely to approve, best
+// first. Only alternatives that are themselves available are suggested.
+func SuggestCompanyNames(name, companyType string, register []RegisteredName, n int) []string {
+	base, _ := SplitCompanyNameSuffix(name)
+	base = strings.TrimSpace(base)
+	if base == "" {
+		return nil
+	}
+	// Without the words CIPC refuses, the rest of the name may still be usable.
+	padded := " " + base + " "
+	for _, word := range restrictedNameWordOrder {
+		if restricted := restrictedNameWords[word]; !restricted.consent && companyType != CompanyTypeStateOwned {
+			re := regexp.MustCompile(`(?i)\s` + strings.ReplaceAll(regexp.QuoteMeta(word), " ", `[\s-]+`) + `\s`)
+			padded = re.ReplaceAllString(padded, " ")
+		}
+	}
+	base = strings.Join(strings.Fields(padded), " ")
+	if base == "" {
+		return nil
+	}
+
+	// The distinctive words alone, with a different description, are often approved where a
+	// longer version of a taken name is not.
+	var core []string
+	for _, word := range strings.Fields(base) {
+		if !genericNameStems[stemNameWord(strings.ToLower(word))] {
+			core = append(core, word)
+		}
+	}
+	candidates := []string{base}
+	for _, prefix := range []string{strings.Join(core, " "), base} {
+		if prefix == "" {
+			continue
+		}
+		for _, word := range nameSuggestionWords {
+			candidates = append(candidates, prefix+" "+word)
+		}
+	}
+
+	seen := map[string]bool{normalizeCompanyName(name): true}
+	var checks []NameCheck
+	for _, candidate := range candidates {
+		normalized := normalizeCompanyName(candidate)
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		if check := CheckCompanyName(candidate, companyType, register); check.Status == NameAvailable {
+			checks = append(checks, check)
+		}
+	}
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].AvailabilityScore > checks[j].AvailabilityScore })
+	var suggestions []string
+	for _, check := range checks {
+		if len(suggestions) == n {
+			break
+		}
+		suggestions = append(suggestions, check.FullName)
+	}
+	return suggestions
+}
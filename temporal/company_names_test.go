@@ -0,0 +1,89 @@
+package temporal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCompanyNameSuffix(t *testing.T) {
+	tests := []struct {
+		name, base, companyType string
+	}{
+		{"Acme (Pty) Ltd", "Acme", CompanyTypePrivate},
+		{"Acme Proprietary Limited", "Acme", CompanyTypePrivate},
+		{"Acme SOC Ltd", "Acme", CompanyTypeStateOwned},
+		{"Acme Ltd.", "Acme", CompanyTypePublic},
+		{"Acme NPC", "Acme", CompanyTypeNonProfit},
+		{"Acme Incorporated", "Acme", CompanyTypePersonalLiability},
+		{"Acme", "Acme", ""},
+	}
+	for _, tt := range tests {
+		base, companyType := SplitCompanyNameSuffix(tt.name)
+		assert.Equal(t, tt.base, base, tt.name)
+		assert.Equal(t, tt.companyType, companyType, tt.name)
+	}
+}
+
+func TestCompanyNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, CompanyNameSimilarity("Blue-Sky & Co (Pty) Ltd", "blue sky and co"))
+	assert.Equal(t, 1.0, CompanyNameSimilarity("Bluesky", "Blue Sky"), "run-together words")
+	assert.GreaterOrEqual(t, CompanyNameSimilarity("Acme Logistic", "Acme Logistics"), nameTooSimilar, "a misspelling")
+	assert.Less(t, CompanyNameSimilarity("Acme Holdings", "Acme"), nameSimilar, "a generic word sets names apart a little")
+	assert.Less(t, CompanyNameSimilarity("Karoo Bakery", "Acme Holdings"), 0.3)
+}
+
+func TestCompanyNameLookupTokens(t *testing.T) {
+	assert.Equal(t, []string{"acme", "bakery", "karoo"}, CompanyNameLookupTokens("The Karoo Bakery (Pty) Ltd", "Acme Holdings"))
+	assert.Equal(t, []string{"global", "holding"}, CompanyNameLookupTokens("Global Holdings"), "generic-only names use their generic words")
+}
+
+func TestCheckCompanyName(t *testing.T) {
+	register := []RegisteredName{
+		{Name: "Blue Sky Logistics (Pty) Ltd", RegistrationNumber: "2015/000001/07", Status: "registered"},
+		{Name: "Karoo Bakeries (Pty) Ltd", RegistrationNumber: "2019/000002/07", Status: "registered"},
+	}
+
+	check := CheckCompanyName("Umoya Coffee Roasters", CompanyTypePrivate, register)
+	assert.Equal(t, NameAvailable, check.Status)
+	assert.Equal(t, 100, check.AvailabilityScore)
+	assert.Equal(t, "Umoya Coffee Roasters (Pty) Ltd", check.FullName)
+
+	check = CheckCompanyName("Blue Sky Logistics", CompanyTypePrivate, register)
+	assert.Equal(t, NameUnavailable, check.Status)
+	assert.Zero(t, check.AvailabilityScore)
+	assert.Contains(t, check.Blocking[0], "already registered")
+
+	check = CheckCompanyName("Karoo Bakery", CompanyTypePrivate, register)
+	assert.Equal(t, NameUnavailable, check.Status, "a plural is too similar")
+
+	check = CheckCompanyName("Umoya Ltd", CompanyTypePrivate, nil)
+	assert.Equal(t, NameUnavailable, check.Status)
+	assert.Contains(t, check.Blocking[0], "must end in '(Pty) Ltd'")
+
+	check = CheckCompanyName("Umoya Government Services", CompanyTypePrivate, nil)
+	assert.Equal(t, NameUnavailable, check.Status)
+	check = CheckCompanyName("Umoya Government Services", CompanyTypeStateOwned, nil)
+	assert.Equal(t, NameAvailable, check.Status, "state-owned companies may use state words")
+
+	check = CheckCompanyName("Umoya Insurance Brokers", CompanyTypePrivate, nil)
+	assert.Equal(t, NameAvailable, check.Status)
+	assert.Equal(t, 100-consentWordPenalty, check.AvailabilityScore)
+	assert.Len(t, check.Warnings, 1)
+
+	check = CheckCompanyName("Umoya #1", CompanyTypePrivate, nil)
+	assert.Equal(t, NameUnavailable, check.Status)
+}
+
+func TestSuggestCompanyNames(t *testing.T) {
+	register := []RegisteredName{{Name: "Blue Sky Logistics (Pty) Ltd", Status: "registered"}}
+
+	suggestions := SuggestCompanyNames("Blue Sky Logistics", CompanyTypePrivate, register, 3)
+	assert.Len(t, suggestions, 3)
+	for _, suggestion := range suggestions {
+		assert.Equal(t, NameAvailable, CheckCompanyName(suggestion, CompanyTypePrivate, register).Status, suggestion)
+	}
+
+	suggestions = SuggestCompanyNames("Umoya Government Services", CompanyTypePrivate, nil, 2)
+	assert.Equal(t, []string{"Umoya Services (Pty) Ltd", "Umoya Holdings (Pty) Ltd"}, suggestions)
+}
@@ -0,0 +1,194 @@
+package temporal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CompanyRegistrationServiceType is the payg_transactions service type of a new company registration.
+const CompanyRegistrationServiceType = "company_registration"
+
+// Standard memoranda of incorporation CIPC registers a private company with. Companies with a
+// custom MOI need an attorney and are not registered through us.
+const (
+	MOIShortStandard = "short_standard" // CoR15.1A
+	MOILongStandard  = "long_standard"  // CoR15.1B
+)
+
+// DefaultFinancialYearEnd is the financial year end month used when the customer does not choose one.
+const DefaultFinancialYearEnd = time.February
+
+// DefaultAuthorisedShares is the number of no par value shares authorised by the standard MOI
+// when the customer does not choose otherwise.
+const DefaultAuthorisedShares = 1000
+
+// CompanyRegistrationName is how a new company is named: either a name the customer reserved
+// with NameReservationWorkflow, or the registration number CIPC issues.
+type CompanyRegistrationName struct {
+	// NameReservationID is the workflow ID of an approved NameReservationWorkflow.
+	NameReservationID string `json:"name_reservation_id,omitempty"`
+	// UseRegistrationNumber registers the company under its registration number, e.g.
+	// "K2026123456 (South Africa) (Pty) Ltd".
+	UseRegistrationNumber bool `json:"use_registration_number,omitempty"`
+}
+
+// Incorporator is a founding shareholder who signs the MOI.
+type Incorporator struct {
+	FullName string `json:"full_name"`
+	IDNumber string `json:"id_number"`
+	Email    string `json:"email,omitempty"`
+	// Shares is the number of shares issued to the incorporator on registration.
+	Shares int `json:"shares"`
+}
+
+// CompanyRegistration is the content of a CoR14.1 notice of incorporation of a private company.
+type CompanyRegistration struct {
+	Name          CompanyRegistrationName `json:"name"`
+	Incorporators []Incorporator          `json:"incorporators"`
+	Directors     []Director              `json:"directors"`
+	MOI           string                  `json:"moi"`
+	// RegisteredAddress is the company's registered office.
+	RegisteredAddress string `json:"registered_address"`
+	// FinancialYearEnd is the month the financial year ends; zero uses DefaultFinancialYearEnd.
+	FinancialYearEnd time.Month `json:"financial_year_end,omitempty"`
+	// AuthorisedShares is zero for DefaultAuthorisedShares.
+	AuthorisedShares int `json:"authorised_shares,omitempty"`
+}
+
+// withDefaults fills in the choices the customer left out.
+func (r CompanyRegistration) withDefaults() CompanyRegistration {
+	if r.MOI == "" {
+		r.MOI = MOIShortStandard
+	}
+	if r.FinancialYearEnd == 0 {
+		r.FinancialYearEnd = DefaultFinancialYearEnd
+	}
+	if r.AuthorisedShares == 0 {
+		r.AuthorisedShares = DefaultAuthorisedShares
+	}
+	return r
+}
+
+// ValidateCompanyRegistration checks a registration and returns every problem found, so the
+// customer can fix them in one go. An empty slice means the registration can be filed.
+func ValidateCompanyRegistration(registration CompanyRegistration) []string {
+	var problems []string
+	registration = registration.withDefaults()
+
+	switch {
+	case registration.Name.NameReservationID != "" && registration.Name.UseRegistrationNumber:
+		problems = append(problems, "choose either a reserved name or the registration number as the company name, not both")
+	case registration.Name.NameReservationID == "" && !registration.Name.UseRegistrationNumber:
+		problems = append(problems, "no company name was chosen: reserve a name first or use the registration number")
+	}
+
+	if registration.MOI != MOIShortStandard && registration.MOI != MOILongStandard {
+		problems = append(problems, fmt.Sprintf("MOI %q is not a standard MOI", registration.MOI))
+	}
+	if strings.TrimSpace(registration.RegisteredAddress) == "" {
+		problems = append(problems, "the registered office address is missing")
+	}
+	if registration.FinancialYearEnd < time.January || registration.FinancialYearEnd > time.December {
+		problems = append(problems, "the financial year end must be a month")
+	}
+
+	if len(registration.Incorporators) == 0 {
+		problems = append(problems, "at least one incorporator is required")
+	}
+	issued := 0
+	incorporators := make(map[string]bool, len(registration.Incorporators))
+	for _, inc := range registration.Incorporators {
+		if strings.TrimSpace(inc.FullName) == "" {
+			problems = append(problems, fmt.Sprintf("incorporator %s has no full name", inc.IDNumber))
+		}
+		if err := ValidateSAIDNumber(inc.IDNumber); err != nil {
+			problems = append(problems, fmt.Sprintf("incorporator %s: %v", inc.FullName, err))
+			continue
+		}
+		if incorporators[inc.IDNumber] {
+			problems = append(problems, fmt.Sprintf("%s is listed as an incorporator more than once", inc.FullName))
+		}
+		incorporators[inc.IDNumber] = true
+		if inc.Shares <= 0 {
+			problems = append(problems, fmt.Sprintf("incorporator %s must take at least one share", inc.FullName))
+		}
+		issued += inc.Shares
+	}
+	if issued > registration.AuthorisedShares {
+		problems = append(problems, fmt.Sprintf("%d shares are issued but only %d are authorised", issued, registration.AuthorisedShares))
+	}
+
+	if required := MinimumDirectors(CompanyTypePrivate); len(registration.Directors) < required {
+		problems = append(problems, fmt.Sprintf("a private company needs at least %d director", required))
+	}
+	directors := make(map[string]bool, len(registration.Directors))
+	for _, d := range registration.Directors {
+		if strings.TrimSpace(d.FullName) == "" {
+			problems = append(problems, fmt.Sprintf("director %s has no full name", d.IDNumber))
+		}
+		if err := ValidateSAIDNumber(d.IDNumber); err != nil {
+			problems = append(problems, fmt.Sprintf("director %s: %v", d.FullName, err))
+			continue
+		}
+		if directors[d.IDNumber] {
+			problems = append(problems, fmt.Sprintf("%s is listed as a director more than once", d.FullName))
+		}
+		directors[d.IDNumber] = true
+		if strings.TrimSpace(d.ResidentialAddress) == "" {
+			problems = append(problems, fmt.Sprintf("director %s has no residential address", d.FullName))
+		}
+	}
+
+	return problems
+}
+
+// CompanyRegistrationDocumentRequirements returns the documents needed to register a company:
+// a certified ID copy of every incorporator and director, counting people who are both once.
+func CompanyRegistrationDocumentRequirements(registration CompanyRegistration) []DocumentRequirement {
+	people := make(map[string]bool)
+	for _, inc := range registration.Incorporators {
+		people[inc.IDNumber] = true
+	}
+	for _, d := range registration.Directors {
+		people[d.IDNumber] = true
+	}
+
+	requirements := []DocumentRequirement{}
+	for _, r := range RequiredDocumentsFor(CompanyRegistrationServiceType) {
+		if r.DocumentType == DocumentTypeIDCopy {
+			r.Count = len(people)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements
+}
+
+// RegistrationNumberCompanyName is the name CIPC gives a company registered without a name,
+// e.g. "K2026123456 (South Africa) (Pty) Ltd" for 2026/123456/07.
+func RegistrationNumberCompanyName(regNumber string) (string, error) {
+	m := regNumberPattern.FindStringSubmatch(regNumber)
+	if m == nil {
+		return "", fmt.Errorf("invalid registration number %q", regNumber)
+	}
+	return fmt.Sprintf("K%s%s (South Africa) %s", m[1], m[2], companyNameSuffixes[CompanyTypePrivate]), nil
+}
+
+// ComplianceDeadline is a statutory deadline recorded in compliance_deadlines.
+type ComplianceDeadline struct {
+	DeadlineType string    `json:"deadline_type"`
+	DueDate      time.Time `json:"due_date"`
+}
+
+// AnnualReturnBusinessDays is how long after the anniversary of its incorporation a company has
+// to file its annual return.
+const AnnualReturnBusinessDays = 30
+
+// NewCompanyComplianceDeadlines returns the first deadlines of a company incorporated on the
+// given date: its beneficial ownership declaration and its first annual return.
+func NewCompanyComplianceDeadlines(incorporated time.Time) []ComplianceDeadline {
+	return []ComplianceDeadline{
+		{DeadlineType: "beneficial_ownership", DueDate: AddBusinessDays(incorporated, BeneficialOwnershipUpdateBusinessDays)},
+		{DeadlineType: "annual_return", DueDate: AddBusinessDays(incorporated.AddDate(1, 0, 0), AnnualReturnBusinessDays)},
+	}
+}
@@ -0,0 +1,49 @@
+package temporal
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// CompanyRegistrationResponse is returned by POST /company-registrations.
+type CompanyRegistrationResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+func (s *APIServer) registerCompanyRegistrationRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /company-registrations", requireInternalAPIKey(s.createCompanyRegistrationHandler))
+}
+
+// createCompanyRegistrationHandler starts a CompanyRegistrationWorkflow. Problems the customer
+// can fix are reported up front; the customer is taken through documents, payment and CIPC's
+// decision over WhatsApp.
+func (s *APIServer) createCompanyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	var input CompanyRegistrationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.PhoneNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id and phone_number are required")
+		return
+	}
+	if problems := ValidateCompanyRegistration(input.Registration); len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("company-registration-%d", time.Now().UnixNano()),
+		TaskQueue: TaskQueue,
+	}, CompanyRegistrationWorkflow, input)
+	if err != nil {
+		log.Printf("Error starting company registration for user %s: %s", input.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start company registration")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, CompanyRegistrationResponse{WorkflowID: run.GetID()})
+}
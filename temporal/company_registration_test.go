@@ -0,0 +1,208 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func validCompanyRegistration() CompanyRegistration {
+	return CompanyRegistration{
+		Name: CompanyRegistrationName{NameReservationID: "name-reservation-1"},
+		Incorporators: []Incorporator{
+			{FullName: "Thandi Mokoena", IDNumber: "8001015009087", Shares: 60},
+			{FullName: "Sipho Dlamini", IDNumber: "7505055009089", Shares: 40},
+		},
+		Directors: []Director{
+			{FullName: "Thandi Mokoena", IDNumber: "8001015009087", ResidentialAddress: "1 Long St, Cape Town"},
+		},
+		RegisteredAddress: "1 Long St, Cape Town",
+	}
+}
+
+func TestValidateCompanyRegistration(t *testing.T) {
+	assert.Empty(t, ValidateCompanyRegistration(validCompanyRegistration()))
+
+	registration := validCompanyRegistration()
+	registration.Name = CompanyRegistrationName{}
+	registration.MOI = "custom"
+	registration.Directors = nil
+	registration.Incorporators[1].IDNumber = "8001015009088"
+	problems := ValidateCompanyRegistration(registration)
+	assert.Len(t, problems, 4)
+	assert.Contains(t, problems[0], "no company name was chosen")
+	assert.Contains(t, problems[1], `MOI "custom" is not a standard MOI`)
+	assert.Contains(t, problems[2], "incorporator Sipho Dlamini: ID number check digit is invalid")
+	assert.Contains(t, problems[3], "at least 1 director")
+
+	registration = validCompanyRegistration()
+	registration.AuthorisedShares = 50
+	registration.Directors[0].ResidentialAddress = ""
+	problems = ValidateCompanyRegistration(registration)
+	assert.Equal(t, []string{"100 shares are issued but only 50 are authorised", "director Thandi Mokoena has no residential address"}, problems)
+}
+
+func TestCompanyRegistrationDocumentRequirements(t *testing.T) {
+	requirements := CompanyRegistrationDocumentRequirements(validCompanyRegistration())
+	assert.Len(t, requirements, 1)
+	assert.Equal(t, DocumentTypeIDCopy, requirements[0].DocumentType)
+	assert.Equal(t, 2, requirements[0].Count, "a director who is also an incorporator sends one ID copy")
+}
+
+func TestRegistrationNumberCompanyName(t *testing.T) {
+	name, err := RegistrationNumberCompanyName("2026/123456/07")
+	assert.NoError(t, err)
+	assert.Equal(t, "K2026123456 (South Africa) (Pty) Ltd", name)
+
+	_, err = RegistrationNumberCompanyName("K2026123456")
+	assert.Error(t, err)
+}
+
+func TestNewCompanyComplianceDeadlines(t *testing.T) {
+	incorporated := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.UTC)
+	deadlines := NewCompanyComplianceDeadlines(incorporated)
+	assert.Equal(t, []ComplianceDeadline{
+		{DeadlineType: "beneficial_ownership", DueDate: AddBusinessDays(incorporated, BeneficialOwnershipUpdateBusinessDays)},
+		{DeadlineType: "annual_return", DueDate: AddBusinessDays(time.Date(2027, time.March, 2, 10, 0, 0, 0, time.UTC), AnnualReturnBusinessDays)},
+	}, deadlines)
+}
+
+// CompanyRegistrationWorkflowTestSuite tests CompanyRegistrationWorkflow.
+type CompanyRegistrationWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestCompanyRegistrationWorkflowTestSuite runs the test suite.
+func TestCompanyRegistrationWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(CompanyRegistrationWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *CompanyRegistrationWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(DocumentCollectionWorkflow)
+	s.env.RegisterWorkflow(CombinedFilingWorkflow)
+	s.env.RegisterWorkflow(CIPCConfirmationWorkflow)
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, "+27721234567", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *CompanyRegistrationWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *CompanyRegistrationWorkflowTestSuite) input(registration CompanyRegistration) CompanyRegistrationInput {
+	return CompanyRegistrationInput{UserID: "user-1", PhoneNumber: "+27721234567", Registration: registration}
+}
+
+func (s *CompanyRegistrationWorkflowTestSuite) result() CompanyRegistrationResult {
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result CompanyRegistrationResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+// Test_RegistersAndOnboardsCompany tests the happy path from reserved name to onboarding.
+func (s *CompanyRegistrationWorkflowTestSuite) Test_RegistersAndOnboardsCompany() {
+	reservedUntil := time.Now().AddDate(0, 3, 0)
+	s.env.OnActivity(LoadReservedCompanyNameActivity, mock.Anything, "user-1", "name-reservation-1").Return(
+		&ReservedCompanyName{Status: NameReservationApproved, Name: "Umoya Coffee (Pty) Ltd", Reference: "NR1", ReservedUntil: &reservedUntil}, nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.ServiceType == CompanyRegistrationServiceType && in.FilingData["company_name"] == "Umoya Coffee (Pty) Ltd" && in.FilingData["moi"] == MOIShortStandard
+	})).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(DocumentCollectionWorkflow, mock.Anything, mock.MatchedBy(func(in DocumentCollectionInput) bool {
+		return in.TransactionID == "tx-1" && len(in.Requirements) == 1 && in.Requirements[0].Count == 2
+	})).Return(&DocumentCollectionResult{Complete: true, Documents: []CollectedDocument{
+		{DocumentID: "doc-1", DocumentType: DocumentTypeIDCopy}, {DocumentID: "doc-2", DocumentType: DocumentTypeIDCopy},
+	}}, nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.MatchedBy(func(in FilingWorkflowInput) bool {
+		return in.TransactionID == "tx-1" && in.ServiceType == CompanyRegistrationServiceType && in.CompanyRegNumber == ""
+	})).Return(&FilingWorkflowResult{Success: true, FilingReference: "CR1"}, nil).Once()
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.MatchedBy(func(in CIPCConfirmationInput) bool {
+		return in.Reference == "CR1" && in.Silent
+	})).Return(&CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: "CR1", RegistrationNumber: "2026/123456/07"}, nil).Once()
+	s.env.OnActivity(OnboardRegisteredCompanyActivity, mock.Anything, mock.MatchedBy(func(in CompanyOnboarding) bool {
+		return in.Name == "Umoya Coffee (Pty) Ltd" && in.RegistrationNumber == "2026/123456/07" &&
+			len(in.DocumentIDs) == 2 && len(in.Registration.Directors) == 1
+	})).Return("company-1", nil).Once()
+
+	s.env.ExecuteWorkflow(CompanyRegistrationWorkflow, s.input(validCompanyRegistration()))
+
+	result := s.result()
+	s.True(result.Success)
+	s.Equal("registered", result.Status)
+	s.Equal("company-1", result.CompanyID)
+	s.Equal("2026/123456/07", result.RegistrationNumber)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "Umoya Coffee (Pty) Ltd is registered")
+}
+
+// Test_RegistrationNumberName tests that a company registered without a name is named after
+// the registration number CIPC issues.
+func (s *CompanyRegistrationWorkflowTestSuite) Test_RegistrationNumberName() {
+	registration := validCompanyRegistration()
+	registration.Name = CompanyRegistrationName{UseRegistrationNumber: true}
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-2", nil).Once()
+	s.env.OnWorkflow(DocumentCollectionWorkflow, mock.Anything, mock.Anything).Return(&DocumentCollectionResult{Complete: true}, nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{Success: true, FilingReference: "CR2"}, nil).Once()
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.Anything).Return(
+		&CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: "CR2", RegistrationNumber: "2026/654321/07"}, nil).Once()
+	s.env.OnActivity(OnboardRegisteredCompanyActivity, mock.Anything, mock.MatchedBy(func(in CompanyOnboarding) bool {
+		return in.Name == "K2026654321 (South Africa) (Pty) Ltd"
+	})).Return("company-2", nil).Once()
+
+	s.env.ExecuteWorkflow(CompanyRegistrationWorkflow, s.input(registration))
+
+	result := s.result()
+	s.True(result.Success)
+	s.Equal("K2026654321 (South Africa) (Pty) Ltd", result.CompanyName)
+}
+
+// Test_LapsedReservationIsRefused tests that nothing is charged for a name that is no longer reserved.
+func (s *CompanyRegistrationWorkflowTestSuite) Test_LapsedReservationIsRefused() {
+	lapsed := time.Now().AddDate(0, -1, 0)
+	s.env.OnActivity(LoadReservedCompanyNameActivity, mock.Anything, "user-1", "name-reservation-1").Return(
+		&ReservedCompanyName{Status: NameReservationApproved, Name: "Umoya Coffee (Pty) Ltd", ReservedUntil: &lapsed}, nil).Once()
+
+	s.env.ExecuteWorkflow(CompanyRegistrationWorkflow, s.input(validCompanyRegistration()))
+
+	result := s.result()
+	s.Equal("name_unavailable", result.Status)
+	s.Require().Len(result.Problems, 1)
+	s.Contains(result.Problems[0], "lapsed")
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "We can't register your company yet")
+}
+
+// Test_RejectionIsNotOnboarded tests that a rejected registration leaves our records alone.
+func (s *CompanyRegistrationWorkflowTestSuite) Test_RejectionIsNotOnboarded() {
+	registration := validCompanyRegistration()
+	registration.Name = CompanyRegistrationName{UseRegistrationNumber: true}
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-3", nil).Once()
+	s.env.OnWorkflow(DocumentCollectionWorkflow, mock.Anything, mock.Anything).Return(&DocumentCollectionResult{Complete: true}, nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{Success: true, FilingReference: "CR3"}, nil).Once()
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.Anything).Return(
+		&CIPCFilingOutcome{Status: CIPCOutcomeRejected, Reference: "CR3", RejectionReason: "ID copy is not certified"}, nil).Once()
+
+	s.env.ExecuteWorkflow(CompanyRegistrationWorkflow, s.input(registration))
+
+	result := s.result()
+	s.False(result.Success)
+	s.Equal("rejected", result.Status)
+	s.Contains(s.messages[len(s.messages)-1], "Reason: ID copy is not certified")
+}
@@ -0,0 +1,386 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// CompanyRegistrationInput is the input for CompanyRegistrationWorkflow.
+type CompanyRegistrationInput struct {
+	UserID       string              `json:"user_id"`
+	PhoneNumber  string              `json:"phone_number"`
+	Registration CompanyRegistration `json:"registration"`
+	CustomerCode string              `json:"cipc_customer_code,omitempty"`
+	// IsUrgent is set when the customer paid for an urgent filing.
+	IsUrgent bool `json:"is_urgent,omitempty"`
+}
+
+// CompanyRegistrationResult is the result of CompanyRegistrationWorkflow.
+type CompanyRegistrationResult struct {
+	Success            bool     `json:"success"`
+	Status             string   `json:"status"`
+	TransactionID      string   `json:"transaction_id,omitempty"`
+	FilingReference    string   `json:"filing_reference,omitempty"`
+	CompanyName        string   `json:"company_name,omitempty"`
+	RegistrationNumber string   `json:"registration_number,omitempty"`
+	CompanyID          string   `json:"company_id,omitempty"`
+	Problems           []string `json:"problems,omitempty"`
+	ErrorMessage       string   `json:"error_message,omitempty"`
+}
+
+// ReservedCompanyName is a name_reservations row as seen by a company registration.
+type ReservedCompanyName struct {
+	Status        string     `json:"status"`
+	Name          string     `json:"name"`
+	Reference     string     `json:"reference"`
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+}
+
+// CompanyRegistrationWorkflow incorporates a private company with a standard MOI: it validates
+// the name, incorporators and directors, collects their ID copies, takes payment and submits the
+// CoR14.1 through the paid filing pipeline, then onboards the company into companies,
+// company_directors and compliance_deadlines once CIPC registers it.
+func CompanyRegistrationWorkflow(ctx workflow.Context, input CompanyRegistrationInput) (*CompanyRegistrationResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting CompanyRegistrationWorkflow", "UserID", input.UserID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	notify := func(message string) {
+		if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send WhatsApp message", "error", err)
+		}
+	}
+	invalid := func(status string, problems []string) *CompanyRegistrationResult {
+		notify("⚠️ *We can't register your company yet:*\n\n• " + strings.Join(problems, "\n• "))
+		return &CompanyRegistrationResult{Status: status, Problems: problems}
+	}
+
+	// Step 1: Validate the registration and the reserved name
+	registration := input.Registration.withDefaults()
+	if problems := ValidateCompanyRegistration(registration); len(problems) > 0 {
+		return invalid("invalid", problems), nil
+	}
+
+	var reserved ReservedCompanyName
+	if registration.Name.NameReservationID != "" {
+		if err := workflow.ExecuteActivity(ctx, LoadReservedCompanyNameActivity, input.UserID, registration.Name.NameReservationID).Get(ctx, &reserved); err != nil {
+			return nil, fmt.Errorf("failed to load name reservation: %w", err)
+		}
+		if problem := reservedNameProblem(reserved, workflow.Now(ctx)); problem != "" {
+			return invalid("name_unavailable", []string{problem}), nil
+		}
+	}
+
+	// Step 2: Price the registration and record the transaction the customer pays
+	filingData := map[string]interface{}{
+		"form":                    "CoR14.1",
+		"company_type":            CompanyTypePrivate,
+		"company_name":            reserved.Name,
+		"use_registration_number": registration.Name.UseRegistrationNumber,
+		"name_reservation_number": reserved.Reference,
+		"incorporators":           registration.Incorporators,
+		"directors":               registration.Directors,
+		"moi":                     registration.MOI,
+		"registered_address":      registration.RegisteredAddress,
+		"financial_year_end":      int(registration.FinancialYearEnd),
+		"authorised_shares":       registration.AuthorisedShares,
+		"cipc_customer_code":      input.CustomerCode,
+	}
+	var transactionID string
+	txInput := CreatePaygTransactionInput{
+		UserID:      input.UserID,
+		ServiceType: CompanyRegistrationServiceType,
+		IsUrgent:    input.IsUrgent,
+		FilingData:  filingData,
+	}
+	if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &transactionID); err != nil {
+		return nil, fmt.Errorf("failed to create company registration transaction: %w", err)
+	}
+	result := &CompanyRegistrationResult{TransactionID: transactionID, CompanyName: reserved.Name}
+
+	// Step 3: Collect certified ID copies of the incorporators and directors
+	var documents DocumentCollectionResult
+	dcwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "documents-" + transactionID,
+	}
+	err := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, dcwo), DocumentCollectionWorkflow, DocumentCollectionInput{
+		TransactionID: transactionID,
+		UserID:        input.UserID,
+		PhoneNumber:   input.PhoneNumber,
+		ServiceType:   CompanyRegistrationServiceType,
+		Purpose:       "your company registration",
+		Requirements:  CompanyRegistrationDocumentRequirements(registration),
+	}).Get(ctx, &documents)
+	if err != nil {
+		return nil, fmt.Errorf("document collection failed: %w", err)
+	}
+	if !documents.Complete {
+		result.Status, result.ErrorMessage = "documents_missing", "supporting documents not received"
+		return result, nil
+	}
+	filingData["documents"] = documents.Documents
+	filingData["document_ids"] = documents.DocumentIDs()
+
+	// Step 4: Run the paid filing pipeline (payment, OTP, submission) as a child workflow
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "filing-company-registration-" + transactionID,
+		TaskQueue:  FilingTaskQueue(input.IsUrgent),
+	}
+	var filing FilingWorkflowResult
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CombinedFilingWorkflow, FilingWorkflowInput{
+		TransactionID:   transactionID,
+		UserID:          input.UserID,
+		ServiceType:     CompanyRegistrationServiceType,
+		FilingData:      filingData,
+		IsUrgent:        input.IsUrgent,
+		CallerFollowsUp: true,
+	}).Get(ctx, &filing)
+	if err != nil {
+		return nil, fmt.Errorf("company registration filing failed: %w", err)
+	}
+	if !filing.Success {
+		result.Status, result.ErrorMessage = "failed", filing.ErrorMessage
+		return result, nil
+	}
+	result.FilingReference = filing.FilingReference
+
+	// Step 5: Follow the CoR14.1 until CIPC registers or rejects the company
+	var decision CIPCFilingOutcome
+	ccwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "cipc-confirmation-" + transactionID,
+	}
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, ccwo), CIPCConfirmationWorkflow, CIPCConfirmationInput{
+		TransactionID: transactionID,
+		UserID:        input.UserID,
+		ServiceType:   CompanyRegistrationServiceType,
+		Reference:     filing.FilingReference,
+		Silent:        true,
+	}).Get(ctx, &decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to follow up company registration with CIPC: %w", err)
+	}
+	switch decision.Status {
+	case CIPCOutcomePending:
+		logger.Warn("No CIPC outcome received for company registration", "reference", filing.FilingReference)
+		result.Status = "awaiting_cipc"
+		return result, nil
+	case CIPCOutcomeRejected:
+		notify(fmt.Sprintf("❌ *CIPC rejected your company registration*\n\nReference: %s\nReason: %s\n\nReply 'HELP' and we'll sort it out with you.", filing.FilingReference, decision.RejectionReason))
+		result.Status, result.ErrorMessage = "rejected", decision.RejectionReason
+		return result, nil
+	}
+	if decision.RegistrationNumber == "" {
+		return nil, fmt.Errorf("CIPC approved company registration %s without a registration number", filing.FilingReference)
+	}
+	result.RegistrationNumber = decision.RegistrationNumber
+	if registration.Name.UseRegistrationNumber {
+		if result.CompanyName, err = RegistrationNumberCompanyName(decision.RegistrationNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 6: Onboard the new company with its directors and first compliance deadlines
+	onboarding := CompanyOnboarding{
+		UserID:             input.UserID,
+		Name:               result.CompanyName,
+		RegistrationNumber: decision.RegistrationNumber,
+		Registration:       registration,
+		Reference:          filing.FilingReference,
+		CIPCReference:      decision.CIPCReference,
+		DocumentIDs:        documents.DocumentIDs(),
+		IncorporatedOn:     workflow.Now(ctx),
+	}
+	if err := workflow.ExecuteActivity(ctx, OnboardRegisteredCompanyActivity, onboarding).Get(ctx, &result.CompanyID); err != nil {
+		return nil, fmt.Errorf("failed to onboard registered company: %w", err)
+	}
+
+	notify(fmt.Sprintf("🎉 *%s is registered!*\n\nRegistration number: %s\n\nWe've added it to your account and will remind you before its beneficial ownership declaration and first annual return are due.",
+		result.CompanyName, result.RegistrationNumber))
+	result.Success, result.Status = true, "registered"
+	return result, nil
+}
+
+// reservedNameProblem explains why a name reservation cannot be used to register a company, or
+// returns "" when it can.
+func reservedNameProblem(reserved ReservedCompanyName, now time.Time) string {
+	switch {
+	case reserved.Status == "":
+		return "we couldn't find that name reservation on your account"
+	case reserved.Status != NameReservationApproved || reserved.Name == "":
+		return "that name reservation has not been approved by CIPC"
+	case reserved.ReservedUntil != nil && !now.Before(*reserved.ReservedUntil):
+		return fmt.Sprintf("the reservation of %s lapsed on %s; please reserve it again", reserved.Name, reserved.ReservedUntil.Format("2 January 2006"))
+	}
+	return ""
+}
+
+// LoadReservedCompanyNameActivity reads one of the user's name reservations. An unknown
+// reservation returns an empty ReservedCompanyName.
+func LoadReservedCompanyNameActivity(ctx context.Context, userID, reservationID string) (*ReservedCompanyName, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var reserved ReservedCompanyName
+	err = db.QueryRowContext(ctx, `
+		SELECT status, COALESCE(approved_name, ''), COALESCE(reference, ''), reserved_until
+		FROM name_reservations
+		WHERE workflow_id = $1 AND user_id = $2
+	`, reservationID, userID).Scan(&reserved.Status, &reserved.Name, &reserved.Reference, &reserved.ReservedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ReservedCompanyName{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reserved, nil
+}
+
+// CompanyOnboarding is a newly registered company to add to our records.
+type CompanyOnboarding struct {
+	UserID             string              `json:"user_id"`
+	Name               string              `json:"name"`
+	RegistrationNumber string              `json:"registration_number"`
+	Registration       CompanyRegistration `json:"registration"`
+	// Reference is the submission reference and CIPCReference the reference CIPC approved it under.
+	Reference     string `json:"reference"`
+	CIPCReference string `json:"cipc_reference,omitempty"`
+	// DocumentIDs are the documents collected for the registration, filed before the company existed.
+	DocumentIDs    []string  `json:"document_ids,omitempty"`
+	IncorporatedOn time.Time `json:"incorporated_on"`
+}
+
+// OnboardRegisteredCompanyActivity adds a company CIPC has registered to companies with its
+// directors, records the registration in cipc_filings, files the registration documents
+// against it and creates its first compliance_deadlines. It is safe to retry and returns the
+// company's ID.
+func OnboardRegisteredCompanyActivity(ctx context.Context, onboarding CompanyOnboarding) (string, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Onboarding registered company", "registration_number", onboarding.RegistrationNumber)
+
+	submission, err := json.Marshal(onboarding.Registration)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal registration: %w", err)
+	}
+	deadlines := NewCompanyComplianceDeadlines(onboarding.IncorporatedOn)
+	var annualReturnDue time.Time
+	for _, d := range deadlines {
+		if d.DeadlineType == "annual_return" {
+			annualReturnDue = d.DueDate
+		}
+	}
+	cipcReference := onboarding.CIPCReference
+	if cipcReference == "" {
+		cipcReference = onboarding.Reference
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var companyID string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO companies (user_id, name, registration_number, compliance_status, next_filing_date)
+		VALUES ($1, $2, $3, 'compliant', $4)
+		ON CONFLICT (registration_number) DO UPDATE SET updated_at = NOW()
+		RETURNING id
+	`, onboarding.UserID, onboarding.Name, onboarding.RegistrationNumber, annualReturnDue).Scan(&companyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create company: %w", err)
+	}
+
+	var cipcFilingID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM cipc_filings WHERE company_id = $1 AND filing_type = 'company_registration'
+	`, companyID).Scan(&cipcFilingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO cipc_filings (company_id, filing_type, status, submitted_at, cipc_reference, submission_data, decided_at)
+			VALUES ($1, 'company_registration', 'approved', NOW(), $2, $3, NOW())
+			RETURNING id
+		`, companyID, cipcReference, submission).Scan(&cipcFilingID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to record company registration filing: %w", err)
+	}
+
+	for _, d := range onboarding.Registration.Directors {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO company_directors (company_id, full_name, id_number, residential_address, email, appointment_date, cipc_filing_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (company_id, id_number) WHERE status = 'active' DO NOTHING
+		`, companyID, d.FullName, d.IDNumber, d.ResidentialAddress, d.Email, onboarding.IncorporatedOn, cipcFilingID)
+		if err != nil {
+			return "", fmt.Errorf("failed to record director %s: %w", d.FullName, err)
+		}
+	}
+
+	if len(onboarding.DocumentIDs) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE documents SET company_id = $1, cipc_filing_id = $2 WHERE id = ANY($3) AND company_id IS NULL
+		`, companyID, cipcFilingID, onboarding.DocumentIDs)
+		if err != nil {
+			return "", fmt.Errorf("failed to file registration documents: %w", err)
+		}
+	}
+
+	for _, d := range deadlines {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO compliance_deadlines (user_id, company_reg_number, deadline_type, due_date, status)
+			SELECT $1, $2, $3, $4, 'pending'
+			WHERE NOT EXISTS (
+				SELECT 1 FROM compliance_deadlines
+				WHERE company_reg_number = $2 AND deadline_type = $3 AND status = 'pending'
+			)
+		`, onboarding.UserID, onboarding.RegistrationNumber, d.DeadlineType, d.DueDate)
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s deadline: %w", d.DeadlineType, err)
+		}
+	}
+
+	// Customers registering their first company have no company on their account yet.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET company_reg_number = $2 WHERE id = $1 AND company_reg_number IS NULL
+	`, onboarding.UserID, onboarding.RegistrationNumber); err != nil {
+		return "", err
+	}
+
+	// The reserved name is now taken by the company rather than held for the customer.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE cipc_registered_names SET status = 'registered', registration_number = $2, reserved_until = NULL
+		WHERE normalized_name = $1
+	`, normalizeCompanyName(onboarding.Name), onboarding.RegistrationNumber); err != nil {
+		return "", err
+	}
+
+	return companyID, tx.Commit()
+}
@@ -0,0 +1,376 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// Company statuses, recorded in companies.company_status. They follow the stages CIPC takes a
+// company through when it stops filing annual returns.
+const (
+	CompanyStatusInBusiness = "in_business"
+	// CompanyStatusARNonCompliant is a company with an annual return outstanding.
+	CompanyStatusARNonCompliant = "ar_non_compliant"
+	// CompanyStatusInDeregistration is a company CIPC is deregistering for not filing annual
+	// returns. Filing every outstanding return stops the process.
+	CompanyStatusInDeregistration = "in_deregistration"
+	// CompanyStatusDeregistered is a company CIPC has finally deregistered. It has to be
+	// reinstated before anything else can be filed.
+	CompanyStatusDeregistered = "deregistered"
+)
+
+// Compliance alert severities, recorded in compliance_alerts.severity.
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityHigh     = "high"
+	AlertSeverityCritical = "critical"
+)
+
+// ReinstatementServiceType is the pay-as-you-go service that applies to CIPC to reinstate a
+// deregistered company.
+const ReinstatementServiceType = "company_reinstatement"
+
+// ARDeregistrationThreshold is how many annual returns a company can have outstanding before
+// CIPC starts deregistering it; section 82(3) of the Companies Act allows it after two years.
+const ARDeregistrationThreshold = 2
+
+// companyStatusAlertType is the compliance_alerts.alert_type of company status changes.
+const companyStatusAlertType = "company_status"
+
+// deregisteredCIPCStatuses are the CIPC enterprise statuses of a finally deregistered company.
+var deregisteredCIPCStatuses = map[string]bool{
+	"final deregistration":    true,
+	"ar final deregistration": true,
+	"deregistration final":    true,
+	"deregistered":            true,
+}
+
+// companyStatusRank orders the statuses from good standing to deregistered.
+var companyStatusRank = map[string]int{
+	CompanyStatusInBusiness:       0,
+	CompanyStatusARNonCompliant:   1,
+	CompanyStatusInDeregistration: 2,
+	CompanyStatusDeregistered:     3,
+}
+
+// OutstandingAnnualReturn is an annual return deadline that has passed without a filing.
+type OutstandingAnnualReturn struct {
+	DeadlineID string    `json:"deadline_id"`
+	DueDate    time.Time `json:"due_date"`
+}
+
+// CompanyStanding is what we know about a company's standing with CIPC.
+type CompanyStanding struct {
+	CompanyID          string `json:"company_id"`
+	UserID             string `json:"user_id"`
+	Name               string `json:"name"`
+	RegistrationNumber string `json:"registration_number"`
+	// Status is the company status last recorded.
+	Status string `json:"status"`
+	// CIPCStatus is the enterprise status CIPC last reported, e.g. "AR Deregistration Process".
+	CIPCStatus string `json:"cipc_status,omitempty"`
+	// AnnualReturns are the company's annual return deadlines that have not been completed.
+	AnnualReturns []OutstandingAnnualReturn `json:"annual_returns,omitempty"`
+}
+
+// CompanyStatusAssessment is a company's status and what it takes to restore it.
+type CompanyStatusAssessment struct {
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+	Reason   string `json:"reason"`
+	// Outstanding are the overdue annual returns, oldest first.
+	Outstanding []OutstandingAnnualReturn `json:"outstanding,omitempty"`
+}
+
+// AssessCompanyStatus works out a company's status from its overdue annual returns and the
+// status CIPC reports. CIPC's status wins when it is worse than the returns alone suggest, e.g.
+// when CIPC has deregistered a company whose returns we were never told about.
+func AssessCompanyStatus(standing CompanyStanding, now time.Time) CompanyStatusAssessment {
+	var outstanding []OutstandingAnnualReturn
+	for _, ar := range standing.AnnualReturns {
+		if ar.DueDate.Before(now) {
+			outstanding = append(outstanding, ar)
+		}
+	}
+	sort.Slice(outstanding, func(i, j int) bool { return outstanding[i].DueDate.Before(outstanding[j].DueDate) })
+	assessment := CompanyStatusAssessment{Status: CompanyStatusInBusiness, Severity: AlertSeverityInfo, Outstanding: outstanding}
+
+	switch {
+	case len(outstanding) >= ARDeregistrationThreshold:
+		assessment.Status, assessment.Severity = CompanyStatusInDeregistration, AlertSeverityHigh
+		assessment.Reason = fmt.Sprintf("%d annual returns are outstanding; CIPC deregisters companies that miss %d or more", len(outstanding), ARDeregistrationThreshold)
+	case len(outstanding) == 1:
+		assessment.Status, assessment.Severity = CompanyStatusARNonCompliant, AlertSeverityWarning
+		assessment.Reason = fmt.Sprintf("the annual return due on %s is outstanding", outstanding[0].DueDate.Format("2 January 2006"))
+	default:
+		assessment.Reason = "all annual returns are up to date"
+	}
+
+	cipcStatus := strings.ToLower(strings.TrimSpace(standing.CIPCStatus))
+	switch {
+	case deregisteredCIPCStatuses[cipcStatus]:
+		assessment.Status, assessment.Severity = CompanyStatusDeregistered, AlertSeverityCritical
+		assessment.Reason = fmt.Sprintf("CIPC lists the company as '%s'", standing.CIPCStatus)
+	case strings.Contains(cipcStatus, "deregistration") && assessment.Status != CompanyStatusInDeregistration:
+		assessment.Status, assessment.Severity = CompanyStatusInDeregistration, AlertSeverityHigh
+		assessment.Reason = fmt.Sprintf("CIPC lists the company as '%s'", standing.CIPCStatus)
+	}
+	return assessment
+}
+
+// CatchUpFiling is one filing needed to restore a company to good standing.
+type CatchUpFiling struct {
+	ServiceType string `json:"service_type"`
+	// DeadlineID and DueDate identify the annual return filed, for annual_return filings.
+	DeadlineID string    `json:"deadline_id,omitempty"`
+	DueDate    time.Time `json:"due_date,omitempty"`
+}
+
+// Description names the filing in customer messages.
+func (f CatchUpFiling) Description() string {
+	if f.ServiceType == "annual_return" {
+		return "annual return due " + f.DueDate.Format("2 January 2006")
+	}
+	return "application for reinstatement"
+}
+
+// CatchUpPlan lists the filings that restore a company: a reinstatement application if it has
+// been deregistered, then every outstanding annual return, oldest first.
+func CatchUpPlan(assessment CompanyStatusAssessment) []CatchUpFiling {
+	var plan []CatchUpFiling
+	if assessment.Status == CompanyStatusDeregistered {
+		plan = append(plan, CatchUpFiling{ServiceType: ReinstatementServiceType})
+	}
+	for _, ar := range assessment.Outstanding {
+		plan = append(plan, CatchUpFiling{ServiceType: "annual_return", DeadlineID: ar.DeadlineID, DueDate: ar.DueDate})
+	}
+	return plan
+}
+
+// CompanyStatusChange is a company whose status was reassessed.
+type CompanyStatusChange struct {
+	CompanyID          string                  `json:"company_id"`
+	UserID             string                  `json:"user_id"`
+	Name               string                  `json:"name"`
+	RegistrationNumber string                  `json:"registration_number"`
+	Previous           string                  `json:"previous"`
+	Assessment         CompanyStatusAssessment `json:"assessment"`
+}
+
+// Changed reports whether the company's status is different from the one last recorded.
+func (c CompanyStatusChange) Changed() bool {
+	return c.Previous != c.Assessment.Status
+}
+
+// Worsened reports whether the company has moved further towards deregistration.
+func (c CompanyStatusChange) Worsened() bool {
+	return companyStatusRank[c.Assessment.Status] > companyStatusRank[c.Previous]
+}
+
+// NeedsCatchUp reports whether the company has to be taken through ReinstatementWorkflow.
+func (c CompanyStatusChange) NeedsCatchUp() bool {
+	return c.Assessment.Status == CompanyStatusInDeregistration || c.Assessment.Status == CompanyStatusDeregistered
+}
+
+// CompanyStatusAlertMessage tells the customer about a change in their company's status.
+func CompanyStatusAlertMessage(change CompanyStatusChange) string {
+	a := change.Assessment
+	switch a.Status {
+	case CompanyStatusDeregistered:
+		return fmt.Sprintf("🚨 *%s has been deregistered by CIPC*\n\n%s. The company can't trade, open accounts or file anything until it is reinstated.\n\nWe'll send you what it takes to reinstate it and file the outstanding annual returns.",
+			change.Name, capitalise(a.Reason))
+	case CompanyStatusInDeregistration:
+		return fmt.Sprintf("🚨 *%s is being deregistered by CIPC*\n\n%s. Filing every outstanding annual return stops the deregistration.\n\nWe'll send you what it takes to catch up.",
+			change.Name, capitalise(a.Reason))
+	case CompanyStatusARNonCompliant:
+		return fmt.Sprintf("⚠️ *%s has an overdue annual return*\n\n%s. CIPC starts deregistering companies with %d outstanding annual returns, so please file it soon.",
+			change.Name, capitalise(a.Reason), ARDeregistrationThreshold)
+	}
+	return fmt.Sprintf("✅ *%s is back in business*\n\nCIPC's records show it in good standing again.", change.Name)
+}
+
+func capitalise(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// ErrCompanyNotFound is returned for companies that are not on record.
+var ErrCompanyNotFound = errors.New("company not found")
+
+// LoadCompanyStanding reads a company's recorded status, CIPC status and open annual return
+// deadlines.
+func LoadCompanyStanding(ctx context.Context, db *sql.DB, companyID string) (*CompanyStanding, error) {
+	standing := &CompanyStanding{CompanyID: companyID}
+	var cipcStatus sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT user_id, name, registration_number, company_status, cipc_status FROM companies WHERE id = $1
+	`, companyID).Scan(&standing.UserID, &standing.Name, &standing.RegistrationNumber, &standing.Status, &cipcStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCompanyNotFound, companyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load company: %w", err)
+	}
+	standing.CIPCStatus = cipcStatus.String
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, due_date FROM compliance_deadlines
+		WHERE company_reg_number = $1 AND deadline_type = 'annual_return' AND status IN ('pending', 'overdue')
+		ORDER BY due_date
+	`, standing.RegistrationNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load annual return deadlines: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ar OutstandingAnnualReturn
+		if err := rows.Scan(&ar.DeadlineID, &ar.DueDate); err != nil {
+			return nil, err
+		}
+		standing.AnnualReturns = append(standing.AnnualReturns, ar)
+	}
+	return standing, rows.Err()
+}
+
+// RefreshCompanyStatus reassesses a company and records its new status. A change is recorded as
+// a compliance alert whose severity follows the new status.
+func RefreshCompanyStatus(ctx context.Context, db *sql.DB, companyID string, now time.Time) (*CompanyStatusChange, error) {
+	standing, err := LoadCompanyStanding(ctx, db, companyID)
+	if err != nil {
+		return nil, err
+	}
+	change := &CompanyStatusChange{
+		CompanyID:          standing.CompanyID,
+		UserID:             standing.UserID,
+		Name:               standing.Name,
+		RegistrationNumber: standing.RegistrationNumber,
+		Previous:           standing.Status,
+		Assessment:         AssessCompanyStatus(*standing, now),
+	}
+	if !change.Changed() {
+		return change, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE companies SET company_status = $2, status_changed_at = NOW(), updated_at = NOW() WHERE id = $1
+	`, companyID, change.Assessment.Status); err != nil {
+		return nil, fmt.Errorf("failed to update company status: %w", err)
+	}
+	var dueDate *time.Time
+	if len(change.Assessment.Outstanding) > 0 {
+		dueDate = &change.Assessment.Outstanding[0].DueDate
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO compliance_alerts (company_id, alert_type, severity, message, due_date)
+		VALUES ($1, $2, $3, $4, $5)
+	`, companyID, companyStatusAlertType, change.Assessment.Severity, CompanyStatusAlertMessage(*change), dueDate); err != nil {
+		return nil, fmt.Errorf("failed to record company status alert: %w", err)
+	}
+	return change, tx.Commit()
+}
+
+// RecordCIPCCompanyStatus stores the enterprise status CIPC reports for a company and reassesses
+// it.
+func RecordCIPCCompanyStatus(ctx context.Context, db *sql.DB, regNumber, cipcStatus string, now time.Time) (*CompanyStatusChange, error) {
+	var companyID string
+	err := db.QueryRowContext(ctx, `
+		UPDATE companies SET cipc_status = $2, updated_at = NOW() WHERE registration_number = $1 RETURNING id
+	`, regNumber, strings.TrimSpace(cipcStatus)).Scan(&companyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCompanyNotFound, regNumber)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record CIPC status: %w", err)
+	}
+	return RefreshCompanyStatus(ctx, db, companyID, now)
+}
+
+// CheckDeregistrationRiskActivity marks the user's missed deadlines overdue and reassesses each
+// of their companies, returning the companies whose status changed.
+func CheckDeregistrationRiskActivity(ctx context.Context, userID string) ([]CompanyStatusChange, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `
+		UPDATE compliance_deadlines SET status = 'overdue' WHERE user_id = $1 AND status = 'pending' AND due_date < NOW()
+	`, userID); err != nil {
+		return nil, fmt.Errorf("failed to mark overdue deadlines: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM companies WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load companies: %w", err)
+	}
+	var companyIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		companyIDs = append(companyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var changes []CompanyStatusChange
+	for _, id := range companyIDs {
+		change, err := RefreshCompanyStatus(ctx, db, id, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if change.Changed() {
+			activity.GetLogger(ctx).Info("Company status changed", "company_id", id, "from", change.Previous, "to", change.Assessment.Status)
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+// SendCompanyStatusAlertsActivity tells the user about their companies' status changes over
+// WhatsApp and marks the alerts as sent.
+func SendCompanyStatusAlertsActivity(ctx context.Context, userID string, changes []CompanyStatusChange) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var phoneNumber string
+	if err := db.QueryRowContext(ctx, "SELECT phone_number FROM users WHERE id = $1", userID).Scan(&phoneNumber); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := sendWhatsAppMessage(phoneNumber, CompanyStatusAlertMessage(change)); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `
+			UPDATE compliance_alerts SET sent_via_whatsapp = true
+			WHERE company_id = $1 AND alert_type = $2 AND NOT sent_via_whatsapp
+		`, change.CompanyID, companyStatusAlertType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
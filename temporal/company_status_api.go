@@ -0,0 +1,113 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// CIPCCompanyStatusRequest is the body of POST /companies/cipc-status.
+type CIPCCompanyStatusRequest struct {
+	CompanyRegNumber string `json:"company_reg_number"`
+	CIPCStatus       string `json:"cipc_status"`
+}
+
+// ReinstatementResponse is returned by POST /reinstatements.
+type ReinstatementResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+func (s *APIServer) registerCompanyStatusRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /companies/cipc-status", requireInternalAPIKey(s.recordCIPCCompanyStatusHandler))
+	mux.HandleFunc("POST /reinstatements", requireInternalAPIKey(s.createReinstatementHandler))
+}
+
+// recordCIPCCompanyStatusHandler records the enterprise status CIPC shows for a company, e.g.
+// from a CIPC notice or an enterprise search, and returns the company's reassessed status. A
+// company that has moved into deregistration is taken through ReinstatementWorkflow.
+func (s *APIServer) recordCIPCCompanyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var req CIPCCompanyStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.CompanyRegNumber == "" || strings.TrimSpace(req.CIPCStatus) == "" {
+		writeError(w, http.StatusBadRequest, "company_reg_number and cipc_status are required")
+		return
+	}
+
+	change, err := RecordCIPCCompanyStatus(r.Context(), s.DB, req.CompanyRegNumber, req.CIPCStatus, time.Now())
+	if errors.Is(err, ErrCompanyNotFound) {
+		writeError(w, http.StatusNotFound, "Company not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording CIPC status for %s: %s", req.CompanyRegNumber, err)
+		writeError(w, http.StatusInternalServerError, "Unable to record CIPC status")
+		return
+	}
+	if change.Worsened() && change.NeedsCatchUp() {
+		_, err := s.startReinstatement(r.Context(), ReinstatementInput{UserID: change.UserID, CompanyID: change.CompanyID})
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if err != nil && !errors.As(err, &alreadyStarted) {
+			log.Printf("Error starting reinstatement for company %s: %s", change.CompanyID, err)
+		}
+	}
+	writeJSON(w, http.StatusOK, change)
+}
+
+// createReinstatementHandler starts a ReinstatementWorkflow for a company. A company has at most
+// one running, whether it was started here or by the daily compliance check. Only the company's
+// owner can start one.
+func (s *APIServer) createReinstatementHandler(w http.ResponseWriter, r *http.Request) {
+	var input ReinstatementInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.CompanyID == "" {
+		writeError(w, http.StatusBadRequest, "user_id and company_id are required")
+		return
+	}
+	standing, err := LoadCompanyStanding(r.Context(), s.DB, input.CompanyID)
+	if errors.Is(err, ErrCompanyNotFound) {
+		writeError(w, http.StatusNotFound, "Company not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading company %s: %s", input.CompanyID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start reinstatement")
+		return
+	}
+	if standing.UserID != input.UserID {
+		writeError(w, http.StatusForbidden, "Company does not belong to this user")
+		return
+	}
+
+	run, err := s.startReinstatement(r.Context(), input)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		writeError(w, http.StatusConflict, "A reinstatement is already in progress for this company")
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting reinstatement for company %s: %s", input.CompanyID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start reinstatement")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, ReinstatementResponse{WorkflowID: run.GetID()})
+}
+
+// startReinstatement starts a company's ReinstatementWorkflow, failing with
+// WorkflowExecutionAlreadyStarted if one is already running.
+func (s *APIServer) startReinstatement(ctx context.Context, input ReinstatementInput) (client.WorkflowRun, error) {
+	return s.Temporal.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:                                       "reinstatement-" + input.CompanyID,
+		TaskQueue:                                TaskQueue,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}, ReinstatementWorkflow, input)
+}
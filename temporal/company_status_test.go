@@ -0,0 +1,214 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestAssessCompanyStatus(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	ar2025 := OutstandingAnnualReturn{DeadlineID: "ar-2025", DueDate: time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	ar2026 := OutstandingAnnualReturn{DeadlineID: "ar-2026", DueDate: time.Date(2026, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	ar2027 := OutstandingAnnualReturn{DeadlineID: "ar-2027", DueDate: time.Date(2027, time.April, 14, 0, 0, 0, 0, time.UTC)}
+
+	a := AssessCompanyStatus(CompanyStanding{AnnualReturns: []OutstandingAnnualReturn{ar2027}}, now)
+	assert.Equal(t, CompanyStatusInBusiness, a.Status)
+	assert.Equal(t, AlertSeverityInfo, a.Severity)
+	assert.Empty(t, a.Outstanding, "returns that are not yet due are not outstanding")
+
+	a = AssessCompanyStatus(CompanyStanding{AnnualReturns: []OutstandingAnnualReturn{ar2026, ar2027}}, now)
+	assert.Equal(t, CompanyStatusARNonCompliant, a.Status)
+	assert.Equal(t, AlertSeverityWarning, a.Severity)
+	assert.Equal(t, "the annual return due on 14 April 2026 is outstanding", a.Reason)
+
+	a = AssessCompanyStatus(CompanyStanding{AnnualReturns: []OutstandingAnnualReturn{ar2026, ar2025}}, now)
+	assert.Equal(t, CompanyStatusInDeregistration, a.Status)
+	assert.Equal(t, AlertSeverityHigh, a.Severity)
+	assert.Equal(t, []OutstandingAnnualReturn{ar2025, ar2026}, a.Outstanding)
+
+	a = AssessCompanyStatus(CompanyStanding{CIPCStatus: "AR Deregistration Process", AnnualReturns: []OutstandingAnnualReturn{ar2026}}, now)
+	assert.Equal(t, CompanyStatusInDeregistration, a.Status)
+	assert.Equal(t, "CIPC lists the company as 'AR Deregistration Process'", a.Reason)
+
+	a = AssessCompanyStatus(CompanyStanding{CIPCStatus: "AR Final Deregistration", AnnualReturns: []OutstandingAnnualReturn{ar2025, ar2026}}, now)
+	assert.Equal(t, CompanyStatusDeregistered, a.Status)
+	assert.Equal(t, AlertSeverityCritical, a.Severity)
+	assert.Len(t, a.Outstanding, 2)
+}
+
+func TestCatchUpPlan(t *testing.T) {
+	due := time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)
+	outstanding := []OutstandingAnnualReturn{{DeadlineID: "ar-2025", DueDate: due}}
+
+	plan := CatchUpPlan(CompanyStatusAssessment{Status: CompanyStatusDeregistered, Outstanding: outstanding})
+	assert.Equal(t, []CatchUpFiling{
+		{ServiceType: ReinstatementServiceType},
+		{ServiceType: "annual_return", DeadlineID: "ar-2025", DueDate: due},
+	}, plan)
+	assert.Equal(t, "application for reinstatement", plan[0].Description())
+	assert.Equal(t, "annual return due 14 April 2025", plan[1].Description())
+
+	assert.Empty(t, CatchUpPlan(CompanyStatusAssessment{Status: CompanyStatusInBusiness}))
+}
+
+func TestCompanyStatusChange(t *testing.T) {
+	change := CompanyStatusChange{Name: "Umoya Coffee (Pty) Ltd", Previous: CompanyStatusARNonCompliant,
+		Assessment: CompanyStatusAssessment{Status: CompanyStatusInDeregistration, Reason: "2 annual returns are outstanding"}}
+	assert.True(t, change.Changed())
+	assert.True(t, change.Worsened())
+	assert.True(t, change.NeedsCatchUp())
+	assert.Contains(t, CompanyStatusAlertMessage(change), "Umoya Coffee (Pty) Ltd is being deregistered by CIPC")
+
+	change.Previous, change.Assessment.Status = CompanyStatusDeregistered, CompanyStatusInBusiness
+	assert.False(t, change.Worsened())
+	assert.False(t, change.NeedsCatchUp())
+	assert.Contains(t, CompanyStatusAlertMessage(change), "back in business")
+}
+
+// ReinstatementWorkflowTestSuite tests ReinstatementWorkflow.
+type ReinstatementWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestReinstatementWorkflowTestSuite runs the test suite.
+func TestReinstatementWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(ReinstatementWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *ReinstatementWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(CombinedFilingWorkflow)
+	s.env.RegisterWorkflow(CIPCConfirmationWorkflow)
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, "user-1", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.Anything).Return("conversation-1", nil).Maybe()
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *ReinstatementWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *ReinstatementWorkflowTestSuite) result() ReinstatementResult {
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result ReinstatementResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+func (s *ReinstatementWorkflowTestSuite) change(status, reason string, outstanding ...OutstandingAnnualReturn) *CompanyStatusChange {
+	return &CompanyStatusChange{CompanyID: "company-1", UserID: "user-1", Name: "Umoya Coffee (Pty) Ltd", RegistrationNumber: "2020/123456/07",
+		Previous: status, Assessment: CompanyStatusAssessment{Status: status, Reason: reason, Outstanding: outstanding}}
+}
+
+func (s *ReinstatementWorkflowTestSuite) approveFiling() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FileConfirmSignalName, true)
+	}, time.Minute)
+}
+
+// Test_ReinstatesAndCatchesUp tests that a deregistered company is reinstated before its
+// outstanding annual return is filed, each charged and followed separately.
+func (s *ReinstatementWorkflowTestSuite) Test_ReinstatesAndCatchesUp() {
+	ar := OutstandingAnnualReturn{DeadlineID: "ar-2025", DueDate: time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	s.env.OnActivity(RefreshCompanyStatusActivity, mock.Anything, "company-1").Return(
+		s.change(CompanyStatusDeregistered, "CIPC lists the company as 'AR Final Deregistration'", ar), nil).Once()
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", ReinstatementServiceType, false).Return(
+		&FilingFeeQuote{ServiceType: ReinstatementServiceType, Amount: 799}, nil).Once()
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", "annual_return", false).Return(
+		&FilingFeeQuote{ServiceType: "annual_return", Amount: 199}, nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.ServiceType == ReinstatementServiceType && in.FilingData["company_id"] == "company-1"
+	})).Return("tx-1", nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.ServiceType == "annual_return" && in.FilingData["deadline_id"] == "ar-2025"
+	})).Return("tx-2", nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.MatchedBy(func(in FilingWorkflowInput) bool {
+		return in.TransactionID == "tx-1" && in.CompanyRegNumber == "2020/123456/07"
+	})).Return(&FilingWorkflowResult{Success: true, FilingReference: "RI1"}, nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.MatchedBy(func(in FilingWorkflowInput) bool {
+		return in.TransactionID == "tx-2"
+	})).Return(&FilingWorkflowResult{Success: true, FilingReference: "AR1"}, nil).Once()
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.MatchedBy(func(in CIPCConfirmationInput) bool {
+		return in.Silent && (in.Reference == "RI1" || in.Reference == "AR1")
+	})).Return(&CIPCFilingOutcome{Status: CIPCOutcomeApproved}, nil).Twice()
+	s.env.OnActivity(RecordCatchUpFilingActivity, mock.Anything, "company-1", CatchUpFiling{ServiceType: ReinstatementServiceType}).Return(nil).Once()
+	s.env.OnActivity(RecordCatchUpFilingActivity, mock.Anything, "company-1", CatchUpFiling{ServiceType: "annual_return", DeadlineID: "ar-2025", DueDate: ar.DueDate}).Return(nil).Once()
+	s.env.OnActivity(RefreshCompanyStatusActivity, mock.Anything, "company-1").Return(
+		&CompanyStatusChange{CompanyID: "company-1", Name: "Umoya Coffee (Pty) Ltd", Previous: CompanyStatusDeregistered,
+			Assessment: CompanyStatusAssessment{Status: CompanyStatusInBusiness}}, nil).Once()
+	s.approveFiling()
+
+	s.env.ExecuteWorkflow(ReinstatementWorkflow, ReinstatementInput{UserID: "user-1", CompanyID: "company-1"})
+
+	result := s.result()
+	s.True(result.Success)
+	s.Equal("completed", result.Status)
+	s.Equal(CompanyStatusInBusiness, result.CompanyStatus)
+	s.Equal(998.0, result.Amount)
+	s.Require().Len(result.Filings, 2)
+	s.Equal("RI1", result.Filings[0].FilingReference)
+	s.Require().Len(s.messages, 2)
+	s.Contains(s.messages[0], "Application for reinstatement: R799.00")
+	s.Contains(s.messages[0], "Annual return due 14 April 2025: R199.00")
+	s.Contains(s.messages[1], "back in business")
+}
+
+// Test_RejectionStopsThePlan tests that the remaining annual returns are not filed once CIPC
+// rejects one.
+func (s *ReinstatementWorkflowTestSuite) Test_RejectionStopsThePlan() {
+	ar1 := OutstandingAnnualReturn{DeadlineID: "ar-2025", DueDate: time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	ar2 := OutstandingAnnualReturn{DeadlineID: "ar-2026", DueDate: time.Date(2026, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	change := s.change(CompanyStatusInDeregistration, "2 annual returns are outstanding", ar1, ar2)
+	s.env.OnActivity(RefreshCompanyStatusActivity, mock.Anything, "company-1").Return(change, nil).Twice()
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", "annual_return", false).Return(
+		&FilingFeeQuote{ServiceType: "annual_return", Amount: 199}, nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{Success: true, FilingReference: "AR1"}, nil).Once()
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.Anything).Return(
+		&CIPCFilingOutcome{Status: CIPCOutcomeRejected, RejectionReason: "Turnover missing"}, nil).Once()
+	s.approveFiling()
+
+	s.env.ExecuteWorkflow(ReinstatementWorkflow, ReinstatementInput{UserID: "user-1", CompanyID: "company-1"})
+
+	result := s.result()
+	s.False(result.Success)
+	s.Equal("rejected", result.Status)
+	s.Equal(398.0, result.Amount)
+	s.Len(result.Filings, 1)
+	s.Require().Len(s.messages, 2)
+	s.Contains(s.messages[1], "CIPC rejected the annual return due 14 April 2025")
+	s.Contains(s.messages[1], "Turnover missing")
+}
+
+// Test_PlanNotAccepted tests that nothing is charged when the customer does not reply FILE.
+func (s *ReinstatementWorkflowTestSuite) Test_PlanNotAccepted() {
+	ar := OutstandingAnnualReturn{DeadlineID: "ar-2025", DueDate: time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)}
+	s.env.OnActivity(RefreshCompanyStatusActivity, mock.Anything, "company-1").Return(
+		s.change(CompanyStatusARNonCompliant, "the annual return due on 14 April 2025 is outstanding", ar), nil).Once()
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", "annual_return", false).Return(
+		&FilingFeeQuote{ServiceType: "annual_return", Amount: 199}, nil).Once()
+
+	s.env.ExecuteWorkflow(ReinstatementWorkflow, ReinstatementInput{UserID: "user-1", CompanyID: "company-1"})
+
+	result := s.result()
+	s.Equal("not_approved", result.Status)
+	s.Empty(result.Filings)
+}
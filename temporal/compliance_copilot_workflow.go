@@ -3,6 +3,7 @@ package temporal
 import (
 	"time"
 
+	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/workflow"
 )
 
@@ -19,21 +20,47 @@ func ComplianceCopilotWorkflow(ctx workflow.Context, userID string) (string, err
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	// 1. Calculate current compliance health score
+	// 1. Check whether any of the user's companies are at risk of deregistration
+	var statusChanges []CompanyStatusChange
+	err := workflow.ExecuteActivity(ctx, CheckDeregistrationRiskActivity, userID).Get(ctx, &statusChanges)
+	if err != nil {
+		return "", err
+	}
+	if len(statusChanges) > 0 {
+		err = workflow.ExecuteActivity(ctx, SendCompanyStatusAlertsActivity, userID, statusChanges).Get(ctx, nil)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, change := range statusChanges {
+		if change.Worsened() && change.NeedsCatchUp() {
+			// Guide the customer through catching up; the workflow outlives this check.
+			cwo := workflow.ChildWorkflowOptions{
+				WorkflowID:        "reinstatement-" + change.CompanyID,
+				ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+			}
+			child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), ReinstatementWorkflow, ReinstatementInput{UserID: userID, CompanyID: change.CompanyID})
+			if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+				workflow.GetLogger(ctx).Warn("Failed to start reinstatement workflow", "company_id", change.CompanyID, "error", err)
+			}
+		}
+	}
+
+	// 2. Calculate current compliance health score
 	var complianceScore int
-	err := workflow.ExecuteActivity(ctx, CalculateComplianceHealthScoreActivity, userID).Get(ctx, &complianceScore)
+	err = workflow.ExecuteActivity(ctx, CalculateComplianceHealthScoreActivity, userID).Get(ctx, &complianceScore)
 	if err != nil {
 		return "", err
 	}
 
-	// 2. Check for upcoming deadlines
+	// 3. Check for upcoming deadlines
 	var upcomingDeadlines []string
 	err = workflow.ExecuteActivity(ctx, CheckUpcomingDeadlinesActivity, userID).Get(ctx, &upcomingDeadlines)
 	if err != nil {
 		return "", err
 	}
 
-	// 3. Send proactive compliance alerts if needed
+	// 4. Send proactive compliance alerts if needed
 	if complianceScore < 80 || len(upcomingDeadlines) > 0 {
 		err = workflow.ExecuteActivity(ctx, SendComplianceAlertActivity, userID, complianceScore, upcomingDeadlines).Get(ctx, nil)
 		if err != nil {
@@ -41,7 +68,7 @@ func ComplianceCopilotWorkflow(ctx workflow.Context, userID string) (string, err
 		}
 	}
 
-	// 4. Schedule automated filings for eligible deadlines
+	// 5. Schedule automated filings for eligible deadlines
 	for _, deadline := range upcomingDeadlines {
 		var canAutomate bool
 		err = workflow.ExecuteActivity(ctx, CheckAutomationEligibilityActivity, userID, deadline).Get(ctx, &canAutomate)
@@ -59,7 +86,7 @@ func ComplianceCopilotWorkflow(ctx workflow.Context, userID string) (string, err
 		}
 	}
 
-	// 5. Update metrics
+	// 6. Update metrics
 	err = workflow.ExecuteActivity(ctx, UpdateComplianceMetricsActivity, userID, complianceScore).Get(ctx, nil)
 	if err != nil {
 		return "", err
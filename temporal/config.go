@@ -0,0 +1,217 @@
+package temporal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TaskQueue is the task queue the worker polls and API-started workflows are sent to.
+const TaskQueue = "CIPC_TASK_QUEUE"
+
+// UrgentTaskQueue is the task queue urgent filings run on. Its workers take no routine work, so an
+// urgent filing never queues behind a backlog of normal ones.
+const UrgentTaskQueue = "CIPC_URGENT_TASK_QUEUE"
+
+// isDevelopment reports whether the worker runs in local development (APP_ENV=development), where
+// unset secrets fall back to well-known development values.
+func isDevelopment() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
+
+// requiredSecrets are the environment variables the worker refuses to start without outside
+// development.
+var requiredSecrets = []string{"OTP_ENCRYPTION_KEY", "INTERNAL_API_KEY", "DOCUMENT_ENCRYPTION_KEY", "DOCUMENT_URL_SIGNING_KEY"}
+
+// ValidateConfig returns an error naming the required secrets that are unset. The worker calls it
+// before starting so a misconfigured deployment fails instead of using development keys.
+func ValidateConfig() error {
+	if isDevelopment() {
+		return nil
+	}
+	var missing []string
+	for _, name := range requiredSecrets {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s must be set (or APP_ENV=development for local development)", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getDatabaseURL returns the connection string activities use to reach the application database.
+func getDatabaseURL() string {
+	return os.Getenv("DATABASE_URL")
+}
+
+// getRunnerPython returns the interpreter used to start the CIPC runner.
+func getRunnerPython() string {
+	if python := os.Getenv("CIPC_RUNNER_PYTHON"); python != "" {
+		return python
+	}
+	return "python3"
+}
+
+// getRunnerScriptPath returns the location of cipc_runner.py.
+func getRunnerScriptPath() string {
+	if path := os.Getenv("CIPC_RUNNER_PATH"); path != "" {
+		return path
+	}
+	return "/app/automation/cipc_runner.py"
+}
+
+// getInternalAPIKey returns the key services on the internal network present to each other in
+// the X-Internal-API-Key header.
+func getInternalAPIKey() string {
+	if key := os.Getenv("INTERNAL_API_KEY"); key != "" {
+		return key
+	}
+	return "your-secret-key" // Fallback for local development
+}
+
+// getOpsAgentTokens returns the ops agents allowed to work the ops queue, keyed by the bearer token
+// each presents. OPS_AGENT_TOKENS lists them as comma-separated agent=token pairs, e.g.
+// "thandi@ops=s3cret,pieter@ops=0ther". There is no fallback: without it the queue is closed.
+func getOpsAgentTokens() map[string]string {
+	agents := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OPS_AGENT_TOKENS"), ",") {
+		agent, token, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && agent != "" && token != "" {
+			agents[token] = agent
+		}
+	}
+	return agents
+}
+
+// getWhatsAppMediaHosts returns the hosts WhatsApp attachments may be downloaded from, normally
+// just the bridge. WHATSAPP_MEDIA_HOSTS lists them comma-separated.
+func getWhatsAppMediaHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("WHATSAPP_MEDIA_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return []string{"localhost"} // Fallback for local development
+	}
+	return hosts
+}
+
+// getAPIAddr returns the address the worker's HTTP API listens on.
+func getAPIAddr() string {
+	if addr := os.Getenv("API_ADDR"); addr != "" {
+		return addr
+	}
+	return ":8082"
+}
+
+// getOTPEncryptionKey returns the secret used to encrypt OTPs in workflow payloads. Every worker
+// and client that handles OTPs must share it. ValidateConfig keeps the fallback out of production.
+func getOTPEncryptionKey() string {
+	if key := os.Getenv("OTP_ENCRYPTION_KEY"); key != "" {
+		return key
+	}
+	return "dev-otp-key" // Fallback for local development
+}
+
+// getPublicAPIURL returns the externally reachable base URL of the worker's HTTP API, used in
+// signed document links.
+func getPublicAPIURL() string {
+	if url := os.Getenv("PUBLIC_API_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8082"
+}
+
+// getDocumentEncryptionKey returns the secret documents are encrypted with at rest. ValidateConfig
+// keeps the fallback out of production.
+func getDocumentEncryptionKey() string {
+	if key := os.Getenv("DOCUMENT_ENCRYPTION_KEY"); key != "" {
+		return key
+	}
+	return "dev-document-key" // Fallback for local development
+}
+
+// getDocumentURLSigningKey returns the secret document download links are signed with.
+// ValidateConfig keeps the fallback out of production.
+func getDocumentURLSigningKey() string {
+	if key := os.Getenv("DOCUMENT_URL_SIGNING_KEY"); key != "" {
+		return key
+	}
+	return "dev-document-url-key" // Fallback for local development
+}
+
+// getDocumentStoreBackend returns where documents are stored: "local" or "s3".
+func getDocumentStoreBackend() string {
+	if backend := os.Getenv("DOCUMENT_STORE"); backend != "" {
+		return backend
+	}
+	return "local"
+}
+
+// getDocumentStorePath returns the directory the local document store writes to.
+func getDocumentStorePath() string {
+	if path := os.Getenv("DOCUMENT_STORE_PATH"); path != "" {
+		return path
+	}
+	return "/app/data/documents"
+}
+
+// getS3Endpoint returns the S3-compatible endpoint documents are stored at, e.g. a local MinIO.
+func getS3Endpoint() string {
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	return "http://localhost:9000"
+}
+
+// getS3Region returns the region requests to the document bucket are signed for.
+func getS3Region() string {
+	if region := os.Getenv("S3_REGION"); region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+// getS3Bucket returns the bucket documents are stored in.
+func getS3Bucket() string {
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		return bucket
+	}
+	return "cipc-documents"
+}
+
+// getCIPCCustomerCode returns the CIPC customer code of the e-services account the runner logs in
+// with, for filings that do not name another.
+func getCIPCCustomerCode() string {
+	if code := os.Getenv("CIPC_CUSTOMER_CODE"); code != "" {
+		return code
+	}
+	return "default"
+}
+
+// getPortalSessionLimits returns how many CIPC portal sessions may be open at once across all
+// workers, and per customer code.
+func getPortalSessionLimits() PortalSessionLimits {
+	return PortalSessionLimits{
+		Global:      getEnvInt("CIPC_MAX_SESSIONS", 4),
+		PerCustomer: getEnvInt("CIPC_MAX_SESSIONS_PER_CUSTOMER", 2),
+	}
+}
+
+// PortalSessionsPerWorker returns how many portal activities one worker runs at once on
+// CIPCPortalTaskQueue.
+func PortalSessionsPerWorker() int {
+	return getEnvInt("CIPC_SESSIONS_PER_WORKER", 2)
+}
+
+func getEnvInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
@@ -0,0 +1,107 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// OpenConversationActivity records that the calling workflow is waiting on a WhatsApp reply, so
+// the reply router can deliver it. Returns the conversation ID.
+func OpenConversationActivity(ctx context.Context, prompt ConversationPrompt) (string, error) {
+	logger := activity.GetLogger(ctx)
+	execution := activity.GetInfo(ctx).WorkflowExecution
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	phoneNumber := prompt.PhoneNumber
+	if phoneNumber == "" {
+		err = db.QueryRowContext(ctx, "SELECT phone_number FROM users WHERE id = $1", prompt.UserID).Scan(&phoneNumber)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up phone number for user %s: %w", prompt.UserID, err)
+		}
+	}
+
+	var conversationID string
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO conversation_contexts (phone_number, awaiting, description, workflow_id, run_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, phoneNumber, prompt.Awaiting, prompt.Description, execution.ID, execution.RunID, prompt.ExpiresAt).Scan(&conversationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to open conversation: %w", err)
+	}
+
+	logger.Info("Conversation opened", "conversation_id", conversationID, "awaiting", prompt.Awaiting)
+	return conversationID, nil
+}
+
+// CloseConversationActivity marks a conversation as answered or abandoned.
+func CloseConversationActivity(ctx context.Context, conversationID string) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return closeConversation(ctx, db, conversationID)
+}
+
+func closeConversation(ctx context.Context, db *sql.DB, conversationID string) error {
+	_, err := db.ExecContext(ctx, `UPDATE conversation_contexts SET closed_at = NOW() WHERE id = $1 AND closed_at IS NULL`, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to close conversation: %w", err)
+	}
+	return nil
+}
+
+// ListOpenConversations returns a phone number's unanswered conversations, oldest first.
+func ListOpenConversations(ctx context.Context, db *sql.DB, phoneNumber string) ([]ConversationContext, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, phone_number, awaiting, description, workflow_id, run_id, expires_at, created_at
+		FROM conversation_contexts
+		WHERE phone_number = $1 AND closed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at
+	`, phoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var contexts []ConversationContext
+	for rows.Next() {
+		var c ConversationContext
+		if err := rows.Scan(&c.ID, &c.PhoneNumber, &c.Awaiting, &c.Description, &c.WorkflowID, &c.RunID, &c.ExpiresAt, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, c)
+	}
+	return contexts, rows.Err()
+}
+
+// openConversation registers a prompt from workflow code. Routing replies is best effort: the
+// workflow keeps waiting even if the conversation could not be recorded.
+func openConversation(ctx workflow.Context, prompt ConversationPrompt) string {
+	var conversationID string
+	if err := workflow.ExecuteActivity(ctx, OpenConversationActivity, prompt).Get(ctx, &conversationID); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to open conversation", "awaiting", prompt.Awaiting, "error", err)
+	}
+	return conversationID
+}
+
+// endConversation closes a conversation opened with openConversation.
+func endConversation(ctx workflow.Context, conversationID string) {
+	if conversationID == "" {
+		return
+	}
+	if err := workflow.ExecuteActivity(ctx, CloseConversationActivity, conversationID).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to close conversation", "conversation_id", conversationID, "error", err)
+	}
+}
@@ -0,0 +1,301 @@
+package temporal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserConsentSignalName is the signal OnboardingWorkflow receives the user's POPIA consent on.
+const UserConsentSignalName = "user-consent-signal"
+
+// FileConfirmSignalName is sent when the user replies FILE to go ahead with a filing a workflow
+// offered them, whatever the filing is.
+const FileConfirmSignalName = "file-confirm"
+
+// UserMessageSignalName carries free text to a workflow started or found by the reply router.
+// Workflows that do not care about free text simply never read it.
+const UserMessageSignalName = "user-message"
+
+// What a workflow can be waiting on from the user, stored in conversation_contexts.awaiting.
+const (
+	ConversationAwaitingConsent = "consent"
+	ConversationAwaitingOTP     = "otp"
+	ConversationAwaitingFile    = "file"
+	// ConversationAwaitingCompensation is a failed paid filing waiting for REFUND, CREDIT or RETRY.
+	ConversationAwaitingCompensation = "compensation"
+	// ConversationAwaitingDocuments is a document collection waiting for attachments.
+	ConversationAwaitingDocuments = "documents"
+)
+
+// Intents recognised in inbound WhatsApp messages.
+const (
+	IntentConsent   = "consent"
+	IntentStart     = "start"
+	IntentSubscribe = "subscribe"
+	IntentAutomate  = "automate"
+	IntentFile      = "file"
+	IntentResend    = "resend"
+	IntentOTP       = "otp"
+	IntentStatus    = "status"
+	IntentCheck     = "check"
+	IntentRefund    = "refund"
+	IntentCredit    = "credit"
+	IntentRetry     = "retry"
+	IntentDocument  = "document"
+	IntentFreeText  = "free_text"
+)
+
+// Actions the router can take for an inbound message.
+const (
+	RouteSignal          = "signal"
+	RouteUpdate          = "update"
+	RouteSignalWithStart = "signal_with_start"
+	RouteReply           = "reply"
+	RouteAI              = "ai"
+	RouteStatus          = "status"
+	RouteCheck           = "check"
+)
+
+var keywordIntents = map[string]string{
+	"YES":       IntentConsent,
+	"Y":         IntentConsent,
+	"START":     IntentStart,
+	"SUBSCRIBE": IntentSubscribe,
+	"AUTOMATE":  IntentAutomate,
+	"FILE":      IntentFile,
+	"RESEND":    IntentResend,
+	"STATUS":    IntentStatus,
+	"REFUND":    IntentRefund,
+	"CREDIT":    IntentCredit,
+	"RETRY":     IntentRetry,
+}
+
+// ConversationContext is an open prompt a workflow is waiting on a reply to.
+type ConversationContext struct {
+	ID          string    `json:"id"`
+	PhoneNumber string    `json:"phone_number"`
+	Awaiting    string    `json:"awaiting"`
+	Description string    `json:"description"`
+	WorkflowID  string    `json:"workflow_id"`
+	RunID       string    `json:"run_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ConversationPrompt is what a workflow registers when it starts waiting on a reply. Either the
+// phone number or the user ID identifies who was asked.
+type ConversationPrompt struct {
+	PhoneNumber string    `json:"phone_number,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Awaiting    string    `json:"awaiting"`
+	Description string    `json:"description"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// InboundMessage is a classified WhatsApp reply.
+type InboundMessage struct {
+	Intent string
+	// Text is the message without any leading choice number.
+	Text string
+	// Choice is the option number the user put in front of the reply, e.g. "2 YES", or 0.
+	Choice int
+	// Raw is the message as received.
+	Raw string
+	// MediaURL is where the attachment of an IntentDocument message can be downloaded.
+	MediaURL string
+}
+
+// RouteDecision says how to deliver an inbound message.
+type RouteDecision struct {
+	Action string
+	// Context is the conversation the message answers, for RouteSignal and RouteUpdate.
+	Context *ConversationContext
+	// Name is the signal or update name.
+	Name    string
+	Payload interface{}
+	// Reply is sent back to the user for RouteReply.
+	Reply string
+}
+
+// ClassifyInboundMessage works out what a reply means. Digits-only replies of 4 to 8 characters
+// are treated as OTPs and left to the filing workflow to validate.
+func ClassifyInboundMessage(text string) InboundMessage {
+	text = strings.TrimSpace(text)
+	if fields := strings.Fields(text); len(fields) > 1 && len(fields[0]) <= 2 {
+		if n, err := strconv.Atoi(fields[0]); err == nil && n > 0 {
+			msg := classifyText(strings.TrimSpace(strings.TrimPrefix(text, fields[0])))
+			msg.Choice = n
+			msg.Raw = text
+			return msg
+		}
+	}
+	msg := classifyText(text)
+	msg.Raw = text
+	return msg
+}
+
+// ClassifyInboundMedia classifies an attachment as IntentDocument, with its caption as the text.
+// The caption may start with an option number like any other reply.
+func ClassifyInboundMedia(mediaURL, caption string) InboundMessage {
+	msg := ClassifyInboundMessage(caption)
+	msg.Intent = IntentDocument
+	msg.MediaURL = mediaURL
+	return msg
+}
+
+func classifyText(text string) InboundMessage {
+	msg := InboundMessage{Text: text}
+	upper := strings.ToUpper(strings.Trim(msg.Text, " .!'\""))
+	if intent, ok := keywordIntents[upper]; ok {
+		msg.Intent = intent
+		return msg
+	}
+	if fields := strings.Fields(msg.Text); len(fields) > 0 && strings.ToUpper(fields[0]) == "CHECK" {
+		msg.Intent = IntentCheck
+		msg.Text = strings.TrimSpace(strings.TrimPrefix(msg.Text, fields[0]))
+		return msg
+	}
+	if code := NormalizeOTP(msg.Text); len(code) >= 4 && len(code) <= 8 && isDigits(code) {
+		msg.Intent = IntentOTP
+		msg.Text = code
+		return msg
+	}
+	msg.Intent = IntentFreeText
+	return msg
+}
+
+// RouteInboundMessage picks where a reply goes given the sender's open conversations. A reply that
+// fits exactly one conversation is delivered to it; one that fits several gets a clarifying
+// question listing them, answered by repeating the reply with the option number in front. STATUS
+// and CHECK are answered whatever the sender is being asked.
+func RouteInboundMessage(msg InboundMessage, contexts []ConversationContext, now time.Time) RouteDecision {
+	if msg.Intent == IntentStatus {
+		return RouteDecision{Action: RouteStatus}
+	}
+	if msg.Intent == IntentCheck {
+		return RouteDecision{Action: RouteCheck, Payload: msg.Text}
+	}
+
+	var active []ConversationContext
+	for _, c := range contexts {
+		if c.ExpiresAt.After(now) {
+			active = append(active, c)
+		}
+	}
+
+	var candidates []int
+	for i, c := range active {
+		if conversationAccepts(c.Awaiting, msg.Intent) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if msg.Choice > len(active) {
+		// Not an option number after all, e.g. an OTP typed as "12 3456".
+		if msg.Intent == IntentDocument {
+			msg.Choice, msg.Text = 0, msg.Raw
+		} else {
+			msg = classifyText(msg.Raw)
+		}
+		return RouteInboundMessage(msg, active, now)
+	}
+	if msg.Choice > 0 {
+		if !conversationAccepts(active[msg.Choice-1].Awaiting, msg.Intent) {
+			return clarify(msg, active, candidates)
+		}
+		return deliver(msg, active[msg.Choice-1])
+	}
+
+	switch len(candidates) {
+	case 1:
+		return deliver(msg, active[candidates[0]])
+	case 0:
+		return routeWithoutContext(msg)
+	default:
+		return clarify(msg, active, candidates)
+	}
+}
+
+func conversationAccepts(awaiting, intent string) bool {
+	switch awaiting {
+	case ConversationAwaitingConsent:
+		return intent == IntentConsent
+	case ConversationAwaitingOTP:
+		return intent == IntentOTP || intent == IntentResend
+	case ConversationAwaitingFile:
+		return intent == IntentFile
+	case ConversationAwaitingCompensation:
+		return intent == IntentRefund || intent == IntentCredit || intent == IntentRetry
+	case ConversationAwaitingDocuments:
+		return intent == IntentDocument
+	}
+	return false
+}
+
+func deliver(msg InboundMessage, c ConversationContext) RouteDecision {
+	decision := RouteDecision{Action: RouteSignal, Context: &c}
+	switch msg.Intent {
+	case IntentConsent:
+		decision.Name = UserConsentSignalName
+		decision.Payload = true
+	case IntentFile:
+		decision.Name = FileConfirmSignalName
+		decision.Payload = true
+	case IntentOTP:
+		decision.Action = RouteUpdate
+		decision.Name = SubmitOTPUpdateName
+		decision.Payload = OTPSignal{OTP: OTPCode(msg.Text)}
+	case IntentResend:
+		decision.Action = RouteUpdate
+		decision.Name = ResendOTPUpdateName
+	case IntentRefund, IntentCredit, IntentRetry:
+		decision.Name = CompensationChoiceSignalName
+		decision.Payload = msg.Intent
+	case IntentDocument:
+		decision.Name = DocumentSignalName
+		decision.Payload = DocumentSignal{DocumentURL: msg.MediaURL, DocumentType: DocumentTypeFromCaption(msg.Text)}
+	}
+	return decision
+}
+
+func routeWithoutContext(msg InboundMessage) RouteDecision {
+	switch msg.Intent {
+	case IntentStart:
+		return RouteDecision{Action: RouteSignalWithStart, Name: UserMessageSignalName, Payload: msg.Text}
+	case IntentConsent:
+		return RouteDecision{Action: RouteReply, Reply: "Nothing is waiting for a YES from you right now. Send 'START' to begin."}
+	case IntentOTP, IntentResend:
+		return RouteDecision{Action: RouteReply, Reply: "We're not waiting for an OTP from you right now. If you started a filing, it may have timed out — send 'START' to begin again."}
+	case IntentFile:
+		return RouteDecision{Action: RouteReply, Reply: "There's no pending filing for you to confirm right now."}
+	case IntentRefund, IntentCredit, IntentRetry:
+		return RouteDecision{Action: RouteReply, Reply: "None of your filings is waiting on a refund, credit or retry choice. Send 'STATUS' to see where they are."}
+	case IntentDocument:
+		return RouteDecision{Action: RouteReply, Reply: "Thanks, but we're not waiting on any documents from you right now. Send 'STATUS' to see where your filings are."}
+	}
+	return RouteDecision{Action: RouteAI, Payload: msg.Text}
+}
+
+func clarify(msg InboundMessage, active []ConversationContext, candidates []int) RouteDecision {
+	if len(candidates) == 0 {
+		return routeWithoutContext(InboundMessage{Intent: msg.Intent, Text: msg.Text})
+	}
+	var b strings.Builder
+	b.WriteString("🤔 We're waiting on a few things from you:\n")
+	for _, i := range candidates {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, active[i].Description)
+	}
+	fmt.Fprintf(&b, "\n\nPlease send your reply again with the number in front, e.g. '%d %s'.", candidates[0]+1, msg.Text)
+	return RouteDecision{Action: RouteReply, Reply: b.String()}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
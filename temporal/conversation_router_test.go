@@ -0,0 +1,165 @@
+package temporal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var routerNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func openContext(id, awaiting, description string) ConversationContext {
+	return ConversationContext{
+		ID:          id,
+		PhoneNumber: "+27821234567",
+		Awaiting:    awaiting,
+		Description: description,
+		WorkflowID:  "wf-" + id,
+		RunID:       "run-" + id,
+		ExpiresAt:   routerNow.Add(time.Hour),
+	}
+}
+
+func TestClassifyInboundMessage(t *testing.T) {
+	tests := []struct {
+		text   string
+		intent string
+		choice int
+	}{
+		{"yes", IntentConsent, 0},
+		{" Start! ", IntentStart, 0},
+		{"resend", IntentResend, 0},
+		{"123 456", IntentOTP, 0},
+		{"2 YES", IntentConsent, 2},
+		{"1 654321", IntentOTP, 1},
+		{"when is my annual return due?", IntentFreeText, 0},
+		{"check annual return", IntentCheck, 0},
+	}
+	for _, tt := range tests {
+		msg := ClassifyInboundMessage(tt.text)
+		assert.Equal(t, tt.intent, msg.Intent, tt.text)
+		assert.Equal(t, tt.choice, msg.Choice, tt.text)
+	}
+}
+
+func TestRouteInboundMessage_SingleContext(t *testing.T) {
+	contexts := []ConversationContext{
+		openContext("c1", ConversationAwaitingConsent, "POPIA consent"),
+		openContext("c2", ConversationAwaitingOTP, "CIPC OTP for your annual return filing"),
+	}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("123456"), contexts, routerNow)
+	assert.Equal(t, RouteUpdate, decision.Action)
+	assert.Equal(t, SubmitOTPUpdateName, decision.Name)
+	require.NotNil(t, decision.Context)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+	assert.Equal(t, OTPSignal{OTP: "123456"}, decision.Payload)
+
+	decision = RouteInboundMessage(ClassifyInboundMessage("YES"), contexts, routerNow)
+	assert.Equal(t, RouteSignal, decision.Action)
+	assert.Equal(t, UserConsentSignalName, decision.Name)
+	assert.Equal(t, "wf-c1", decision.Context.WorkflowID)
+}
+
+func TestRouteInboundMessage_AmbiguousOTPAsksWhichFiling(t *testing.T) {
+	contexts := []ConversationContext{
+		openContext("c1", ConversationAwaitingOTP, "CIPC OTP for your annual return filing"),
+		openContext("c2", ConversationAwaitingOTP, "CIPC OTP for your beneficial ownership filing"),
+	}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("123456"), contexts, routerNow)
+	assert.Equal(t, RouteReply, decision.Action)
+	assert.Contains(t, decision.Reply, "1. CIPC OTP for your annual return filing")
+	assert.Contains(t, decision.Reply, "2. CIPC OTP for your beneficial ownership filing")
+
+	decision = RouteInboundMessage(ClassifyInboundMessage("2 123456"), contexts, routerNow)
+	assert.Equal(t, RouteUpdate, decision.Action)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+	assert.Equal(t, OTPSignal{OTP: "123456"}, decision.Payload)
+}
+
+func TestRouteInboundMessage_ChoiceOutOfRangeIsPartOfTheReply(t *testing.T) {
+	contexts := []ConversationContext{openContext("c1", ConversationAwaitingOTP, "CIPC OTP")}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("12 3456"), contexts, routerNow)
+	assert.Equal(t, RouteUpdate, decision.Action)
+	assert.Equal(t, OTPSignal{OTP: "123456"}, decision.Payload)
+}
+
+func TestRouteInboundMessage_WithoutContext(t *testing.T) {
+	expired := openContext("c1", ConversationAwaitingOTP, "CIPC OTP")
+	expired.ExpiresAt = routerNow.Add(-time.Minute)
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("123456"), []ConversationContext{expired}, routerNow)
+	assert.Equal(t, RouteReply, decision.Action)
+
+	decision = RouteInboundMessage(ClassifyInboundMessage("START"), nil, routerNow)
+	assert.Equal(t, RouteSignalWithStart, decision.Action)
+
+	decision = RouteInboundMessage(ClassifyInboundMessage("what does CIPC charge?"), nil, routerNow)
+	assert.Equal(t, RouteAI, decision.Action)
+	assert.Equal(t, "what does CIPC charge?", decision.Payload)
+}
+
+func TestRouteInboundMessage_StatusIgnoresOpenContexts(t *testing.T) {
+	contexts := []ConversationContext{openContext("c1", ConversationAwaitingOTP, "CIPC OTP")}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("status"), contexts, routerNow)
+	assert.Equal(t, RouteStatus, decision.Action)
+}
+
+func TestRouteInboundMessage_CheckIgnoresOpenContexts(t *testing.T) {
+	contexts := []ConversationContext{openContext("c1", ConversationAwaitingOTP, "CIPC OTP")}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("CHECK BO 2020/123456/07"), contexts, routerNow)
+	assert.Equal(t, RouteCheck, decision.Action)
+	assert.Equal(t, "BO 2020/123456/07", decision.Payload)
+}
+
+func TestRouteInboundMessage_CompensationChoice(t *testing.T) {
+	contexts := []ConversationContext{
+		openContext("c1", ConversationAwaitingOTP, "CIPC OTP"),
+		openContext("c2", ConversationAwaitingCompensation, "Refund, credit or retry"),
+	}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("Refund"), contexts, routerNow)
+	assert.Equal(t, RouteSignal, decision.Action)
+	assert.Equal(t, CompensationChoiceSignalName, decision.Name)
+	assert.Equal(t, CompensationRefund, decision.Payload)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+}
+
+func TestRouteInboundMessage_FileConfirmsTheOfferedFiling(t *testing.T) {
+	contexts := []ConversationContext{
+		openContext("c1", ConversationAwaitingOTP, "CIPC OTP"),
+		openContext("c2", ConversationAwaitingFile, "Financial statements (reply FILE)"),
+	}
+
+	decision := RouteInboundMessage(ClassifyInboundMessage("FILE"), contexts, routerNow)
+	assert.Equal(t, RouteSignal, decision.Action)
+	assert.Equal(t, FileConfirmSignalName, decision.Name)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+}
+
+func TestRouteInboundMessage_AttachmentGoesToDocumentCollection(t *testing.T) {
+	contexts := []ConversationContext{
+		openContext("c1", ConversationAwaitingOTP, "CIPC OTP"),
+		openContext("c2", ConversationAwaitingDocuments, "Documents for your director change"),
+	}
+
+	decision := RouteInboundMessage(ClassifyInboundMedia("https://media.example/1", "ID copy"), contexts, routerNow)
+	assert.Equal(t, RouteSignal, decision.Action)
+	assert.Equal(t, DocumentSignalName, decision.Name)
+	assert.Equal(t, DocumentSignal{DocumentURL: "https://media.example/1", DocumentType: DocumentTypeIDCopy}, decision.Payload)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+
+	// A caption that looks like an option number is kept as the caption.
+	decision = RouteInboundMessage(ClassifyInboundMedia("https://media.example/2", "9 resolution"), contexts, routerNow)
+	assert.Equal(t, RouteSignal, decision.Action)
+	assert.Equal(t, "wf-c2", decision.Context.WorkflowID)
+
+	decision = RouteInboundMessage(ClassifyInboundMedia("https://media.example/3", ""), nil, routerNow)
+	assert.Equal(t, RouteReply, decision.Action)
+}
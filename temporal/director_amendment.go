@@ -0,0 +1,182 @@
+package temporal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Document types collected for a CoR39 director amendment.
+const (
+	DocumentTypeIDCopy     = "id_copy"
+	DocumentTypeResolution = "board_resolution"
+)
+
+// Director is a member of a company's board as held in our records.
+type Director struct {
+	FullName string `json:"full_name"`
+	// IDNumber is the director's SA ID number, or passport number for foreign nationals without one.
+	IDNumber string `json:"id_number"`
+	// Nationality is the ISO 3166-1 alpha-2 code of a foreign director's nationality, empty for
+	// South African citizens.
+	Nationality        string    `json:"nationality,omitempty"`
+	ResidentialAddress string    `json:"residential_address,omitempty"`
+	Email              string    `json:"email,omitempty"`
+	AppointmentDate    time.Time `json:"appointment_date"`
+}
+
+// DirectorResignation removes a director from the board.
+type DirectorResignation struct {
+	IDNumber      string    `json:"id_number"`
+	EffectiveDate time.Time `json:"effective_date"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+// DirectorDetailChange amends a single field of an existing director's details.
+type DirectorDetailChange struct {
+	IDNumber string `json:"id_number"`
+	Field    string `json:"field"`
+	NewValue string `json:"new_value"`
+}
+
+// DirectorAmendment is the content of a CoR39 notice of change of directors.
+type DirectorAmendment struct {
+	Appointments  []Director             `json:"appointments,omitempty"`
+	Resignations  []DirectorResignation  `json:"resignations,omitempty"`
+	DetailChanges []DirectorDetailChange `json:"detail_changes,omitempty"`
+}
+
+// amendableDirectorFields are the director details that can be changed on a CoR39.
+var amendableDirectorFields = map[string]bool{
+	"full_name":           true,
+	"residential_address": true,
+	"email":               true,
+}
+
+// ValidateDirectorAmendment checks an amendment against the current board and returns every
+// problem found, so the user can fix them in one go. An empty slice means the amendment is valid.
+func ValidateDirectorAmendment(companyType string, current []Director, amendment DirectorAmendment) []string {
+	var problems []string
+
+	if len(amendment.Appointments)+len(amendment.Resignations)+len(amendment.DetailChanges) == 0 {
+		return []string{"no director changes were provided"}
+	}
+
+	board := make(map[string]bool, len(current))
+	for _, d := range current {
+		board[d.IDNumber] = true
+	}
+
+	for _, d := range amendment.Appointments {
+		if strings.TrimSpace(d.FullName) == "" {
+			problems = append(problems, fmt.Sprintf("appointee %s has no full name", d.IDNumber))
+		}
+		if err := ValidateDirectorIdentity(d.IDNumber, d.Nationality); err != nil {
+			problems = append(problems, fmt.Sprintf("appointee %s: %v", d.FullName, err))
+			continue
+		}
+		if board[d.IDNumber] {
+			problems = append(problems, fmt.Sprintf("%s is already a director", d.FullName))
+		}
+		board[d.IDNumber] = true
+	}
+
+	for _, r := range amendment.Resignations {
+		if !board[r.IDNumber] {
+			problems = append(problems, fmt.Sprintf("director %s is not on the board and cannot resign", r.IDNumber))
+			continue
+		}
+		delete(board, r.IDNumber)
+	}
+
+	for _, c := range amendment.DetailChanges {
+		if !board[c.IDNumber] {
+			problems = append(problems, fmt.Sprintf("director %s is not on the board and cannot be amended", c.IDNumber))
+		}
+		if !amendableDirectorFields[c.Field] {
+			problems = append(problems, fmt.Sprintf("%q cannot be changed on a CoR39", c.Field))
+		}
+		if strings.TrimSpace(c.NewValue) == "" {
+			problems = append(problems, fmt.Sprintf("new %s for director %s is empty", c.Field, c.IDNumber))
+		}
+	}
+
+	if minimum := MinimumDirectors(companyType); len(board) < minimum {
+		problems = append(problems, fmt.Sprintf("a %s company must keep at least %d director(s), this change leaves %d", strings.ReplaceAll(companyType, "_", " "), minimum, len(board)))
+	}
+
+	return problems
+}
+
+// ValidateCurrentBoard checks a board supplied to seed our records for a company we hold no
+// directors for, e.g. copied from its CIPC disclosure certificate. An empty slice means it can be
+// imported.
+func ValidateCurrentBoard(board []Director) []string {
+	if len(board) == 0 {
+		return []string{"the current board has no directors"}
+	}
+
+	var problems []string
+	seen := make(map[string]bool, len(board))
+	for _, d := range board {
+		if strings.TrimSpace(d.FullName) == "" {
+			problems = append(problems, fmt.Sprintf("director %s has no full name", d.IDNumber))
+		}
+		if err := ValidateDirectorIdentity(d.IDNumber, d.Nationality); err != nil {
+			problems = append(problems, fmt.Sprintf("director %s: %v", d.FullName, err))
+			continue
+		}
+		if seen[d.IDNumber] {
+			problems = append(problems, fmt.Sprintf("%s is listed more than once", d.FullName))
+		}
+		seen[d.IDNumber] = true
+	}
+	return problems
+}
+
+// DatedDirectorAmendment returns a copy of amendment with appointments and resignations that give
+// no date dated decidedAt, the day CIPC approved the change.
+func DatedDirectorAmendment(amendment DirectorAmendment, decidedAt time.Time) DirectorAmendment {
+	dated := amendment
+	dated.Appointments = append([]Director(nil), amendment.Appointments...)
+	for i := range dated.Appointments {
+		if dated.Appointments[i].AppointmentDate.IsZero() {
+			dated.Appointments[i].AppointmentDate = decidedAt
+		}
+	}
+	dated.Resignations = append([]DirectorResignation(nil), amendment.Resignations...)
+	for i := range dated.Resignations {
+		if dated.Resignations[i].EffectiveDate.IsZero() {
+			dated.Resignations[i].EffectiveDate = decidedAt
+		}
+	}
+	return dated
+}
+
+// RequiredDirectorAmendmentDocuments lists the supporting documents CIPC expects with a CoR39:
+// a certified ID copy per appointee and a board or shareholder resolution for any appointment
+// or resignation.
+func RequiredDirectorAmendmentDocuments(amendment DirectorAmendment) map[string]int {
+	required := make(map[string]int)
+	if n := len(amendment.Appointments); n > 0 {
+		required[DocumentTypeIDCopy] = n
+	}
+	if len(amendment.Appointments) > 0 || len(amendment.Resignations) > 0 {
+		required[DocumentTypeResolution] = 1
+	}
+	return required
+}
+
+// DirectorAmendmentDocumentRequirements turns RequiredDirectorAmendmentDocuments into document
+// collection requirements. It is never nil, so an amendment needing no documents collects none.
+func DirectorAmendmentDocumentRequirements(amendment DirectorAmendment) []DocumentRequirement {
+	counts := RequiredDirectorAmendmentDocuments(amendment)
+	requirements := []DocumentRequirement{}
+	for _, r := range RequiredDocumentsFor("director_amendment") {
+		if n := counts[r.DocumentType]; n > 0 {
+			r.Count = n
+			requirements = append(requirements, r)
+		}
+	}
+	return requirements
+}
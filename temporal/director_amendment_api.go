@@ -0,0 +1,64 @@
+package temporal
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// DirectorAmendmentResponse is returned by POST /director-amendments.
+type DirectorAmendmentResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+func (s *APIServer) registerDirectorAmendmentRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /director-amendments", requireInternalAPIKey(s.createDirectorAmendmentHandler))
+}
+
+// createDirectorAmendmentHandler starts a DirectorAmendmentWorkflow to file a CoR39. The change is
+// checked against the board once the workflow has loaded it; a current_board supplied here seeds
+// our records for companies we hold no directors for. A company has at most one director change
+// in progress, since each is validated against the board the previous one leaves.
+func (s *APIServer) createDirectorAmendmentHandler(w http.ResponseWriter, r *http.Request) {
+	var input DirectorAmendmentInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.UserID == "" || input.PhoneNumber == "" || input.CompanyID == "" || input.CompanyRegNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id, phone_number, company_id and company_reg_number are required")
+		return
+	}
+	if _, err := CompanyTypeFromRegNumber(input.CompanyRegNumber); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.CurrentBoard != nil {
+		if problems := ValidateCurrentBoard(input.CurrentBoard); len(problems) > 0 {
+			writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+			return
+		}
+	}
+	// The transaction is created by the workflow once the change has been validated.
+	input.TransactionID = ""
+
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:                                       "director-amendment-" + input.CompanyID,
+		TaskQueue:                                TaskQueue,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}, DirectorAmendmentWorkflow, input)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		writeError(w, http.StatusConflict, "A director change is already in progress for this company")
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting director amendment for company %s: %s", input.CompanyID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start director amendment")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, DirectorAmendmentResponse{WorkflowID: run.GetID()})
+}
@@ -0,0 +1,211 @@
+package temporal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestValidateSAIDNumber(t *testing.T) {
+	assert.NoError(t, ValidateSAIDNumber("8001015009087"))
+	assert.ErrorContains(t, ValidateSAIDNumber("8001015009088"), "check digit")
+	assert.ErrorContains(t, ValidateSAIDNumber("8013015009087"), "date of birth")
+	assert.ErrorContains(t, ValidateSAIDNumber("80010150090"), "13 digits")
+}
+
+func TestValidateDirectorIdentity(t *testing.T) {
+	assert.NoError(t, ValidateDirectorIdentity("8001015009087", ""))
+	assert.ErrorContains(t, ValidateDirectorIdentity("A1234567", ""), "13 digits")
+	assert.NoError(t, ValidateDirectorIdentity("A1234567", "ZW"))
+	assert.NoError(t, ValidateDirectorIdentity("8001015009087", "GB"), "permanent residents may give their SA ID")
+	assert.ErrorContains(t, ValidateDirectorIdentity("A1234567", "Zimbabwe"), "two-letter country code")
+	assert.ErrorContains(t, ValidateDirectorIdentity("A123", "ZW"), "6 to 20 characters")
+	assert.ErrorContains(t, ValidateDirectorIdentity("a1234567", "ZW"), "capital letters and digits")
+}
+
+func TestCompanyTypeFromRegNumber(t *testing.T) {
+	companyType, err := CompanyTypeFromRegNumber("2020/123456/07")
+	require.NoError(t, err)
+	assert.Equal(t, CompanyTypePrivate, companyType)
+
+	companyType, err = CompanyTypeFromRegNumber("2011/000001/08")
+	require.NoError(t, err)
+	assert.Equal(t, CompanyTypeNonProfit, companyType)
+
+	_, err = CompanyTypeFromRegNumber("K2020123456")
+	assert.Error(t, err)
+}
+
+func TestValidateDirectorAmendment(t *testing.T) {
+	board := []Director{
+		{FullName: "Thandi Mokoena", IDNumber: "8001015009087"},
+		{FullName: "Sipho Dlamini", IDNumber: "7505055009089"},
+		{FullName: "Lerato Nkosi", IDNumber: "9002020109085"},
+	}
+
+	t.Run("valid appointment and resignation", func(t *testing.T) {
+		problems := ValidateDirectorAmendment(CompanyTypePublic, board, DirectorAmendment{
+			Appointments: []Director{{FullName: "Ayanda Zulu", IDNumber: "8807070109087"}},
+			Resignations: []DirectorResignation{{IDNumber: "7505055009089"}},
+		})
+		assert.Empty(t, problems)
+	})
+
+	t.Run("public company must keep three directors", func(t *testing.T) {
+		problems := ValidateDirectorAmendment(CompanyTypePublic, board, DirectorAmendment{
+			Resignations: []DirectorResignation{{IDNumber: "7505055009089"}},
+		})
+		require.Len(t, problems, 1)
+		assert.Contains(t, problems[0], "at least 3 director(s)")
+	})
+
+	t.Run("private company may not lose its last director", func(t *testing.T) {
+		problems := ValidateDirectorAmendment(CompanyTypePrivate, board[:1], DirectorAmendment{
+			Resignations: []DirectorResignation{{IDNumber: "8001015009087"}},
+		})
+		require.Len(t, problems, 1)
+		assert.Contains(t, problems[0], "leaves 0")
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		problems := ValidateDirectorAmendment(CompanyTypePrivate, board, DirectorAmendment{
+			Appointments:  []Director{{FullName: "Thandi Mokoena", IDNumber: "8001015009087"}, {FullName: "Bad ID", IDNumber: "123"}},
+			Resignations:  []DirectorResignation{{IDNumber: "0000000000000"}},
+			DetailChanges: []DirectorDetailChange{{IDNumber: "9002020109085", Field: "id_number", NewValue: "x"}},
+		})
+		assert.Len(t, problems, 4)
+	})
+
+	t.Run("foreign appointee with a passport", func(t *testing.T) {
+		problems := ValidateDirectorAmendment(CompanyTypePrivate, board, DirectorAmendment{
+			Appointments: []Director{{FullName: "Tendai Moyo", IDNumber: "FN123456", Nationality: "ZW"}},
+		})
+		assert.Empty(t, problems)
+	})
+
+	t.Run("required documents", func(t *testing.T) {
+		docs := RequiredDirectorAmendmentDocuments(DirectorAmendment{
+			Appointments: []Director{{IDNumber: "8807070109087"}, {IDNumber: "9002020109085"}},
+		})
+		assert.Equal(t, map[string]int{DocumentTypeIDCopy: 2, DocumentTypeResolution: 1}, docs)
+		assert.Empty(t, RequiredDirectorAmendmentDocuments(DirectorAmendment{
+			DetailChanges: []DirectorDetailChange{{IDNumber: "9002020109085", Field: "email", NewValue: "a@b.co.za"}},
+		}))
+	})
+}
+
+func TestValidateCurrentBoard(t *testing.T) {
+	assert.Empty(t, ValidateCurrentBoard([]Director{
+		{FullName: "Thandi Mokoena", IDNumber: "8001015009087"},
+		{FullName: "Sipho Dlamini", IDNumber: "7505055009089"},
+	}))
+	assert.Len(t, ValidateCurrentBoard(nil), 1)
+
+	problems := ValidateCurrentBoard([]Director{
+		{FullName: "Thandi Mokoena", IDNumber: "8001015009087"},
+		{FullName: "Thandi Mokoena", IDNumber: "8001015009087"},
+		{FullName: "", IDNumber: "123"},
+	})
+	assert.Len(t, problems, 3)
+}
+
+func TestDatedDirectorAmendment(t *testing.T) {
+	decidedAt := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	appointed := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	amendment := DirectorAmendment{
+		Appointments: []Director{
+			{FullName: "Ayanda Zulu", IDNumber: "8807070109087"},
+			{FullName: "Tendai Moyo", IDNumber: "FN123456", Nationality: "ZW", AppointmentDate: appointed},
+		},
+		Resignations: []DirectorResignation{{IDNumber: "7505055009089"}},
+	}
+
+	dated := DatedDirectorAmendment(amendment, decidedAt)
+
+	assert.Equal(t, decidedAt, dated.Appointments[0].AppointmentDate)
+	assert.Equal(t, appointed, dated.Appointments[1].AppointmentDate)
+	assert.Equal(t, decidedAt, dated.Resignations[0].EffectiveDate)
+	assert.True(t, amendment.Appointments[0].AppointmentDate.IsZero(), "the original amendment is left as it was")
+	assert.True(t, amendment.Resignations[0].EffectiveDate.IsZero())
+}
+
+// DirectorAmendmentWorkflowTestSuite is the test suite for DirectorAmendmentWorkflow.
+type DirectorAmendmentWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+// TestDirectorAmendmentWorkflowTestSuite runs the test suite.
+func TestDirectorAmendmentWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(DirectorAmendmentWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *DirectorAmendmentWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(DocumentCollectionWorkflow)
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *DirectorAmendmentWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var directorAmendmentInput = DirectorAmendmentInput{
+	UserID:           "user-1",
+	PhoneNumber:      "+27721234567",
+	CompanyID:        "company-1",
+	CompanyRegNumber: "2020/123456/07",
+	Amendment: DirectorAmendment{
+		Resignations: []DirectorResignation{{IDNumber: "7505055009089"}},
+	},
+}
+
+// Test_NoBoardOnRecord_AsksForIt tests that a change is not validated against an empty board.
+func (s *DirectorAmendmentWorkflowTestSuite) Test_NoBoardOnRecord_AsksForIt() {
+	s.env.OnActivity(LoadCompanyDirectorsActivity, mock.Anything, "company-1").Return(nil, nil).Once()
+
+	s.env.ExecuteWorkflow(DirectorAmendmentWorkflow, directorAmendmentInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result DirectorAmendmentResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("invalid", result.Status)
+	s.Require().Len(result.Problems, 1)
+	s.Contains(result.Problems[0], "current directors on record")
+}
+
+// Test_NoBoardOnRecord_ImportsTheSuppliedBoard tests that the supplied board is imported and the
+// change validated against it before a transaction is created.
+func (s *DirectorAmendmentWorkflowTestSuite) Test_NoBoardOnRecord_ImportsTheSuppliedBoard() {
+	input := directorAmendmentInput
+	input.CurrentBoard = []Director{
+		{FullName: "Thandi Mokoena", IDNumber: "8001015009087"},
+		{FullName: "Sipho Dlamini", IDNumber: "7505055009089"},
+	}
+	s.env.OnActivity(LoadCompanyDirectorsActivity, mock.Anything, "company-1").Return(nil, nil).Once()
+	s.env.OnActivity(ImportCompanyDirectorsActivity, mock.Anything, "company-1", input.CurrentBoard).Return(nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.Anything).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(DocumentCollectionWorkflow, mock.Anything, mock.Anything).Return(func(ctx workflow.Context, in DocumentCollectionInput) (*DocumentCollectionResult, error) {
+		s.Equal("tx-1", in.TransactionID)
+		return &DocumentCollectionResult{}, nil
+	}).Once()
+
+	s.env.ExecuteWorkflow(DirectorAmendmentWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result DirectorAmendmentResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("documents_missing", result.Status)
+}
@@ -0,0 +1,375 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// DirectorAmendmentInput is the input for DirectorAmendmentWorkflow.
+type DirectorAmendmentInput struct {
+	TransactionID    string            `json:"transaction_id"`
+	UserID           string            `json:"user_id"`
+	PhoneNumber      string            `json:"phone_number"`
+	CompanyID        string            `json:"company_id"`
+	CompanyRegNumber string            `json:"company_reg_number"`
+	Amendment        DirectorAmendment `json:"amendment"`
+	// CurrentBoard is the company's board as CIPC holds it, e.g. from its disclosure certificate.
+	// It seeds our records when we hold no directors for the company and is ignored otherwise.
+	CurrentBoard []Director `json:"current_board,omitempty"`
+	// IsUrgent is set when the customer paid for an urgent filing.
+	IsUrgent bool `json:"is_urgent,omitempty"`
+}
+
+// DirectorAmendmentResult is the result of DirectorAmendmentWorkflow.
+type DirectorAmendmentResult struct {
+	Success         bool     `json:"success"`
+	Status          string   `json:"status"`
+	FilingReference string   `json:"filing_reference,omitempty"`
+	Problems        []string `json:"problems,omitempty"`
+	ErrorMessage    string   `json:"error_message,omitempty"`
+}
+
+// DirectorAmendmentWorkflow files a CoR39 change of directors: it validates the change against the
+// current board, seeding it from the input for companies we hold no directors for, collects ID
+// copies and the resolution, runs the paid filing pipeline and applies the change to our records
+// once CIPC approves it.
+func DirectorAmendmentWorkflow(ctx workflow.Context, input DirectorAmendmentInput) (*DirectorAmendmentResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting DirectorAmendmentWorkflow", "TransactionID", input.TransactionID, "CompanyID", input.CompanyID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	notify := func(message string) {
+		if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send WhatsApp message", "error", err)
+		}
+	}
+
+	// Step 1: Validate the change against the current board
+	companyType, err := CompanyTypeFromRegNumber(input.CompanyRegNumber)
+	if err != nil {
+		return &DirectorAmendmentResult{Status: "invalid", Problems: []string{err.Error()}}, nil
+	}
+
+	var currentBoard []Director
+	if err := workflow.ExecuteActivity(ctx, LoadCompanyDirectorsActivity, input.CompanyID).Get(ctx, &currentBoard); err != nil {
+		return nil, fmt.Errorf("failed to load current directors: %w", err)
+	}
+
+	reject := func(problems []string) *DirectorAmendmentResult {
+		notify("⚠️ *We can't file this director change yet:*\n\n• " + strings.Join(problems, "\n• "))
+		return &DirectorAmendmentResult{Status: "invalid", Problems: problems}
+	}
+	if len(currentBoard) == 0 {
+		// Companies registered before they joined us have no board on record. Seed it from the one
+		// the customer gave us rather than validating the change against an empty board.
+		if len(input.CurrentBoard) == 0 {
+			return reject([]string{"we don't have your company's current directors on record yet, send us the directors listed on your CIPC disclosure certificate"}), nil
+		}
+		if problems := ValidateCurrentBoard(input.CurrentBoard); len(problems) > 0 {
+			return reject(problems), nil
+		}
+		if err := workflow.ExecuteActivity(ctx, ImportCompanyDirectorsActivity, input.CompanyID, input.CurrentBoard).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to import current directors: %w", err)
+		}
+		currentBoard = input.CurrentBoard
+	}
+
+	if problems := ValidateDirectorAmendment(companyType, currentBoard, input.Amendment); len(problems) > 0 {
+		return reject(problems), nil
+	}
+
+	// Step 2: Create the transaction, unless the caller already has
+	if input.TransactionID == "" {
+		txInput := CreatePaygTransactionInput{
+			UserID:      input.UserID,
+			ServiceType: "director_amendment",
+			IsUrgent:    input.IsUrgent,
+			FilingData: map[string]interface{}{
+				"form":       "CoR39",
+				"company_id": input.CompanyID,
+				"amendment":  input.Amendment,
+			},
+		}
+		if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &input.TransactionID); err != nil {
+			return nil, fmt.Errorf("failed to create director amendment transaction: %w", err)
+		}
+	}
+
+	// Step 3: Collect supporting documents
+	var documents DocumentCollectionResult
+	dcwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "documents-" + input.TransactionID,
+	}
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, dcwo), DocumentCollectionWorkflow, DocumentCollectionInput{
+		TransactionID:    input.TransactionID,
+		UserID:           input.UserID,
+		PhoneNumber:      input.PhoneNumber,
+		CompanyID:        input.CompanyID,
+		CompanyRegNumber: input.CompanyRegNumber,
+		ServiceType:      "director_amendment",
+		Purpose:          "your director change",
+		Requirements:     DirectorAmendmentDocumentRequirements(input.Amendment),
+	}).Get(ctx, &documents)
+	if err != nil {
+		return nil, fmt.Errorf("document collection failed: %w", err)
+	}
+	if !documents.Complete {
+		return &DirectorAmendmentResult{Status: "documents_missing", ErrorMessage: "supporting documents not received"}, nil
+	}
+
+	// Step 4: Run the paid filing pipeline (payment, OTP, submission) as a child workflow
+	filingData := map[string]interface{}{
+		"form":         "CoR39",
+		"company_id":   input.CompanyID,
+		"amendment":    input.Amendment,
+		"documents":    documents.Documents,
+		"document_ids": documents.DocumentIDs(),
+	}
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "filing-director-amendment-" + input.TransactionID,
+		TaskQueue:  FilingTaskQueue(input.IsUrgent),
+	}
+	var filing FilingWorkflowResult
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CombinedFilingWorkflow, FilingWorkflowInput{
+		TransactionID:    input.TransactionID,
+		UserID:           input.UserID,
+		ServiceType:      "director_amendment",
+		FilingData:       filingData,
+		CompanyRegNumber: input.CompanyRegNumber,
+		IsUrgent:         input.IsUrgent,
+		CallerFollowsUp:  true,
+	}).Get(ctx, &filing)
+	if err != nil {
+		return nil, fmt.Errorf("director amendment filing failed: %w", err)
+	}
+	if !filing.Success {
+		return &DirectorAmendmentResult{Status: "failed", ErrorMessage: filing.ErrorMessage}, nil
+	}
+
+	var cipcFilingID string
+	if err := workflow.ExecuteActivity(ctx, RecordDirectorAmendmentFilingActivity, input, filing.FilingReference).Get(ctx, &cipcFilingID); err != nil {
+		return nil, fmt.Errorf("failed to record director amendment filing: %w", err)
+	}
+
+	// Step 5: Follow the CoR39 until CIPC approves or rejects it
+	var decision CIPCFilingOutcome
+	ccwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "cipc-confirmation-" + input.TransactionID,
+	}
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, ccwo), CIPCConfirmationWorkflow, CIPCConfirmationInput{
+		TransactionID:    input.TransactionID,
+		UserID:           input.UserID,
+		ServiceType:      "director_amendment",
+		CompanyRegNumber: input.CompanyRegNumber,
+		Reference:        filing.FilingReference,
+		CIPCFilingID:     cipcFilingID,
+		CompanyID:        input.CompanyID,
+		Silent:           true,
+	}).Get(ctx, &decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to follow up director amendment with CIPC: %w", err)
+	}
+	if decision.Status == CIPCOutcomePending {
+		logger.Warn("No CIPC outcome received for director amendment", "reference", filing.FilingReference)
+		return &DirectorAmendmentResult{Status: "awaiting_cipc", FilingReference: filing.FilingReference}, nil
+	}
+	outcome := CIPCOutcomeSignal{
+		Reference:       decision.CIPCReference,
+		Approved:        decision.Status == CIPCOutcomeApproved,
+		RejectionReason: decision.RejectionReason,
+	}
+
+	// Step 6: Apply the outcome to our records, dating undated changes to CIPC's decision
+	input.Amendment = DatedDirectorAmendment(input.Amendment, workflow.Now(ctx))
+	if err := workflow.ExecuteActivity(ctx, ApplyDirectorAmendmentActivity, input, cipcFilingID, outcome).Get(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to update director records: %w", err)
+	}
+
+	if !outcome.Approved {
+		notify(fmt.Sprintf("❌ *CIPC rejected your director change*\n\nReference: %s\nReason: %s\n\nReply 'HELP' and we'll sort it out with you.", filing.FilingReference, outcome.RejectionReason))
+		return &DirectorAmendmentResult{Status: "rejected", FilingReference: filing.FilingReference, ErrorMessage: outcome.RejectionReason}, nil
+	}
+
+	notify(fmt.Sprintf("✅ *CIPC approved your director change*\n\nReference: %s\nYour company records have been updated.", filing.FilingReference))
+	return &DirectorAmendmentResult{Success: true, Status: "approved", FilingReference: filing.FilingReference}, nil
+}
+
+// LoadCompanyDirectorsActivity returns the active directors of a company.
+func LoadCompanyDirectorsActivity(ctx context.Context, companyID string) ([]Director, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT full_name, id_number, COALESCE(nationality, ''), COALESCE(residential_address, ''), COALESCE(email, ''), COALESCE(appointment_date, created_at)
+		FROM company_directors
+		WHERE company_id = $1 AND status = 'active'
+	`, companyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var directors []Director
+	for rows.Next() {
+		var d Director
+		if err := rows.Scan(&d.FullName, &d.IDNumber, &d.Nationality, &d.ResidentialAddress, &d.Email, &d.AppointmentDate); err != nil {
+			return nil, err
+		}
+		directors = append(directors, d)
+	}
+	return directors, rows.Err()
+}
+
+// ImportCompanyDirectorsActivity records the board of a company we hold no directors for. It does
+// nothing if the company already has active directors, so a retry cannot import the board twice.
+func ImportCompanyDirectorsActivity(ctx context.Context, companyID string, board []Director) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Importing current directors", "company_id", companyID, "directors", len(board))
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var active int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM company_directors WHERE company_id = $1 AND status = 'active'
+	`, companyID).Scan(&active); err != nil {
+		return err
+	}
+	if active > 0 {
+		logger.Info("Company already has directors on record, not importing", "company_id", companyID)
+		return nil
+	}
+
+	for _, d := range board {
+		// CIPC records do not always give an appointment date; leave it unset rather than guess.
+		appointed := sql.NullTime{Time: d.AppointmentDate, Valid: !d.AppointmentDate.IsZero()}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO company_directors (company_id, full_name, id_number, nationality, residential_address, email, appointment_date)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)
+		`, companyID, d.FullName, d.IDNumber, d.Nationality, d.ResidentialAddress, d.Email, appointed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordDirectorAmendmentFilingActivity stores the submitted CoR39 in cipc_filings and returns its ID.
+func RecordDirectorAmendmentFilingActivity(ctx context.Context, input DirectorAmendmentInput, reference string) (string, error) {
+	submission, err := json.Marshal(input.Amendment)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal amendment: %w", err)
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var filingID string
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO cipc_filings (company_id, filing_type, status, submitted_at, cipc_reference, submission_data)
+		VALUES ($1, 'director_amendment', 'submitted', NOW(), $2, $3)
+		RETURNING id
+	`, input.CompanyID, reference, submission).Scan(&filingID)
+	return filingID, err
+}
+
+// ApplyDirectorAmendmentActivity records CIPC's decision and, when approved, applies the
+// appointments, resignations and detail changes to company_directors.
+func ApplyDirectorAmendmentActivity(ctx context.Context, input DirectorAmendmentInput, cipcFilingID string, outcome CIPCOutcomeSignal) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Applying director amendment outcome", "company_id", input.CompanyID, "approved", outcome.Approved)
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status := "rejected"
+	if outcome.Approved {
+		status = "approved"
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE cipc_filings SET status = $1 WHERE id = $2`, status, cipcFilingID); err != nil {
+		return err
+	}
+	if !outcome.Approved {
+		return tx.Commit()
+	}
+
+	for _, d := range input.Amendment.Appointments {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO company_directors (company_id, full_name, id_number, nationality, residential_address, email, appointment_date, cipc_filing_id)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)
+		`, input.CompanyID, d.FullName, d.IDNumber, d.Nationality, d.ResidentialAddress, d.Email, d.AppointmentDate, cipcFilingID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range input.Amendment.Resignations {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE company_directors SET status = 'resigned', resignation_date = $3, cipc_filing_id = $4
+			WHERE company_id = $1 AND id_number = $2 AND status = 'active'
+		`, input.CompanyID, r.IDNumber, r.EffectiveDate, cipcFilingID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range input.Amendment.DetailChanges {
+		// Field is restricted to amendableDirectorFields by ValidateDirectorAmendment.
+		if !amendableDirectorFields[c.Field] {
+			return fmt.Errorf("director field %q cannot be amended", c.Field)
+		}
+		query := fmt.Sprintf(`UPDATE company_directors SET %s = $3, cipc_filing_id = $4 WHERE company_id = $1 AND id_number = $2 AND status = 'active'`, c.Field)
+		if _, err := tx.ExecContext(ctx, query, input.CompanyID, c.IDNumber, c.NewValue, cipcFilingID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE companies SET updated_at = NOW() WHERE id = $1`, input.CompanyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
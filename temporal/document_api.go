@@ -0,0 +1,194 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+)
+
+// DocumentUploadResponse is returned by POST /documents.
+type DocumentUploadResponse struct {
+	*Document
+	// WorkflowNotified is set when a workflow_id was given and the workflow received the
+	// DocumentSignal.
+	WorkflowNotified bool `json:"workflow_notified"`
+}
+
+type documentLinkRequest struct {
+	CIPCFilingID string `json:"cipc_filing_id"`
+}
+
+func (s *APIServer) registerDocumentRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /documents", requireInternalAPIKey(s.uploadDocumentHandler))
+	mux.HandleFunc("GET /documents/{id}", requireInternalAPIKey(s.getDocumentHandler))
+	// Customers download with the signed link they were sent instead of the internal key.
+	mux.HandleFunc("GET /documents/{id}/content", s.downloadDocumentHandler)
+	mux.HandleFunc("POST /documents/{id}/link", requireInternalAPIKey(s.linkDocumentHandler))
+}
+
+// uploadDocumentHandler takes a multipart form with the file, company_id, document_type and
+// optionally cipc_filing_id and the workflow_id of a workflow waiting for the document, which
+// must be collecting documents for the same company.
+func (s *APIServer) uploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxDocumentSize+1<<20)
+	if err := r.ParseMultipartForm(MaxDocumentSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeDocumentError(w, ErrDocumentTooLarge)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid multipart form")
+		return
+	}
+
+	upload := DocumentUpload{
+		CompanyID:    r.FormValue("company_id"),
+		CIPCFilingID: r.FormValue("cipc_filing_id"),
+		DocumentType: r.FormValue("document_type"),
+	}
+	if upload.CompanyID == "" || upload.DocumentType == "" {
+		writeError(w, http.StatusBadRequest, "company_id and document_type are required")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	upload.FileName = header.Filename
+
+	workflowID := r.FormValue("workflow_id")
+	if workflowID != "" && !s.collectsDocumentsFor(w, r, workflowID, upload.CompanyID) {
+		return
+	}
+
+	data, err := readAllLimited(file, MaxDocumentSize)
+	if err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	doc, err := s.Documents.Upload(r.Context(), upload, data)
+	if err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	doc.DownloadURL = s.Documents.SignedURL(doc.ID, time.Now())
+
+	resp := DocumentUploadResponse{Document: doc}
+	if workflowID != "" {
+		signal := DocumentSignal{DocumentID: doc.ID, DocumentURL: doc.DownloadURL, DocumentType: doc.DocumentType}
+		if err := s.Temporal.SignalWorkflow(r.Context(), workflowID, "", DocumentSignalName, signal); err != nil {
+			// The document is stored either way; the caller can link it again.
+			log.Printf("Error signalling workflow %s with document %s: %s", workflowID, doc.ID, err)
+		} else {
+			resp.WorkflowNotified = true
+		}
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// collectsDocumentsFor reports whether workflowID is a document collection for companyID, writing
+// the error response when it is not.
+func (s *APIServer) collectsDocumentsFor(w http.ResponseWriter, r *http.Request, workflowID, companyID string) bool {
+	value, err := s.Temporal.QueryWorkflow(r.Context(), workflowID, "", DocumentCollectionCompanyQueryName)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		writeError(w, http.StatusNotFound, "Workflow not found")
+		return false
+	}
+	var collectionCompanyID string
+	if err == nil {
+		err = value.Get(&collectionCompanyID)
+	}
+	if err != nil {
+		log.Printf("Error querying workflow %s for its company: %s", workflowID, err)
+		writeError(w, http.StatusBadRequest, "workflow_id is not collecting documents")
+		return false
+	}
+	if collectionCompanyID != companyID {
+		writeError(w, http.StatusForbidden, "workflow_id is collecting documents for another company")
+		return false
+	}
+	return true
+}
+
+func (s *APIServer) getDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.Documents.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	doc.DownloadURL = s.Documents.SignedURL(doc.ID, time.Now())
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// downloadDocumentHandler serves a document's contents to the holder of a signed link.
+func (s *APIServer) downloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+	if err := s.Documents.VerifySignedURL(id, query.Get("expires"), query.Get("signature"), time.Now()); err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	doc, err := s.Documents.Get(r.Context(), id)
+	if err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	data, err := s.Documents.Open(r.Context(), doc)
+	if err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", doc.MIMEType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing document %s: %s", id, err)
+	}
+}
+
+func (s *APIServer) linkDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	var req documentLinkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.CIPCFilingID == "" {
+		writeError(w, http.StatusBadRequest, "cipc_filing_id is required")
+		return
+	}
+	if err := s.Documents.LinkFiling(r.Context(), r.PathValue("id"), req.CIPCFilingID); err != nil {
+		writeDocumentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeDocumentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrDocumentTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrDocumentTypeNotAllowed):
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, ErrDocumentEmpty):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrDocumentNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrDocumentLinkInvalid):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrDocumentLinkExpired):
+		writeError(w, http.StatusGone, err.Error())
+	default:
+		log.Printf("Document vault error: %s", err)
+		writeError(w, http.StatusInternalServerError, "Unable to process document")
+	}
+}
@@ -0,0 +1,519 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// DocumentCollectionDeadline is how long the customer has to send their documents before the
+	// collection is escalated to ops.
+	DocumentCollectionDeadline = 7 * 24 * time.Hour
+	// DocumentReminderInterval is how often the customer is reminded of missing documents.
+	DocumentReminderInterval = 48 * time.Hour
+)
+
+// Statuses of a finished document collection.
+const (
+	DocumentCollectionComplete = "complete"
+	// DocumentCollectionResolvedByOps means ops completed the escalation task, having collected
+	// the documents outside the workflow.
+	DocumentCollectionResolvedByOps = "resolved_by_ops"
+	DocumentCollectionAbandoned     = "abandoned"
+)
+
+// DocumentCollectionCompanyQueryName is the query DocumentCollectionWorkflow answers with the ID
+// of the company it collects documents for.
+const DocumentCollectionCompanyQueryName = "document-collection-company"
+
+// ErrorTypeDocumentRejected marks documents that can never satisfy a requirement, such as an
+// unsupported file type.
+const ErrorTypeDocumentRejected = "DocumentRejected"
+
+// DocumentCollectionInput is the input for DocumentCollectionWorkflow.
+type DocumentCollectionInput struct {
+	TransactionID    string `json:"transaction_id"`
+	UserID           string `json:"user_id"`
+	PhoneNumber      string `json:"phone_number"`
+	CompanyID        string `json:"company_id"`
+	CompanyRegNumber string `json:"company_reg_number,omitempty"`
+	ServiceType      string `json:"service_type"`
+	// Purpose names the filing in messages, e.g. "your director change".
+	Purpose string `json:"purpose"`
+	// Requirements overrides the service's declared requirements, e.g. with one ID copy per
+	// new director. Nil uses the declared requirements; empty needs no documents.
+	Requirements []DocumentRequirement `json:"requirements"`
+	// Deadline overrides DocumentCollectionDeadline.
+	Deadline time.Duration `json:"deadline,omitempty"`
+}
+
+// CollectedDocument is a document accepted against a requirement.
+type CollectedDocument struct {
+	DocumentID   string `json:"document_id"`
+	DocumentType string `json:"document_type"`
+	MIMEType     string `json:"mime_type"`
+	FileName     string `json:"file_name"`
+}
+
+// DocumentCollectionResult is the result of DocumentCollectionWorkflow.
+type DocumentCollectionResult struct {
+	// Complete is true when the filing can go ahead.
+	Complete  bool                  `json:"complete"`
+	Status    string                `json:"status"`
+	Documents []CollectedDocument   `json:"documents,omitempty"`
+	Missing   []DocumentRequirement `json:"missing,omitempty"`
+	OpsTaskID string                `json:"ops_task_id,omitempty"`
+}
+
+// DocumentIDs returns the vault IDs of the collected documents.
+func (r *DocumentCollectionResult) DocumentIDs() []string {
+	ids := make([]string, 0, len(r.Documents))
+	for _, d := range r.Documents {
+		ids = append(ids, d.DocumentID)
+	}
+	return ids
+}
+
+// DocumentCollectionWorkflow collects the supporting documents for a filing. It receives
+// DocumentSignals from WhatsApp attachments and web uploads, checks each against the outstanding
+// requirements, reminds the customer of what is still missing and returns once the set is
+// complete. After the deadline it escalates to ops and keeps accepting documents until ops
+// resolve the task.
+func DocumentCollectionWorkflow(ctx workflow.Context, input DocumentCollectionInput) (*DocumentCollectionResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting DocumentCollectionWorkflow", "TransactionID", input.TransactionID, "ServiceType", input.ServiceType)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	if err := workflow.SetQueryHandler(ctx, DocumentCollectionCompanyQueryName, func() (string, error) {
+		return input.CompanyID, nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register company query: %w", err)
+	}
+
+	notify := func(message string) {
+		if input.PhoneNumber == "" {
+			notifyUser(ctx, input.UserID, message)
+			return
+		}
+		if err := workflow.ExecuteActivity(ctx, SendWhatsAppActivity, input.PhoneNumber, message).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send WhatsApp message", "error", err)
+		}
+	}
+
+	requirements := input.Requirements
+	if requirements == nil {
+		requirements = RequiredDocumentsFor(input.ServiceType)
+	}
+	collection := newDocumentCollection(requirements)
+	if collection.done() {
+		return &DocumentCollectionResult{Complete: true, Status: DocumentCollectionComplete}, nil
+	}
+
+	purpose := input.Purpose
+	if purpose == "" {
+		purpose = "your " + strings.ReplaceAll(input.ServiceType, "_", " ") + " filing"
+	}
+	deadlineAfter := input.Deadline
+	if deadlineAfter <= 0 {
+		deadlineAfter = DocumentCollectionDeadline
+	}
+
+	// Step 1: Ask for the documents
+	now := workflow.Now(ctx)
+	deadline := now.Add(deadlineAfter)
+	nextReminder := now.Add(DocumentReminderInterval)
+	notify(fmt.Sprintf("📎 Please send the following for %s:\n\n• %s\n\nReply with each document as a photo or PDF, with a caption saying what it is (e.g. 'ID copy').",
+		purpose, strings.Join(collection.missingList(), "\n• ")))
+	conversationID := openConversation(ctx, ConversationPrompt{
+		PhoneNumber: input.PhoneNumber,
+		UserID:      input.UserID,
+		Awaiting:    ConversationAwaitingDocuments,
+		Description: "Documents for " + purpose,
+		ExpiresAt:   deadline.Add(opsTaskTimeout),
+	})
+	defer endConversation(ctx, conversationID)
+
+	docChan := workflow.GetSignalChannel(ctx, DocumentSignalName)
+	opsChan := workflow.GetSignalChannel(ctx, OpsTaskResolvedSignalName)
+	var opsTaskID string
+
+	result := func(status string) *DocumentCollectionResult {
+		return &DocumentCollectionResult{
+			Complete:  status != DocumentCollectionAbandoned,
+			Status:    status,
+			Documents: collection.documents,
+			Missing:   collection.missing(),
+			OpsTaskID: opsTaskID,
+		}
+	}
+
+	// Step 2: Receive documents until the set is complete, reminding and escalating on timers
+	for !collection.done() {
+		wake := nextReminder
+		if opsTaskID == "" && deadline.Before(wake) {
+			wake = deadline
+		}
+		if opsTaskID != "" {
+			wake = deadline.Add(opsTaskTimeout)
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wake.Sub(workflow.Now(ctx)))
+		var doc DocumentSignal
+		var resolution OpsTaskResolution
+		gotDoc, gotResolution := false, false
+
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(docChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &doc)
+			gotDoc = true
+		})
+		selector.AddReceive(opsChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &resolution)
+			gotResolution = true
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {})
+		selector.Select(ctx)
+		cancelTimer()
+
+		switch {
+		case gotDoc:
+			if reply := acceptDocument(ctx, input, collection, doc); reply != "" {
+				notify(reply)
+			}
+
+		case gotResolution:
+			if resolution.TaskID != opsTaskID {
+				logger.Warn("Ignoring resolution for another ops task", "task_id", resolution.TaskID)
+				continue
+			}
+			if resolution.Status == OpsTaskCompleted {
+				notify(fmt.Sprintf("✅ Our team has everything needed for %s. We'll carry on with it now.", purpose))
+				return result(DocumentCollectionResolvedByOps), nil
+			}
+			notify(fmt.Sprintf("We couldn't complete the documents for %s, so we've stopped it for now. Reply 'HELP' if you'd like to pick it up again.", purpose))
+			return result(DocumentCollectionAbandoned), nil
+
+		case opsTaskID != "":
+			logger.Warn("Ops did not resolve document collection in time", "task_id", opsTaskID)
+			return result(DocumentCollectionAbandoned), nil
+
+		case !workflow.Now(ctx).Before(deadline):
+			// Step 3: Escalate to ops once the deadline passes
+			err := workflow.ExecuteActivity(ctx, EscalateDocumentCollectionActivity, input, collection.missing(), collection.documents).Get(ctx, &opsTaskID)
+			if err != nil {
+				logger.Error("Failed to escalate document collection", "error", err)
+				return result(DocumentCollectionAbandoned), nil
+			}
+			notify(fmt.Sprintf("⌛ We still need the following for %s:\n\n• %s\n\nOne of our team will be in touch to help. You can still send them here in the meantime.",
+				purpose, strings.Join(collection.missingList(), "\n• ")))
+
+		default:
+			notify(fmt.Sprintf("👋 Friendly reminder: we're still waiting on these for %s:\n\n• %s",
+				purpose, strings.Join(collection.missingList(), "\n• ")))
+			nextReminder = nextReminder.Add(DocumentReminderInterval)
+		}
+	}
+
+	// Step 4: Release the filing
+	if opsTaskID != "" {
+		if err := workflow.ExecuteActivity(ctx, CloseDocumentCollectionTaskActivity, opsTaskID).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to close document collection ops task", "task_id", opsTaskID, "error", err)
+		}
+	}
+	notify(fmt.Sprintf("✅ Thanks, we have all the documents for %s.", purpose))
+	return result(DocumentCollectionComplete), nil
+}
+
+// acceptDocument checks a received document against the outstanding requirements and stores it.
+// It returns the message for the customer.
+func acceptDocument(ctx workflow.Context, input DocumentCollectionInput, collection *documentCollection, doc DocumentSignal) string {
+	if doc.DocumentType == "" {
+		// An attachment without a usable caption is only unambiguous when one type is missing.
+		outstanding := collection.outstandingTypes()
+		if len(outstanding) != 1 {
+			return "🤔 Which document is this? Please send it again with a caption, e.g. 'ID copy' or 'resolution'. Still needed:\n\n• " +
+				strings.Join(collection.missingList(), "\n• ")
+		}
+		doc.DocumentType = outstanding[0]
+	}
+
+	requirement, ok := collection.requirement(doc.DocumentType)
+	if !ok {
+		return fmt.Sprintf("We weren't expecting a %s. Still needed:\n\n• %s",
+			strings.ReplaceAll(doc.DocumentType, "_", " "), strings.Join(collection.missingList(), "\n• "))
+	}
+	if collection.outstanding[doc.DocumentType] == 0 {
+		return fmt.Sprintf("We already have the %s, thanks.", requirement.Description)
+	}
+
+	var stored Document
+	err := workflow.ExecuteActivity(ctx, StoreCollectedDocumentActivity, input.CompanyID, doc).Get(ctx, &stored)
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == ErrorTypeDocumentRejected {
+		return "⚠️ We couldn't accept that file: " + appErr.Message() + ". Please send a PDF or a clear photo."
+	}
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to store document", "error", err)
+		return "⚠️ Something went wrong saving that document. Please send it again."
+	}
+	if !requirement.Accepts(stored.MIMEType) {
+		return fmt.Sprintf("⚠️ The %s must be sent as %s. Please send it again.", requirement.Description, strings.Join(requirement.MIMETypes, " or "))
+	}
+
+	collection.add(CollectedDocument{DocumentID: stored.ID, DocumentType: doc.DocumentType, MIMEType: stored.MIMEType, FileName: stored.FileName})
+	if collection.done() {
+		return ""
+	}
+	return fmt.Sprintf("👍 Got the %s. Still needed:\n\n• %s", requirement.Description, strings.Join(collection.missingList(), "\n• "))
+}
+
+// documentCollection tracks which requirements are still outstanding.
+type documentCollection struct {
+	requirements []DocumentRequirement
+	outstanding  map[string]int
+	documents    []CollectedDocument
+}
+
+func newDocumentCollection(requirements []DocumentRequirement) *documentCollection {
+	c := &documentCollection{requirements: requirements, outstanding: map[string]int{}}
+	for _, r := range requirements {
+		if r.Count > 0 {
+			c.outstanding[r.DocumentType] += r.Count
+		}
+	}
+	return c
+}
+
+func (c *documentCollection) done() bool {
+	return len(c.outstanding) == 0
+}
+
+func (c *documentCollection) requirement(docType string) (DocumentRequirement, bool) {
+	for _, r := range c.requirements {
+		if r.DocumentType == docType {
+			return r, true
+		}
+	}
+	return DocumentRequirement{}, false
+}
+
+func (c *documentCollection) add(doc CollectedDocument) {
+	c.documents = append(c.documents, doc)
+	c.outstanding[doc.DocumentType]--
+	if c.outstanding[doc.DocumentType] <= 0 {
+		delete(c.outstanding, doc.DocumentType)
+	}
+}
+
+// outstandingTypes lists the missing document types in requirement order.
+func (c *documentCollection) outstandingTypes() []string {
+	var types []string
+	for _, r := range c.requirements {
+		if c.outstanding[r.DocumentType] > 0 {
+			types = append(types, r.DocumentType)
+		}
+	}
+	return types
+}
+
+// missing returns the outstanding requirements with their remaining counts.
+func (c *documentCollection) missing() []DocumentRequirement {
+	var missing []DocumentRequirement
+	for _, r := range c.requirements {
+		if n := c.outstanding[r.DocumentType]; n > 0 {
+			r.Count = n
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+func (c *documentCollection) missingList() []string {
+	var list []string
+	for _, r := range c.missing() {
+		list = append(list, r.String())
+	}
+	return list
+}
+
+// StoreCollectedDocumentActivity returns the vault record for a received document, downloading
+// WhatsApp attachments into the vault first. Files the vault refuses fail with a non-retryable
+// ErrorTypeDocumentRejected error.
+func StoreCollectedDocumentActivity(ctx context.Context, companyID string, doc DocumentSignal) (*Document, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	vault := NewDocumentVault(db, store)
+
+	if doc.DocumentID != "" {
+		stored, err := vault.Get(ctx, doc.DocumentID)
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeDocumentRejected, err)
+		}
+		if err == nil && stored.CompanyID != companyID {
+			return nil, temporal.NewNonRetryableApplicationError("the document belongs to another company", ErrorTypeDocumentRejected, nil)
+		}
+		return stored, err
+	}
+	if doc.DocumentURL == "" {
+		return nil, temporal.NewNonRetryableApplicationError("no document was attached", ErrorTypeDocumentRejected, nil)
+	}
+
+	data, err := downloadDocument(ctx, doc.DocumentURL)
+	if err == nil {
+		var stored *Document
+		stored, err = vault.Upload(ctx, DocumentUpload{
+			CompanyID:    companyID,
+			FileName:     path.Base(strings.SplitN(doc.DocumentURL, "?", 2)[0]),
+			DocumentType: doc.DocumentType,
+		}, data)
+		if err == nil {
+			activity.GetLogger(ctx).Info("Stored collected document", "document_id", stored.ID, "type", stored.DocumentType)
+			return stored, nil
+		}
+	}
+	if errors.Is(err, ErrDocumentTooLarge) || errors.Is(err, ErrDocumentEmpty) || errors.Is(err, ErrDocumentTypeNotAllowed) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeDocumentRejected, err)
+	}
+	return nil, err
+}
+
+// errMediaHostNotAllowed is returned for attachment URLs, or redirects, that leave the WhatsApp
+// media hosts.
+var errMediaHostNotAllowed = errors.New("documents can only be downloaded from WhatsApp")
+
+// mediaClient downloads WhatsApp attachments, following redirects only within the media hosts.
+var mediaClient = &http.Client{
+	Timeout: 2 * time.Minute,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if !isWhatsAppMediaURL(req.URL) {
+			return errMediaHostNotAllowed
+		}
+		return nil
+	},
+}
+
+// isWhatsAppMediaURL reports whether u points at one of getWhatsAppMediaHosts.
+func isWhatsAppMediaURL(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	for _, host := range getWhatsAppMediaHosts() {
+		if strings.EqualFold(u.Hostname(), host) || strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func downloadDocument(ctx context.Context, documentURL string) ([]byte, error) {
+	u, err := url.Parse(documentURL)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError("invalid document URL", ErrorTypeDocumentRejected, err)
+	}
+	if !isWhatsAppMediaURL(u) {
+		return nil, temporal.NewNonRetryableApplicationError(errMediaHostNotAllowed.Error(), ErrorTypeDocumentRejected, nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError("invalid document URL", ErrorTypeDocumentRejected, err)
+	}
+	resp, err := mediaClient.Do(req)
+	if errors.Is(err, errMediaHostNotAllowed) {
+		return nil, temporal.NewNonRetryableApplicationError(errMediaHostNotAllowed.Error(), ErrorTypeDocumentRejected, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download document: status %d", resp.StatusCode)
+	}
+	return readAllLimited(resp.Body, MaxDocumentSize)
+}
+
+// EscalateDocumentCollectionActivity queues an ops task to chase the customer's missing
+// documents. Returns the task ID.
+func EscalateDocumentCollectionActivity(ctx context.Context, input DocumentCollectionInput, missing []DocumentRequirement, received []CollectedDocument) (string, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var missingList []string
+	for _, r := range missing {
+		missingList = append(missingList, r.String())
+	}
+	filing := AutomatedFilingInput{
+		TransactionID:    input.TransactionID,
+		ServiceType:      input.ServiceType,
+		UserID:           input.UserID,
+		CompanyRegNumber: input.CompanyRegNumber,
+		ClientData: map[string]interface{}{
+			"company_id":         input.CompanyID,
+			"phone_number":       input.PhoneNumber,
+			"missing_documents":  missing,
+			"received_documents": received,
+		},
+	}
+	result := FilingResult{
+		ErrorCode: "DOCUMENTS_MISSING",
+		Error:     "Customer has not sent: " + strings.Join(missingList, ", "),
+	}
+
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return createOpsTask(ctx, db, OpsTaskDocumentCollection, filing, result, execution.ID, execution.RunID)
+}
+
+// CloseDocumentCollectionTaskActivity completes an escalation task once the customer has sent
+// everything themselves.
+func CloseDocumentCollectionTaskActivity(ctx context.Context, taskID string) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE ops_tasks
+		SET status = 'completed', resolution_note = 'Customer sent the missing documents', resolved_by = 'system', resolved_at = NOW()
+		WHERE id = $1 AND status IN ('open', 'claimed')
+	`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to close ops task: %w", err)
+	}
+	return nil
+}
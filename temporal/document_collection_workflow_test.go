@@ -0,0 +1,293 @@
+package temporal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestDocumentTypeFromCaption(t *testing.T) {
+	tests := map[string]string{
+		"ID copy":                      DocumentTypeIDCopy,
+		"here's my ID":                 DocumentTypeIDCopy,
+		"Board resolution, signed":     DocumentTypeResolution,
+		"special resolution":           DocumentTypeSpecialResolution,
+		"Share register":               DocumentTypeShareRegister,
+		"AFS 2024":                     DocumentTypeFinancialStatements,
+		"ID and resolution":            "",
+		"":                             "",
+		"invalid document (ignore me)": "",
+	}
+	for caption, want := range tests {
+		assert.Equal(t, want, DocumentTypeFromCaption(caption), caption)
+	}
+}
+
+func TestDownloadDocument_OnlyFromWhatsAppMediaHosts(t *testing.T) {
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)+"/media", http.StatusFound)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer media.Close()
+	t.Setenv("WHATSAPP_MEDIA_HOSTS", "127.0.0.1")
+
+	data, err := downloadDocument(context.Background(), media.URL+"/media")
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4", string(data))
+
+	for _, url := range []string{
+		strings.Replace(media.URL, "127.0.0.1", "localhost", 1) + "/media",
+		media.URL + "/redirect",
+		"file:///etc/passwd",
+	} {
+		_, err := downloadDocument(context.Background(), url)
+		var appErr *temporal.ApplicationError
+		if assert.ErrorAs(t, err, &appErr, url) {
+			assert.Equal(t, ErrorTypeDocumentRejected, appErr.Type(), url)
+		}
+	}
+}
+
+func TestDirectorAmendmentDocumentRequirements(t *testing.T) {
+	requirements := DirectorAmendmentDocumentRequirements(DirectorAmendment{
+		Appointments: []Director{{IDNumber: "1"}, {IDNumber: "2"}},
+	})
+	assert.Equal(t, []string{"2 × certified ID copy of each new director", "signed board or shareholder resolution"},
+		[]string{requirements[0].String(), requirements[1].String()})
+
+	// A change of details needs no documents, which must not fall back to the service defaults.
+	requirements = DirectorAmendmentDocumentRequirements(DirectorAmendment{DetailChanges: []DirectorDetailChange{{IDNumber: "1"}}})
+	assert.NotNil(t, requirements)
+	assert.Empty(t, requirements)
+}
+
+// DocumentCollectionWorkflowTestSuite is the test suite for DocumentCollectionWorkflow.
+type DocumentCollectionWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestDocumentCollectionWorkflowTestSuite runs the test suite.
+func TestDocumentCollectionWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(DocumentCollectionWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *DocumentCollectionWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, "+27821234567", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.MatchedBy(func(p ConversationPrompt) bool {
+		return p.Awaiting == ConversationAwaitingDocuments
+	})).Return("conversation-1", nil).Maybe()
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, "conversation-1").Return(nil).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *DocumentCollectionWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) input(serviceType string) DocumentCollectionInput {
+	return DocumentCollectionInput{
+		TransactionID: "tx-1",
+		UserID:        "user-1",
+		PhoneNumber:   "+27821234567",
+		CompanyID:     "company-1",
+		ServiceType:   serviceType,
+	}
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) sendDocument(after time.Duration, doc DocumentSignal) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(DocumentSignalName, doc)
+	}, after)
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) storesAs(doc DocumentSignal, id, mimeType string) {
+	s.env.OnActivity(StoreCollectedDocumentActivity, mock.Anything, "company-1", doc).
+		Return(&Document{ID: id, MIMEType: mimeType, DocumentType: doc.DocumentType}, nil).Once()
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) lastMessage() string {
+	if len(s.messages) == 0 {
+		return ""
+	}
+	return s.messages[len(s.messages)-1]
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_CompletesOnceEveryRequirementIsMet() {
+	share := DocumentSignal{DocumentURL: "https://media.example/1", DocumentType: DocumentTypeShareRegister}
+	id := DocumentSignal{DocumentID: "doc-2", DocumentType: DocumentTypeIDCopy}
+	s.storesAs(share, "doc-1", "application/pdf")
+	s.storesAs(id, "doc-2", "image/jpeg")
+	s.sendDocument(time.Hour, share)
+	s.sendDocument(2*time.Hour, id)
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("beneficial_ownership"))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.Complete)
+	s.Equal(DocumentCollectionComplete, result.Status)
+	s.Equal([]string{"doc-1", "doc-2"}, result.DocumentIDs())
+	s.Contains(s.messages[0], "securities (share) register")
+	s.Contains(s.messages[1], "Got the securities (share) register")
+	s.Contains(s.lastMessage(), "we have all the documents")
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_AnswersWhichCompanyItCollectsFor() {
+	s.env.RegisterDelayedCallback(func() {
+		value, err := s.env.QueryWorkflow(DocumentCollectionCompanyQueryName)
+		s.NoError(err)
+		var companyID string
+		s.NoError(value.Get(&companyID))
+		s.Equal("company-1", companyID)
+	}, time.Hour)
+	id := DocumentSignal{DocumentID: "doc-1", DocumentType: DocumentTypeIDCopy}
+	s.storesAs(id, "doc-1", "image/jpeg")
+	s.sendDocument(2*time.Hour, id)
+
+	input := s.input("director_amendment")
+	input.Requirements = []DocumentRequirement{{DocumentType: DocumentTypeIDCopy, Count: 1}}
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_NoRequirementsCompletesImmediately() {
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("annual_return"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.Complete)
+	s.Empty(s.messages)
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_UncaptionedAttachmentAsksWhichDocument() {
+	uncaptioned := DocumentSignal{DocumentURL: "https://media.example/1"}
+	captioned := DocumentSignal{DocumentURL: "https://media.example/1", DocumentType: DocumentTypeShareRegister}
+	lastID := DocumentSignal{DocumentURL: "https://media.example/2", DocumentType: DocumentTypeIDCopy}
+	s.storesAs(captioned, "doc-1", "application/pdf")
+	s.storesAs(lastID, "doc-2", "image/png")
+	s.sendDocument(time.Hour, uncaptioned)
+	s.env.RegisterDelayedCallback(func() {
+		s.Contains(s.lastMessage(), "Which document is this?")
+	}, 90*time.Minute)
+	s.sendDocument(2*time.Hour, captioned)
+	// With only the ID copy left, an uncaptioned attachment must be it.
+	s.sendDocument(3*time.Hour, DocumentSignal{DocumentURL: "https://media.example/2"})
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("beneficial_ownership"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(DocumentCollectionComplete, result.Status)
+	s.Equal(DocumentTypeIDCopy, result.Documents[1].DocumentType)
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_RejectsDocumentsThatDoNotFitTheRequirement() {
+	photo := DocumentSignal{DocumentURL: "https://media.example/photo", DocumentType: DocumentTypeFinancialStatements}
+	script := DocumentSignal{DocumentURL: "https://media.example/script", DocumentType: DocumentTypeFinancialStatements}
+	pdf := DocumentSignal{DocumentURL: "https://media.example/afs.pdf", DocumentType: DocumentTypeFinancialStatements}
+	s.storesAs(photo, "doc-1", "image/jpeg")
+	s.env.OnActivity(StoreCollectedDocumentActivity, mock.Anything, "company-1", script).
+		Return(nil, temporal.NewNonRetryableApplicationError("document type is not allowed: text/plain", ErrorTypeDocumentRejected, nil)).Once()
+	s.storesAs(pdf, "doc-3", "application/pdf")
+
+	s.sendDocument(time.Hour, photo)
+	s.sendDocument(2*time.Hour, script)
+	s.sendDocument(3*time.Hour, DocumentSignal{DocumentURL: "https://media.example/id", DocumentType: DocumentTypeIDCopy})
+	s.sendDocument(4*time.Hour, pdf)
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("afs_submission"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(DocumentCollectionComplete, result.Status)
+	s.Equal([]string{"doc-3"}, result.DocumentIDs())
+	s.Contains(s.messages[1], "must be sent as application/pdf")
+	s.Contains(s.messages[2], "We couldn't accept that file")
+	s.Contains(s.messages[3], "We weren't expecting a id copy")
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_RemindsThenEscalatesToOps() {
+	s.env.OnActivity(EscalateDocumentCollectionActivity, mock.Anything, mock.Anything, mock.MatchedBy(func(missing []DocumentRequirement) bool {
+		return len(missing) == 1 && missing[0].DocumentType == DocumentTypeSpecialResolution
+	}), mock.Anything).Return("task-1", nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(OpsTaskResolvedSignalName, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, ResolvedBy: "agent"})
+	}, DocumentCollectionDeadline+24*time.Hour)
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("company_update"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.Complete)
+	s.Equal(DocumentCollectionResolvedByOps, result.Status)
+	s.Equal("task-1", result.OpsTaskID)
+
+	reminders, escalations := 0, 0
+	for _, m := range s.messages {
+		if strings.Contains(m, "Friendly reminder") {
+			reminders++
+		}
+		if strings.Contains(m, "One of our team will be in touch") {
+			escalations++
+		}
+	}
+	s.Equal(3, reminders)
+	s.Equal(1, escalations)
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_DocumentsAfterEscalationCloseTheOpsTask() {
+	resolution := DocumentSignal{DocumentID: "doc-1", DocumentType: DocumentTypeSpecialResolution}
+	s.storesAs(resolution, "doc-1", "application/pdf")
+	s.env.OnActivity(EscalateDocumentCollectionActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("task-1", nil).Once()
+	s.env.OnActivity(CloseDocumentCollectionTaskActivity, mock.Anything, "task-1").Return(nil).Once()
+	s.sendDocument(DocumentCollectionDeadline+time.Hour, resolution)
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("company_update"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(DocumentCollectionComplete, result.Status)
+	s.Equal("task-1", result.OpsTaskID)
+}
+
+func (s *DocumentCollectionWorkflowTestSuite) Test_AbandonedWhenOpsRejectTheTask() {
+	s.env.OnActivity(EscalateDocumentCollectionActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("task-1", nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(OpsTaskResolvedSignalName, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskRejected, ResolvedBy: "agent"})
+	}, DocumentCollectionDeadline+time.Hour)
+
+	s.env.ExecuteWorkflow(DocumentCollectionWorkflow, s.input("company_update"))
+
+	var result DocumentCollectionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.False(result.Complete)
+	s.Equal(DocumentCollectionAbandoned, result.Status)
+	s.Len(result.Missing, 1)
+}
@@ -0,0 +1,181 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// MinExtractionConfidence is the confidence an extracted field needs before it is used to fill
+// in a filing.
+const MinExtractionConfidence = 0.8
+
+// Error types returned by document extraction for documents that will never extract.
+const (
+	ExtractionErrNoExtractor = "NoExtractor"
+	ExtractionErrNoText      = "NoExtractableText"
+)
+
+// extractDocument runs the registered extractor over a vault document and stores the result in
+// documents.processed_data.
+func extractDocument(ctx context.Context, vault *DocumentVault, registry *ExtractorRegistry, documentID string) (*ExtractionResult, error) {
+	logger := activity.GetLogger(ctx)
+
+	doc, err := vault.Get(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	extractor, ok := registry.Lookup(doc.DocumentType)
+	if !ok {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("no extractor for document type %q", doc.DocumentType), ExtractionErrNoExtractor, nil)
+	}
+	data, err := vault.Open(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := extractor.Extract(ctx, doc.MIMEType, data)
+	if errors.Is(err, ErrNoPDFText) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), ExtractionErrNoText, err)
+	}
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("extraction failed: %s", err), ExtractionErrNoText, err)
+	}
+
+	processed, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal extraction result: %w", err)
+	}
+	if _, err := vault.DB.ExecContext(ctx, `UPDATE documents SET processed_data = $1 WHERE id = $2`, processed, documentID); err != nil {
+		return nil, fmt.Errorf("failed to store extraction result: %w", err)
+	}
+
+	logger.Info("Document extracted", "document_id", documentID, "extractor", result.Extractor, "kind", result.DocumentKind)
+	return result, nil
+}
+
+// ExtractDocumentDataActivity extracts the documents listed in the filing's document_ids and
+// returns the filing data with confidently extracted fields filled in. Values the customer
+// supplied are kept; extraction_confidence records the score of every extracted field. Annual
+// returns also get the company's AFSObligation.
+func ExtractDocumentDataActivity(ctx context.Context, input FilingWorkflowInput) (map[string]interface{}, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Extracting document data", "service_type", input.ServiceType)
+
+	data := map[string]interface{}{}
+	for k, v := range input.FilingData {
+		data[k] = v
+	}
+	documentIDs := filingDocumentIDs(input.FilingData)
+	if len(documentIDs) == 0 && input.ServiceType != "annual_return" {
+		return data, nil
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+	if input.ServiceType == "annual_return" {
+		if err := addAFSObligation(ctx, db, input.CompanyRegNumber, data); err != nil {
+			return nil, err
+		}
+	}
+	if len(documentIDs) == 0 {
+		return data, nil
+	}
+	store, err := NewBlobStore()
+	if err != nil {
+		return nil, err
+	}
+	vault := NewDocumentVault(db, store)
+	registry := NewExtractorRegistry()
+
+	var results []*ExtractionResult
+	for _, id := range documentIDs {
+		result, err := extractDocument(ctx, vault, registry, id)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.NonRetryable() {
+			// Scans and unsupported documents are left for a person to read.
+			logger.Warn("Skipping document that cannot be extracted", "document_id", id, "error", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	MergeExtractedData(data, results)
+	return data, nil
+}
+
+// addAFSObligation adds the company's AFSObligation to annual return data: the one determined
+// from the financial year facts supplied with the filing, or else the last one recorded.
+func addAFSObligation(ctx context.Context, db *sql.DB, regNumber string, data map[string]interface{}) error {
+	obligation, err := AnnualReturnAFSObligation(regNumber, data)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "InvalidAFSObligation", err)
+	}
+	if obligation == nil {
+		if obligation, err = LatestAFSObligation(ctx, db, regNumber); err != nil {
+			return err
+		}
+	}
+	if obligation == nil {
+		activity.GetLogger(ctx).Warn("No AFS obligation recorded for annual return", "company_reg_number", regNumber)
+		return nil
+	}
+	for k, v := range obligation.AnnualReturnFields() {
+		data[k] = v
+	}
+	return nil
+}
+
+// MergeExtractedData fills data from extraction results, keeping values already present and
+// using the most confident extraction of each field.
+func MergeExtractedData(data map[string]interface{}, results []*ExtractionResult) {
+	best := map[string]ExtractedField{}
+	for _, result := range results {
+		for name, field := range result.Fields {
+			if field.Confidence > best[name].Confidence {
+				best[name] = field
+			}
+		}
+		if _, ok := data["directors"]; !ok && len(result.Directors) > 0 {
+			data["directors"] = result.Directors
+		}
+	}
+	if len(best) == 0 {
+		return
+	}
+
+	confidence := map[string]float64{}
+	for name, field := range best {
+		confidence[name] = field.Confidence
+		if _, supplied := data[name]; !supplied && field.Confidence >= MinExtractionConfidence {
+			data[name] = field.Value
+		}
+	}
+	data["extraction_confidence"] = confidence
+}
+
+func filingDocumentIDs(filingData map[string]interface{}) []string {
+	var ids []string
+	switch v := filingData["document_ids"].(type) {
+	case []string:
+		ids = v
+	case []interface{}:
+		for _, id := range v {
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	}
+	return ids
+}
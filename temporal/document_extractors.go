@@ -0,0 +1,108 @@
+package temporal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Document types with registered extractors. DocumentTypeCIPCCertificate is the generic type the
+// web app uploads certificates as; the extractor works out which certificate it is.
+const (
+	DocumentTypeCIPCCertificate       = "cipc_certificate"
+	DocumentTypeRegistrationCert      = "cor14_3"
+	DocumentTypeDisclosureCertificate = "disclosure_certificate"
+)
+
+// Names of the fields certificate extractors return.
+const (
+	FieldRegistrationNumber = "registration_number"
+	FieldCompanyName        = "company_name"
+	FieldRegistrationDate   = "registration_date"
+	FieldCompanyStatus      = "company_status"
+)
+
+// ExtractedField is one value read from a document, with how sure the extractor is of it, from 0
+// (not found) to 1.
+type ExtractedField struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ExtractedDirector is a director listed on a certificate.
+type ExtractedDirector struct {
+	FullName   string  `json:"full_name"`
+	IDNumber   string  `json:"id_number,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ExtractionResult is what an Extractor read from a document. It is stored in
+// documents.processed_data.
+type ExtractionResult struct {
+	Extractor string `json:"extractor"`
+	// DocumentKind is the kind of document found, e.g. DocumentTypeRegistrationCert for a
+	// certificate uploaded as DocumentTypeCIPCCertificate.
+	DocumentKind string                    `json:"document_kind"`
+	Fields       map[string]ExtractedField `json:"fields"`
+	Directors    []ExtractedDirector       `json:"directors,omitempty"`
+}
+
+// Field returns a field's value, or "" when it was not found with at least minConfidence.
+func (r *ExtractionResult) Field(name string, minConfidence float64) string {
+	if f, ok := r.Fields[name]; ok && f.Confidence >= minConfidence {
+		return f.Value
+	}
+	return ""
+}
+
+// Extractor reads structured data from a document's contents.
+type Extractor interface {
+	// Name identifies the extractor in stored results.
+	Name() string
+	Extract(ctx context.Context, mimeType string, data []byte) (*ExtractionResult, error)
+}
+
+// ExtractorRegistry maps document types to the extractor for them.
+type ExtractorRegistry struct {
+	extractors map[string]Extractor
+}
+
+// NewExtractorRegistry returns a registry with the built-in extractors.
+func NewExtractorRegistry() *ExtractorRegistry {
+	r := &ExtractorRegistry{extractors: map[string]Extractor{}}
+	certificates := &CIPCCertificateExtractor{}
+	r.Register(DocumentTypeCIPCCertificate, certificates)
+	r.Register(DocumentTypeRegistrationCert, certificates)
+	r.Register(DocumentTypeDisclosureCertificate, certificates)
+	return r
+}
+
+// Register sets the extractor for a document type, replacing any earlier one.
+func (r *ExtractorRegistry) Register(documentType string, extractor Extractor) {
+	r.extractors[documentType] = extractor
+}
+
+// Lookup returns the extractor for a document type.
+func (r *ExtractorRegistry) Lookup(documentType string) (Extractor, bool) {
+	extractor, ok := r.extractors[documentType]
+	return extractor, ok
+}
+
+// DocumentTypes lists the document types with an extractor.
+func (r *ExtractorRegistry) DocumentTypes() []string {
+	types := make([]string, 0, len(r.extractors))
+	for t := range r.extractors {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Extract runs the extractor registered for a document type.
+func (r *ExtractorRegistry) Extract(ctx context.Context, documentType, mimeType string, data []byte) (*ExtractionResult, error) {
+	extractor, ok := r.Lookup(documentType)
+	if !ok {
+		return nil, fmt.Errorf("no extractor for document type %q", documentType)
+	}
+	return extractor.Extract(ctx, mimeType, data)
+}
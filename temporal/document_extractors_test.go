@@ -0,0 +1,139 @@
+package temporal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTextPDF writes a minimal PDF drawing each line with Tj, compressing the content stream if
+// asked.
+func buildTextPDF(t *testing.T, lines []string, compress bool) []byte {
+	var content bytes.Buffer
+	content.WriteString("BT /F1 10 Tf 72 800 Td 14 TL\n")
+	for _, line := range lines {
+		escaped := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line)
+		fmt.Fprintf(&content, "(%s) Tj T*\n", escaped)
+	}
+	content.WriteString("ET\n")
+
+	stream, filter := content.Bytes(), ""
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		_, err := w.Write(stream)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		stream, filter = z.Bytes(), " /Filter /FlateDecode"
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	pdf.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	pdf.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj << /Length %d%s >>\nstream\n", len(stream), filter)
+	pdf.Write(stream)
+	pdf.WriteString("\nendstream endobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+var disclosureCertificateLines = []string{
+	"Companies and Intellectual Property Commission",
+	"DISCLOSURE CERTIFICATE",
+	"Enterprise Number: 2019/123456/07",
+	"Enterprise Name: ACME TRADING (PTY) LTD",
+	"Registration Date: 15/03/2019",
+	"Enterprise Status: In Business",
+	"Active Directors",
+	"Surname and First Names ID Number",
+	"SMITH JOHN 8001015009087",
+	"NDLOVU THANDI (DIRECTOR) 9202204720082",
+	"Auditors",
+	"None",
+}
+
+func TestExtractPDFText(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		text, err := ExtractPDFText(buildTextPDF(t, []string{"Enterprise Name: O'BRIEN (PTY) LTD", "Line two"}, compress))
+		require.NoError(t, err)
+		assert.Equal(t, "Enterprise Name: O'BRIEN (PTY) LTD\nLine two", text)
+	}
+
+	_, err := ExtractPDFText(buildTextPDF(t, nil, false))
+	assert.ErrorIs(t, err, ErrNoPDFText)
+}
+
+func TestExtractPDFText_RefusesStreamsInflatingPastTheLimit(t *testing.T) {
+	_, err := ExtractPDFText(buildTextPDF(t, []string{strings.Repeat("A", maxPDFDecodedSize)}, true))
+	assert.ErrorIs(t, err, ErrPDFTooLarge)
+}
+
+func TestCIPCCertificateExtractor_DisclosureCertificate(t *testing.T) {
+	registry := NewExtractorRegistry()
+	result, err := registry.Extract(context.Background(), DocumentTypeCIPCCertificate, "application/pdf", buildTextPDF(t, disclosureCertificateLines, true))
+	require.NoError(t, err)
+
+	assert.Equal(t, DocumentTypeDisclosureCertificate, result.DocumentKind)
+	assert.Equal(t, ExtractedField{Value: "2019/123456/07", Confidence: 0.95}, result.Fields[FieldRegistrationNumber])
+	assert.Equal(t, ExtractedField{Value: "ACME TRADING (PTY) LTD", Confidence: 0.9}, result.Fields[FieldCompanyName])
+	assert.Equal(t, ExtractedField{Value: "2019-03-15", Confidence: 0.95}, result.Fields[FieldRegistrationDate])
+	assert.Equal(t, ExtractedField{Value: "In Business", Confidence: 0.95}, result.Fields[FieldCompanyStatus])
+	assert.Equal(t, []ExtractedDirector{
+		{FullName: "SMITH JOHN", IDNumber: "8001015009087", Confidence: 0.9},
+		// The check digit is wrong, so this director scores lower.
+		{FullName: "NDLOVU THANDI", IDNumber: "9202204720082", Confidence: 0.5},
+	}, result.Directors)
+}
+
+func TestParseCIPCCertificateText_RegistrationCertificate(t *testing.T) {
+	result := ParseCIPCCertificateText(strings.Join([]string{
+		"Form CoR14.3",
+		"Registration Certificate",
+		"Registration number",
+		"2021 / 654321 / 07",
+		"Enterprise name",
+		"Bright Ideas",
+		"Registration date",
+		"Sometime in 2021",
+	}, "\n"))
+
+	assert.Equal(t, DocumentTypeRegistrationCert, result.DocumentKind)
+	assert.Equal(t, ExtractedField{Value: "2021/654321/07", Confidence: 0.95}, result.Fields[FieldRegistrationNumber])
+	assert.Equal(t, ExtractedField{Value: "Bright Ideas", Confidence: 0.7}, result.Fields[FieldCompanyName])
+	assert.Equal(t, ExtractedField{Value: "Sometime in 2021", Confidence: 0.4}, result.Fields[FieldRegistrationDate])
+	assert.Equal(t, ExtractedField{}, result.Fields[FieldCompanyStatus])
+	assert.Empty(t, result.Directors)
+}
+
+func TestExtractorRegistry_UnknownType(t *testing.T) {
+	_, err := NewExtractorRegistry().Extract(context.Background(), "id_copy", "image/jpeg", nil)
+	assert.ErrorContains(t, err, "no extractor")
+}
+
+func TestMergeExtractedData(t *testing.T) {
+	data := map[string]interface{}{FieldCompanyName: "Acme Trading (Pty) Ltd"}
+	MergeExtractedData(data, []*ExtractionResult{
+		{Fields: map[string]ExtractedField{
+			FieldRegistrationNumber: {Value: "2019/123456/07", Confidence: 0.6},
+			FieldCompanyName:        {Value: "ACME TRADING (PTY) LTD", Confidence: 0.9},
+			FieldRegistrationDate:   {Value: "2019-03-15", Confidence: 0.95},
+		}},
+		{Fields: map[string]ExtractedField{
+			FieldRegistrationNumber: {Value: "2019/123456/07", Confidence: 0.95},
+			FieldCompanyStatus:      {Value: "Unclear", Confidence: 0.6},
+		}},
+	})
+
+	assert.Equal(t, "Acme Trading (Pty) Ltd", data[FieldCompanyName], "customer-supplied values win")
+	assert.Equal(t, "2019/123456/07", data[FieldRegistrationNumber])
+	assert.Equal(t, "2019-03-15", data[FieldRegistrationDate])
+	assert.NotContains(t, data, FieldCompanyStatus, "low-confidence values are not used")
+	assert.Equal(t, 0.6, data["extraction_confidence"].(map[string]float64)[FieldCompanyStatus])
+}
@@ -0,0 +1,116 @@
+package temporal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supporting document types collected for filings, alongside DocumentTypeIDCopy and
+// DocumentTypeResolution.
+const (
+	DocumentTypeShareRegister       = "share_register"
+	DocumentTypeFinancialStatements = "annual_financial_statements"
+	DocumentTypeSpecialResolution   = "special_resolution"
+)
+
+// DocumentRequirement is a supporting document a filing cannot be submitted without.
+type DocumentRequirement struct {
+	DocumentType string `json:"document_type"`
+	// Description is how the document is named to the customer.
+	Description string `json:"description"`
+	Count       int    `json:"count"`
+	// MIMETypes limits the formats accepted; empty accepts anything the vault stores.
+	MIMETypes []string `json:"mime_types,omitempty"`
+}
+
+// Accepts reports whether a document of the given MIME type satisfies the requirement.
+func (r DocumentRequirement) Accepts(mimeType string) bool {
+	if len(r.MIMETypes) == 0 {
+		return true
+	}
+	for _, t := range r.MIMETypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the requirement for WhatsApp messages, e.g. "2 × certified ID copy".
+func (r DocumentRequirement) String() string {
+	if r.Count > 1 {
+		return fmt.Sprintf("%d × %s", r.Count, r.Description)
+	}
+	return r.Description
+}
+
+// serviceDocumentRequirements declares the supporting documents each service needs. Services
+// missing from the map need none.
+var serviceDocumentRequirements = map[string][]DocumentRequirement{
+	"beneficial_ownership": {
+		{DocumentType: DocumentTypeShareRegister, Description: "securities (share) register", Count: 1},
+		{DocumentType: DocumentTypeIDCopy, Description: "certified ID copy of each beneficial owner", Count: 1},
+	},
+	"director_amendment": {
+		{DocumentType: DocumentTypeIDCopy, Description: "certified ID copy of each new director", Count: 1},
+		{DocumentType: DocumentTypeResolution, Description: "signed board or shareholder resolution", Count: 1},
+	},
+	"company_update": {
+		{DocumentType: DocumentTypeSpecialResolution, Description: "signed special resolution", Count: 1},
+	},
+	"company_registration": {
+		{DocumentType: DocumentTypeIDCopy, Description: "certified ID copy of each director and incorporator", Count: 1},
+	},
+	"afs_submission": {
+		{DocumentType: DocumentTypeFinancialStatements, Description: "signed annual financial statements (PDF)", Count: 1, MIMETypes: []string{"application/pdf"}},
+	},
+}
+
+// RequiredDocumentsFor returns the supporting documents a service needs.
+func RequiredDocumentsFor(serviceType string) []DocumentRequirement {
+	return append([]DocumentRequirement(nil), serviceDocumentRequirements[serviceType]...)
+}
+
+// documentCaptionKeywords maps words customers use in WhatsApp captions to document types.
+var documentCaptionKeywords = map[string]string{
+	"id":         DocumentTypeIDCopy,
+	"identity":   DocumentTypeIDCopy,
+	"passport":   DocumentTypeIDCopy,
+	"resolution": DocumentTypeResolution,
+	"special":    DocumentTypeSpecialResolution,
+	"register":   DocumentTypeShareRegister,
+	"share":      DocumentTypeShareRegister,
+	"shares":     DocumentTypeShareRegister,
+	"securities": DocumentTypeShareRegister,
+	"afs":        DocumentTypeFinancialStatements,
+	"financial":  DocumentTypeFinancialStatements,
+	"financials": DocumentTypeFinancialStatements,
+	"statements": DocumentTypeFinancialStatements,
+}
+
+// DocumentTypeFromCaption guesses the document type from the caption sent with a WhatsApp
+// attachment. It returns "" when the caption names no document type, or more than one.
+func DocumentTypeFromCaption(caption string) string {
+	words := strings.FieldsFunc(strings.ToLower(caption), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+
+	found := ""
+	for _, word := range words {
+		docType, ok := documentCaptionKeywords[word]
+		if !ok || docType == found {
+			continue
+		}
+		switch {
+		case found == "":
+			found = docType
+		case docType == DocumentTypeSpecialResolution && found == DocumentTypeResolution,
+			found == DocumentTypeSpecialResolution && docType == DocumentTypeResolution:
+			// "special resolution" names one document.
+			found = DocumentTypeSpecialResolution
+		default:
+			return ""
+		}
+	}
+	return found
+}
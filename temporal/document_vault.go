@@ -0,0 +1,298 @@
+package temporal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxDocumentSize is the largest document the vault accepts.
+	MaxDocumentSize = 10 << 20
+	// DocumentURLTTL is how long a signed download link stays valid.
+	DocumentURLTTL = 15 * time.Minute
+)
+
+// AllowedDocumentTypes are the MIME types the vault stores. Types are sniffed from the contents,
+// not taken from the upload.
+var AllowedDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	// XBRL instance documents generated for AFS submissions.
+	"text/xml": true,
+}
+
+var (
+	ErrDocumentTooLarge         = fmt.Errorf("document is larger than %d MB", MaxDocumentSize>>20)
+	ErrDocumentEmpty            = errors.New("document is empty")
+	ErrDocumentTypeNotAllowed   = errors.New("document type is not allowed")
+	ErrDocumentNotFound         = errors.New("document not found")
+	ErrDocumentLinkInvalid      = errors.New("document link is invalid")
+	ErrDocumentLinkExpired      = errors.New("document link has expired")
+	ErrDocumentContentsMismatch = errors.New("stored document does not match its hash")
+)
+
+// Document is a row of the documents table.
+type Document struct {
+	ID           string    `json:"id"`
+	CompanyID    string    `json:"company_id"`
+	CIPCFilingID string    `json:"cipc_filing_id,omitempty"`
+	FileName     string    `json:"file_name"`
+	MIMEType     string    `json:"mime_type"`
+	DocumentType string    `json:"document_type"`
+	ContentHash  string    `json:"content_hash"`
+	SizeBytes    int64     `json:"size_bytes"`
+	CreatedAt    time.Time `json:"created_at"`
+	// DownloadURL is a signed link valid for DocumentURLTTL, filled in by the API.
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// DocumentUpload describes a document being added to the vault.
+type DocumentUpload struct {
+	CompanyID    string
+	CIPCFilingID string
+	FileName     string
+	DocumentType string
+}
+
+// DocumentVault stores documents encrypted and content-addressed in a BlobStore, with their
+// metadata in the documents table.
+type DocumentVault struct {
+	DB    *sql.DB
+	Store BlobStore
+	// BaseURL is prefixed to signed download links.
+	BaseURL       string
+	encryptionKey [32]byte
+	signingKey    []byte
+}
+
+// NewDocumentVault returns a vault using the configured keys.
+func NewDocumentVault(db *sql.DB, store BlobStore) *DocumentVault {
+	return &DocumentVault{
+		DB:            db,
+		Store:         store,
+		BaseURL:       getPublicAPIURL(),
+		encryptionKey: sha256.Sum256([]byte(getDocumentEncryptionKey())),
+		signingKey:    []byte(getDocumentURLSigningKey()),
+	}
+}
+
+// DetectDocumentType validates a document's size and returns its sniffed MIME type.
+func DetectDocumentType(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", ErrDocumentEmpty
+	}
+	if len(data) > MaxDocumentSize {
+		return "", ErrDocumentTooLarge
+	}
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil || !AllowedDocumentTypes[mimeType] {
+		return "", fmt.Errorf("%w: %s", ErrDocumentTypeNotAllowed, mimeType)
+	}
+	return mimeType, nil
+}
+
+// documentKey is where a document's contents are stored: its SHA-256, so identical uploads share
+// one blob.
+func documentKey(contentHash string) string {
+	return "sha256/" + contentHash[:2] + "/" + contentHash
+}
+
+// Upload validates, encrypts and stores a document and records it against its company and filing.
+func (v *DocumentVault) Upload(ctx context.Context, upload DocumentUpload, data []byte) (*Document, error) {
+	mimeType, err := DetectDocumentType(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	contentHash := hex.EncodeToString(sum[:])
+	key := documentKey(contentHash)
+
+	exists, err := v.Store.Exists(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check document store: %w", err)
+	}
+	if !exists {
+		sealed, err := v.seal(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.Store.Put(ctx, key, sealed); err != nil {
+			return nil, fmt.Errorf("failed to store document: %w", err)
+		}
+	}
+
+	doc := &Document{
+		CompanyID:    upload.CompanyID,
+		CIPCFilingID: upload.CIPCFilingID,
+		FileName:     upload.FileName,
+		MIMEType:     mimeType,
+		DocumentType: upload.DocumentType,
+		ContentHash:  contentHash,
+		SizeBytes:    int64(len(data)),
+	}
+	err = v.DB.QueryRowContext(ctx, `
+		INSERT INTO documents (company_id, cipc_filing_id, file_name, file_type, file_path, document_type, content_hash, size_bytes)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, doc.CompanyID, doc.CIPCFilingID, doc.FileName, doc.MIMEType, key, doc.DocumentType, doc.ContentHash, doc.SizeBytes).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record document: %w", err)
+	}
+	return doc, nil
+}
+
+// Get loads a document's metadata.
+func (v *DocumentVault) Get(ctx context.Context, id string) (*Document, error) {
+	var doc Document
+	var companyID, filingID, contentHash sql.NullString
+	var size sql.NullInt64
+	err := v.DB.QueryRowContext(ctx, `
+		SELECT id, company_id, cipc_filing_id, file_name, file_type, document_type, content_hash, size_bytes, created_at
+		FROM documents WHERE id = $1
+	`, id).Scan(&doc.ID, &companyID, &filingID, &doc.FileName, &doc.MIMEType, &doc.DocumentType, &contentHash, &size, &doc.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load document: %w", err)
+	}
+	doc.CompanyID = companyID.String
+	doc.CIPCFilingID = filingID.String
+	doc.ContentHash = contentHash.String
+	doc.SizeBytes = size.Int64
+	return &doc, nil
+}
+
+// Open returns a document's decrypted contents, checking them against the content hash.
+func (v *DocumentVault) Open(ctx context.Context, doc *Document) ([]byte, error) {
+	if doc.ContentHash == "" {
+		// Rows written before the vault have no blob.
+		return nil, ErrDocumentNotFound
+	}
+	sealed, err := v.Store.Get(ctx, documentKey(doc.ContentHash))
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	data, err := v.open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != doc.ContentHash {
+		return nil, ErrDocumentContentsMismatch
+	}
+	return data, nil
+}
+
+// LinkFiling attaches a document to a CIPC filing.
+func (v *DocumentVault) LinkFiling(ctx context.Context, id, cipcFilingID string) error {
+	result, err := v.DB.ExecContext(ctx, `UPDATE documents SET cipc_filing_id = $1 WHERE id = $2`, cipcFilingID, id)
+	if err != nil {
+		return fmt.Errorf("failed to link document: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
+
+// SignedURL returns a download link for a document that expires after DocumentURLTTL.
+func (v *DocumentVault) SignedURL(id string, now time.Time) string {
+	return v.SignedURLWithTTL(id, now, DocumentURLTTL)
+}
+
+// SignedURLWithTTL returns a download link for a document that expires after ttl, for links the
+// customer needs for longer than DocumentURLTTL.
+func (v *DocumentVault) SignedURLWithTTL(id string, now time.Time, ttl time.Duration) string {
+	expires := strconv.FormatInt(now.Add(ttl).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {v.sign(id, expires)}}
+	return strings.TrimRight(v.BaseURL, "/") + "/documents/" + url.PathEscape(id) + "/content?" + query.Encode()
+}
+
+// VerifySignedURL checks the expires and signature parameters of a download link.
+func (v *DocumentVault) VerifySignedURL(id, expires, signature string, now time.Time) error {
+	if !hmac.Equal([]byte(v.sign(id, expires)), []byte(signature)) {
+		return ErrDocumentLinkInvalid
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrDocumentLinkInvalid
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrDocumentLinkExpired
+	}
+	return nil
+}
+
+func (v *DocumentVault) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, v.signingKey)
+	mac.Write([]byte(id + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (v *DocumentVault) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.encryptionKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a document for storage as nonce followed by ciphertext.
+func (v *DocumentVault) seal(data []byte) ([]byte, error) {
+	aead, err := v.cipher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt document: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt document: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (v *DocumentVault) open(sealed []byte) ([]byte, error) {
+	aead, err := v.cipher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt document: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("failed to decrypt document: stored blob is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt document: %w", err)
+	}
+	return data, nil
+}
+
+// readAllLimited reads r, failing with ErrDocumentTooLarge if it holds more than limit bytes.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrDocumentTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,197 @@
+package temporal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Limits on filing batches.
+const (
+	MaxFilingBatchRows            = 500
+	DefaultFilingBatchParallelism = 5
+	MaxFilingBatchParallelism     = 20
+)
+
+// batchFilingServices are the services a batch can file: the ones CombinedFilingWorkflow submits
+// through the CIPC Runner without supporting documents.
+var batchFilingServices = map[string]bool{
+	"annual_return":        true,
+	"beneficial_ownership": true,
+}
+
+// FilingBatchRow is one filing in a batch. Row is 1-based and does not count the CSV header.
+type FilingBatchRow struct {
+	Row              int    `json:"row"`
+	CompanyRegNumber string `json:"company_reg_number"`
+	ServiceType      string `json:"service_type"`
+	CompanyName      string `json:"company_name,omitempty"`
+	// TransactionID is an already paid transaction to file against; a pending one is created
+	// for the row otherwise.
+	TransactionID string                 `json:"transaction_id,omitempty"`
+	FilingData    map[string]interface{} `json:"filing_data,omitempty"`
+}
+
+// FilingBatchTransaction is who a transaction named in a batch row belongs to and the service it
+// was paid for.
+type FilingBatchTransaction struct {
+	UserID      string `json:"user_id"`
+	ServiceType string `json:"service_type"`
+}
+
+// FilingBatchRowError explains why a row was rejected.
+type FilingBatchRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FilingBatchRowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s: %s", e.Row, e.Field, e.Message)
+}
+
+// ErrEmptyFilingBatch is returned for a batch without rows.
+var ErrEmptyFilingBatch = errors.New("the batch has no rows")
+
+// ParseFilingBatchCSV reads a batch from CSV with a header row. company_reg_number and
+// service_type are required columns; company_name and transaction_id are optional, and any other
+// column is passed to the filing as filing data. Header names are matched case-insensitively, with
+// spaces read as underscores.
+func ParseFilingBatchCSV(r io.Reader) ([]FilingBatchRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyFilingBatch
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+	}
+	for _, required := range []string{"company_reg_number", "service_type"} {
+		if !containsString(columns, required) {
+			return nil, fmt.Errorf("the CSV header has no %s column", required)
+		}
+	}
+
+	var rows []FilingBatchRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		row := FilingBatchRow{Row: len(rows) + 1}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "company_reg_number":
+				row.CompanyRegNumber = value
+			case "service_type":
+				row.ServiceType = value
+			case "company_name":
+				row.CompanyName = value
+			case "transaction_id":
+				row.TransactionID = value
+			default:
+				if value == "" {
+					continue
+				}
+				if row.FilingData == nil {
+					row.FilingData = map[string]interface{}{}
+				}
+				row.FilingData[columns[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyFilingBatch
+	}
+	return rows, nil
+}
+
+// ValidateFilingBatch checks every row of a batch filed by userID and returns all problems found,
+// so the whole file can be corrected at once. Rows are numbered in order. transactions holds the
+// transactions the rows name, by ID; a row may only file against the user's own transaction for
+// the same service.
+func ValidateFilingBatch(userID string, rows []FilingBatchRow, transactions map[string]FilingBatchTransaction) []FilingBatchRowError {
+	if len(rows) == 0 {
+		return []FilingBatchRowError{{Message: ErrEmptyFilingBatch.Error()}}
+	}
+	if len(rows) > MaxFilingBatchRows {
+		return []FilingBatchRowError{{Message: fmt.Sprintf("a batch can have at most %d rows, this one has %d", MaxFilingBatchRows, len(rows))}}
+	}
+
+	var problems []FilingBatchRowError
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		row.Row = i + 1
+		row.CompanyRegNumber = strings.TrimSpace(row.CompanyRegNumber)
+		row.ServiceType = strings.ToLower(strings.TrimSpace(row.ServiceType))
+		row.TransactionID = strings.TrimSpace(row.TransactionID)
+
+		if row.CompanyRegNumber == "" {
+			problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "company_reg_number", Message: "is required"})
+		} else if _, err := CompanyTypeFromRegNumber(row.CompanyRegNumber); err != nil {
+			problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "company_reg_number", Message: err.Error()})
+		}
+		switch {
+		case row.ServiceType == "":
+			problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "service_type", Message: "is required"})
+		case !batchFilingServices[row.ServiceType]:
+			problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "service_type", Message: fmt.Sprintf("%s cannot be filed in a batch", row.ServiceType)})
+		}
+
+		if row.TransactionID != "" {
+			transaction, ok := transactions[row.TransactionID]
+			switch {
+			case !ok || transaction.UserID != userID:
+				problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "transaction_id", Message: "is not one of your transactions"})
+			case transaction.ServiceType != row.ServiceType:
+				problems = append(problems, FilingBatchRowError{Row: row.Row, Field: "transaction_id", Message: fmt.Sprintf("was paid for %s, not %s", transaction.ServiceType, row.ServiceType)})
+			}
+		}
+
+		key := row.CompanyRegNumber + "|" + row.ServiceType
+		if first, ok := seen[key]; ok && row.CompanyRegNumber != "" {
+			problems = append(problems, FilingBatchRowError{Row: row.Row, Message: fmt.Sprintf("duplicates row %d", first)})
+		} else {
+			seen[key] = row.Row
+		}
+	}
+	return problems
+}
+
+// filingData is the data the row's filing is submitted with.
+func (r FilingBatchRow) filingData() map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range r.FilingData {
+		data[k] = v
+	}
+	data["company_reg_number"] = r.CompanyRegNumber
+	if r.CompanyName != "" {
+		data["company_name"] = r.CompanyName
+	}
+	return data
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
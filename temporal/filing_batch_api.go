@@ -0,0 +1,209 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// maxFilingBatchBody bounds the size of an uploaded batch.
+const maxFilingBatchBody = 5 << 20
+
+type filingBatchRequest struct {
+	UserID      string           `json:"user_id"`
+	MaxParallel int              `json:"max_parallel"`
+	Rows        []FilingBatchRow `json:"rows"`
+}
+
+// FilingBatchResponse is returned by POST /filing-batches.
+type FilingBatchResponse struct {
+	BatchID    string `json:"batch_id"`
+	WorkflowID string `json:"workflow_id"`
+	Rows       int    `json:"rows"`
+}
+
+func (s *APIServer) registerFilingBatchRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /filing-batches", requireInternalAPIKey(s.createFilingBatchHandler))
+	mux.HandleFunc("GET /filing-batches/{id}", requireInternalAPIKey(s.getFilingBatchHandler))
+	mux.HandleFunc("POST /filing-batches/{id}/retry", requireInternalAPIKey(s.retryFilingBatchHandler))
+}
+
+// FilingBatchWorkflowID is the ID of the FilingBatchWorkflow running a batch.
+func FilingBatchWorkflowID(batchID string) string {
+	return "filing-batch-" + batchID
+}
+
+// createFilingBatchHandler takes a batch as JSON, or as CSV (Content-Type text/csv) with user_id
+// and max_parallel in the query string. Nothing is filed unless every row is valid.
+func (s *APIServer) createFilingBatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFilingBatchBody)
+	var req filingBatchRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		rows, err := ParseFilingBatchCSV(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Rows = rows
+		req.UserID = r.URL.Query().Get("user_id")
+		if v := r.URL.Query().Get("max_parallel"); v != "" {
+			if req.MaxParallel, err = strconv.Atoi(v); err != nil {
+				writeError(w, http.StatusBadRequest, "max_parallel must be a number")
+				return
+			}
+		}
+	} else if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.UserID == "" {
+		writeError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if req.MaxParallel < 0 || req.MaxParallel > MaxFilingBatchParallelism {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("max_parallel must be between 1 and %d", MaxFilingBatchParallelism))
+		return
+	}
+	transactions, err := s.loadFilingBatchTransactions(r.Context(), req.Rows)
+	if err != nil {
+		log.Printf("Error loading batch transactions for user %s: %s", req.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to check batch transactions")
+		return
+	}
+	if problems := ValidateFilingBatch(req.UserID, req.Rows, transactions); len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":    "The batch has invalid rows; nothing was filed",
+			"problems": problems,
+		})
+		return
+	}
+
+	batchID := strconv.FormatInt(time.Now().UnixNano(), 10)
+	input := FilingBatchInput{BatchID: batchID, UserID: req.UserID, MaxParallel: req.MaxParallel, Rows: req.Rows}
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        FilingBatchWorkflowID(batchID),
+		TaskQueue: TaskQueue,
+	}, FilingBatchWorkflow, input)
+	if err != nil {
+		log.Printf("Error starting filing batch for user %s: %s", req.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start batch")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, FilingBatchResponse{BatchID: batchID, WorkflowID: run.GetID(), Rows: len(req.Rows)})
+}
+
+// loadFilingBatchTransactions looks up the transactions the rows name. Unknown IDs are left out.
+func (s *APIServer) loadFilingBatchTransactions(ctx context.Context, rows []FilingBatchRow) (map[string]FilingBatchTransaction, error) {
+	transactions := map[string]FilingBatchTransaction{}
+	for _, row := range rows {
+		id := strings.TrimSpace(row.TransactionID)
+		if id == "" {
+			continue
+		}
+		if _, ok := transactions[id]; ok {
+			continue
+		}
+		var transaction FilingBatchTransaction
+		err := s.DB.QueryRowContext(ctx, `
+			SELECT user_id, service_type FROM payg_transactions WHERE id = $1
+		`, id).Scan(&transaction.UserID, &transaction.ServiceType)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		transactions[id] = transaction
+	}
+	return transactions, nil
+}
+
+func (s *APIServer) getFilingBatchHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := s.queryFilingBatchReport(r.Context(), r.PathValue("id"))
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeError(w, http.StatusNotFound, "Batch not found")
+			return
+		}
+		log.Printf("Error querying filing batch %s: %s", r.PathValue("id"), err)
+		writeError(w, http.StatusInternalServerError, "Unable to get batch report")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// queryFilingBatchReport asks a batch for its report and fills in what each running filing is
+// waiting on.
+func (s *APIServer) queryFilingBatchReport(ctx context.Context, batchID string) (*FilingBatchReport, error) {
+	value, err := s.Temporal.QueryWorkflow(ctx, FilingBatchWorkflowID(batchID), "", FilingBatchReportQueryName)
+	if err != nil {
+		return nil, err
+	}
+	var report FilingBatchReport
+	if err := value.Get(&report); err != nil {
+		return nil, fmt.Errorf("failed to decode batch report: %w", err)
+	}
+
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		if row.Status != FilingBatchRowRunning || row.WorkflowID == "" {
+			continue
+		}
+		progress, err := s.queryFilingProgress(ctx, row.WorkflowID)
+		if err != nil {
+			// The row is reported as running without detail.
+			log.Printf("Error querying batch %s row %d: %s", batchID, row.Row, err)
+			continue
+		}
+		row.WaitingOn = progress.WaitingOn
+	}
+	report.Tally()
+	return &report, nil
+}
+
+// retryFilingBatchHandler queues the failed rows listed in the body again, or every failed row
+// when no rows are given.
+func (s *APIServer) retryFilingBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req FilingBatchRetryRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	handle, err := s.Temporal.UpdateWorkflow(r.Context(), client.UpdateWorkflowOptions{
+		WorkflowID:   FilingBatchWorkflowID(r.PathValue("id")),
+		UpdateName:   RetryFailedRowsUpdateName,
+		Args:         []interface{}{req},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	var result FilingBatchRetryResult
+	if err == nil {
+		err = handle.Get(r.Context(), &result)
+	}
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		var appErr *temporal.ApplicationError
+		switch {
+		case errors.As(err, &notFound):
+			writeError(w, http.StatusNotFound, "Batch not found or already closed")
+		case errors.As(err, &appErr):
+			// Rejected by the workflow's validator.
+			writeError(w, http.StatusConflict, appErr.Message())
+		default:
+			log.Printf("Error retrying filing batch %s: %s", r.PathValue("id"), err)
+			writeError(w, http.StatusInternalServerError, "Unable to retry batch")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,74 @@
+package temporal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilingBatchCSV(t *testing.T) {
+	rows, err := ParseFilingBatchCSV(strings.NewReader(`Company Reg Number,Service_Type,company_name,financial_year_end
+2020/123456/07, annual_return ,Acme (Pty) Ltd,2025-02-28
+2019/654321/07,beneficial_ownership,,
+`))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, FilingBatchRow{
+		Row:              1,
+		CompanyRegNumber: "2020/123456/07",
+		ServiceType:      "annual_return",
+		CompanyName:      "Acme (Pty) Ltd",
+		FilingData:       map[string]interface{}{"financial_year_end": "2025-02-28"},
+	}, rows[0])
+	assert.Nil(t, rows[1].FilingData, "empty cells are not passed on")
+
+	_, err = ParseFilingBatchCSV(strings.NewReader("company_reg_number,company_name\n2020/123456/07,Acme\n"))
+	assert.ErrorContains(t, err, "no service_type column")
+	_, err = ParseFilingBatchCSV(strings.NewReader("company_reg_number,service_type\n"))
+	assert.ErrorIs(t, err, ErrEmptyFilingBatch)
+}
+
+func TestValidateFilingBatch(t *testing.T) {
+	rows := []FilingBatchRow{
+		{CompanyRegNumber: "2020/123456/07", ServiceType: "annual_return"},
+		{CompanyRegNumber: "2020/12345/07", ServiceType: "Annual_Return"},
+		{CompanyRegNumber: "2021/000001/07", ServiceType: "director_amendment"},
+		{CompanyRegNumber: "2020/123456/07", ServiceType: " annual_return"},
+		{ServiceType: ""},
+	}
+	problems := ValidateFilingBatch("user-1", rows, nil)
+
+	assert.Equal(t, []FilingBatchRowError{
+		{Row: 2, Field: "company_reg_number", Message: `invalid registration number "2020/12345/07", expected YYYY/NNNNNN/NN`},
+		{Row: 3, Field: "service_type", Message: "director_amendment cannot be filed in a batch"},
+		{Row: 4, Message: "duplicates row 1"},
+		{Row: 5, Field: "company_reg_number", Message: "is required"},
+		{Row: 5, Field: "service_type", Message: "is required"},
+	}, problems)
+	assert.Equal(t, "annual_return", rows[1].ServiceType, "service types are normalised")
+
+	assert.Empty(t, ValidateFilingBatch("user-1", rows[:1], nil))
+	assert.Len(t, ValidateFilingBatch("user-1", make([]FilingBatchRow, MaxFilingBatchRows+1), nil), 1)
+}
+
+func TestValidateFilingBatch_Transactions(t *testing.T) {
+	transactions := map[string]FilingBatchTransaction{
+		"tx-1": {UserID: "user-1", ServiceType: "annual_return"},
+		"tx-2": {UserID: "user-2", ServiceType: "annual_return"},
+		"tx-3": {UserID: "user-1", ServiceType: "beneficial_ownership"},
+	}
+	rows := []FilingBatchRow{
+		{CompanyRegNumber: "2020/123456/07", ServiceType: "annual_return", TransactionID: "tx-1"},
+		{CompanyRegNumber: "2020/654321/07", ServiceType: "annual_return", TransactionID: "tx-2"},
+		{CompanyRegNumber: "2021/000001/07", ServiceType: "annual_return", TransactionID: "tx-3"},
+		{CompanyRegNumber: "2021/000002/07", ServiceType: "annual_return", TransactionID: "tx-unknown"},
+	}
+
+	assert.Equal(t, []FilingBatchRowError{
+		{Row: 2, Field: "transaction_id", Message: "is not one of your transactions"},
+		{Row: 3, Field: "transaction_id", Message: "was paid for beneficial_ownership, not annual_return"},
+		{Row: 4, Field: "transaction_id", Message: "is not one of your transactions"},
+	}, ValidateFilingBatch("user-1", rows, transactions))
+}
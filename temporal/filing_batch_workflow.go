@@ -0,0 +1,344 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// FilingBatchReportQueryName is the query FilingBatchWorkflow answers with its FilingBatchReport.
+const FilingBatchReportQueryName = "filing-batch-report"
+
+// RetryFailedRowsUpdateName is the Update that queues failed rows of a batch again.
+const RetryFailedRowsUpdateName = "retry-failed-rows"
+
+// FilingBatchRetryWindow is how long a settled batch accepts retries before it closes.
+const FilingBatchRetryWindow = 7 * 24 * time.Hour
+
+// Overall state of a batch.
+const (
+	FilingBatchRunning = "running"
+	// FilingBatchSettled means every row has finished; failed rows can still be retried.
+	FilingBatchSettled = "settled"
+	FilingBatchClosed  = "closed"
+)
+
+// State of a row in a batch.
+const (
+	FilingBatchRowQueued    = "queued"
+	FilingBatchRowRunning   = "running"
+	FilingBatchRowSucceeded = "succeeded"
+	FilingBatchRowFailed    = "failed"
+)
+
+// FilingBatchInput starts a FilingBatchWorkflow. Rows must have passed ValidateFilingBatch.
+type FilingBatchInput struct {
+	BatchID string `json:"batch_id"`
+	// UserID is the accountant filing on behalf of the companies; OTP requests and the batch
+	// summary go to them.
+	UserID      string           `json:"user_id"`
+	MaxParallel int              `json:"max_parallel,omitempty"`
+	Rows        []FilingBatchRow `json:"rows"`
+}
+
+// FilingBatchRowStatus is a row's place in the FilingBatchReport.
+type FilingBatchRowStatus struct {
+	FilingBatchRow
+	Status          string `json:"status"`
+	Attempts        int    `json:"attempts"`
+	WorkflowID      string `json:"workflow_id,omitempty"`
+	FilingReference string `json:"filing_reference,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// WaitingOn is filled in from the row's FilingProgress by the API for running rows.
+	WaitingOn string `json:"waiting_on,omitempty"`
+}
+
+// FilingBatchCounts aggregates the rows of a batch. WaitingOnOTP counts running rows whose filing
+// is waiting for an OTP and is only known to the API.
+type FilingBatchCounts struct {
+	Total        int `json:"total"`
+	Queued       int `json:"queued"`
+	Running      int `json:"running"`
+	WaitingOnOTP int `json:"waiting_on_otp"`
+	Succeeded    int `json:"succeeded"`
+	Failed       int `json:"failed"`
+}
+
+// FilingBatchReport is the answer to FilingBatchReportQueryName and the workflow's result.
+type FilingBatchReport struct {
+	BatchID   string                 `json:"batch_id"`
+	UserID    string                 `json:"user_id"`
+	Status    string                 `json:"status"`
+	Counts    FilingBatchCounts      `json:"counts"`
+	Rows      []FilingBatchRowStatus `json:"rows"`
+	StartedAt time.Time              `json:"started_at"`
+	// SettledAt is when the last row finished, and is cleared when failed rows are retried.
+	SettledAt *time.Time `json:"settled_at,omitempty"`
+}
+
+// Tally recomputes the report's counts from its rows.
+func (r *FilingBatchReport) Tally() {
+	counts := FilingBatchCounts{Total: len(r.Rows)}
+	for _, row := range r.Rows {
+		switch row.Status {
+		case FilingBatchRowQueued:
+			counts.Queued++
+		case FilingBatchRowRunning:
+			counts.Running++
+			if row.WaitingOn == FilingWaitingOnOTP {
+				counts.WaitingOnOTP++
+			}
+		case FilingBatchRowSucceeded:
+			counts.Succeeded++
+		case FilingBatchRowFailed:
+			counts.Failed++
+		}
+	}
+	r.Counts = counts
+}
+
+// Summary describes a settled batch in a WhatsApp message.
+func (r FilingBatchReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📦 *Batch %s finished*\n\n✅ Filed: %d of %d", r.BatchID, r.Counts.Succeeded, r.Counts.Total)
+	if r.Counts.Failed > 0 {
+		fmt.Fprintf(&b, "\n❌ Failed: %d", r.Counts.Failed)
+		for _, row := range r.Rows {
+			if row.Status == FilingBatchRowFailed {
+				fmt.Fprintf(&b, "\n• Row %d, %s %s: %s", row.Row, row.CompanyRegNumber, serviceDisplayName(row.ServiceType), row.Error)
+			}
+		}
+		fmt.Fprintf(&b, "\n\nFailed rows can be retried for the next %d days.", int(FilingBatchRetryWindow.Hours()/24))
+	}
+	return b.String()
+}
+
+// FilingBatchRetryRequest is the argument of RetryFailedRowsUpdateName. With no rows, every
+// failed row is retried.
+type FilingBatchRetryRequest struct {
+	Rows []int `json:"rows,omitempty"`
+}
+
+// FilingBatchRetryResult lists the rows queued again by RetryFailedRowsUpdateName.
+type FilingBatchRetryResult struct {
+	Retried []int `json:"retried"`
+}
+
+// FilingBatchWorkflow files every row of a batch as a CombinedFilingWorkflow child, at most
+// MaxParallel at a time. Once every row has finished the accountant is sent a summary and failed
+// rows can be retried through RetryFailedRowsUpdateName until FilingBatchRetryWindow passes.
+func FilingBatchWorkflow(ctx workflow.Context, input FilingBatchInput) (*FilingBatchReport, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting filing batch", "batch_id", input.BatchID, "rows", len(input.Rows))
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	})
+
+	batch, err := newFilingBatch(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// Step 1: Start queued rows while fewer than MaxParallel are filing
+		if err := workflow.Await(ctx, func() bool {
+			return batch.settled() || (len(batch.queue) > 0 && batch.running < batch.parallelism)
+		}); err != nil {
+			return nil, err
+		}
+		if len(batch.queue) > 0 {
+			batch.start(ctx)
+			continue
+		}
+
+		// Step 2: Tell the accountant how the batch went and wait for retries
+		settledAt := workflow.Now(ctx)
+		batch.report.Status = FilingBatchSettled
+		batch.report.SettledAt = &settledAt
+		batch.report.Tally()
+		notifyUser(ctx, input.UserID, batch.report.Summary())
+
+		retried, err := workflow.AwaitWithTimeout(ctx, FilingBatchRetryWindow, func() bool { return len(batch.queue) > 0 })
+		if err != nil {
+			return nil, err
+		}
+		if !retried {
+			break
+		}
+	}
+
+	// Step 3: Close the batch
+	batch.report.Status = FilingBatchClosed
+	batch.report.Tally()
+	logger.Info("Filing batch closed", "batch_id", input.BatchID, "succeeded", batch.report.Counts.Succeeded, "failed", batch.report.Counts.Failed)
+	return &batch.report, nil
+}
+
+// filingBatch is FilingBatchWorkflow's state, served to FilingBatchReportQueryName.
+type filingBatch struct {
+	input       FilingBatchInput
+	report      FilingBatchReport
+	parallelism int
+	running     int
+	// queue holds indexes into report.Rows, in filing order.
+	queue []int
+}
+
+func newFilingBatch(ctx workflow.Context, input FilingBatchInput) (*filingBatch, error) {
+	b := &filingBatch{
+		input:       input,
+		parallelism: input.MaxParallel,
+		report: FilingBatchReport{
+			BatchID:   input.BatchID,
+			UserID:    input.UserID,
+			Status:    FilingBatchRunning,
+			Rows:      make([]FilingBatchRowStatus, len(input.Rows)),
+			StartedAt: workflow.Now(ctx),
+		},
+	}
+	if b.parallelism <= 0 {
+		b.parallelism = DefaultFilingBatchParallelism
+	}
+	if b.parallelism > MaxFilingBatchParallelism {
+		b.parallelism = MaxFilingBatchParallelism
+	}
+	for i, row := range input.Rows {
+		b.report.Rows[i] = FilingBatchRowStatus{FilingBatchRow: row, Status: FilingBatchRowQueued}
+		b.queue = append(b.queue, i)
+	}
+
+	if err := workflow.SetQueryHandler(ctx, FilingBatchReportQueryName, func() (FilingBatchReport, error) {
+		report := b.report
+		report.Tally()
+		return report, nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, RetryFailedRowsUpdateName, b.retry,
+		workflow.UpdateHandlerOptions{Validator: b.validateRetry}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *filingBatch) settled() bool {
+	return len(b.queue) == 0 && b.running == 0
+}
+
+// start files the next queued row in the background.
+func (b *filingBatch) start(ctx workflow.Context) {
+	row := &b.report.Rows[b.queue[0]]
+	b.queue = b.queue[1:]
+	row.Status = FilingBatchRowRunning
+	row.Attempts++
+	row.Error, row.WorkflowID = "", ""
+	b.running++
+	b.report.Status = FilingBatchRunning
+	b.report.SettledAt = nil
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		defer func() { b.running-- }()
+		reference, err := b.file(ctx, row)
+		if err != nil {
+			workflow.GetLogger(ctx).Warn("Batch row failed", "batch_id", b.input.BatchID, "row", row.Row, "error", err)
+			row.Status = FilingBatchRowFailed
+			row.Error = err.Error()
+			return
+		}
+		row.Status = FilingBatchRowSucceeded
+		row.FilingReference = reference
+	})
+}
+
+// file runs a row's filing as a child workflow, creating its transaction first when the row did
+// not come with one. Retried rows keep the transaction of their first attempt.
+func (b *filingBatch) file(ctx workflow.Context, row *FilingBatchRowStatus) (string, error) {
+	if row.TransactionID == "" {
+		transaction := CreatePaygTransactionInput{
+			UserID:      b.input.UserID,
+			ServiceType: row.ServiceType,
+			FilingData:  row.filingData(),
+		}
+		transaction.FilingData["batch_id"] = b.input.BatchID
+		if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, transaction).Get(ctx, &row.TransactionID); err != nil {
+			return "", fmt.Errorf("could not create the transaction: %w", err)
+		}
+	}
+
+	workflowID := fmt.Sprintf("filing-batch-%s-row-%d", b.input.BatchID, row.Row)
+	if row.Attempts > 1 {
+		workflowID = fmt.Sprintf("%s-attempt-%d", workflowID, row.Attempts)
+	}
+	row.WorkflowID = workflowID
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{WorkflowID: workflowID})
+	var result FilingWorkflowResult
+	err := workflow.ExecuteChildWorkflow(childCtx, CombinedFilingWorkflow, FilingWorkflowInput{
+		TransactionID:    row.TransactionID,
+		UserID:           b.input.UserID,
+		ServiceType:      row.ServiceType,
+		FilingData:       row.filingData(),
+		CompanyRegNumber: row.CompanyRegNumber,
+	}).Get(ctx, &result)
+	if err != nil {
+		return "", err
+	}
+	if !result.Success {
+		return "", errors.New(result.ErrorMessage)
+	}
+	return result.FilingReference, nil
+}
+
+func (b *filingBatch) validateRetry(ctx workflow.Context, req FilingBatchRetryRequest) error {
+	if len(req.Rows) == 0 {
+		for _, row := range b.report.Rows {
+			if row.Status == FilingBatchRowFailed {
+				return nil
+			}
+		}
+		return errors.New("no rows have failed")
+	}
+	for _, n := range req.Rows {
+		if n < 1 || n > len(b.report.Rows) {
+			return fmt.Errorf("row %d is not in the batch", n)
+		}
+		if status := b.report.Rows[n-1].Status; status != FilingBatchRowFailed {
+			return fmt.Errorf("row %d is %s, only failed rows can be retried", n, status)
+		}
+	}
+	return nil
+}
+
+func (b *filingBatch) retry(ctx workflow.Context, req FilingBatchRetryRequest) (FilingBatchRetryResult, error) {
+	var result FilingBatchRetryResult
+	for i := range b.report.Rows {
+		row := &b.report.Rows[i]
+		if row.Status != FilingBatchRowFailed || (len(req.Rows) > 0 && !containsInt(req.Rows, row.Row)) {
+			continue
+		}
+		row.Status = FilingBatchRowQueued
+		b.queue = append(b.queue, i)
+		result.Retried = append(result.Retried, row.Row)
+	}
+	workflow.GetLogger(ctx).Info("Retrying failed batch rows", "batch_id", b.input.BatchID, "rows", result.Retried)
+	return result, nil
+}
+
+func containsInt(values []int, n int) bool {
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// FilingBatchWorkflowTestSuite is the test suite for FilingBatchWorkflow.
+type FilingBatchWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestFilingBatchWorkflowTestSuite runs the test suite.
+func TestFilingBatchWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(FilingBatchWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *FilingBatchWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(CombinedFilingWorkflow)
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, "accountant-1", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *FilingBatchWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *FilingBatchWorkflowTestSuite) onFiling(regNumber string, result FilingWorkflowResult, after time.Duration) *testsuite.MockCallWrapper {
+	return s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.MatchedBy(func(in FilingWorkflowInput) bool {
+		return in.CompanyRegNumber == regNumber
+	})).After(after).Return(&result, nil)
+}
+
+func (s *FilingBatchWorkflowTestSuite) report() FilingBatchReport {
+	value, err := s.env.QueryWorkflow(FilingBatchReportQueryName)
+	s.Require().NoError(err)
+	var report FilingBatchReport
+	s.Require().NoError(value.Get(&report))
+	return report
+}
+
+func (s *FilingBatchWorkflowTestSuite) Test_FilesRowsWithBoundedParallelism() {
+	regNumbers := []string{"2020/000001/07", "2020/000002/07", "2020/000003/07", "2020/000004/07", "2020/000005/07"}
+	input := FilingBatchInput{BatchID: "b1", UserID: "accountant-1", MaxParallel: 2}
+	for i, reg := range regNumbers {
+		input.Rows = append(input.Rows, FilingBatchRow{Row: i + 1, CompanyRegNumber: reg, ServiceType: "annual_return"})
+		s.onFiling(reg, FilingWorkflowResult{Success: true, FilingReference: "AR-" + reg}, time.Hour).Once()
+	}
+	input.Rows[0].TransactionID = "tx-paid"
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.UserID == "accountant-1" && in.FilingData["batch_id"] == "b1" && in.FilingData["company_reg_number"] != regNumbers[0]
+	})).Return("tx-new", nil).Times(4)
+
+	s.env.RegisterDelayedCallback(func() {
+		report := s.report()
+		s.Equal(FilingBatchRunning, report.Status)
+		s.Equal(FilingBatchCounts{Total: 5, Queued: 3, Running: 2}, report.Counts)
+		s.Equal("filing-batch-b1-row-1", report.Rows[0].WorkflowID)
+	}, 30*time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal(FilingBatchCounts{Total: 5, Queued: 1, Running: 2, Succeeded: 2}, s.report().Counts)
+	}, 90*time.Minute)
+
+	s.env.ExecuteWorkflow(FilingBatchWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var report FilingBatchReport
+	s.NoError(s.env.GetWorkflowResult(&report))
+	s.Equal(FilingBatchClosed, report.Status)
+	s.Equal(FilingBatchCounts{Total: 5, Succeeded: 5}, report.Counts)
+	s.Equal("tx-paid", report.Rows[0].TransactionID)
+	s.Equal("AR-2020/000005/07", report.Rows[4].FilingReference)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "Filed: 5 of 5")
+}
+
+func (s *FilingBatchWorkflowTestSuite) Test_FailedRowsCanBeRetried() {
+	input := FilingBatchInput{BatchID: "b2", UserID: "accountant-1", Rows: []FilingBatchRow{
+		{Row: 1, CompanyRegNumber: "2020/000001/07", ServiceType: "annual_return", TransactionID: "tx-1"},
+		{Row: 2, CompanyRegNumber: "2020/000002/07", ServiceType: "beneficial_ownership", TransactionID: "tx-2"},
+	}}
+	s.onFiling("2020/000001/07", FilingWorkflowResult{Success: true, FilingReference: "AR1"}, time.Minute).Once()
+	s.onFiling("2020/000002/07", FilingWorkflowResult{Success: false, ErrorMessage: "Payment not confirmed"}, time.Minute).Once()
+	s.onFiling("2020/000002/07", FilingWorkflowResult{Success: true, FilingReference: "BO2"}, time.Minute).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		report := s.report()
+		s.Equal(FilingBatchSettled, report.Status)
+		s.Equal(FilingBatchCounts{Total: 2, Succeeded: 1, Failed: 1}, report.Counts)
+
+		s.env.UpdateWorkflow(RetryFailedRowsUpdateName, "retry-1", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("succeeded rows cannot be retried") },
+			OnReject:   func(err error) { s.ErrorContains(err, "row 1 is succeeded") },
+			OnComplete: func(interface{}, error) {},
+		}, FilingBatchRetryRequest{Rows: []int{1}})
+		s.env.UpdateWorkflow(RetryFailedRowsUpdateName, "retry-2", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("failed rows can be retried", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				s.Equal(FilingBatchRetryResult{Retried: []int{2}}, result)
+			},
+		}, FilingBatchRetryRequest{})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(FilingBatchWorkflow, input)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var report FilingBatchReport
+	s.NoError(s.env.GetWorkflowResult(&report))
+	s.Equal(FilingBatchCounts{Total: 2, Succeeded: 2}, report.Counts)
+	s.Equal(2, report.Rows[1].Attempts)
+	s.Equal("filing-batch-b2-row-2-attempt-2", report.Rows[1].WorkflowID)
+	s.Equal("tx-2", report.Rows[1].TransactionID)
+	s.Require().Len(s.messages, 2)
+	s.Contains(s.messages[0], "Failed: 1")
+	s.Contains(s.messages[0], "Row 2, 2020/000002/07 beneficial ownership declaration: Payment not confirmed")
+	s.Contains(s.messages[1], "Filed: 2 of 2")
+}
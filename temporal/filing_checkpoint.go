@@ -0,0 +1,163 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Checkpoint steps reported by the runner as progress events, in the order they happen.
+const (
+	CheckpointLoggedIn   = "logged_in"
+	CheckpointFormPage   = "form_page"
+	CheckpointSubmitting = "submitting"
+	CheckpointSubmitted  = "submitted"
+)
+
+var checkpointSteps = map[string]bool{
+	CheckpointLoggedIn:   true,
+	CheckpointFormPage:   true,
+	CheckpointSubmitting: true,
+	CheckpointSubmitted:  true,
+}
+
+// filingHeartbeatInterval keeps the heartbeat alive while the runner is quiet, e.g. waiting on a slow page.
+const filingHeartbeatInterval = 15 * time.Second
+
+// FilingCheckpoint is recorded as heartbeat details so a retried attempt knows how far the
+// previous one got.
+type FilingCheckpoint struct {
+	Step       string    `json:"step"`
+	Page       int       `json:"page,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// reachedSubmission reports whether the portal may already have accepted the filing.
+func (c FilingCheckpoint) reachedSubmission() bool {
+	return c.Step == CheckpointSubmitting || c.Step == CheckpointSubmitted
+}
+
+// runCheckpointedFiling runs a filing through the CIPC runner from inside an activity. Every
+// progress event is heartbeated as a FilingCheckpoint. When a previous attempt got as far as
+// submitting, the runner is asked to verify the submission instead of filing again, and only
+// files when the portal has no record of it. The runner only starts once a portal session is
+// free; see PortalSessionLimiter. Worker shutdown cancels the runner promptly and fails the
+// attempt with a retryable error so another worker can resume from the checkpoint.
+func runCheckpointedFiling(ctx context.Context, serviceType string, clientData map[string]interface{}) (*RunnerOutcome, error) {
+	logger := activity.GetLogger(ctx)
+	info := activity.GetInfo(ctx)
+
+	var mu sync.Mutex
+	var checkpoint FilingCheckpoint
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &checkpoint); err != nil {
+			logger.Warn("Ignoring unreadable filing checkpoint", "error", err)
+			checkpoint = FilingCheckpoint{}
+		}
+	}
+	if checkpoint.Step != "" {
+		logger.Info("Resuming filing from checkpoint", "attempt", info.Attempt, "step", checkpoint.Step, "page", checkpoint.Page, "reference", checkpoint.Reference)
+	}
+
+	heartbeat := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		activity.RecordHeartbeat(ctx, checkpoint)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var workerStopping bool
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(filingHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				heartbeat()
+			case <-activity.GetWorkerStopChannel(ctx):
+				mu.Lock()
+				workerStopping = true
+				mu.Unlock()
+				cancel()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	runner := NewRunnerClient()
+	runner.OnEvent = func(event RunnerEvent) {
+		if event.Type != RunnerEventProgress {
+			return
+		}
+		logger.Info("CIPC Runner progress", "step", event.Step, "message", event.Message)
+		if !checkpointSteps[event.Step] {
+			// Informational steps such as "verifying" must not overwrite a submission checkpoint.
+			return
+		}
+		mu.Lock()
+		checkpoint = FilingCheckpoint{Step: event.Step, Page: checkpoint.Page, Reference: checkpoint.Reference, RecordedAt: time.Now()}
+		if page, ok := event.Details["page"].(float64); ok {
+			checkpoint.Page = int(page)
+		}
+		if reference, ok := event.Details["reference"].(string); ok {
+			checkpoint.Reference = reference
+		}
+		mu.Unlock()
+		heartbeat()
+	}
+	runner.OnLog = func(line string) {
+		logger.Debug("CIPC Runner output", "line", line)
+	}
+
+	request := RunnerRequest{
+		RequestID:   info.WorkflowExecution.ID + "/" + info.ActivityID,
+		ServiceType: serviceType,
+		ClientData:  clientData,
+		Action:      RunnerActionFile,
+	}
+	if checkpoint.Step != "" {
+		resume := checkpoint
+		request.Resume = &resume
+	}
+
+	var outcome *RunnerOutcome
+	sessionCtx, release, err := acquirePortalSession(runCtx, heartbeat)
+	switch {
+	case err != nil:
+	case checkpoint.reachedSubmission():
+		request.Action = RunnerActionVerify
+		outcome, err = runner.Run(sessionCtx, request)
+
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.Type() == RunnerErrNotSubmitted {
+			logger.Info("Previous attempt did not reach CIPC, submitting again")
+			request.Action = RunnerActionFile
+			outcome, err = runner.Run(sessionCtx, request)
+		}
+	default:
+		outcome, err = runner.Run(sessionCtx, request)
+	}
+	if release != nil {
+		release()
+	}
+
+	mu.Lock()
+	stopping := workerStopping
+	mu.Unlock()
+	if stopping && ctx.Err() == nil {
+		heartbeat()
+		return nil, temporal.NewApplicationError("worker shutting down during CIPC filing", "WorkerShutdown")
+	}
+	return outcome, err
+}
@@ -0,0 +1,250 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// CompensationChoiceSignalName carries the customer's choice of CompensationRefund,
+// CompensationCredit or CompensationRetry to a filing that could not be completed.
+const CompensationChoiceSignalName = "compensation-choice"
+
+// How a customer can be compensated for a paid filing that failed.
+const (
+	CompensationRefund = "refund"
+	CompensationCredit = "credit"
+	CompensationRetry  = "retry"
+)
+
+// Why a filing needed compensation, stored in filing_compensations.reason.
+const (
+	CompensationReasonSubmissionFailed = "submission_failed"
+	CompensationReasonOTPTimeout       = "otp_timeout"
+)
+
+const (
+	// CompensationChoiceTimeout is how long the customer has to choose before they are refunded.
+	CompensationChoiceTimeout = 72 * time.Hour
+	// CompensationRetryDelay is how long a filing waits before it is retried at the customer's request.
+	CompensationRetryDelay = 24 * time.Hour
+	// MaxFilingRetries is how many times a customer can ask for a failed filing to be retried.
+	MaxFilingRetries = 2
+)
+
+// FilingCompensation is the outcome recorded for finance by CompensateFilingActivity.
+type FilingCompensation struct {
+	TransactionID string `json:"transaction_id"`
+	UserID        string `json:"user_id"`
+	Reason        string `json:"reason"`
+	Failure       string `json:"failure"`
+	Choice        string `json:"choice"`
+	// ChosenBy is "customer", or "default" when the customer did not answer in time.
+	ChosenBy string `json:"chosen_by"`
+}
+
+// compensateFiling is the compensating step for a paid filing that failed permanently. The
+// customer chooses a refund, an account credit or a retry later; the choice is recorded for
+// finance. A retry continues the workflow as new after CompensationRetryDelay; otherwise the
+// original failure is returned.
+func compensateFiling(ctx workflow.Context, params FilingWorkflowInput, progress *filingProgress, reason string, failure error) (*FilingWorkflowResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Compensating failed filing", "TransactionID", params.TransactionID, "reason", reason)
+
+	choices := []string{CompensationRefund, CompensationCredit}
+	if params.RetryAttempt < MaxFilingRetries {
+		choices = append(choices, CompensationRetry)
+	}
+	progress.compensating(ctx, failure)
+
+	choice, chosenBy := awaitCompensationChoice(ctx, params, reason, choices)
+
+	compensation := FilingCompensation{
+		TransactionID: params.TransactionID,
+		UserID:        params.UserID,
+		Reason:        reason,
+		Failure:       failure.Error(),
+		Choice:        choice,
+		ChosenBy:      chosenBy,
+	}
+	if err := workflow.ExecuteActivity(ctx, CompensateFilingActivity, compensation).Get(ctx, nil); err != nil {
+		return nil, progress.fail(ctx, fmt.Errorf("compensation failed after %v: %w", failure, err))
+	}
+	progress.compensated(choice)
+
+	switch choice {
+	case CompensationRefund:
+		notifyUser(ctx, params.UserID, "💸 We've started your refund. It usually reaches your account within 5-7 business days.")
+	case CompensationCredit:
+		notifyUser(ctx, params.UserID, "💳 The amount has been added to your account as credit for your next filing.")
+	case CompensationRetry:
+		notifyUser(ctx, params.UserID, fmt.Sprintf("🔁 We'll try your filing again in %d hours and ask you for a new OTP then.", int(CompensationRetryDelay.Hours())))
+		progress.recordError(ctx, failure.Error())
+		if err := workflow.Sleep(ctx, CompensationRetryDelay); err != nil {
+			return nil, err
+		}
+		params.RetryAttempt++
+		return nil, workflow.NewContinueAsNewError(ctx, CombinedFilingWorkflow, params)
+	}
+	return nil, progress.fail(ctx, failure)
+}
+
+// awaitCompensationChoice asks the customer how to be compensated and waits for a valid answer,
+// defaulting to a refund.
+func awaitCompensationChoice(ctx workflow.Context, params FilingWorkflowInput, reason string, choices []string) (string, string) {
+	var b strings.Builder
+	if reason == CompensationReasonOTPTimeout {
+		b.WriteString("😔 We couldn't complete your filing because we didn't receive the OTP in time.")
+	} else {
+		b.WriteString("😔 We couldn't complete your filing with CIPC.")
+	}
+	b.WriteString(" You've already paid, so please choose what you'd like us to do:\n")
+	for _, choice := range choices {
+		b.WriteString("\n" + compensationOptions[choice])
+	}
+	fmt.Fprintf(&b, "\n\nIf we don't hear from you within %d hours we'll refund you.", int(CompensationChoiceTimeout.Hours()))
+	notifyUser(ctx, params.UserID, b.String())
+
+	deadline := workflow.Now(ctx).Add(CompensationChoiceTimeout)
+	conversationID := openConversation(ctx, ConversationPrompt{
+		UserID:      params.UserID,
+		Awaiting:    ConversationAwaitingCompensation,
+		Description: fmt.Sprintf("Refund, credit or retry for your %s filing", strings.ReplaceAll(params.ServiceType, "_", " ")),
+		ExpiresAt:   deadline,
+	})
+	defer endConversation(ctx, conversationID)
+
+	choiceChan := workflow.GetSignalChannel(ctx, CompensationChoiceSignalName)
+	for {
+		var choice string
+		ok, _ := choiceChan.ReceiveWithTimeout(ctx, deadline.Sub(workflow.Now(ctx)), &choice)
+		if !ok {
+			return CompensationRefund, "default"
+		}
+		choice = strings.ToLower(strings.TrimSpace(choice))
+		for _, c := range choices {
+			if c == choice {
+				return choice, "customer"
+			}
+		}
+		notifyUser(ctx, params.UserID, "⚠️ That option isn't available for this filing. Please reply with one of:\n\n"+compensationChoiceList(choices))
+	}
+}
+
+var compensationOptions = map[string]string{
+	CompensationRefund: "REFUND - get your money back",
+	CompensationCredit: "CREDIT - keep it as account credit for your next filing",
+	CompensationRetry:  "RETRY - let us try the filing again later",
+}
+
+func compensationChoiceList(choices []string) string {
+	options := make([]string, len(choices))
+	for i, choice := range choices {
+		options[i] = compensationOptions[choice]
+	}
+	return strings.Join(options, "\n")
+}
+
+// notifyUser sends a WhatsApp message to a user by ID, logging rather than failing on errors.
+func notifyUser(ctx workflow.Context, userID, message string) {
+	if err := workflow.ExecuteActivity(ctx, SendWhatsAppMessageActivity, userID, message).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to send WhatsApp message", "error", err)
+	}
+}
+
+// CompensateFilingActivity applies the customer's compensation choice and records it in
+// filing_compensations. Refunds are left pending for finance to pay out; credits are added to the
+// customer's account. Re-running it for the same workflow run has no further effect.
+func CompensateFilingActivity(ctx context.Context, compensation FilingCompensation) error {
+	logger := activity.GetLogger(ctx)
+	execution := activity.GetInfo(ctx).WorkflowExecution
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM filing_compensations WHERE workflow_id = $1 AND run_id = $2`, execution.ID, execution.RunID).Scan(&existing)
+	if err == nil {
+		logger.Info("Filing already compensated", "compensation_id", existing)
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check for compensation: %w", err)
+	}
+
+	var amount float64
+	err = tx.QueryRowContext(ctx, `SELECT amount FROM payg_transactions WHERE id = $1 FOR UPDATE`, compensation.TransactionID).Scan(&amount)
+	if err != nil {
+		return fmt.Errorf("failed to load transaction %s: %w", compensation.TransactionID, err)
+	}
+
+	transactionStatus, compensationStatus := "retry_scheduled", "completed"
+	var creditID sql.NullString
+	switch compensation.Choice {
+	case CompensationRefund:
+		transactionStatus, compensationStatus = "refund_pending", "pending"
+	case CompensationCredit:
+		transactionStatus = "credited"
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO account_credits (user_id, amount, source_transaction_id)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`, compensation.UserID, amount, compensation.TransactionID).Scan(&creditID)
+		if err != nil {
+			return fmt.Errorf("failed to add account credit: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE payg_transactions SET status = $1 WHERE id = $2`, transactionStatus, compensation.TransactionID); err != nil {
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO filing_compensations
+			(transaction_id, user_id, workflow_id, run_id, reason, failure, choice, chosen_by, amount, status, account_credit_id, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CASE WHEN $10 = 'completed' THEN NOW() END)
+	`, compensation.TransactionID, compensation.UserID, execution.ID, execution.RunID, compensation.Reason, compensation.Failure,
+		compensation.Choice, compensation.ChosenBy, amount, compensationStatus, creditID)
+	if err != nil {
+		return fmt.Errorf("failed to record compensation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.Info("Filing compensated", "transaction_id", compensation.TransactionID, "choice", compensation.Choice, "amount", amount)
+	return nil
+}
+
+// SettleRetriedFilingActivity moves a transaction left at retry_scheduled by
+// CompensateFilingActivity back to paid once its retried filing has been submitted.
+func SettleRetriedFilingActivity(ctx context.Context, transactionID string) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE payg_transactions SET status = 'paid' WHERE id = $1 AND status = 'retry_scheduled'
+	`, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to settle transaction %s: %w", transactionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// The checks of a filing dry run, reported with each issue.
+const (
+	FilingCheckPayload    = "payload"
+	FilingCheckDocuments  = "documents"
+	FilingCheckCompliance = "compliance"
+	FilingCheckFee        = "fee"
+)
+
+// FilingDryRunInput is a filing the customer is thinking of paying for.
+type FilingDryRunInput struct {
+	UserID           string `json:"user_id"`
+	ServiceType      string `json:"service_type"`
+	CompanyRegNumber string `json:"company_reg_number"`
+	// CompanyID identifies the company's stored directors and documents, if we have any.
+	CompanyID  string                 `json:"company_id,omitempty"`
+	IsUrgent   bool                   `json:"is_urgent,omitempty"`
+	FilingData map[string]interface{} `json:"filing_data,omitempty"`
+}
+
+// FilingCheckIssue is a problem found by a dry run.
+type FilingCheckIssue struct {
+	Check   string `json:"check"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// FilingDryRunResult says whether a filing would go through. Blocking issues stop it; warnings
+// do not, but the customer should know about them before paying.
+type FilingDryRunResult struct {
+	ServiceType      string                `json:"service_type"`
+	CompanyRegNumber string                `json:"company_reg_number"`
+	CanFile          bool                  `json:"can_file"`
+	Blocking         []FilingCheckIssue    `json:"blocking"`
+	Warnings         []FilingCheckIssue    `json:"warnings"`
+	MissingDocuments []DocumentRequirement `json:"missing_documents,omitempty"`
+	// Compliance is the outcome reported by ComplianceCheckWorkflow.
+	Compliance string          `json:"compliance,omitempty"`
+	Fee        *FilingFeeQuote `json:"fee,omitempty"`
+	// UrgentSLAHours is how long an urgent filing of the service may take.
+	UrgentSLAHours int `json:"urgent_sla_hours,omitempty"`
+}
+
+func (r *FilingDryRunResult) block(check, field, message string) {
+	r.Blocking = append(r.Blocking, FilingCheckIssue{Check: check, Field: field, Message: message})
+}
+
+func (r *FilingDryRunResult) warn(check, field, message string) {
+	r.Warnings = append(r.Warnings, FilingCheckIssue{Check: check, Field: field, Message: message})
+}
+
+// Summary describes the result in a WhatsApp message.
+func (r FilingDryRunResult) Summary() string {
+	var b strings.Builder
+	subject := serviceDisplayName(r.ServiceType)
+	if r.CompanyRegNumber != "" {
+		subject += " for " + r.CompanyRegNumber
+	}
+	if r.CanFile {
+		fmt.Fprintf(&b, "✅ Your %s is ready to file.", subject)
+	} else {
+		fmt.Fprintf(&b, "❌ Your %s can't be filed yet:\n", subject)
+		for _, issue := range r.Blocking {
+			b.WriteString("\n• " + issue.Message)
+		}
+	}
+	if r.Fee != nil {
+		fmt.Fprintf(&b, "\n\nFee: R%.2f", r.Fee.Amount)
+		if r.Fee.UrgencyFee > 0 {
+			fmt.Fprintf(&b, " (including R%.2f for urgent filing within %d hours)", r.Fee.UrgencyFee, r.UrgentSLAHours)
+		}
+		if r.Fee.AvailableCredit > 0 {
+			fmt.Fprintf(&b, "\nYou have R%.2f account credit.", r.Fee.AvailableCredit)
+		}
+	}
+	if len(r.Warnings) > 0 {
+		b.WriteString("\n\n⚠️ Please note:")
+		for _, issue := range r.Warnings {
+			b.WriteString("\n• " + issue.Message)
+		}
+	}
+	if r.CanFile {
+		b.WriteString("\n\nNothing has been filed or charged.")
+	}
+	return b.String()
+}
+
+// inactiveCompanyStatuses are CIPC enterprise statuses under which nothing but a reinstatement
+// can be filed.
+var inactiveCompanyStatuses = map[string]bool{
+	"final deregistration":    true,
+	"ar final deregistration": true,
+	"deregistered":            true,
+	"deregistration final":    true,
+	"voluntary liquidation":   true,
+	"compulsory liquidation":  true,
+	"in liquidation":          true,
+	"dissolved":               true,
+}
+
+// ValidateFilingPayload checks a filing's data, after document extraction, the way CIPC would
+// before accepting it. It returns the blocking issues and the warnings found.
+func ValidateFilingPayload(regNumber string, data map[string]interface{}) (blocking, warnings []FilingCheckIssue) {
+	if strings.TrimSpace(regNumber) == "" {
+		blocking = append(blocking, FilingCheckIssue{Check: FilingCheckPayload, Field: "company_reg_number", Message: "The company registration number is missing"})
+	} else if _, err := CompanyTypeFromRegNumber(regNumber); err != nil {
+		blocking = append(blocking, FilingCheckIssue{Check: FilingCheckPayload, Field: "company_reg_number", Message: err.Error()})
+	}
+
+	if onDocs, _ := data[FieldRegistrationNumber].(string); onDocs != "" && regNumber != "" && strings.TrimSpace(onDocs) != strings.TrimSpace(regNumber) {
+		blocking = append(blocking, FilingCheckIssue{Check: FilingCheckPayload, Field: FieldRegistrationNumber,
+			Message: fmt.Sprintf("Your documents are for company %s, not %s", onDocs, regNumber)})
+	}
+	if status, _ := data[FieldCompanyStatus].(string); status != "" {
+		switch lower := strings.ToLower(strings.TrimSpace(status)); {
+		case inactiveCompanyStatuses[lower]:
+			blocking = append(blocking, FilingCheckIssue{Check: FilingCheckPayload, Field: FieldCompanyStatus,
+				Message: fmt.Sprintf("CIPC lists the company as '%s'; it has to be reinstated before anything else can be filed", status)})
+		case lower != "in business":
+			warnings = append(warnings, FilingCheckIssue{Check: FilingCheckPayload, Field: FieldCompanyStatus,
+				Message: fmt.Sprintf("CIPC lists the company as '%s'", status)})
+		}
+	}
+
+	if name, _ := data["company_name"].(string); strings.TrimSpace(name) == "" {
+		warnings = append(warnings, FilingCheckIssue{Check: FilingCheckPayload, Field: "company_name", Message: "The company name is missing; we'll use the name CIPC has on record"})
+	}
+	if confidence, ok := data["extraction_confidence"].(map[string]interface{}); ok {
+		fields := make([]string, 0, len(confidence))
+		for field := range confidence {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if score, _ := confidence[field].(float64); score < MinExtractionConfidence {
+				warnings = append(warnings, FilingCheckIssue{Check: FilingCheckPayload, Field: field,
+					Message: fmt.Sprintf("We couldn't read the %s from your documents clearly and will confirm it with you", strings.ReplaceAll(field, "_", " "))})
+			}
+		}
+	}
+	return blocking, warnings
+}
+
+// MissingFilingDocuments returns the requirements the given documents do not meet. Documents in
+// a format a requirement does not accept do not count towards it.
+func MissingFilingDocuments(requirements []DocumentRequirement, documents []CollectedDocument) []DocumentRequirement {
+	collection := newDocumentCollection(requirements)
+	for _, doc := range documents {
+		requirement, ok := collection.requirement(doc.DocumentType)
+		if !ok || collection.outstanding[doc.DocumentType] == 0 || !requirement.Accepts(doc.MIMEType) {
+			continue
+		}
+		collection.add(doc)
+	}
+	return collection.missing()
+}
+
+// FilingDryRunWorkflow runs the checks of the filing pipeline without payment, OTP or
+// submission: payload validation, document completeness, ComplianceCheckWorkflow and the fee. It
+// stops where CombinedFilingWorkflow would call SubmitToCIPCActivity; nothing is filed or charged.
+func FilingDryRunWorkflow(ctx workflow.Context, input FilingDryRunInput) (*FilingDryRunResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting FilingDryRunWorkflow", "service_type", input.ServiceType, "company_reg_number", input.CompanyRegNumber)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	result := &FilingDryRunResult{
+		ServiceType:      input.ServiceType,
+		CompanyRegNumber: input.CompanyRegNumber,
+		Blocking:         []FilingCheckIssue{},
+		Warnings:         []FilingCheckIssue{},
+	}
+
+	// Step 1: Validate the payload, with document data extracted as the filing would
+	data := input.FilingData
+	filingInput := FilingWorkflowInput{
+		UserID:           input.UserID,
+		ServiceType:      input.ServiceType,
+		FilingData:       input.FilingData,
+		IsUrgent:         input.IsUrgent,
+		CompanyRegNumber: input.CompanyRegNumber,
+	}
+	if err := workflow.ExecuteActivity(ctx, ExtractDocumentDataActivity, filingInput).Get(ctx, &data); err != nil {
+		logger.Warn("Failed to extract document data", "error", err)
+		result.warn(FilingCheckPayload, "", "We couldn't read the documents you sent, so their details were not checked")
+	}
+	blocking, warnings := ValidateFilingPayload(input.CompanyRegNumber, data)
+	result.Blocking = append(result.Blocking, blocking...)
+	result.Warnings = append(result.Warnings, warnings...)
+
+	requirements := RequiredDocumentsFor(input.ServiceType)
+	if input.ServiceType == "director_amendment" {
+		requirements = checkDirectorAmendment(ctx, input, data, result)
+	}
+
+	// Step 2: Check the supporting documents we already have
+	if len(requirements) > 0 {
+		var documents []CollectedDocument
+		err := workflow.ExecuteActivity(ctx, LoadFilingDocumentsActivity, input.CompanyID, filingDocumentIDs(data)).Get(ctx, &documents)
+		if err != nil {
+			logger.Warn("Failed to load documents", "error", err)
+			result.warn(FilingCheckDocuments, "", "We couldn't check your supporting documents right now")
+		} else {
+			result.MissingDocuments = MissingFilingDocuments(requirements, documents)
+			for _, r := range result.MissingDocuments {
+				result.block(FilingCheckDocuments, r.DocumentType, "We still need your "+r.String())
+			}
+		}
+	}
+
+	// Step 3: Run the compliance check
+	if _, err := CompanyTypeFromRegNumber(input.CompanyRegNumber); err == nil {
+		cwo := workflow.ChildWorkflowOptions{
+			WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID + "-compliance",
+		}
+		err := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), ComplianceCheckWorkflow, input.CompanyRegNumber).Get(ctx, &result.Compliance)
+		if err != nil {
+			logger.Warn("Compliance check failed", "error", err)
+			result.warn(FilingCheckCompliance, "", "We couldn't complete the compliance check right now")
+		}
+	}
+
+	// Step 4: Calculate the fee
+	var quote *FilingFeeQuote
+	if err := workflow.ExecuteActivity(ctx, QuoteFilingFeeActivity, input.UserID, input.ServiceType, input.IsUrgent).Get(ctx, &quote); err != nil {
+		logger.Warn("Failed to quote fee", "error", err)
+		result.warn(FilingCheckFee, "", "We couldn't work out the fee right now")
+	} else if quote == nil {
+		result.block(FilingCheckFee, "service_type", fmt.Sprintf("We don't offer %s filings", strings.ReplaceAll(input.ServiceType, "_", " ")))
+	} else {
+		result.Fee = quote
+	}
+	if input.IsUrgent {
+		result.UrgentSLAHours = int(UrgentFilingSLA(input.ServiceType).Hours())
+	}
+
+	result.CanFile = len(result.Blocking) == 0
+	return result, nil
+}
+
+// checkDirectorAmendment validates a CoR39 against the company's current board and returns the
+// documents it needs.
+func checkDirectorAmendment(ctx workflow.Context, input FilingDryRunInput, data map[string]interface{}, result *FilingDryRunResult) []DocumentRequirement {
+	var amendment DirectorAmendment
+	raw, err := json.Marshal(data["amendment"])
+	if err == nil {
+		err = json.Unmarshal(raw, &amendment)
+	}
+	if err != nil || data["amendment"] == nil {
+		result.block(FilingCheckPayload, "amendment", "Tell us which directors are being appointed, resigning or changing details")
+		return nil
+	}
+
+	companyType, err := CompanyTypeFromRegNumber(input.CompanyRegNumber)
+	if err != nil {
+		return DirectorAmendmentDocumentRequirements(amendment)
+	}
+	var directors []Director
+	if input.CompanyID != "" {
+		if err := workflow.ExecuteActivity(ctx, LoadCompanyDirectorsActivity, input.CompanyID).Get(ctx, &directors); err != nil {
+			workflow.GetLogger(ctx).Warn("Failed to load directors", "error", err)
+			result.warn(FilingCheckPayload, "amendment", "We couldn't compare the change with the current board right now")
+			return DirectorAmendmentDocumentRequirements(amendment)
+		}
+	}
+	if len(directors) == 0 {
+		// Resignations and the minimum board size can't be checked against a board we don't hold.
+		result.warn(FilingCheckPayload, "amendment", "We don't have the current board on record, so the change was only partly checked")
+		return DirectorAmendmentDocumentRequirements(amendment)
+	}
+	for _, problem := range ValidateDirectorAmendment(companyType, directors, amendment) {
+		result.block(FilingCheckPayload, "amendment", problem)
+	}
+	return DirectorAmendmentDocumentRequirements(amendment)
+}
+
+// LoadFilingDocumentsActivity returns the documents a filing would be submitted with: the ones
+// listed in its document_ids, or else everything stored for the company.
+func LoadFilingDocumentsActivity(ctx context.Context, companyID string, documentIDs []string) ([]CollectedDocument, error) {
+	if companyID == "" && len(documentIDs) == 0 {
+		return nil, nil
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	query := `SELECT id, document_type, file_type, file_name FROM documents WHERE company_id = $1 ORDER BY created_at DESC`
+	arg := interface{}(companyID)
+	if len(documentIDs) > 0 {
+		query = `SELECT id, document_type, file_type, file_name FROM documents WHERE id = ANY($1)`
+		arg = documentIDs
+	}
+	rows, err := db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []CollectedDocument
+	for rows.Next() {
+		var doc CollectedDocument
+		if err := rows.Scan(&doc.DocumentID, &doc.DocumentType, &doc.MIMEType, &doc.FileName); err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
+
+// filingServiceTypes are the services customers can pay for.
+var filingServiceTypes = []string{
+	"annual_return", "beneficial_ownership", "director_amendment", "afs_submission", "bbee_certificate", "company_update",
+}
+
+// checkServiceAliases are the words customers use for services in a CHECK message, besides the
+// service types themselves.
+var checkServiceAliases = map[string]string{
+	"annual return":               "annual_return",
+	"ar":                          "annual_return",
+	"beneficial ownership":        "beneficial_ownership",
+	"bo":                          "beneficial_ownership",
+	"director":                    "director_amendment",
+	"directors":                   "director_amendment",
+	"director change":             "director_amendment",
+	"afs":                         "afs_submission",
+	"financial statements":        "afs_submission",
+	"annual financial statements": "afs_submission",
+	"bbbee":                       "bbee_certificate",
+	"b-bbee":                      "bbee_certificate",
+	"bee":                         "bbee_certificate",
+	"company update":              "company_update",
+}
+
+// CheckCommandHelp explains the CHECK command.
+const CheckCommandHelp = "Send CHECK followed by the filing, e.g. 'CHECK annual return' or 'CHECK BO 2020/123456/07', and we'll tell you whether it will go through before you pay."
+
+// ParseCheckCommand reads the service and optional registration number from the text after
+// CHECK. The service is "" when none is recognised.
+func ParseCheckCommand(text string) (serviceType, regNumber string) {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if regNumberPattern.MatchString(word) {
+			regNumber = word
+			continue
+		}
+		words = append(words, strings.ToLower(strings.Trim(word, ".,!?")))
+	}
+	service := strings.Join(words, " ")
+	if alias, ok := checkServiceAliases[service]; ok {
+		return alias, regNumber
+	}
+	if containsString(filingServiceTypes, service) {
+		return service, regNumber
+	}
+	return "", regNumber
+}
@@ -0,0 +1,98 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// filingCheckTimeout bounds how long a caller waits for a dry run.
+const filingCheckTimeout = 30 * time.Second
+
+func (s *APIServer) registerFilingDryRunRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /filings/check", requireInternalAPIKey(s.filingCheckHandler))
+}
+
+// filingCheckHandler dry-runs a filing and returns the FilingDryRunResult. Nothing is filed or
+// charged.
+func (s *APIServer) filingCheckHandler(w http.ResponseWriter, r *http.Request) {
+	var input FilingDryRunInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.ServiceType == "" {
+		writeError(w, http.StatusBadRequest, "service_type is required")
+		return
+	}
+
+	result, err := s.runFilingDryRun(r.Context(), input)
+	if err != nil {
+		log.Printf("Error checking %s filing for %s: %s", input.ServiceType, input.CompanyRegNumber, err)
+		writeError(w, http.StatusInternalServerError, "Unable to check filing")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// filingCheckReply answers a WhatsApp CHECK message. The registration number defaults to the
+// sender's company.
+func (s *APIServer) filingCheckReply(ctx context.Context, phone, text string) (string, error) {
+	serviceType, regNumber := ParseCheckCommand(text)
+	if serviceType == "" {
+		return CheckCommandHelp, nil
+	}
+
+	var userID string
+	var companyRegNumber sql.NullString
+	err := s.DB.QueryRowContext(ctx, `SELECT id, company_reg_number FROM users WHERE phone_number = $1`, phone).
+		Scan(&userID, &companyRegNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "We don't have an account for this number yet. Send 'START' to register.", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up user: %w", err)
+	}
+	if regNumber == "" {
+		regNumber = companyRegNumber.String
+	}
+
+	result, err := s.runFilingDryRun(ctx, FilingDryRunInput{UserID: userID, ServiceType: serviceType, CompanyRegNumber: regNumber})
+	if err != nil {
+		return "", err
+	}
+	return result.Summary(), nil
+}
+
+// runFilingDryRun runs FilingDryRunWorkflow and waits for its result, filling in the company ID
+// from the registration number when it is not given.
+func (s *APIServer) runFilingDryRun(ctx context.Context, input FilingDryRunInput) (*FilingDryRunResult, error) {
+	if input.CompanyID == "" && input.CompanyRegNumber != "" {
+		err := s.DB.QueryRowContext(ctx, `SELECT id FROM companies WHERE registration_number = $1`, input.CompanyRegNumber).
+			Scan(&input.CompanyID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to look up company: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, filingCheckTimeout)
+	defer cancel()
+	run, err := s.Temporal.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:                       fmt.Sprintf("filing-check-%d", time.Now().UnixNano()),
+		TaskQueue:                TaskQueue,
+		WorkflowExecutionTimeout: filingCheckTimeout,
+	}, FilingDryRunWorkflow, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start dry run: %w", err)
+	}
+	var result FilingDryRunResult
+	if err := run.Get(ctx, &result); err != nil {
+		return nil, fmt.Errorf("dry run failed: %w", err)
+	}
+	return &result, nil
+}
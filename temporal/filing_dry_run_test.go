@@ -0,0 +1,164 @@
+package temporal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestValidateFilingPayload(t *testing.T) {
+	blocking, warnings := ValidateFilingPayload("2020/123456/07", map[string]interface{}{
+		"company_name":          "Acme (Pty) Ltd",
+		FieldRegistrationNumber: "2020/123456/07",
+		FieldCompanyStatus:      "In Business",
+	})
+	assert.Empty(t, blocking)
+	assert.Empty(t, warnings)
+
+	blocking, _ = ValidateFilingPayload("", map[string]interface{}{"company_name": "Acme"})
+	assert.Len(t, blocking, 1)
+	assert.Equal(t, "company_reg_number", blocking[0].Field)
+
+	blocking, _ = ValidateFilingPayload("2020/123456/07", map[string]interface{}{
+		"company_name":          "Acme",
+		FieldRegistrationNumber: "2019/654321/07",
+		FieldCompanyStatus:      "Final Deregistration",
+	})
+	assert.Len(t, blocking, 2, "documents for another company and a deregistered company both block")
+
+	blocking, warnings = ValidateFilingPayload("2020/123456/07", map[string]interface{}{
+		FieldCompanyStatus:      "AR Deregistration Process",
+		"extraction_confidence": map[string]interface{}{"company_name": 0.95, "financial_year_end": 0.4},
+	})
+	assert.Empty(t, blocking)
+	if assert.Len(t, warnings, 3) {
+		assert.Equal(t, FieldCompanyStatus, warnings[0].Field)
+		assert.Equal(t, "company_name", warnings[1].Field, "a missing name is a warning")
+		assert.Equal(t, "financial_year_end", warnings[2].Field)
+	}
+}
+
+func TestMissingFilingDocuments(t *testing.T) {
+	requirements := RequiredDocumentsFor("afs_submission")
+	missing := MissingFilingDocuments(requirements, []CollectedDocument{
+		{DocumentID: "d1", DocumentType: DocumentTypeFinancialStatements, MIMEType: "image/jpeg"},
+	})
+	assert.Len(t, missing, 1, "a photo of the statements is not accepted")
+
+	missing = MissingFilingDocuments(requirements, []CollectedDocument{
+		{DocumentID: "d2", DocumentType: DocumentTypeFinancialStatements, MIMEType: "application/pdf"},
+	})
+	assert.Empty(t, missing)
+}
+
+func TestParseCheckCommand(t *testing.T) {
+	tests := []struct {
+		text      string
+		service   string
+		regNumber string
+	}{
+		{"annual return", "annual_return", ""},
+		{"BO 2020/123456/07", "beneficial_ownership", "2020/123456/07"},
+		{"2020/123456/07 afs", "afs_submission", "2020/123456/07"},
+		{"director_amendment", "director_amendment", ""},
+		{"my company please", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		service, regNumber := ParseCheckCommand(tt.text)
+		assert.Equal(t, tt.service, service, tt.text)
+		assert.Equal(t, tt.regNumber, regNumber, tt.text)
+	}
+}
+
+func TestFilingDryRunResult_Summary(t *testing.T) {
+	result := FilingDryRunResult{ServiceType: "annual_return", CompanyRegNumber: "2020/123456/07", CanFile: true,
+		Fee: &FilingFeeQuote{Amount: 199}}
+	summary := result.Summary()
+	assert.Contains(t, summary, "ready to file")
+	assert.Contains(t, summary, "R199.00")
+	assert.Contains(t, summary, "Nothing has been filed or charged")
+
+	result = FilingDryRunResult{ServiceType: "afs_submission", Blocking: []FilingCheckIssue{{Message: "We still need your statements"}}}
+	summary = result.Summary()
+	assert.Contains(t, summary, "can't be filed yet")
+	assert.Contains(t, summary, "We still need your statements")
+}
+
+// FilingDryRunTestSuite tests FilingDryRunWorkflow.
+type FilingDryRunTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+// TestFilingDryRunTestSuite runs the test suite.
+func TestFilingDryRunTestSuite(t *testing.T) {
+	suite.Run(t, new(FilingDryRunTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *FilingDryRunTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(ComplianceCheckWorkflow)
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *FilingDryRunTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// Test_ReadyToFile tests a filing that passes every check, without anything being submitted.
+func (s *FilingDryRunTestSuite) Test_ReadyToFile() {
+	s.env.OnActivity(ExtractDocumentDataActivity, mock.Anything, mock.Anything).Return(
+		map[string]interface{}{"company_name": "Acme (Pty) Ltd"}, nil)
+	s.env.OnActivity(LoadFilingDocumentsActivity, mock.Anything, "company-1", mock.Anything).Return(
+		[]CollectedDocument{{DocumentID: "d1", DocumentType: DocumentTypeFinancialStatements, MIMEType: "application/pdf"}}, nil)
+	s.env.OnWorkflow(ComplianceCheckWorkflow, mock.Anything, "2020/123456/07").Return("Compliance check passed", nil)
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", "afs_submission", true).Return(
+		&FilingFeeQuote{ServiceType: "afs_submission", BasePrice: 300, UrgencyFee: 150, Amount: 450}, nil)
+
+	s.env.ExecuteWorkflow(FilingDryRunWorkflow, FilingDryRunInput{
+		UserID: "user-1", ServiceType: "afs_submission", CompanyRegNumber: "2020/123456/07", CompanyID: "company-1", IsUrgent: true,
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result FilingDryRunResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.CanFile)
+	s.Empty(result.Blocking)
+	s.Equal("Compliance check passed", result.Compliance)
+	s.Equal(450.0, result.Fee.Amount)
+	s.Equal(72, result.UrgentSLAHours)
+}
+
+// Test_ReportsBlockingIssuesAndWarnings tests that missing documents and an unpriced service
+// block, while a failed compliance check only warns.
+func (s *FilingDryRunTestSuite) Test_ReportsBlockingIssuesAndWarnings() {
+	s.env.OnActivity(ExtractDocumentDataActivity, mock.Anything, mock.Anything).Return(
+		map[string]interface{}{"company_name": "Acme (Pty) Ltd"}, nil)
+	s.env.OnActivity(LoadFilingDocumentsActivity, mock.Anything, "", mock.Anything).Return([]CollectedDocument(nil), nil)
+	s.env.OnWorkflow(ComplianceCheckWorkflow, mock.Anything, "2020/123456/07").Return("", errors.New("CIPC unavailable"))
+	s.env.OnActivity(QuoteFilingFeeActivity, mock.Anything, "user-1", "company_update", false).Return((*FilingFeeQuote)(nil), nil)
+
+	s.env.ExecuteWorkflow(FilingDryRunWorkflow, FilingDryRunInput{
+		UserID: "user-1", ServiceType: "company_update", CompanyRegNumber: "2020/123456/07",
+	})
+
+	s.NoError(s.env.GetWorkflowError())
+	var result FilingDryRunResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.False(result.CanFile)
+	s.Require().Len(result.Blocking, 2)
+	s.Equal(FilingCheckDocuments, result.Blocking[0].Check)
+	s.Equal(FilingCheckFee, result.Blocking[1].Check)
+	s.Require().Len(result.Warnings, 1)
+	s.Equal(FilingCheckCompliance, result.Warnings[0].Check)
+	s.Len(result.MissingDocuments, 1)
+}
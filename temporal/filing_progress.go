@@ -0,0 +1,265 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// FilingProgressQueryName is the query CombinedFilingWorkflow answers with its FilingProgress.
+const FilingProgressQueryName = "filing-progress"
+
+// The steps of CombinedFilingWorkflow, in order.
+const (
+	FilingStepValidatePayment = iota + 1
+	FilingStepExtractDocuments
+	FilingStepRequestOTP
+	FilingStepWaitForOTP
+	FilingStepSubmitToCIPC
+	FilingStepUpdateRecords
+	FilingStepSendConfirmation
+)
+
+// FilingTotalSteps is the number of steps in CombinedFilingWorkflow.
+const FilingTotalSteps = FilingStepSendConfirmation
+
+var filingStepNames = map[int]string{
+	FilingStepValidatePayment:  "validating_payment",
+	FilingStepExtractDocuments: "extracting_documents",
+	FilingStepRequestOTP:       "requesting_otp",
+	FilingStepWaitForOTP:       "waiting_for_otp",
+	FilingStepSubmitToCIPC:     "submitting_to_cipc",
+	FilingStepUpdateRecords:    "updating_records",
+	FilingStepSendConfirmation: "sending_confirmation",
+}
+
+// What a filing can be waiting on outside the workflow.
+const (
+	FilingWaitingOnPayment = "payment"
+	FilingWaitingOnOTP     = "otp"
+	FilingWaitingOnCIPC    = "cipc"
+	// FilingWaitingOnCompensation means the customer is choosing a refund, credit or retry.
+	FilingWaitingOnCompensation = "compensation_choice"
+)
+
+// Overall state of a filing.
+const (
+	FilingStatusRunning   = "running"
+	FilingStatusCompleted = "completed"
+	FilingStatusFailed    = "failed"
+)
+
+// FilingStepTiming records when a step started and finished.
+type FilingStepTiming struct {
+	Step        int        `json:"step"`
+	Name        string     `json:"name"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// FilingProgress is the answer to FilingProgressQueryName.
+type FilingProgress struct {
+	WorkflowID      string             `json:"workflow_id"`
+	TransactionID   string             `json:"transaction_id"`
+	ServiceType     string             `json:"service_type"`
+	Status          string             `json:"status"`
+	Step            int                `json:"step"`
+	TotalSteps      int                `json:"total_steps"`
+	StepName        string             `json:"step_name"`
+	WaitingOn       string             `json:"waiting_on,omitempty"`
+	OTPExpiresAt    *time.Time         `json:"otp_expires_at,omitempty"`
+	StartedAt       time.Time          `json:"started_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	CompletedAt     *time.Time         `json:"completed_at,omitempty"`
+	Steps           []FilingStepTiming `json:"steps"`
+	LastError       string             `json:"last_error,omitempty"`
+	FilingReference string             `json:"filing_reference,omitempty"`
+	// Compensation is the refund, credit or retry chosen after the filing failed.
+	Compensation string `json:"compensation,omitempty"`
+}
+
+// Summary describes the filing's progress in a WhatsApp message.
+func (p FilingProgress) Summary() string {
+	service := strings.ReplaceAll(p.ServiceType, "_", " ")
+	var b strings.Builder
+	switch p.Status {
+	case FilingStatusCompleted:
+		fmt.Fprintf(&b, "✅ Your %s filing is complete.", service)
+		if p.FilingReference != "" {
+			fmt.Fprintf(&b, " Reference: %s", p.FilingReference)
+		}
+	case FilingStatusFailed:
+		fmt.Fprintf(&b, "❌ Your %s filing stopped at step %d of %d (%s).", service, p.Step, p.TotalSteps, strings.ReplaceAll(p.StepName, "_", " "))
+		switch p.Compensation {
+		case CompensationRefund:
+			b.WriteString(" Your refund is on its way.")
+		case CompensationCredit:
+			b.WriteString(" The amount was added to your account credit.")
+		}
+	default:
+		fmt.Fprintf(&b, "⏳ Your %s filing is at step %d of %d: %s.", service, p.Step, p.TotalSteps, strings.ReplaceAll(p.StepName, "_", " "))
+		switch p.WaitingOn {
+		case FilingWaitingOnPayment:
+			b.WriteString(" We're confirming your payment.")
+		case FilingWaitingOnOTP:
+			b.WriteString(" We're waiting for the OTP CIPC sent you by SMS")
+			if p.OTPExpiresAt != nil {
+				fmt.Fprintf(&b, " (it expires at %s)", p.OTPExpiresAt.Format("15:04"))
+			}
+			b.WriteString(".")
+		case FilingWaitingOnCIPC:
+			b.WriteString(" We're waiting for CIPC to respond.")
+		case FilingWaitingOnCompensation:
+			b.WriteString(" We couldn't complete it and are waiting for you to reply REFUND, CREDIT or RETRY.")
+		}
+	}
+	if p.LastError != "" && p.Status != FilingStatusCompleted {
+		fmt.Fprintf(&b, "\nLast issue: %s", p.LastError)
+	}
+	return b.String()
+}
+
+// filingProgress keeps CombinedFilingWorkflow's FilingProgress up to date and serves it to
+// FilingProgressQueryName.
+type filingProgress struct {
+	state FilingProgress
+	// otp, once set, supplies the OTP expiry while the workflow waits for a code.
+	otp *otpSession
+	// sla is the SLA clock of an urgent filing; it stops while the customer has to answer.
+	sla *filingSLA
+}
+
+func newFilingProgress(ctx workflow.Context, params FilingWorkflowInput) *filingProgress {
+	now := workflow.Now(ctx)
+	p := &filingProgress{state: FilingProgress{
+		WorkflowID:    workflow.GetInfo(ctx).WorkflowExecution.ID,
+		TransactionID: params.TransactionID,
+		ServiceType:   params.ServiceType,
+		Status:        FilingStatusRunning,
+		TotalSteps:    FilingTotalSteps,
+		StartedAt:     now,
+		UpdatedAt:     now,
+	}}
+	if err := workflow.SetQueryHandler(ctx, FilingProgressQueryName, p.query); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to register filing progress query", "error", err)
+	}
+	return p
+}
+
+func (p *filingProgress) query() (FilingProgress, error) {
+	progress := p.state
+	progress.Steps = append([]FilingStepTiming(nil), p.state.Steps...)
+	if progress.WaitingOn == FilingWaitingOnOTP && p.otp != nil && p.otp.waiting {
+		expiresAt := p.otp.expiresAt
+		progress.OTPExpiresAt = &expiresAt
+	}
+	return progress, nil
+}
+
+// begin moves the filing to step, finishing the step before it. Steps can be entered again, e.g.
+// back to waiting for an OTP after CIPC rejects one.
+func (p *filingProgress) begin(ctx workflow.Context, step int, waitingOn string) {
+	now := workflow.Now(ctx)
+	p.finishStep(now)
+	p.state.Step = step
+	p.state.StepName = filingStepNames[step]
+	p.state.WaitingOn = waitingOn
+	p.state.UpdatedAt = now
+	p.state.Steps = append(p.state.Steps, FilingStepTiming{Step: step, Name: p.state.StepName, StartedAt: now})
+	p.sla.step(p.state.StepName)
+	p.sla.customerWaiting(ctx, waitingOn == FilingWaitingOnOTP)
+}
+
+// recordError notes a problem the workflow recovered from.
+func (p *filingProgress) recordError(ctx workflow.Context, message string) {
+	p.state.LastError = message
+	p.state.UpdatedAt = workflow.Now(ctx)
+}
+
+// compensating notes that the filing failed and the customer is being asked how to be compensated.
+func (p *filingProgress) compensating(ctx workflow.Context, failure error) {
+	p.recordError(ctx, failure.Error())
+	p.state.WaitingOn = FilingWaitingOnCompensation
+	p.sla.customerWaiting(ctx, true)
+}
+
+func (p *filingProgress) compensated(choice string) {
+	p.state.Compensation = choice
+}
+
+// fail marks the filing as failed at the current step and returns err unchanged.
+func (p *filingProgress) fail(ctx workflow.Context, err error) error {
+	p.end(ctx, FilingStatusFailed)
+	p.state.LastError = err.Error()
+	return err
+}
+
+func (p *filingProgress) complete(ctx workflow.Context, filingReference string) {
+	p.end(ctx, FilingStatusCompleted)
+	p.state.FilingReference = filingReference
+}
+
+func (p *filingProgress) end(ctx workflow.Context, status string) {
+	now := workflow.Now(ctx)
+	p.finishStep(now)
+	p.state.Status = status
+	p.state.WaitingOn = ""
+	p.state.UpdatedAt = now
+	p.state.CompletedAt = &now
+}
+
+func (p *filingProgress) finishStep(now time.Time) {
+	if n := len(p.state.Steps); n > 0 && p.state.Steps[n-1].CompletedAt == nil {
+		p.state.Steps[n-1].CompletedAt = &now
+	}
+}
+
+// RecordFilingWorkflowActivity links a transaction to the filing workflow processing it, so the
+// filing can be found from the customer's phone number.
+func RecordFilingWorkflowActivity(ctx context.Context, transactionID string) error {
+	execution := activity.GetInfo(ctx).WorkflowExecution
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `UPDATE payg_transactions SET workflow_id = $1 WHERE id = $2`, execution.ID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record filing workflow: %w", err)
+	}
+	return nil
+}
+
+// ListRecentFilingWorkflows returns the workflow IDs of a phone number's most recent filings,
+// newest first.
+func ListRecentFilingWorkflows(ctx context.Context, db *sql.DB, phoneNumber string, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.workflow_id
+		FROM payg_transactions t
+		JOIN users u ON u.id = t.user_id
+		WHERE u.phone_number = $1 AND t.workflow_id IS NOT NULL
+		ORDER BY t.created_at DESC
+		LIMIT $2
+	`, phoneNumber, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filings: %w", err)
+	}
+	defer rows.Close()
+
+	var workflowIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		workflowIDs = append(workflowIDs, id)
+	}
+	return workflowIDs, rows.Err()
+}
@@ -0,0 +1,473 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// FilingTaskQueue is the task queue a filing runs on. Its activities run there too, apart from
+// portal activities, which run on CIPCPortalTaskQueue with an urgent priority.
+func FilingTaskQueue(urgent bool) string {
+	if urgent {
+		return UrgentTaskQueue
+	}
+	return TaskQueue
+}
+
+// DefaultUrgentFilingSLA is the SLA of urgent services without one of their own.
+const DefaultUrgentFilingSLA = 48 * time.Hour
+
+// urgentFilingSLAs is how long an urgent filing of each service may take, not counting time spent
+// waiting on the customer.
+var urgentFilingSLAs = map[string]time.Duration{
+	"annual_return":        24 * time.Hour,
+	"beneficial_ownership": 24 * time.Hour,
+	"director_amendment":   48 * time.Hour,
+	"company_update":       48 * time.Hour,
+	"afs_submission":       72 * time.Hour,
+}
+
+// UrgentFilingSLA returns the SLA of an urgent filing of serviceType.
+func UrgentFilingSLA(serviceType string) time.Duration {
+	if sla, ok := urgentFilingSLAs[serviceType]; ok {
+		return sla
+	}
+	return DefaultUrgentFilingSLA
+}
+
+// urgentSLAEscalations are the shares of the SLA, in percent, at which ops are alerted.
+var urgentSLAEscalations = []int{50, 90}
+
+// Outcomes of an urgent filing's SLA, stored in filing_slas.outcome.
+const (
+	FilingSLAMet      = "met"
+	FilingSLABreached = "breached"
+	// FilingSLAFailed is a filing that ended without being filed and before its SLA ran out.
+	FilingSLAFailed = "failed"
+)
+
+// UrgentFilingEscalation is the input of EscalateUrgentFilingActivity.
+type UrgentFilingEscalation struct {
+	TransactionID    string        `json:"transaction_id"`
+	UserID           string        `json:"user_id"`
+	ServiceType      string        `json:"service_type"`
+	CompanyRegNumber string        `json:"company_reg_number,omitempty"`
+	SLA              time.Duration `json:"sla"`
+	// Percent is the share of the SLA used; 100 or more means it was breached.
+	Percent  int    `json:"percent"`
+	StepName string `json:"step_name"`
+	// TaskID is the filing's escalation task, if one was already opened.
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// message describes the escalation for ops.
+func (e UrgentFilingEscalation) message() string {
+	service := strings.ReplaceAll(e.ServiceType, "_", " ")
+	hours := int(e.SLA.Hours())
+	if e.Percent >= 100 {
+		return fmt.Sprintf("Urgent %s breached its %dh SLA at %s; the urgency fee was credited to the customer",
+			service, hours, strings.ReplaceAll(e.StepName, "_", " "))
+	}
+	return fmt.Sprintf("Urgent %s has used %d%% of its %dh SLA and is at %s",
+		service, e.Percent, hours, strings.ReplaceAll(e.StepName, "_", " "))
+}
+
+// FilingSLARecord is an urgent filing's SLA outcome, stored by RecordFilingSLAActivity.
+type FilingSLARecord struct {
+	TransactionID string        `json:"transaction_id"`
+	UserID        string        `json:"user_id"`
+	ServiceType   string        `json:"service_type"`
+	SLA           time.Duration `json:"sla"`
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	// Elapsed is the time counted against the SLA; CustomerWait is the time that was not.
+	Elapsed          time.Duration `json:"elapsed"`
+	CustomerWait     time.Duration `json:"customer_wait"`
+	Outcome          string        `json:"outcome"`
+	EscalationTaskID string        `json:"escalation_task_id,omitempty"`
+}
+
+// filingSLA is the SLA clock of an urgent CombinedFilingWorkflow run. The clock stops while the
+// customer owes us something (an OTP or a compensation choice), escalates to ops as it runs down,
+// and credits the urgency fee if it runs out. A retry the customer asks for starts a new clock.
+type filingSLA struct {
+	params    FilingWorkflowInput
+	sla       time.Duration
+	startedAt time.Time
+	// used is the SLA time used up to resumedAt, or up to pausedAt while paused.
+	used         time.Duration
+	resumedAt    time.Time
+	paused       bool
+	pausedAt     time.Time
+	customerWait time.Duration
+	// changes counts pauses, resumes and the end of the filing, waking the clock.
+	changes  int
+	finished bool
+	// acting is set while the clock runs an activity; the filing waits for it before recording.
+	acting   bool
+	breached bool
+	stepName string
+	taskID   string
+	options  workflow.ActivityOptions
+}
+
+// startFilingSLA starts the SLA clock of an urgent filing.
+func startFilingSLA(ctx workflow.Context, params FilingWorkflowInput) *filingSLA {
+	now := workflow.Now(ctx)
+	s := &filingSLA{
+		params:    params,
+		sla:       UrgentFilingSLA(params.ServiceType),
+		startedAt: now,
+		resumedAt: now,
+		options:   workflow.GetActivityOptions(ctx),
+	}
+	workflow.Go(ctx, s.run)
+	return s
+}
+
+// usedAt returns the SLA time used by now.
+func (s *filingSLA) usedAt(now time.Time) time.Duration {
+	if s.paused {
+		return s.used
+	}
+	return s.used + now.Sub(s.resumedAt)
+}
+
+// customerWaiting stops the clock while the filing waits on the customer and starts it again
+// once it does not. It is safe to call on a nil clock.
+func (s *filingSLA) customerWaiting(ctx workflow.Context, waiting bool) {
+	if s == nil || s.finished || s.paused == waiting {
+		return
+	}
+	now := workflow.Now(ctx)
+	if waiting {
+		s.used += now.Sub(s.resumedAt)
+		s.pausedAt = now
+	} else {
+		s.customerWait += now.Sub(s.pausedAt)
+		s.resumedAt = now
+	}
+	s.paused = waiting
+	s.changes++
+}
+
+// step notes the filing's current step for escalations. It is safe to call on a nil clock.
+func (s *filingSLA) step(name string) {
+	if s != nil {
+		s.stepName = name
+	}
+}
+
+func (s *filingSLA) run(ctx workflow.Context) {
+	ctx = workflow.WithActivityOptions(ctx, s.options)
+	for _, percent := range append(urgentSLAEscalations, 100) {
+		due := s.sla * time.Duration(percent) / 100
+		for {
+			if s.finished {
+				return
+			}
+			changes := s.changes
+			changed := func() bool { return s.changes != changes }
+			if s.paused {
+				if err := workflow.Await(ctx, changed); err != nil {
+					return
+				}
+				continue
+			}
+			remaining := due - s.usedAt(workflow.Now(ctx))
+			if remaining <= 0 {
+				break
+			}
+			if _, err := workflow.AwaitWithTimeout(ctx, remaining, changed); err != nil {
+				return
+			}
+		}
+
+		s.acting = true
+		if percent >= 100 {
+			s.breach(ctx)
+		}
+		s.escalate(ctx, percent)
+		s.acting = false
+	}
+}
+
+// escalate opens the filing's escalation task, or adds to it.
+func (s *filingSLA) escalate(ctx workflow.Context, percent int) {
+	escalation := UrgentFilingEscalation{
+		TransactionID:    s.params.TransactionID,
+		UserID:           s.params.UserID,
+		ServiceType:      s.params.ServiceType,
+		CompanyRegNumber: s.params.CompanyRegNumber,
+		SLA:              s.sla,
+		Percent:          percent,
+		StepName:         s.stepName,
+		TaskID:           s.taskID,
+	}
+	var taskID string
+	if err := workflow.ExecuteActivity(ctx, EscalateUrgentFilingActivity, escalation).Get(ctx, &taskID); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to escalate urgent filing", "percent", percent, "error", err)
+		return
+	}
+	s.taskID = taskID
+}
+
+// breach credits the urgency fee and tells the customer.
+func (s *filingSLA) breach(ctx workflow.Context) {
+	s.breached = true
+	var credited float64
+	if err := workflow.ExecuteActivity(ctx, CreditUrgencyFeeActivity, s.params.TransactionID, s.params.UserID).Get(ctx, &credited); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to credit urgency fee", "error", err)
+		return
+	}
+	if credited > 0 {
+		notifyUser(ctx, s.params.UserID, fmt.Sprintf(
+			"⏱️ Your urgent %s is taking longer than the %d hours we promised. We've added the R%.2f urgency fee to your account as credit, and we're still working on your filing.",
+			serviceDisplayName(s.params.ServiceType), int(s.sla.Hours()), credited))
+	}
+}
+
+// finish stops the clock and records the outcome. It is safe to call on a nil clock.
+func (s *filingSLA) finish(ctx workflow.Context, status string) {
+	if s == nil {
+		return
+	}
+	// Record even if the workflow was cancelled.
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	ctx = workflow.WithActivityOptions(ctx, s.options)
+	if err := workflow.Await(ctx, func() bool { return !s.acting }); err != nil {
+		return
+	}
+	now := workflow.Now(ctx)
+	s.customerWaiting(ctx, false)
+	s.finished = true
+	s.changes++
+
+	outcome := FilingSLAFailed
+	switch {
+	case s.breached:
+		outcome = FilingSLABreached
+	case status == FilingStatusCompleted:
+		outcome = FilingSLAMet
+	}
+	record := FilingSLARecord{
+		TransactionID:    s.params.TransactionID,
+		UserID:           s.params.UserID,
+		ServiceType:      s.params.ServiceType,
+		SLA:              s.sla,
+		StartedAt:        s.startedAt,
+		FinishedAt:       now,
+		Elapsed:          s.usedAt(now),
+		CustomerWait:     s.customerWait,
+		Outcome:          outcome,
+		EscalationTaskID: s.taskID,
+	}
+	if err := workflow.ExecuteActivity(ctx, RecordFilingSLAActivity, record).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to record filing SLA", "error", err)
+	}
+}
+
+// EscalateUrgentFilingActivity alerts ops to an urgent filing running down its SLA. The first
+// escalation opens an urgent_sla task; later ones add a note to it and raise its error code.
+// Returns the task ID.
+func EscalateUrgentFilingActivity(ctx context.Context, escalation UrgentFilingEscalation) (string, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	errorCode := fmt.Sprintf("SLA_%d_PERCENT", escalation.Percent)
+	if escalation.Percent >= 100 {
+		errorCode = "SLA_BREACHED"
+	}
+
+	if escalation.TaskID != "" {
+		_, err := db.ExecContext(ctx, `
+			UPDATE ops_tasks SET error_code = $2, error_message = $3 WHERE id = $1
+		`, escalation.TaskID, errorCode, escalation.message())
+		if err != nil {
+			return "", fmt.Errorf("failed to update ops task: %w", err)
+		}
+		if err := AddOpsTaskNote(ctx, db, escalation.TaskID, "sla-clock", escalation.message()); err != nil {
+			return "", err
+		}
+		return escalation.TaskID, nil
+	}
+
+	filing := AutomatedFilingInput{
+		TransactionID:    escalation.TransactionID,
+		ServiceType:      escalation.ServiceType,
+		UserID:           escalation.UserID,
+		CompanyRegNumber: escalation.CompanyRegNumber,
+		IsUrgent:         true,
+		ClientData: map[string]interface{}{
+			"sla_hours": escalation.SLA.Hours(),
+			"step":      escalation.StepName,
+		},
+	}
+	execution := activity.GetInfo(ctx).WorkflowExecution
+	return createOpsTask(ctx, db, OpsTaskUrgentSLA, filing, FilingResult{ErrorCode: errorCode, Error: escalation.message()}, execution.ID, execution.RunID)
+}
+
+// CreditUrgencyFeeActivity credits the urgency fee of a transaction to the customer's account:
+// the part of the amount charged above the service's base price. A transaction is credited at
+// most once. Returns the amount credited, zero if it was credited before.
+func CreditUrgencyFeeActivity(ctx context.Context, transactionID, userID string) (float64, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var amount, urgencyMultiplier float64
+	var urgent bool
+	var creditID sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT t.amount, COALESCE(t.urgency_fee, FALSE), COALESCE(p.urgency_multiplier, 1), t.sla_credit_id
+		FROM payg_transactions t
+		LEFT JOIN pricing_config p ON p.service_type = t.service_type
+		WHERE t.id = $1
+		FOR UPDATE OF t
+	`, transactionID).Scan(&amount, &urgent, &urgencyMultiplier, &creditID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load transaction %s: %w", transactionID, err)
+	}
+	if creditID.Valid {
+		activity.GetLogger(ctx).Info("Urgency fee already credited", "credit_id", creditID.String)
+		return 0, nil
+	}
+	if !urgent || urgencyMultiplier <= 1 {
+		return 0, errors.New("transaction was not charged an urgency fee")
+	}
+
+	fee := amount - amount/urgencyMultiplier
+	fee = float64(int64(fee*100+0.5)) / 100
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO account_credits (user_id, amount, source_transaction_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, userID, fee, transactionID).Scan(&creditID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add account credit: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE payg_transactions SET sla_credit_id = $1 WHERE id = $2`, creditID, transactionID); err != nil {
+		return 0, fmt.Errorf("failed to link urgency credit: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	activity.GetLogger(ctx).Info("Urgency fee credited", "transaction_id", transactionID, "amount", fee)
+	return fee, nil
+}
+
+// RecordFilingSLAActivity stores an urgent filing's SLA outcome and closes its escalation task,
+// which only asked ops to keep an eye on the filing.
+func RecordFilingSLAActivity(ctx context.Context, record FilingSLARecord) error {
+	execution := activity.GetInfo(ctx).WorkflowExecution
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO filing_slas
+			(transaction_id, user_id, workflow_id, run_id, service_type, sla_seconds, started_at, finished_at,
+			 elapsed_seconds, customer_wait_seconds, outcome, escalation_task_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, '')::uuid)
+		ON CONFLICT (workflow_id, run_id) DO NOTHING
+	`, record.TransactionID, record.UserID, execution.ID, execution.RunID, record.ServiceType, int64(record.SLA.Seconds()),
+		record.StartedAt, record.FinishedAt, int64(record.Elapsed.Seconds()), int64(record.CustomerWait.Seconds()),
+		record.Outcome, record.EscalationTaskID)
+	if err != nil {
+		return fmt.Errorf("failed to record filing SLA: %w", err)
+	}
+
+	if record.EscalationTaskID != "" {
+		_, err = db.ExecContext(ctx, `
+			UPDATE ops_tasks
+			SET status = 'completed', resolution_note = $2, resolved_by = 'system', resolved_at = NOW()
+			WHERE id = $1 AND status IN ('open', 'claimed')
+		`, record.EscalationTaskID, "Filing finished, SLA "+record.Outcome)
+		if err != nil {
+			return fmt.Errorf("failed to close ops task: %w", err)
+		}
+	}
+	return nil
+}
+
+// ServiceSLAAttainment is the SLA attainment of urgent filings of one service.
+type ServiceSLAAttainment struct {
+	ServiceType string `json:"service_type"`
+	SLAHours    int    `json:"sla_hours"`
+	Filings     int    `json:"filings"`
+	Met         int    `json:"met"`
+	Breached    int    `json:"breached"`
+	Failed      int    `json:"failed"`
+	// AttainmentPct is the share of filed filings that met their SLA.
+	AttainmentPct   float64 `json:"attainment_pct"`
+	AvgElapsedHours float64 `json:"avg_elapsed_hours"`
+}
+
+// FilingSLAReport is SLA attainment per service of urgent filings finished in [From, To).
+type FilingSLAReport struct {
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Services []ServiceSLAAttainment `json:"services"`
+}
+
+// GetFilingSLAReport reports SLA attainment of urgent filings finished between from and to.
+func GetFilingSLAReport(ctx context.Context, db *sql.DB, from, to time.Time) (*FilingSLAReport, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT service_type,
+		       COUNT(*) FILTER (WHERE outcome = 'met'),
+		       COUNT(*) FILTER (WHERE outcome = 'breached'),
+		       COUNT(*) FILTER (WHERE outcome = 'failed'),
+		       COALESCE(AVG(elapsed_seconds) FILTER (WHERE outcome <> 'failed'), 0)
+		FROM filing_slas
+		WHERE finished_at >= $1 AND finished_at < $2
+		GROUP BY service_type
+		ORDER BY service_type
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to report filing SLAs: %w", err)
+	}
+	defer rows.Close()
+
+	report := &FilingSLAReport{From: from, To: to, Services: []ServiceSLAAttainment{}}
+	for rows.Next() {
+		var s ServiceSLAAttainment
+		var avgElapsed float64
+		if err := rows.Scan(&s.ServiceType, &s.Met, &s.Breached, &s.Failed, &avgElapsed); err != nil {
+			return nil, err
+		}
+		s.tally(avgElapsed)
+		report.Services = append(report.Services, s)
+	}
+	return report, rows.Err()
+}
+
+// tally fills in the totals from the outcome counts.
+func (s *ServiceSLAAttainment) tally(avgElapsedSeconds float64) {
+	s.SLAHours = int(UrgentFilingSLA(s.ServiceType).Hours())
+	s.Filings = s.Met + s.Breached + s.Failed
+	if filed := s.Met + s.Breached; filed > 0 {
+		s.AttainmentPct = float64(int64(float64(s.Met)/float64(filed)*1000+0.5)) / 10
+	}
+	s.AvgElapsedHours = float64(int64(avgElapsedSeconds/3600*10+0.5)) / 10
+}
@@ -0,0 +1,49 @@
+package temporal
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultSLAReportPeriod is the period reported when no start date is given.
+const defaultSLAReportPeriod = 30 * 24 * time.Hour
+
+func (s *APIServer) registerFilingSLARoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /reports/sla", requireInternalAPIKey(s.filingSLAReportHandler))
+}
+
+// filingSLAReportHandler reports urgent filing SLA attainment per service. from and to are
+// dates (YYYY-MM-DD), to inclusive; the default is the last 30 days.
+func (s *APIServer) filingSLAReportHandler(w http.ResponseWriter, r *http.Request) {
+	to := time.Now().UTC()
+	if v := r.URL.Query().Get("to"); v != "" {
+		day, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to must be a date (YYYY-MM-DD)")
+			return
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	from := to.Add(-defaultSLAReportPeriod)
+	if v := r.URL.Query().Get("from"); v != "" {
+		day, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "from must be a date (YYYY-MM-DD)")
+			return
+		}
+		from = day
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	report, err := GetFilingSLAReport(r.Context(), s.DB, from, to)
+	if err != nil {
+		log.Printf("Error reporting filing SLAs: %s", err)
+		writeError(w, http.StatusInternalServerError, "Unable to report SLAs")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,134 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestServiceSLAAttainment_Tally(t *testing.T) {
+	s := ServiceSLAAttainment{ServiceType: "annual_return", Met: 2, Breached: 1, Failed: 1}
+	s.tally(18 * 3600)
+	assert.Equal(t, 24, s.SLAHours)
+	assert.Equal(t, 4, s.Filings)
+	assert.Equal(t, 66.7, s.AttainmentPct, "failed filings do not count towards attainment")
+	assert.Equal(t, 18.0, s.AvgElapsedHours)
+
+	s = ServiceSLAAttainment{ServiceType: "something_new", Failed: 1}
+	s.tally(0)
+	assert.Equal(t, int(DefaultUrgentFilingSLA.Hours()), s.SLAHours)
+	assert.Zero(t, s.AttainmentPct)
+}
+
+// FilingSLATestSuite tests the SLA clock of urgent CombinedFilingWorkflow runs.
+type FilingSLATestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env         *testsuite.TestWorkflowEnvironment
+	escalations []UrgentFilingEscalation
+	messages    []string
+	// followUpQueues are the task queues CIPCConfirmationWorkflow children were started on.
+	followUpQueues []string
+}
+
+// TestFilingSLATestSuite runs the test suite.
+func TestFilingSLATestSuite(t *testing.T) {
+	suite.Run(t, new(FilingSLATestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *FilingSLATestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.escalations, s.messages, s.followUpQueues = nil, nil, nil
+	s.env.RegisterWorkflow(CIPCConfirmationWorkflow)
+	s.env.OnWorkflow(CIPCConfirmationWorkflow, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, _ CIPCConfirmationInput) (*CIPCFilingOutcome, error) {
+			s.followUpQueues = append(s.followUpQueues, workflow.GetInfo(ctx).TaskQueueName)
+			return &CIPCFilingOutcome{Status: CIPCOutcomeApproved}, nil
+		}).Maybe()
+	s.env.OnActivity(RecordFilingWorkflowActivity, mock.Anything, "tx-1").Return(nil)
+	s.env.OnActivity(ValidatePaymentActivity, mock.Anything, mock.Anything).Return(true, nil)
+	s.env.OnActivity(ExtractDocumentDataActivity, mock.Anything, mock.Anything).Return(map[string]interface{}{}, nil)
+	s.env.OnActivity(RequestOTPActivity, mock.Anything, "user-1").Return(nil)
+	s.env.OnActivity(UpdateUserRecordsActivity, mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.Anything).Return("conversation-1", nil)
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, "conversation-1").Return(nil)
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, "user-1", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		})
+	s.env.OnActivity(EscalateUrgentFilingActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, e UrgentFilingEscalation) (string, error) {
+			s.escalations = append(s.escalations, e)
+			return "task-1", nil
+		}).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *FilingSLATestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *FilingSLATestSuite) params() FilingWorkflowInput {
+	return FilingWorkflowInput{TransactionID: "tx-1", UserID: "user-1", ServiceType: "annual_return", IsUrgent: true}
+}
+
+// sendOTP delivers a valid OTP after the given time, asking for a new one first if the first has
+// expired by then.
+func (s *FilingSLATestSuite) sendOTP(after time.Duration) {
+	if after > OTPValidity {
+		s.env.RegisterDelayedCallback(func() {
+			s.env.UpdateWorkflowNoRejection(ResendOTPUpdateName, "resend", s.T())
+		}, after-time.Minute)
+	}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflowNoRejection(SubmitOTPUpdateName, "otp", s.T(), OTPSignal{OTP: "123456"})
+	}, after)
+}
+
+// Test_CustomerWaitDoesNotCount tests that a filing whose only delay is the customer's OTP meets
+// its SLA without escalating.
+func (s *FilingSLATestSuite) Test_CustomerWaitDoesNotCount() {
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, mock.Anything).Return("AR1", nil).Once()
+	s.env.OnActivity(RecordFilingSLAActivity, mock.Anything, mock.MatchedBy(func(r FilingSLARecord) bool {
+		return r.Outcome == FilingSLAMet && r.SLA == 24*time.Hour && r.CustomerWait >= 20*time.Hour &&
+			r.Elapsed < time.Hour && r.EscalationTaskID == ""
+	})).Return(nil).Once()
+	s.sendOTP(20 * time.Hour)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Empty(s.escalations)
+	s.Equal([]string{TaskQueue}, s.followUpQueues, "urgent workers do not run the follow-up")
+}
+
+// Test_EscalatesAndCreditsOnBreach tests the 50% and 90% escalations and the urgency fee credit
+// when the submission itself runs past the SLA.
+func (s *FilingSLATestSuite) Test_EscalatesAndCreditsOnBreach() {
+	s.env.OnActivity(SubmitToCIPCActivity, mock.Anything, mock.Anything).After(25*time.Hour).Return("AR1", nil).Once()
+	s.env.OnActivity(CreditUrgencyFeeActivity, mock.Anything, "tx-1", "user-1").Return(66.33, nil).Once()
+	s.env.OnActivity(RecordFilingSLAActivity, mock.Anything, mock.MatchedBy(func(r FilingSLARecord) bool {
+		return r.Outcome == FilingSLABreached && r.EscalationTaskID == "task-1" && r.Elapsed > 24*time.Hour
+	})).Return(nil).Once()
+	s.sendOTP(3 * time.Minute)
+
+	s.env.ExecuteWorkflow(CombinedFilingWorkflow, s.params())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().Len(s.escalations, 3)
+	s.Equal([]int{50, 90, 100}, []int{s.escalations[0].Percent, s.escalations[1].Percent, s.escalations[2].Percent})
+	s.Equal("submitting_to_cipc", s.escalations[0].StepName)
+	s.Empty(s.escalations[0].TaskID)
+	s.Equal("task-1", s.escalations[1].TaskID, "later escalations add to the first task")
+	s.Require().NotEmpty(s.messages)
+	s.Contains(s.messages[0], "R66.33 urgency fee")
+}
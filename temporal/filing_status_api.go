@@ -0,0 +1,70 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.temporal.io/api/serviceerror"
+)
+
+// statusReplyFilings is how many recent filings a STATUS reply describes.
+const statusReplyFilings = 3
+
+func (s *APIServer) registerFilingRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /filings/{id}", requireInternalAPIKey(s.getFilingHandler))
+}
+
+func (s *APIServer) getFilingHandler(w http.ResponseWriter, r *http.Request) {
+	progress, err := s.queryFilingProgress(r.Context(), r.PathValue("id"))
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeError(w, http.StatusNotFound, "Filing not found")
+			return
+		}
+		log.Printf("Error querying filing %s: %s", r.PathValue("id"), err)
+		writeError(w, http.StatusInternalServerError, "Unable to get filing progress")
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// queryFilingProgress asks a filing workflow, running or closed, where it is.
+func (s *APIServer) queryFilingProgress(ctx context.Context, workflowID string) (*FilingProgress, error) {
+	value, err := s.Temporal.QueryWorkflow(ctx, workflowID, "", FilingProgressQueryName)
+	if err != nil {
+		return nil, err
+	}
+	var progress FilingProgress
+	if err := value.Get(&progress); err != nil {
+		return nil, fmt.Errorf("failed to decode filing progress: %w", err)
+	}
+	return &progress, nil
+}
+
+// filingStatusReply answers a STATUS message with the progress of the sender's recent filings.
+func (s *APIServer) filingStatusReply(ctx context.Context, phone string) (string, error) {
+	workflowIDs, err := ListRecentFilingWorkflows(ctx, s.DB, phone, statusReplyFilings)
+	if err != nil {
+		return "", err
+	}
+
+	var summaries []string
+	for _, id := range workflowIDs {
+		progress, err := s.queryFilingProgress(ctx, id)
+		if err != nil {
+			// Filings older than the retention period can no longer be queried.
+			log.Printf("Skipping filing %s in status reply: %s", id, err)
+			continue
+		}
+		summaries = append(summaries, progress.Summary())
+	}
+	if len(summaries) == 0 {
+		return "You don't have any filings in progress. Send 'START' to begin one.", nil
+	}
+	return strings.Join(summaries, "\n\n"), nil
+}
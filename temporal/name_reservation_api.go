@@ -0,0 +1,67 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// NameReservationResponse is returned by POST /name-reservations.
+type NameReservationResponse struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+func (s *APIServer) registerNameReservationRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /name-reservations", requireInternalAPIKey(s.createNameReservationHandler))
+	mux.HandleFunc("GET /name-reservations/{id}", requireInternalAPIKey(s.getNameReservationHandler))
+}
+
+// createNameReservationHandler starts a NameReservationWorkflow. The customer hears the name
+// check and CIPC's decision over WhatsApp; GET /name-reservations/{id} reports the same.
+func (s *APIServer) createNameReservationHandler(w http.ResponseWriter, r *http.Request) {
+	var input NameReservationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := ValidateNameReservationInput(input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := s.Temporal.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("name-reservation-%d", time.Now().UnixNano()),
+		TaskQueue: TaskQueue,
+	}, NameReservationWorkflow, input)
+	if err != nil {
+		log.Printf("Error starting name reservation for user %s: %s", input.UserID, err)
+		writeError(w, http.StatusInternalServerError, "Unable to start name reservation")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, NameReservationResponse{WorkflowID: run.GetID()})
+}
+
+func (s *APIServer) getNameReservationHandler(w http.ResponseWriter, r *http.Request) {
+	value, err := s.Temporal.QueryWorkflow(r.Context(), r.PathValue("id"), "", NameReservationStatusQueryName)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeError(w, http.StatusNotFound, "Name reservation not found")
+			return
+		}
+		log.Printf("Error querying name reservation %s: %s", r.PathValue("id"), err)
+		writeError(w, http.StatusInternalServerError, "Unable to get name reservation")
+		return
+	}
+	var state NameReservationState
+	if err := value.Get(&state); err != nil {
+		log.Printf("Error decoding name reservation %s: %s", r.PathValue("id"), err)
+		writeError(w, http.StatusInternalServerError, "Unable to get name reservation")
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
@@ -0,0 +1,428 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// NameReservationServiceType is the runner service that reserves company names.
+const NameReservationServiceType = "name_reservation"
+
+// NameReservationStatusQueryName is the query NameReservationWorkflow answers with its
+// NameReservationState.
+const NameReservationStatusQueryName = "name-reservation-status"
+
+// MaxProposedNames is how many names CIPC considers in one reservation.
+const MaxProposedNames = 4
+
+// nameReservationMonths is how long CIPC holds an approved name.
+const nameReservationMonths = 6
+
+// State of a name reservation, stored in name_reservations.status.
+const (
+	NameReservationChecking  = "checking"
+	NameReservationNoNames   = "no_available_names"
+	NameReservationSubmitted = "submitted"
+	NameReservationApproved  = "approved"
+	NameReservationRejected  = "rejected"
+	NameReservationFailed    = "failed"
+	// NameReservationUndecided means CIPC did not decide before cipcOutcomeDeadline.
+	NameReservationUndecided = "undecided"
+)
+
+// NameReservationInput starts a NameReservationWorkflow.
+type NameReservationInput struct {
+	UserID string `json:"user_id"`
+	// CompanyType is the type of company the name is for; private when empty.
+	CompanyType string `json:"company_type,omitempty"`
+	// ProposedNames are in order of preference, as CIPC considers them.
+	ProposedNames []string `json:"proposed_names"`
+	CustomerCode  string   `json:"cipc_customer_code,omitempty"`
+}
+
+// NameReservationState is where a reservation has got to.
+type NameReservationState struct {
+	Status      string      `json:"status"`
+	CompanyType string      `json:"company_type"`
+	Checks      []NameCheck `json:"checks,omitempty"`
+	// SubmittedNames are the full names sent to CIPC, in order of preference.
+	SubmittedNames  []string   `json:"submitted_names,omitempty"`
+	Reference       string     `json:"reference,omitempty"`
+	ApprovedName    string     `json:"approved_name,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReservedUntil   *time.Time `json:"reserved_until,omitempty"`
+}
+
+// NameReservationSubmission is what the runner needs to reserve names or check on a reservation.
+type NameReservationSubmission struct {
+	CompanyType  string   `json:"company_type"`
+	Names        []string `json:"names"`
+	CustomerCode string   `json:"cipc_customer_code,omitempty"`
+	// Reference is set once the reservation has been submitted.
+	Reference string `json:"reference,omitempty"`
+}
+
+// ValidateNameReservationInput checks a reservation request before it is started.
+func ValidateNameReservationInput(input NameReservationInput) error {
+	if input.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if len(input.ProposedNames) == 0 || len(input.ProposedNames) > MaxProposedNames {
+		return fmt.Errorf("between 1 and %d proposed names are required", MaxProposedNames)
+	}
+	if _, ok := companyNameSuffixes[input.CompanyType]; input.CompanyType != "" && !ok {
+		return fmt.Errorf("unsupported company type %q", input.CompanyType)
+	}
+	return nil
+}
+
+// NameReservationWorkflow reserves a company name. It checks the proposed names against CIPC's
+// naming rules and our cached copy of the register, suggests alternatives to names that are
+// unlikely to be approved, submits the rest to CIPC in the customer's order of preference and
+// follows the reservation until CIPC approves or rejects it.
+func NameReservationWorkflow(ctx workflow.Context, input NameReservationInput) (*NameReservationState, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting NameReservationWorkflow", "user_id", input.UserID, "names", len(input.ProposedNames))
+
+	if err := ValidateNameReservationInput(input); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidNameReservation", nil)
+	}
+	if input.CompanyType == "" {
+		input.CompanyType = CompanyTypePrivate
+	}
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+	portalRetry := &temporal.RetryPolicy{
+		InitialInterval:    30 * time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    5 * time.Minute,
+		MaximumAttempts:    3,
+	}
+	submitCtx := withPortalSession(workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 15 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy:         portalRetry,
+	}), input.CustomerCode, PortalPriorityNormal)
+	pollCtx := withPortalSession(workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		RetryPolicy:         portalRetry,
+	}), input.CustomerCode, PortalPriorityBackground)
+
+	state := &NameReservationState{Status: NameReservationChecking, CompanyType: input.CompanyType}
+	if err := workflow.SetQueryHandler(ctx, NameReservationStatusQueryName, func() (NameReservationState, error) {
+		return *state, nil
+	}); err != nil {
+		return nil, err
+	}
+	record := func() {
+		if err := workflow.ExecuteActivity(ctx, RecordNameReservationActivity, input.UserID, *state).Get(ctx, nil); err != nil {
+			logger.Error("Failed to record name reservation", "status", state.Status, "error", err)
+		}
+	}
+
+	// Step 1: Check the names against the naming rules and the register
+	var register []RegisteredName
+	err := workflow.ExecuteActivity(ctx, LoadRegisteredNamesActivity, CompanyNameLookupTokens(input.ProposedNames...)).Get(ctx, &register)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registered names: %w", err)
+	}
+	submission := NameReservationSubmission{CompanyType: input.CompanyType, CustomerCode: input.CustomerCode}
+	for _, name := range input.ProposedNames {
+		check := CheckCompanyName(name, input.CompanyType, register)
+		if check.Status != NameAvailable {
+			check.Suggestions = SuggestCompanyNames(name, input.CompanyType, register, 3)
+		}
+		state.Checks = append(state.Checks, check)
+		if check.Status != NameUnavailable && !containsString(submission.Names, check.FullName) {
+			submission.Names = append(submission.Names, check.FullName)
+		}
+	}
+
+	// Step 2: Tell the customer, stopping if no name can be submitted
+	if len(submission.Names) == 0 {
+		state.Status = NameReservationNoNames
+		record()
+		notifyUser(ctx, input.UserID, nameCheckMessage(*state))
+		return state, nil
+	}
+	state.SubmittedNames = submission.Names
+	notifyUser(ctx, input.UserID, nameCheckMessage(*state))
+
+	// Step 3: Submit the reservation
+	if err := workflow.ExecuteActivity(submitCtx, SubmitNameReservationActivity, submission).Get(ctx, &submission.Reference); err != nil {
+		state.Status = NameReservationFailed
+		record()
+		notifyUser(ctx, input.UserID, "We couldn't submit your name reservation to CIPC. Please try again later, or reply 'HELP' and we'll sort it out with you.")
+		return nil, fmt.Errorf("name reservation submission failed: %w", err)
+	}
+	state.Status, state.Reference = NameReservationSubmitted, submission.Reference
+	record()
+
+	// Step 4: Follow the reservation until CIPC decides, or take the decision from ops
+	outcome := trackNameReservation(ctx, pollCtx, submission)
+
+	// Step 5: Record the outcome and tell the customer
+	switch outcome.Status {
+	case CIPCOutcomeApproved:
+		state.Status, state.ApprovedName = NameReservationApproved, outcome.ApprovedName
+		until := workflow.Now(ctx).AddDate(0, nameReservationMonths, 0)
+		state.ReservedUntil = &until
+	case CIPCOutcomeRejected:
+		state.Status, state.RejectionReason = NameReservationRejected, outcome.RejectionReason
+	default:
+		state.Status = NameReservationUndecided
+		logger.Warn("CIPC did not decide on name reservation in time", "reference", submission.Reference)
+	}
+	record()
+	if state.Status != NameReservationUndecided {
+		notifyUser(ctx, input.UserID, nameReservationOutcomeMessage(*state))
+	}
+	return state, nil
+}
+
+// trackNameReservation polls CIPC until it decides on a reservation or cipcOutcomeDeadline
+// passes. A CIPCOutcomeSignal from ops ends the wait early.
+func trackNameReservation(ctx, pollCtx workflow.Context, submission NameReservationSubmission) CIPCFilingOutcome {
+	logger := workflow.GetLogger(ctx)
+	outcome := CIPCFilingOutcome{Status: CIPCOutcomePending, Reference: submission.Reference}
+	outcomeChan := workflow.GetSignalChannel(ctx, CIPCOutcomeSignalName)
+	deadline := workflow.Now(ctx).Add(cipcOutcomeDeadline)
+	wait := cipcFirstPollDelay
+
+	for outcome.Status == CIPCOutcomePending {
+		remaining := deadline.Sub(workflow.Now(ctx))
+		if remaining <= 0 {
+			return outcome
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wait)
+		var signal CIPCOutcomeSignal
+		signalled := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(outcomeChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &signal)
+			signalled = true
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {})
+		selector.Select(ctx)
+		cancelTimer()
+		wait = cipcPollInterval
+
+		if signalled {
+			outcome = CIPCFilingOutcome{Status: CIPCOutcomeRejected, Reference: submission.Reference, RejectionReason: signal.RejectionReason}
+			if signal.Approved {
+				outcome = CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: submission.Reference,
+					CIPCReference: signal.Reference, ApprovedName: signal.ApprovedName}
+			}
+			break
+		}
+		if !workflow.Now(ctx).Before(deadline) {
+			continue
+		}
+
+		var polled CIPCFilingOutcome
+		if err := workflow.ExecuteActivity(pollCtx, PollNameReservationActivity, submission).Get(ctx, &polled); err != nil {
+			logger.Warn("Failed to check name reservation status", "reference", submission.Reference, "error", err)
+			continue
+		}
+		outcome = polled
+	}
+	return outcome
+}
+
+// nameCheckMessage tells the customer how their names fared and what happens next.
+func nameCheckMessage(state NameReservationState) string {
+	var b strings.Builder
+	b.WriteString("*Name check*\n")
+	for _, check := range state.Checks {
+		icon := "✅"
+		switch check.Status {
+		case NameAtRisk:
+			icon = "⚠️"
+		case NameUnavailable:
+			icon = "❌"
+		}
+		fmt.Fprintf(&b, "\n%s %s (%d/100)", icon, check.FullName, check.AvailabilityScore)
+		for _, problem := range append(append([]string(nil), check.Blocking...), check.Warnings...) {
+			b.WriteString("\n   • " + problem)
+		}
+		if len(check.Suggestions) > 0 {
+			b.WriteString("\n   Try: " + strings.Join(check.Suggestions, ", "))
+		}
+	}
+	if state.Status == NameReservationNoNames {
+		b.WriteString("\n\nNone of these names can be reserved. Send us new names, such as the suggestions above, to try again.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n\nWe're submitting %s to CIPC in that order of preference. CIPC reserves at most one of them; we'll let you know which.",
+		strings.Join(state.SubmittedNames, ", "))
+	return b.String()
+}
+
+// nameReservationOutcomeMessage tells the customer what CIPC decided.
+func nameReservationOutcomeMessage(state NameReservationState) string {
+	if state.Status == NameReservationRejected {
+		reason := state.RejectionReason
+		if reason == "" {
+			reason = "CIPC did not give a reason"
+		}
+		return fmt.Sprintf("❌ *CIPC rejected your name reservation*\n\nReference: %s\nReason: %s\n\nSend us new names and we'll check them before trying again.",
+			state.Reference, reason)
+	}
+	name := state.ApprovedName
+	if name == "" {
+		name = "one of your names"
+	}
+	return fmt.Sprintf("✅ *CIPC reserved %s for you*\n\nReference: %s\nThe name is yours to register until %s.",
+		name, state.Reference, state.ReservedUntil.Format("2 January 2006"))
+}
+
+// LoadRegisteredNamesActivity returns the names on our cached copy of CIPC's register that share
+// a word with the given tokens, including names reserved by other customers.
+func LoadRegisteredNamesActivity(ctx context.Context, tokens []string) ([]RegisteredName, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, COALESCE(registration_number, ''), status
+		FROM cipc_registered_names
+		WHERE tokens && $1 AND (status = 'registered' OR reserved_until > NOW())
+	`, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registered names: %w", err)
+	}
+	defer rows.Close()
+
+	var names []RegisteredName
+	for rows.Next() {
+		var name RegisteredName
+		if err := rows.Scan(&name.Name, &name.RegistrationNumber, &name.Status); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SubmitNameReservationActivity submits a name reservation through the CIPC Runner and returns
+// its reference.
+func SubmitNameReservationActivity(ctx context.Context, submission NameReservationSubmission) (string, error) {
+	activity.GetLogger(ctx).Info("Submitting name reservation", "names", len(submission.Names), "attempt", activity.GetInfo(ctx).Attempt)
+
+	outcome, err := runCheckpointedFiling(ctx, NameReservationServiceType, map[string]interface{}{
+		"company_type":       submission.CompanyType,
+		"names":              submission.Names,
+		"cipc_customer_code": submission.CustomerCode,
+	})
+	if err != nil {
+		return "", err
+	}
+	if outcome.Result.Status != "success" {
+		return "", temporal.NewNonRetryableApplicationError(outcome.Result.Error, outcome.Result.ErrorCode, nil)
+	}
+	return outcome.Result.ReferenceNumber, nil
+}
+
+// PollNameReservationActivity asks the runner whether CIPC has decided on a reservation.
+func PollNameReservationActivity(ctx context.Context, submission NameReservationSubmission) (*CIPCFilingOutcome, error) {
+	logger := activity.GetLogger(ctx)
+
+	sessionCtx, release, err := acquirePortalSession(ctx, func() { activity.RecordHeartbeat(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	runner := NewRunnerClient()
+	runner.OnLog = func(line string) { logger.Debug("runner", "line", line) }
+	status, err := runner.Run(sessionCtx, RunnerRequest{
+		RequestID:   activity.GetInfo(ctx).WorkflowExecution.ID,
+		ServiceType: NameReservationServiceType,
+		ClientData:  map[string]interface{}{"reference": submission.Reference, "names": submission.Names},
+		Action:      RunnerActionStatus,
+	})
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := CIPCOutcomeFromRunner(submission.Reference, status)
+	logger.Info("Checked name reservation status", "reference", submission.Reference, "status", outcome.Status)
+	return outcome, nil
+}
+
+// RecordNameReservationActivity stores a reservation's state in name_reservations. An approved
+// name is added to the cached register as reserved, so other customers are warned off it.
+func RecordNameReservationActivity(ctx context.Context, userID string, state NameReservationState) error {
+	workflowID := activity.GetInfo(ctx).WorkflowExecution.ID
+
+	checks, err := json.Marshal(state.Checks)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO name_reservations (workflow_id, user_id, company_type, status, checks, submitted_names,
+		                               reference, approved_name, rejection_reason, reserved_until)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10)
+		ON CONFLICT (workflow_id) DO UPDATE
+		SET status = EXCLUDED.status, checks = EXCLUDED.checks, submitted_names = EXCLUDED.submitted_names,
+		    reference = EXCLUDED.reference, approved_name = EXCLUDED.approved_name,
+		    rejection_reason = EXCLUDED.rejection_reason, reserved_until = EXCLUDED.reserved_until, updated_at = NOW()
+	`, workflowID, userID, state.CompanyType, state.Status, checks, state.SubmittedNames,
+		state.Reference, state.ApprovedName, state.RejectionReason, state.ReservedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to record name reservation: %w", err)
+	}
+
+	if state.Status == NameReservationApproved && state.ApprovedName != "" {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO cipc_registered_names (name, normalized_name, tokens, status, reserved_until)
+			VALUES ($1, $2, $3, 'reserved', $4)
+			ON CONFLICT (normalized_name) DO NOTHING
+		`, state.ApprovedName, normalizeCompanyName(state.ApprovedName), CompanyNameLookupTokens(state.ApprovedName), state.ReservedUntil)
+		if err != nil {
+			return fmt.Errorf("failed to add reserved name to register: %w", err)
+		}
+	}
+	return tx.Commit()
+}
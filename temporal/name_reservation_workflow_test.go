@@ -0,0 +1,119 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// NameReservationTestSuite tests NameReservationWorkflow.
+type NameReservationTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	recorded []string
+	messages []string
+}
+
+// TestNameReservationTestSuite runs the test suite.
+func TestNameReservationTestSuite(t *testing.T) {
+	suite.Run(t, new(NameReservationTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *NameReservationTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.recorded, s.messages = nil, nil
+	s.env.OnActivity(LoadRegisteredNamesActivity, mock.Anything, mock.Anything).Return(
+		[]RegisteredName{{Name: "Blue Sky Logistics (Pty) Ltd", RegistrationNumber: "2015/000001/07", Status: "registered"}}, nil)
+	s.env.OnActivity(RecordNameReservationActivity, mock.Anything, "user-1", mock.Anything).Return(
+		func(_ context.Context, _ string, state NameReservationState) error {
+			s.recorded = append(s.recorded, state.Status)
+			return nil
+		})
+	s.env.OnActivity(SendWhatsAppMessageActivity, mock.Anything, "user-1", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		})
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *NameReservationTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *NameReservationTestSuite) result() NameReservationState {
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var state NameReservationState
+	s.NoError(s.env.GetWorkflowResult(&state))
+	return state
+}
+
+// Test_SubmitsAvailableNamesAndTracksApproval tests that taken names are left out of the
+// submission with suggestions, and that CIPC's approval is polled for and recorded.
+func (s *NameReservationTestSuite) Test_SubmitsAvailableNamesAndTracksApproval() {
+	submitted := []string{"Umoya Coffee (Pty) Ltd", "Blue Sky Bakers (Pty) Ltd"}
+	s.env.OnActivity(SubmitNameReservationActivity, mock.Anything, mock.MatchedBy(func(sub NameReservationSubmission) bool {
+		return sub.CompanyType == CompanyTypePrivate && len(sub.Names) == 2 && sub.Names[0] == submitted[0] && sub.Names[1] == submitted[1]
+	})).Return("NR1", nil).Once()
+	s.env.OnActivity(PollNameReservationActivity, mock.Anything, mock.Anything).Return(
+		&CIPCFilingOutcome{Status: CIPCOutcomePending, Reference: "NR1"}, nil).Once()
+	s.env.OnActivity(PollNameReservationActivity, mock.Anything, mock.Anything).Return(
+		&CIPCFilingOutcome{Status: CIPCOutcomeApproved, Reference: "NR1", ApprovedName: submitted[1]}, nil).Once()
+
+	s.env.ExecuteWorkflow(NameReservationWorkflow, NameReservationInput{
+		UserID:        "user-1",
+		ProposedNames: []string{"Blue Sky Logistics", "Umoya Coffee", "Blue Sky Bakers"},
+	})
+
+	state := s.result()
+	s.Equal(NameReservationApproved, state.Status)
+	s.Equal(submitted, state.SubmittedNames)
+	s.Equal(submitted[1], state.ApprovedName)
+	s.Require().NotNil(state.ReservedUntil)
+	s.Require().Len(state.Checks, 3)
+	s.Equal(NameUnavailable, state.Checks[0].Status)
+	s.NotEmpty(state.Checks[0].Suggestions)
+	s.Equal([]string{NameReservationSubmitted, NameReservationApproved}, s.recorded)
+	s.Require().Len(s.messages, 2)
+	s.Contains(s.messages[0], "Try: ")
+	s.Contains(s.messages[1], "CIPC reserved Blue Sky Bakers (Pty) Ltd")
+}
+
+// Test_StopsWhenNoNameCanBeSubmitted tests that nothing is submitted when every name is refused.
+func (s *NameReservationTestSuite) Test_StopsWhenNoNameCanBeSubmitted() {
+	s.env.ExecuteWorkflow(NameReservationWorkflow, NameReservationInput{
+		UserID:        "user-1",
+		ProposedNames: []string{"Blue Sky Logistics", "Bluesky Logistic", "Umoya Government"},
+	})
+
+	state := s.result()
+	s.Equal(NameReservationNoNames, state.Status)
+	s.Empty(state.SubmittedNames)
+	s.Equal([]string{NameReservationNoNames}, s.recorded)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "None of these names can be reserved")
+}
+
+// Test_OpsRecordsRejection tests that a decision signalled by ops ends the wait for CIPC.
+func (s *NameReservationTestSuite) Test_OpsRecordsRejection() {
+	s.env.OnActivity(SubmitNameReservationActivity, mock.Anything, mock.Anything).Return("NR2", nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(CIPCOutcomeSignalName, CIPCOutcomeSignal{RejectionReason: "Name is offensive"})
+	}, 30*time.Minute)
+
+	s.env.ExecuteWorkflow(NameReservationWorkflow, NameReservationInput{UserID: "user-1", ProposedNames: []string{"Umoya Coffee"}})
+
+	state := s.result()
+	s.Equal(NameReservationRejected, state.Status)
+	s.Equal("Name is offensive", state.RejectionReason)
+	s.Nil(state.ReservedUntil)
+	s.Contains(s.messages[len(s.messages)-1], "Reason: Name is offensive")
+}
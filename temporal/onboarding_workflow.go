@@ -21,7 +21,7 @@ func OnboardingWorkflow(ctx workflow.Context, phoneNumber string) (string, error
 
 	// 1. Welcome & Consent
 	var consentGiven bool
-	consentSignalChan := workflow.GetSignalChannel(ctx, "user-consent-signal")
+	consentSignalChan := workflow.GetSignalChannel(ctx, UserConsentSignalName)
 
 	err := workflow.ExecuteActivity(ctx, SendWelcomeAndConsentActivity, phoneNumber).Get(ctx, nil)
 	if err != nil {
@@ -29,7 +29,14 @@ func OnboardingWorkflow(ctx workflow.Context, phoneNumber string) (string, error
 	}
 
 	// Wait for the user's consent signal, with a timeout.
-	timer := workflow.NewTimer(ctx, time.Minute*15) // User has 15 minutes to respond
+	consentTimeout := time.Minute * 15 // User has 15 minutes to respond
+	conversationID := openConversation(ctx, ConversationPrompt{
+		PhoneNumber: phoneNumber,
+		Awaiting:    ConversationAwaitingConsent,
+		Description: "POPIA consent to get started (reply YES)",
+		ExpiresAt:   workflow.Now(ctx).Add(consentTimeout),
+	})
+	timer := workflow.NewTimer(ctx, consentTimeout)
 	selector := workflow.NewSelector(ctx)
 	selector.AddReceive(consentSignalChan, func(c workflow.ReceiveChannel, more bool) {
 		c.Receive(ctx, &consentGiven)
@@ -40,6 +47,7 @@ func OnboardingWorkflow(ctx workflow.Context, phoneNumber string) (string, error
 	})
 
 	selector.Select(ctx) // Wait for signal or timer
+	endConversation(ctx, conversationID)
 
 	if !consentGiven {
 		workflow.ExecuteActivity(ctx, SendConsentTimeoutMessageActivity, phoneNumber)
@@ -0,0 +1,275 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ops task types.
+const (
+	OpsTaskAutomationFailure = "automation_failure"
+	OpsTaskManualFiling      = "manual_filing"
+	// OpsTaskDocumentCollection asks ops to chase a customer's missing supporting documents.
+	OpsTaskDocumentCollection = "document_collection"
+	// OpsTaskUrgentSLA alerts ops to an urgent filing running out of its SLA. Nothing waits on it;
+	// it is closed when the filing finishes.
+	OpsTaskUrgentSLA = "urgent_sla"
+	// OpsTaskCIPCOutcomeOverdue asks ops to chase CIPC for a filing it has not decided in time.
+	OpsTaskCIPCOutcomeOverdue = "cipc_outcome_overdue"
+)
+
+// Ops task statuses. Open tasks can be claimed; open or claimed tasks can be completed or rejected,
+// or expire when the workflow waiting on them stops waiting.
+const (
+	OpsTaskOpen      = "open"
+	OpsTaskClaimed   = "claimed"
+	OpsTaskCompleted = "completed"
+	OpsTaskRejected  = "rejected"
+	OpsTaskExpired   = "expired"
+)
+
+// OpsTaskResolvedSignalName is sent to the waiting workflow when ops complete or reject its task.
+const OpsTaskResolvedSignalName = "ops-task-resolved"
+
+// ErrOpsTaskConflict is returned when a task is not in a state that allows the requested change.
+var ErrOpsTaskConflict = errors.New("ops task is not in a state that allows this change")
+
+// ErrOpsTaskNotFound is returned for unknown task IDs.
+var ErrOpsTaskNotFound = errors.New("ops task not found")
+
+// ErrOpsTaskReferenceRequired is returned when a filing task is completed without its CIPC
+// reference.
+var ErrOpsTaskReferenceRequired = errors.New("a CIPC reference is required to complete a filing task")
+
+// OpsTaskResolution is the payload of OpsTaskResolvedSignalName.
+type OpsTaskResolution struct {
+	TaskID string `json:"task_id"`
+	// Status is OpsTaskCompleted or OpsTaskRejected.
+	Status string `json:"status"`
+	// Reference is the CIPC reference of the manual filing, set when completed.
+	Reference  string `json:"reference,omitempty"`
+	Note       string `json:"note,omitempty"`
+	ResolvedBy string `json:"resolved_by"`
+}
+
+// OpsTaskNote is a free-text note left on a task by an agent.
+type OpsTaskNote struct {
+	Author    string    `json:"author"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OpsTask is a filing that needs a human.
+type OpsTask struct {
+	ID               string                 `json:"id"`
+	TaskType         string                 `json:"task_type"`
+	Status           string                 `json:"status"`
+	TransactionID    string                 `json:"transaction_id"`
+	ServiceType      string                 `json:"service_type"`
+	UserID           string                 `json:"user_id"`
+	CompanyRegNumber string                 `json:"company_reg_number,omitempty"`
+	ClientData       map[string]interface{} `json:"client_data,omitempty"`
+	ErrorCode        string                 `json:"error_code,omitempty"`
+	ErrorMessage     string                 `json:"error_message,omitempty"`
+	WorkflowID       string                 `json:"workflow_id"`
+	RunID            string                 `json:"run_id"`
+	ClaimedBy        string                 `json:"claimed_by,omitempty"`
+	ManualReference  string                 `json:"manual_reference,omitempty"`
+	ResolutionNote   string                 `json:"resolution_note,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	Notes            []OpsTaskNote          `json:"notes,omitempty"`
+}
+
+// createOpsTask queues a task for the given workflow run and returns its ID.
+func createOpsTask(ctx context.Context, db *sql.DB, taskType string, input AutomatedFilingInput, result FilingResult, workflowID, runID string) (string, error) {
+	clientData, err := json.Marshal(input.ClientData)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal client data: %w", err)
+	}
+
+	var taskID string
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO ops_tasks (task_type, transaction_id, service_type, user_id, company_reg_number, client_data,
+		                       error_code, error_message, workflow_id, run_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10)
+		RETURNING id
+	`, taskType, input.TransactionID, input.ServiceType, input.UserID, input.CompanyRegNumber, clientData,
+		result.ErrorCode, result.Error, workflowID, runID).Scan(&taskID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create ops task: %w", err)
+	}
+	return taskID, nil
+}
+
+// ListOpsTasks returns tasks with the given status, oldest first. An empty status lists every
+// task that still needs work.
+func ListOpsTasks(ctx context.Context, db *sql.DB, status string) ([]OpsTask, error) {
+	query := `
+		SELECT id, task_type, status, transaction_id, service_type, user_id, COALESCE(company_reg_number, ''),
+		       client_data, COALESCE(error_code, ''), COALESCE(error_message, ''), workflow_id, run_id,
+		       COALESCE(claimed_by, ''), COALESCE(manual_reference, ''), COALESCE(resolution_note, ''), created_at
+		FROM ops_tasks`
+	args := []interface{}{}
+	if status == "" {
+		query += ` WHERE status IN ('open', 'claimed')`
+	} else {
+		query += ` WHERE status = $1`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ops tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []OpsTask{}
+	for rows.Next() {
+		task, err := scanOpsTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// GetOpsTask loads one task with its notes.
+func GetOpsTask(ctx context.Context, db *sql.DB, taskID string) (*OpsTask, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT id, task_type, status, transaction_id, service_type, user_id, COALESCE(company_reg_number, ''),
+		       client_data, COALESCE(error_code, ''), COALESCE(error_message, ''), workflow_id, run_id,
+		       COALESCE(claimed_by, ''), COALESCE(manual_reference, ''), COALESCE(resolution_note, ''), created_at
+		FROM ops_tasks WHERE id = $1
+	`, taskID)
+	task, err := scanOpsTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOpsTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT author, note, created_at FROM ops_task_notes WHERE task_id = $1 ORDER BY created_at`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ops task notes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var note OpsTaskNote
+		if err := rows.Scan(&note.Author, &note.Note, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		task.Notes = append(task.Notes, note)
+	}
+	return &task, rows.Err()
+}
+
+// ClaimOpsTask assigns an open task to an agent.
+func ClaimOpsTask(ctx context.Context, db *sql.DB, taskID, agent string) error {
+	res, err := db.ExecContext(ctx, `
+		UPDATE ops_tasks SET status = 'claimed', claimed_by = $2, claimed_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`, taskID, agent)
+	if err != nil {
+		return fmt.Errorf("failed to claim ops task: %w", err)
+	}
+	return requireOpsTaskChanged(ctx, db, res, taskID)
+}
+
+// AddOpsTaskNote appends a note to a task.
+func AddOpsTaskNote(ctx context.Context, db *sql.DB, taskID, author, note string) error {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO ops_task_notes (task_id, author, note)
+		SELECT id, $2, $3 FROM ops_tasks WHERE id = $1
+	`, taskID, author, note)
+	if err != nil {
+		return fmt.Errorf("failed to add ops task note: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOpsTaskNotFound
+	}
+	return nil
+}
+
+// ResolveOpsTask completes or rejects a task and signals the workflow waiting on it. The status
+// change is only committed once the signal has been delivered, so a task can never be closed
+// while its workflow keeps waiting.
+func ResolveOpsTask(ctx context.Context, db *sql.DB, resolution OpsTaskResolution, signal func(workflowID, runID string, resolution OpsTaskResolution) error) error {
+	if resolution.Status != OpsTaskCompleted && resolution.Status != OpsTaskRejected {
+		return fmt.Errorf("invalid resolution status %q", resolution.Status)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var workflowID, runID, taskType string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE ops_tasks
+		SET status = $2, manual_reference = NULLIF($3, ''), resolution_note = NULLIF($4, ''), resolved_by = $5, resolved_at = NOW()
+		WHERE id = $1 AND status IN ('open', 'claimed')
+		RETURNING workflow_id, run_id, task_type
+	`, resolution.TaskID, resolution.Status, resolution.Reference, resolution.Note, resolution.ResolvedBy).Scan(&workflowID, &runID, &taskType)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, getErr := GetOpsTask(ctx, db, resolution.TaskID); errors.Is(getErr, ErrOpsTaskNotFound) {
+			return ErrOpsTaskNotFound
+		}
+		return ErrOpsTaskConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve ops task: %w", err)
+	}
+	// Only filing tasks file anything; the others are completed without a reference.
+	isFiling := taskType == OpsTaskAutomationFailure || taskType == OpsTaskManualFiling
+	if resolution.Status == OpsTaskCompleted && resolution.Reference == "" && isFiling {
+		return ErrOpsTaskReferenceRequired
+	}
+
+	// Nothing waits on an SLA escalation or an overdue CIPC outcome.
+	if taskType != OpsTaskUrgentSLA && taskType != OpsTaskCIPCOutcomeOverdue {
+		if err := signal(workflowID, runID, resolution); err != nil {
+			return fmt.Errorf("failed to signal workflow %s: %w", workflowID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOpsTask(row rowScanner) (OpsTask, error) {
+	var task OpsTask
+	var clientData []byte
+	err := row.Scan(&task.ID, &task.TaskType, &task.Status, &task.TransactionID, &task.ServiceType, &task.UserID,
+		&task.CompanyRegNumber, &clientData, &task.ErrorCode, &task.ErrorMessage, &task.WorkflowID, &task.RunID,
+		&task.ClaimedBy, &task.ManualReference, &task.ResolutionNote, &task.CreatedAt)
+	if err != nil {
+		return OpsTask{}, err
+	}
+	if len(clientData) > 0 {
+		if err := json.Unmarshal(clientData, &task.ClientData); err != nil {
+			return OpsTask{}, fmt.Errorf("failed to decode client data: %w", err)
+		}
+	}
+	return task, nil
+}
+
+// requireOpsTaskChanged tells a conflict apart from an unknown task when an update matched no rows.
+func requireOpsTaskChanged(ctx context.Context, db *sql.DB, res sql.Result, taskID string) error {
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := GetOpsTask(ctx, db, taskID); err != nil {
+		return err
+	}
+	return ErrOpsTaskConflict
+}
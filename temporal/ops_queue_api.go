@@ -0,0 +1,141 @@
+package temporal
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type opsNoteRequest struct {
+	Note string `json:"note"`
+}
+
+type opsResolveRequest struct {
+	Reference string `json:"reference"`
+	Note      string `json:"note"`
+}
+
+type opsAgentKey struct{}
+
+func (s *APIServer) registerOpsQueueRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /ops/tasks", requireOpsAgent(s.listOpsTasksHandler))
+	mux.HandleFunc("GET /ops/tasks/{id}", requireOpsAgent(s.getOpsTaskHandler))
+	mux.HandleFunc("POST /ops/tasks/{id}/claim", requireOpsAgent(s.claimOpsTaskHandler))
+	mux.HandleFunc("POST /ops/tasks/{id}/notes", requireOpsAgent(s.addOpsTaskNoteHandler))
+	mux.HandleFunc("POST /ops/tasks/{id}/complete", requireOpsAgent(s.resolveOpsTaskHandler(OpsTaskCompleted)))
+	mux.HandleFunc("POST /ops/tasks/{id}/reject", requireOpsAgent(s.resolveOpsTaskHandler(OpsTaskRejected)))
+}
+
+// requireOpsAgent only lets requests bearing an ops agent's token (see getOpsAgentTokens)
+// through to next, which finds the agent with opsAgent.
+func requireOpsAgent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		agent := ""
+		for token, name := range getOpsAgentTokens() {
+			if ok && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+				agent = name
+			}
+		}
+		if agent == "" {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), opsAgentKey{}, agent)))
+	}
+}
+
+// opsAgent returns the ops agent requireOpsAgent authenticated the request as.
+func opsAgent(r *http.Request) string {
+	agent, _ := r.Context().Value(opsAgentKey{}).(string)
+	return agent
+}
+
+// listOpsTasksHandler lists tasks without their client data; agents see it on the task they open.
+func (s *APIServer) listOpsTasksHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := ListOpsTasks(r.Context(), s.DB, r.URL.Query().Get("status"))
+	if err != nil {
+		writeOpsError(w, err)
+		return
+	}
+	for i := range tasks {
+		tasks[i].ClientData = nil
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *APIServer) getOpsTaskHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := GetOpsTask(r.Context(), s.DB, r.PathValue("id"))
+	if err != nil {
+		writeOpsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *APIServer) claimOpsTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if err := ClaimOpsTask(r.Context(), s.DB, r.PathValue("id"), opsAgent(r)); err != nil {
+		writeOpsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": OpsTaskClaimed})
+}
+
+func (s *APIServer) addOpsTaskNoteHandler(w http.ResponseWriter, r *http.Request) {
+	var req opsNoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Note == "" {
+		writeError(w, http.StatusBadRequest, "note is required")
+		return
+	}
+	if err := AddOpsTaskNote(r.Context(), s.DB, r.PathValue("id"), opsAgent(r), req.Note); err != nil {
+		writeOpsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "noted"})
+}
+
+func (s *APIServer) resolveOpsTaskHandler(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req opsResolveRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		resolution := OpsTaskResolution{
+			TaskID:     r.PathValue("id"),
+			Status:     status,
+			Reference:  req.Reference,
+			Note:       req.Note,
+			ResolvedBy: opsAgent(r),
+		}
+		err := ResolveOpsTask(r.Context(), s.DB, resolution, func(workflowID, runID string, resolution OpsTaskResolution) error {
+			return s.Temporal.SignalWorkflow(r.Context(), workflowID, runID, OpsTaskResolvedSignalName, resolution)
+		})
+		if err != nil {
+			writeOpsError(w, err)
+			return
+		}
+		log.Printf("Ops task %s %s by %s", resolution.TaskID, status, resolution.ResolvedBy)
+		writeJSON(w, http.StatusOK, map[string]string{"status": status})
+	}
+}
+
+func writeOpsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOpsTaskNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrOpsTaskConflict):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrOpsTaskReferenceRequired):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Ops queue error: %s", err)
+		writeError(w, http.StatusInternalServerError, "Unable to update ops task")
+	}
+}
@@ -0,0 +1,358 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOpsStore is an in-memory ops_tasks and ops_task_notes table behind database/sql. It only
+// understands the statements ops_queue.go runs. Every connection shares it and transactions are
+// not isolated; a rollback restores the tasks as they were when the transaction began.
+type fakeOpsStore struct {
+	mu    sync.Mutex
+	tasks map[string]*OpsTask
+	order []string
+}
+
+func newFakeOpsDB(t *testing.T, tasks ...OpsTask) (*sql.DB, *fakeOpsStore) {
+	store := &fakeOpsStore{tasks: map[string]*OpsTask{}}
+	for i := range tasks {
+		task := tasks[i]
+		task.CreatedAt = time.Date(2026, 1, 1, 9, i, 0, 0, time.UTC)
+		store.tasks[task.ID] = &task
+		store.order = append(store.order, task.ID)
+	}
+	db := sql.OpenDB(store)
+	t.Cleanup(func() { db.Close() })
+	return db, store
+}
+
+func (s *fakeOpsStore) task(id string) OpsTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.tasks[id]
+}
+
+func (s *fakeOpsStore) Connect(context.Context) (driver.Conn, error) { return &fakeOpsConn{store: s}, nil }
+func (s *fakeOpsStore) Driver() driver.Driver                        { return nil }
+
+type fakeOpsConn struct {
+	store    *fakeOpsStore
+	snapshot map[string]OpsTask
+}
+
+func (c *fakeOpsConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake ops database does not prepare statements")
+}
+func (c *fakeOpsConn) Close() error { return nil }
+
+func (c *fakeOpsConn) Begin() (driver.Tx, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.snapshot = map[string]OpsTask{}
+	for id, task := range c.store.tasks {
+		c.snapshot[id] = *task
+	}
+	return c, nil
+}
+
+func (c *fakeOpsConn) Commit() error {
+	c.snapshot = nil
+	return nil
+}
+
+func (c *fakeOpsConn) Rollback() error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	for id, task := range c.snapshot {
+		*c.store.tasks[id] = task
+	}
+	c.snapshot = nil
+	return nil
+}
+
+func (c *fakeOpsConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	task, ok := s.tasks[args[0].Value.(string)]
+	switch {
+	case strings.HasPrefix(query, "UPDATE ops_tasks SET status = 'claimed'"):
+		if !ok || task.Status != OpsTaskOpen {
+			return driver.RowsAffected(0), nil
+		}
+		task.Status = OpsTaskClaimed
+		task.ClaimedBy = args[1].Value.(string)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "INSERT INTO ops_task_notes"):
+		if !ok {
+			return driver.RowsAffected(0), nil
+		}
+		task.Notes = append(task.Notes, OpsTaskNote{Author: args[1].Value.(string), Note: args[2].Value.(string), CreatedAt: time.Now()})
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("fake ops database cannot run %q", query)
+}
+
+func (c *fakeOpsConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	rows := &fakeOpsRows{}
+	switch {
+	case strings.HasPrefix(query, "UPDATE ops_tasks SET status = $2"):
+		rows.columns = []string{"workflow_id", "run_id", "task_type"}
+		task, ok := s.tasks[args[0].Value.(string)]
+		if ok && (task.Status == OpsTaskOpen || task.Status == OpsTaskClaimed) {
+			task.Status = args[1].Value.(string)
+			task.ManualReference = args[2].Value.(string)
+			task.ResolutionNote = args[3].Value.(string)
+			rows.values = append(rows.values, []driver.Value{task.WorkflowID, task.RunID, task.TaskType})
+		}
+	case strings.HasPrefix(query, "SELECT author, note, created_at FROM ops_task_notes"):
+		rows.columns = []string{"author", "note", "created_at"}
+		if task, ok := s.tasks[args[0].Value.(string)]; ok {
+			for _, note := range task.Notes {
+				rows.values = append(rows.values, []driver.Value{note.Author, note.Note, note.CreatedAt})
+			}
+		}
+	case strings.HasSuffix(query, "FROM ops_tasks WHERE id = $1"):
+		if task, ok := s.tasks[args[0].Value.(string)]; ok {
+			rows.addTask(task)
+		}
+	case strings.HasSuffix(query, "FROM ops_tasks WHERE status IN ('open', 'claimed') ORDER BY created_at"):
+		for _, id := range s.order {
+			if task := s.tasks[id]; task.Status == OpsTaskOpen || task.Status == OpsTaskClaimed {
+				rows.addTask(task)
+			}
+		}
+	case strings.HasSuffix(query, "FROM ops_tasks WHERE status = $1 ORDER BY created_at"):
+		for _, id := range s.order {
+			if task := s.tasks[id]; task.Status == args[0].Value.(string) {
+				rows.addTask(task)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("fake ops database cannot run %q", query)
+	}
+	return rows, nil
+}
+
+type fakeOpsRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeOpsRows) addTask(task *OpsTask) {
+	r.columns = make([]string, 16)
+	clientData, _ := json.Marshal(task.ClientData)
+	r.values = append(r.values, []driver.Value{task.ID, task.TaskType, task.Status, task.TransactionID, task.ServiceType,
+		task.UserID, task.CompanyRegNumber, clientData, task.ErrorCode, task.ErrorMessage, task.WorkflowID, task.RunID,
+		task.ClaimedBy, task.ManualReference, task.ResolutionNote, task.CreatedAt})
+}
+
+func (r *fakeOpsRows) Columns() []string { return r.columns }
+func (r *fakeOpsRows) Close() error      { return nil }
+
+func (r *fakeOpsRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func opsTestTask(id, taskType, status string) OpsTask {
+	return OpsTask{
+		ID:            id,
+		TaskType:      taskType,
+		Status:        status,
+		TransactionID: "txn-" + id,
+		ServiceType:   "annual_return",
+		UserID:        "user-1",
+		ClientData:    map[string]interface{}{"company_name": "Acme (Pty) Ltd"},
+		WorkflowID:    "filing-" + id,
+		RunID:         "run-" + id,
+	}
+}
+
+func opsRequest(t *testing.T, s *APIServer, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	s.registerOpsQueueRoutes(mux)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOpsQueue_ListsTasksThatNeedWork(t *testing.T) {
+	t.Setenv("OPS_AGENT_TOKENS", "thandi@ops=token-1")
+	db, _ := newFakeOpsDB(t,
+		opsTestTask("task-1", OpsTaskManualFiling, OpsTaskOpen),
+		opsTestTask("task-2", OpsTaskAutomationFailure, OpsTaskCompleted),
+		opsTestTask("task-3", OpsTaskDocumentCollection, OpsTaskClaimed),
+	)
+	s := &APIServer{DB: db}
+
+	rec := opsRequest(t, s, http.MethodGet, "/ops/tasks", "token-1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tasks []OpsTask
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tasks))
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "task-1", tasks[0].ID)
+	assert.Equal(t, "task-3", tasks[1].ID)
+	assert.Nil(t, tasks[0].ClientData, "client data is only shown on the task itself")
+
+	rec = opsRequest(t, s, http.MethodGet, "/ops/tasks?status=completed", "token-1", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tasks))
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "task-2", tasks[0].ID)
+}
+
+func TestOpsQueue_ClaimTwice(t *testing.T) {
+	t.Setenv("OPS_AGENT_TOKENS", "thandi@ops=token-1,pieter@ops=token-2")
+	db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskOpen))
+	s := &APIServer{DB: db}
+
+	rec := opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/claim", "token-1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/claim", "token-2", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, OpsTaskClaimed, store.task("task-1").Status)
+	assert.Equal(t, "thandi@ops", store.task("task-1").ClaimedBy, "the first claim stands")
+
+	rec = opsRequest(t, s, http.MethodPost, "/ops/tasks/task-9/claim", "token-2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestOpsQueue_Notes(t *testing.T) {
+	t.Setenv("OPS_AGENT_TOKENS", "thandi@ops=token-1")
+	db, _ := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskClaimed))
+	s := &APIServer{DB: db}
+
+	rec := opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/notes", "token-1", `{"note": "Called the customer"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	rec = opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/notes", "token-1", `{"note": ""}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = opsRequest(t, s, http.MethodPost, "/ops/tasks/task-9/notes", "token-1", `{"note": "Called the customer"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = opsRequest(t, s, http.MethodGet, "/ops/tasks/task-1", "token-1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var task OpsTask
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &task))
+	require.Len(t, task.Notes, 1)
+	assert.Equal(t, "thandi@ops", task.Notes[0].Author)
+	assert.Equal(t, "Called the customer", task.Notes[0].Note)
+	assert.Equal(t, "Acme (Pty) Ltd", task.ClientData["company_name"])
+}
+
+func TestOpsQueue_ResolveExpiredTask(t *testing.T) {
+	t.Setenv("OPS_AGENT_TOKENS", "thandi@ops=token-1")
+	db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskExpired))
+	s := &APIServer{DB: db}
+
+	rec := opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/complete", "token-1", `{"reference": "CIPC-123"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = opsRequest(t, s, http.MethodPost, "/ops/tasks/task-1/reject", "token-1", `{"note": "Too late"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, OpsTaskExpired, store.task("task-1").Status)
+}
+
+func TestResolveOpsTask(t *testing.T) {
+	var signalled []OpsTaskResolution
+	signal := func(workflowID, runID string, resolution OpsTaskResolution) error {
+		assert.Equal(t, "filing-task-1", workflowID)
+		assert.Equal(t, "run-task-1", runID)
+		signalled = append(signalled, resolution)
+		return nil
+	}
+
+	t.Run("complete signals the workflow", func(t *testing.T) {
+		signalled = nil
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskClaimed))
+		resolution := OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, Reference: "CIPC-123", ResolvedBy: "thandi@ops"}
+
+		require.NoError(t, ResolveOpsTask(context.Background(), db, resolution, signal))
+		assert.Equal(t, []OpsTaskResolution{resolution}, signalled)
+		assert.Equal(t, OpsTaskCompleted, store.task("task-1").Status)
+		assert.Equal(t, "CIPC-123", store.task("task-1").ManualReference)
+	})
+
+	t.Run("reject signals the workflow", func(t *testing.T) {
+		signalled = nil
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskAutomationFailure, OpsTaskOpen))
+		resolution := OpsTaskResolution{TaskID: "task-1", Status: OpsTaskRejected, Note: "Customer cancelled", ResolvedBy: "thandi@ops"}
+
+		require.NoError(t, ResolveOpsTask(context.Background(), db, resolution, signal))
+		assert.Equal(t, []OpsTaskResolution{resolution}, signalled)
+		assert.Equal(t, OpsTaskRejected, store.task("task-1").Status)
+	})
+
+	t.Run("filing task needs a reference", func(t *testing.T) {
+		signalled = nil
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskClaimed))
+
+		err := ResolveOpsTask(context.Background(), db, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, ResolvedBy: "thandi@ops"}, signal)
+		assert.ErrorIs(t, err, ErrOpsTaskReferenceRequired)
+		assert.Empty(t, signalled)
+		assert.Equal(t, OpsTaskClaimed, store.task("task-1").Status)
+	})
+
+	t.Run("failed signal leaves the task open", func(t *testing.T) {
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskClaimed))
+		failing := func(string, string, OpsTaskResolution) error { return errors.New("workflow not found") }
+
+		err := ResolveOpsTask(context.Background(), db, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, Reference: "CIPC-123"}, failing)
+		assert.ErrorContains(t, err, "workflow not found")
+		assert.Equal(t, OpsTaskClaimed, store.task("task-1").Status)
+	})
+
+	t.Run("nothing waits on an SLA escalation", func(t *testing.T) {
+		signalled = nil
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskUrgentSLA, OpsTaskOpen))
+
+		require.NoError(t, ResolveOpsTask(context.Background(), db, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted}, signal))
+		assert.Empty(t, signalled)
+		assert.Equal(t, OpsTaskCompleted, store.task("task-1").Status)
+	})
+
+	t.Run("expired task", func(t *testing.T) {
+		signalled = nil
+		db, store := newFakeOpsDB(t, opsTestTask("task-1", OpsTaskManualFiling, OpsTaskExpired))
+
+		err := ResolveOpsTask(context.Background(), db, OpsTaskResolution{TaskID: "task-1", Status: OpsTaskCompleted, Reference: "CIPC-123"}, signal)
+		assert.ErrorIs(t, err, ErrOpsTaskConflict)
+		assert.Empty(t, signalled)
+		assert.Equal(t, OpsTaskExpired, store.task("task-1").Status)
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		db, _ := newFakeOpsDB(t)
+
+		err := ResolveOpsTask(context.Background(), db, OpsTaskResolution{TaskID: "task-9", Status: OpsTaskRejected}, signal)
+		assert.ErrorIs(t, err, ErrOpsTaskNotFound)
+	})
+}
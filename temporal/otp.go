@@ -0,0 +1,150 @@
+package temporal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Names of the workflow Updates used to hand the filing workflow an OTP.
+const (
+	SubmitOTPUpdateName = "submit-otp"
+	ResendOTPUpdateName = "resend-otp"
+	// OTPSignalName is the legacy fire-and-forget way to send an OTP; prefer SubmitOTPUpdateName,
+	// which tells the caller whether the code was accepted.
+	OTPSignalName = "UserSentOTP"
+)
+
+const (
+	// OTPValidity is how long a CIPC OTP can be used after it was sent.
+	OTPValidity = 10 * time.Minute
+	// MaxOTPResends is how many extra OTPs a user can ask for during one filing.
+	MaxOTPResends = 3
+	// OTPWaitTimeout is how long the workflow keeps waiting for a usable OTP before giving up.
+	OTPWaitTimeout = 48 * time.Hour
+)
+
+// sealedOTPPrefix marks an encrypted OTP in a payload.
+const sealedOTPPrefix = "sealed:v1:"
+
+// OTPCode holds a one-time PIN. It encrypts itself when serialised, so the code only ever reaches
+// workflow history, activity inputs or logs as ciphertext.
+type OTPCode string
+
+// String masks the code so it cannot leak through logging.
+func (c OTPCode) String() string {
+	if c == "" {
+		return ""
+	}
+	return "******"
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c OTPCode) MarshalJSON() ([]byte, error) {
+	if c == "" {
+		return json.Marshal("")
+	}
+	sealed, err := sealOTP(string(c))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Plain codes from legacy callers are accepted.
+func (c *OTPCode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(s, sealedOTPPrefix) {
+		*c = OTPCode(s)
+		return nil
+	}
+	plain, err := openOTP(s)
+	if err != nil {
+		return err
+	}
+	*c = OTPCode(plain)
+	return nil
+}
+
+// OTPUpdateResult is returned to the caller of an OTP Update.
+type OTPUpdateResult struct {
+	Accepted       bool      `json:"accepted"`
+	Message        string    `json:"message"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	ResendsLeft    int       `json:"resends_left"`
+	ResendsAllowed int       `json:"resends_allowed"`
+}
+
+// NormalizeOTP strips the spaces and dashes people type into codes.
+func NormalizeOTP(otp string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(otp))
+}
+
+// ValidateOTPFormat checks that a code looks like a CIPC OTP: six digits.
+func ValidateOTPFormat(otp string) error {
+	if len(otp) != 6 {
+		return fmt.Errorf("the OTP should be 6 digits, got %d characters", len(otp))
+	}
+	for _, r := range otp {
+		if r < '0' || r > '9' {
+			return errors.New("the OTP should contain digits only")
+		}
+	}
+	return nil
+}
+
+func otpCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(getOTPEncryptionKey()))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealOTP(otp string) (string, error) {
+	aead, err := otpCipher()
+	if err != nil {
+		return "", fmt.Errorf("failed to seal OTP: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to seal OTP: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(otp), nil)
+	return sealedOTPPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openOTP(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedOTPPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to open OTP: %w", err)
+	}
+	aead, err := otpCipher()
+	if err != nil {
+		return "", fmt.Errorf("failed to open OTP: %w", err)
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", errors.New("failed to open OTP: ciphertext too short")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open OTP: %w", err)
+	}
+	return string(plain), nil
+}
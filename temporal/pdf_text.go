@@ -0,0 +1,308 @@
+package temporal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoPDFText is returned for PDFs with no extractable text, such as scans.
+var ErrNoPDFText = errors.New("PDF has no extractable text")
+
+// ErrPDFTooLarge is returned for PDFs whose compressed streams inflate past maxPDFDecodedSize.
+var ErrPDFTooLarge = errors.New("PDF content is too large")
+
+// maxPDFDecodedSize bounds the content a PDF's FlateDecode streams may inflate to, together, so a
+// small upload can't decompress into gigabytes.
+const maxPDFDecodedSize = 16 << 20
+
+var pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// ExtractPDFText returns the text drawn by a PDF's content streams, one line per text line. It
+// handles uncompressed and FlateDecode streams with single-byte string encodings, which covers
+// the certificates CIPC generates; text in images or CID fonts is not recovered.
+func ExtractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\r\n\t "), []byte("%PDF-")) {
+		return "", errors.New("not a PDF")
+	}
+
+	var text strings.Builder
+	decodedBudget := int64(maxPDFDecodedSize)
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		if bytes.Contains(dict, []byte("/Subtype/Image")) || bytes.Contains(dict, []byte("/Subtype /Image")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			decoded, err := io.ReadAll(io.LimitReader(r, decodedBudget+1))
+			if int64(len(decoded)) > decodedBudget {
+				return "", ErrPDFTooLarge
+			}
+			decodedBudget -= int64(len(decoded))
+			if err != nil && len(decoded) == 0 {
+				continue
+			}
+			stream = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Other filters are images or encodings we do not read.
+			continue
+		}
+		text.WriteString(pdfContentText(stream))
+	}
+
+	lines := strings.Split(text.String(), "\n")
+	var out []string
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	if len(out) == 0 {
+		return "", ErrNoPDFText
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// pdfContentText interprets the text operators of a content stream.
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var operands []pdfToken
+	inText := false
+
+	lex := pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.value {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			out.WriteByte('\n')
+		case "Td", "TD", "T*", "Tm":
+			if !inText {
+				break
+			}
+			if pdfMovesLine(tok.value, operands) {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(' ')
+			}
+		case "Tj":
+			writePDFStrings(&out, operands)
+		case "'", "\"":
+			out.WriteByte('\n')
+			writePDFStrings(&out, operands)
+		case "TJ":
+			writePDFStrings(&out, operands)
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// pdfMovesLine reports whether a positioning operator starts a new line rather than moving along
+// the current one.
+func pdfMovesLine(op string, operands []pdfToken) bool {
+	switch op {
+	case "T*":
+		return true
+	case "Td", "TD":
+		if len(operands) < 2 {
+			return true
+		}
+		y, err := strconv.ParseFloat(operands[len(operands)-1].value, 64)
+		return err != nil || y != 0
+	}
+	return true
+}
+
+func writePDFStrings(out *strings.Builder, operands []pdfToken) {
+	for _, tok := range operands {
+		switch tok.kind {
+		case pdfString:
+			out.WriteString(tok.value)
+		case pdfNumber:
+			// Large negative kerning inside TJ arrays is a word gap.
+			if n, err := strconv.ParseFloat(tok.value, 64); err == nil && n < -200 {
+				out.WriteByte(' ')
+			}
+		}
+	}
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfName
+	pdfDelimiter
+)
+
+type pdfToken struct {
+	kind  pdfTokenKind
+	value string
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0:
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, value: l.literalString()}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return pdfToken{kind: pdfDelimiter, value: "<<"}, true
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{kind: pdfDelimiter, value: ">>"}, true
+		case c == '<':
+			return pdfToken{kind: pdfString, value: l.hexString()}, true
+		case c == '[' || c == ']' || c == '{' || c == '}':
+			l.pos++
+			return pdfToken{kind: pdfDelimiter, value: string(c)}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.regular()
+			return pdfToken{kind: pdfName, value: string(l.data[start:l.pos])}, true
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			start := l.pos
+			l.regular()
+			return pdfToken{kind: pdfNumber, value: string(l.data[start:l.pos])}, true
+		default:
+			start := l.pos
+			if c == '\'' || c == '"' {
+				l.pos++
+			} else {
+				l.regular()
+				if l.pos == start {
+					l.pos++
+				}
+			}
+			return pdfToken{kind: pdfOperator, value: string(l.data[start:l.pos])}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// regular advances past a run of regular characters.
+func (l *pdfLexer) regular() {
+	for l.pos < len(l.data) && !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(l.data[l.pos])) {
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) literalString() string {
+	var b strings.Builder
+	depth := 0
+	l.pos++ // opening parenthesis
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			b.WriteByte(c)
+		case ')':
+			if depth == 0 {
+				return b.String()
+			}
+			depth--
+			b.WriteByte(c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b.String()
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b.WriteString(latin1(byte(n)))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteString(latin1(c))
+		}
+	}
+	return b.String()
+}
+
+func (l *pdfLexer) hexString() string {
+	l.pos++ // opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var b strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		b.WriteString(latin1(byte(n)))
+	}
+	return b.String()
+}
+
+// latin1 decodes a single-byte string character. WinAnsi and Latin-1 agree on the letters used
+// in company and director names.
+func latin1(c byte) string {
+	return string(rune(c))
+}
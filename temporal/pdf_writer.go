@@ -0,0 +1,170 @@
+package temporal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size and margins in PDF points.
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 56.0
+)
+
+// pdfAverageCharWidth approximates Helvetica's average glyph width as a fraction of the font
+// size, which is close enough to wrap prose without font metrics.
+const pdfAverageCharWidth = 0.5
+
+type pdfLine struct {
+	text string
+	size float64
+	bold bool
+	y    float64
+}
+
+// PDFDocument lays out plain text on A4 pages in Helvetica, for the documents we generate for
+// customers to print and sign. Text is wrapped to the page width and flows onto new pages; the
+// output is uncompressed so ExtractPDFText can read it back.
+type PDFDocument struct {
+	pages [][]pdfLine
+	y     float64
+}
+
+// NewPDFDocument returns an empty document with one page.
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{pages: [][]pdfLine{nil}, y: pdfPageHeight - pdfMargin}
+}
+
+// Heading adds a bold line of text.
+func (d *PDFDocument) Heading(size float64, text string) {
+	d.write(size, true, text)
+}
+
+// Paragraph adds text wrapped to the page width.
+func (d *PDFDocument) Paragraph(size float64, text string) {
+	d.write(size, false, text)
+}
+
+// Space adds vertical space.
+func (d *PDFDocument) Space(points float64) {
+	d.y -= points
+}
+
+func (d *PDFDocument) write(size float64, bold bool, text string) {
+	maxChars := int((pdfPageWidth - 2*pdfMargin) / (size * pdfAverageCharWidth))
+	leading := size * 1.4
+	for _, line := range wrapPDFText(text, maxChars) {
+		if d.y-leading < pdfMargin {
+			d.pages = append(d.pages, nil)
+			d.y = pdfPageHeight - pdfMargin
+		}
+		d.y -= leading
+		page := len(d.pages) - 1
+		d.pages[page] = append(d.pages[page], pdfLine{text: line, size: size, bold: bold, y: d.y})
+	}
+}
+
+// wrapPDFText breaks text into lines of at most maxChars, at spaces where possible. Explicit
+// newlines are kept.
+func wrapPDFText(text string, maxChars int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len(word) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:maxChars])
+				word = word[maxChars:]
+			}
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfLiteral encodes text as a PDF literal string in WinAnsiEncoding. Characters outside it are
+// replaced with '?'.
+func pdfLiteral(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '–' || r == '—':
+			b.WriteByte('-')
+		case r == '‘' || r == '’':
+			b.WriteByte('\'')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Bytes renders the document.
+func (d *PDFDocument) Bytes() []byte {
+	// Objects: 1 catalog, 2 page tree, 3 regular font, 4 bold font, then a page and its content
+	// stream per page.
+	var objects []string
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range d.pages {
+		var content strings.Builder
+		for _, line := range page {
+			font := "F1"
+			if line.bold {
+				font = "F2"
+			}
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td %s Tj ET\n", font, line.size, pdfMargin, line.y, pdfLiteral(line.text))
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
@@ -0,0 +1,35 @@
+package temporal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapPDFText(t *testing.T) {
+	assert.Equal(t, []string{"one two", "three", "", "four"}, wrapPDFText("one two three\n\nfour", 8))
+	assert.Equal(t, []string{"a", "abcde", "fgh"}, wrapPDFText("a abcdefgh", 5), "long words are split")
+}
+
+func TestPDFDocumentRoundTrip(t *testing.T) {
+	doc := NewPDFDocument()
+	doc.Heading(14, "Sworn (affidavit)")
+	for i := 0; i < 80; i++ {
+		doc.Paragraph(11, "Line of text – with a dash")
+	}
+
+	data := doc.Bytes()
+	mimeType, err := DetectDocumentType(data)
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", mimeType)
+	assert.Contains(t, string(data), "/Count 2", "text flows onto a second page")
+
+	text, err := ExtractPDFText(data)
+	require.NoError(t, err)
+	lines := strings.Split(text, "\n")
+	assert.Equal(t, "Sworn (affidavit)", lines[0])
+	assert.Equal(t, "Line of text - with a dash", lines[1])
+	assert.Len(t, lines, 81)
+}
@@ -0,0 +1,378 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// CIPCPortalTaskQueue is the task queue of activities that open a CIPC e-services session. Its
+// workers cap how many run per process; PortalSessionLimiter caps them across all workers.
+const CIPCPortalTaskQueue = "CIPC_PORTAL_TASK_QUEUE"
+
+// Task queue priorities of portal activities. Smaller keys run sooner.
+const (
+	PortalPriorityUrgent     = 1
+	PortalPriorityNormal     = 3
+	PortalPriorityBackground = 5
+)
+
+const (
+	// portalSessionLeaseTTL is how long a lease outlives a worker that stopped renewing it.
+	portalSessionLeaseTTL = 2 * time.Minute
+	// portalSessionPollInterval is how often a waiting activity checks whether it is its turn.
+	portalSessionPollInterval = 2 * time.Second
+	// portalWaiterStaleAfter drops waiters that stopped polling, so they do not hold up the queue.
+	portalWaiterStaleAfter = time.Minute
+)
+
+// PortalSessionLimits caps concurrent CIPC portal sessions.
+type PortalSessionLimits struct {
+	Global      int `json:"global"`
+	PerCustomer int `json:"per_customer"`
+}
+
+// withPortalSession routes an activity that opens a CIPC portal session to CIPCPortalTaskQueue.
+// The customer code is the activity's fairness key, so one customer's backlog cannot starve the
+// others; an empty code means the default e-services account.
+func withPortalSession(ctx workflow.Context, customerCode string, priorityKey int) workflow.Context {
+	options := workflow.GetActivityOptions(ctx)
+	options.TaskQueue = CIPCPortalTaskQueue
+	options.Priority = temporal.Priority{PriorityKey: priorityKey, FairnessKey: customerCode}
+	return workflow.WithActivityOptions(ctx, options)
+}
+
+// portalPriority is the priority of a filing's portal activities.
+func portalPriority(urgent bool) int {
+	if urgent {
+		return PortalPriorityUrgent
+	}
+	return PortalPriorityNormal
+}
+
+// portalCustomerCode returns the CIPC customer code a filing is made under, if its data names one.
+func portalCustomerCode(data map[string]interface{}) string {
+	code, _ := data["cipc_customer_code"].(string)
+	return code
+}
+
+// portalWaiter is an activity queued for a portal session.
+type portalWaiter struct {
+	Holder       string
+	CustomerCode string
+}
+
+// portalSessionGrants returns the waiters that get a session now. Waiters must be in queue
+// order, urgent first and then by arrival. Waiters whose customer code is at its limit are
+// skipped, so they do not hold up other customers behind them.
+func portalSessionGrants(limits PortalSessionLimits, active map[string]int, waiters []portalWaiter) []string {
+	open := 0
+	perCustomer := map[string]int{}
+	for code, n := range active {
+		open += n
+		perCustomer[code] = n
+	}
+
+	var grants []string
+	for _, w := range waiters {
+		if open >= limits.Global {
+			break
+		}
+		if perCustomer[w.CustomerCode] >= limits.PerCustomer {
+			continue
+		}
+		grants = append(grants, w.Holder)
+		open++
+		perCustomer[w.CustomerCode]++
+	}
+	return grants
+}
+
+// PortalSessionLimiter hands out CIPC portal sessions through leases in cipc_portal_sessions.
+// Waiting activities queue in cipc_portal_session_waiters and are served urgent first, then in
+// arrival order.
+type PortalSessionLimiter struct {
+	DB           *sql.DB
+	Limits       PortalSessionLimits
+	PollInterval time.Duration
+}
+
+// PortalSession is a held lease. It is renewed in the background until released, or until the
+// lease is found to be gone.
+type PortalSession struct {
+	holder string
+	// extend pushes the lease's expiry out by portalSessionLeaseTTL and returns how many leases
+	// it extended.
+	extend     func(ctx context.Context) (int64, error)
+	remove     func(ctx context.Context) error
+	renewEvery time.Duration
+	stop       chan struct{}
+	done       chan struct{}
+	lost       chan struct{}
+	lostErr    error
+}
+
+// Acquire waits until holder may open a portal session, calling onWait after every turn it has
+// to wait. Holders already holding a lease, such as a retried activity attempt, get it back.
+func (l *PortalSessionLimiter) Acquire(ctx context.Context, holder, customerCode string, urgent bool, onWait func()) (*PortalSession, error) {
+	for {
+		granted, err := l.tryAcquire(ctx, holder, customerCode, urgent)
+		if err != nil {
+			return nil, err
+		}
+		if granted {
+			s := l.newSession(holder)
+			go s.renew()
+			return s, nil
+		}
+		if onWait != nil {
+			onWait()
+		}
+		select {
+		case <-ctx.Done():
+			l.leaveQueue(holder)
+			return nil, ctx.Err()
+		case <-time.After(l.PollInterval):
+		}
+	}
+}
+
+// tryAcquire queues holder, or keeps its place, and takes a lease if it is holder's turn.
+func (l *PortalSessionLimiter) tryAcquire(ctx context.Context, holder, customerCode string, urgent bool) (bool, error) {
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Serialise grants across workers.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('cipc_portal_sessions'))`); err != nil {
+		return false, fmt.Errorf("failed to lock portal sessions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cipc_portal_sessions WHERE expires_at < NOW()`); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM cipc_portal_session_waiters WHERE last_seen_at < NOW() - make_interval(secs => $1)
+	`, portalWaiterStaleAfter.Seconds()); err != nil {
+		return false, err
+	}
+
+	renewed, err := tx.ExecContext(ctx, `
+		UPDATE cipc_portal_sessions SET expires_at = NOW() + make_interval(secs => $2) WHERE holder = $1
+	`, holder, portalSessionLeaseTTL.Seconds())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := renewed.RowsAffected(); n > 0 {
+		return true, tx.Commit()
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO cipc_portal_session_waiters (holder, customer_code, urgent)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (holder) DO UPDATE SET last_seen_at = NOW(), urgent = EXCLUDED.urgent
+	`, holder, customerCode, urgent); err != nil {
+		return false, fmt.Errorf("failed to queue for a portal session: %w", err)
+	}
+
+	active, err := l.activeSessions(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	waiters, err := l.waiters(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	if !containsString(portalSessionGrants(l.Limits, active, waiters), holder) {
+		return false, tx.Commit()
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO cipc_portal_sessions (holder, customer_code, urgent, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+	`, holder, customerCode, urgent, portalSessionLeaseTTL.Seconds()); err != nil {
+		return false, fmt.Errorf("failed to lease a portal session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cipc_portal_session_waiters WHERE holder = $1`, holder); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (l *PortalSessionLimiter) activeSessions(ctx context.Context, tx *sql.Tx) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT customer_code, COUNT(*) FROM cipc_portal_sessions GROUP BY customer_code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count portal sessions: %w", err)
+	}
+	defer rows.Close()
+
+	active := map[string]int{}
+	for rows.Next() {
+		var code string
+		var n int
+		if err := rows.Scan(&code, &n); err != nil {
+			return nil, err
+		}
+		active[code] = n
+	}
+	return active, rows.Err()
+}
+
+func (l *PortalSessionLimiter) waiters(ctx context.Context, tx *sql.Tx) ([]portalWaiter, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT holder, customer_code FROM cipc_portal_session_waiters
+		ORDER BY urgent DESC, enqueued_at, holder
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list portal session waiters: %w", err)
+	}
+	defer rows.Close()
+
+	var waiters []portalWaiter
+	for rows.Next() {
+		var w portalWaiter
+		if err := rows.Scan(&w.Holder, &w.CustomerCode); err != nil {
+			return nil, err
+		}
+		waiters = append(waiters, w)
+	}
+	return waiters, rows.Err()
+}
+
+// leaveQueue removes a holder that stopped waiting. Failures are left to the stale waiter cleanup.
+func (l *PortalSessionLimiter) leaveQueue(holder string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = l.DB.ExecContext(ctx, `DELETE FROM cipc_portal_session_waiters WHERE holder = $1`, holder)
+}
+
+func (l *PortalSessionLimiter) newSession(holder string) *PortalSession {
+	return &PortalSession{
+		holder: holder,
+		extend: func(ctx context.Context) (int64, error) {
+			res, err := l.DB.ExecContext(ctx, `
+				UPDATE cipc_portal_sessions SET expires_at = NOW() + make_interval(secs => $2) WHERE holder = $1
+			`, holder, portalSessionLeaseTTL.Seconds())
+			if err != nil {
+				return 0, err
+			}
+			return res.RowsAffected()
+		},
+		remove: func(ctx context.Context) error {
+			_, err := l.DB.ExecContext(ctx, `DELETE FROM cipc_portal_sessions WHERE holder = $1`, holder)
+			return err
+		},
+		renewEvery: portalSessionLeaseTTL / 3,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		lost:       make(chan struct{}),
+	}
+}
+
+// renew extends the lease until the session is released. A failed renewal is retried on the next
+// tick while the lease can still be valid; once it has been deleted or may have expired, another
+// holder can have its slot, so the session is marked lost and renewal stops.
+func (s *PortalSession) renew() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.renewEvery)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			n, err := s.extend(ctx)
+			cancel()
+			switch {
+			case err == nil && n == 0:
+				s.markLost(fmt.Errorf("portal session lease of %s no longer exists", s.holder))
+				return
+			case err == nil:
+				renewed = time.Now()
+			case time.Since(renewed) >= portalSessionLeaseTTL-s.renewEvery:
+				s.markLost(fmt.Errorf("failed to renew portal session lease of %s: %w", s.holder, err))
+				return
+			}
+		}
+	}
+}
+
+func (s *PortalSession) markLost(err error) {
+	s.lostErr = err
+	close(s.lost)
+}
+
+// Lost is closed when the lease was lost while held. Err says why.
+func (s *PortalSession) Lost() <-chan struct{} {
+	return s.lost
+}
+
+// Err returns why the lease was lost, once Lost is closed.
+func (s *PortalSession) Err() error {
+	select {
+	case <-s.lost:
+		return s.lostErr
+	default:
+		return nil
+	}
+}
+
+// Release gives the session back. A lease that cannot be deleted expires on its own.
+func (s *PortalSession) Release() {
+	close(s.stop)
+	<-s.done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = s.remove(ctx)
+}
+
+// acquirePortalSession waits for a portal session for the running activity, which is identified
+// by its workflow and activity ID so retries keep their place. The customer code and urgency come
+// from the priority withPortalSession scheduled the activity with. onWait keeps the activity's
+// heartbeat alive while it queues. The returned context is cancelled if the lease is lost while
+// held, so the activity fails and its retry queues for a session again; the runner must be called
+// with it. The returned func releases the session.
+func acquirePortalSession(ctx context.Context, onWait func()) (context.Context, func(), error) {
+	info := activity.GetInfo(ctx)
+	customerCode := info.Priority.FairnessKey
+	if customerCode == "" {
+		customerCode = getCIPCCustomerCode()
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	limiter := &PortalSessionLimiter{DB: db, Limits: getPortalSessionLimits(), PollInterval: portalSessionPollInterval}
+	holder := info.WorkflowExecution.ID + "/" + info.ActivityID
+	session, err := limiter.Acquire(ctx, holder, customerCode, info.Priority.PriorityKey == PortalPriorityUrgent, onWait)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	logger := activity.GetLogger(ctx)
+	logger.Info("Acquired CIPC portal session", "customer_code", customerCode, "holder", holder)
+
+	sessionCtx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-session.Lost():
+			logger.Warn("Lost CIPC portal session", "holder", holder, "error", session.Err())
+			cancel(session.Err())
+		case <-sessionCtx.Done():
+		}
+	}()
+	return sessionCtx, func() {
+		session.Release()
+		cancel(nil)
+		db.Close()
+	}, nil
+}
@@ -0,0 +1,107 @@
+package temporal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestPortalSessionGrants(t *testing.T) {
+	limits := PortalSessionLimits{Global: 3, PerCustomer: 2}
+	waiters := []portalWaiter{
+		{Holder: "urgent", CustomerCode: "A"},
+		{Holder: "a-1", CustomerCode: "A"},
+		{Holder: "a-2", CustomerCode: "A"},
+		{Holder: "b-1", CustomerCode: "B"},
+		{Holder: "c-1", CustomerCode: "C"},
+	}
+
+	tests := []struct {
+		name   string
+		active map[string]int
+		want   []string
+	}{
+		{name: "idle portal serves in queue order", active: nil, want: []string{"urgent", "a-1", "b-1"}},
+		{name: "busy customer does not block others", active: map[string]int{"A": 1}, want: []string{"urgent", "b-1"}},
+		{name: "customer at its limit waits", active: map[string]int{"A": 2}, want: []string{"b-1"}},
+		{name: "global limit reached", active: map[string]int{"B": 2, "C": 1}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, portalSessionGrants(limits, tt.active, waiters))
+		})
+	}
+}
+
+func TestWithPortalSession_RoutesToPortalQueue(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+
+	var info activity.Info
+	var options workflow.ActivityOptions
+	env.OnActivity(PollCIPCFilingActivity, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, _ CIPCConfirmationInput) (*CIPCFilingOutcome, error) {
+			info = activity.GetInfo(ctx)
+			return &CIPCFilingOutcome{Status: CIPCOutcomePending}, nil
+		})
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		ctx = withPortalSession(ctx, "C123", portalPriority(true))
+		options = workflow.GetActivityOptions(ctx)
+		return workflow.ExecuteActivity(ctx, PollCIPCFilingActivity, CIPCConfirmationInput{}).Get(ctx, nil)
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, CIPCPortalTaskQueue, info.TaskQueue)
+	assert.Equal(t, PortalPriorityUrgent, options.Priority.PriorityKey)
+	assert.Equal(t, "C123", options.Priority.FairnessKey)
+	assert.Equal(t, time.Minute, options.StartToCloseTimeout, "other options are kept")
+}
+
+func newTestPortalSession(extend func(ctx context.Context) (int64, error)) *PortalSession {
+	return &PortalSession{
+		holder:     "wf/1",
+		extend:     extend,
+		remove:     func(context.Context) error { return nil },
+		renewEvery: time.Millisecond,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		lost:       make(chan struct{}),
+	}
+}
+
+func TestPortalSessionRenew_LeaseGone(t *testing.T) {
+	s := newTestPortalSession(func(context.Context) (int64, error) { return 0, nil })
+	go s.renew()
+
+	select {
+	case <-s.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("session was not marked lost")
+	}
+	assert.ErrorContains(t, s.Err(), "no longer exists")
+	s.Release()
+}
+
+func TestPortalSessionRenew_KeepsRenewing(t *testing.T) {
+	renewals := make(chan struct{}, 100)
+	s := newTestPortalSession(func(context.Context) (int64, error) {
+		renewals <- struct{}{}
+		return 1, nil
+	})
+	go s.renew()
+
+	for i := 0; i < 3; i++ {
+		<-renewals
+	}
+	s.Release()
+	assert.NoError(t, s.Err())
+}
@@ -0,0 +1,317 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Public Interest Score thresholds of regulations 28 and 29 of the Companies Regulations.
+const (
+	PublicInterestScoreAuditThreshold  = 350
+	PublicInterestScoreReviewThreshold = 100
+	// FiduciaryAssetsAuditThreshold is the value of assets held in a fiduciary capacity for
+	// unrelated persons above which a company must be audited whatever its score.
+	FiduciaryAssetsAuditThreshold = 5_000_000
+	// AFSPreparationMonths is how long after its financial year end a company has to prepare its
+	// annual financial statements (section 30(1) of the Companies Act).
+	AFSPreparationMonths = 6
+)
+
+// How a company's annual financial statements must be assured.
+const (
+	AFSAudit             = "audit"
+	AFSIndependentReview = "independent_review"
+	// AFSExempt applies to owner-managed companies: section 30(2A) exempts a company whose
+	// shareholders are all directors from audit and review.
+	AFSExempt = "exempt"
+)
+
+// What a company lodges with its annual return: its annual financial statements or, when it is
+// not audited, a financial accountability supplement.
+const (
+	AFSLodgeFinancialStatements      = "afs"
+	AFSLodgeAccountabilitySupplement = "fas"
+)
+
+// FieldPublicInterest is the annual return filing data field holding the AFSObligationFacts of
+// the financial year being reported on.
+const FieldPublicInterest = "public_interest"
+
+// PublicInterestFacts are the figures of one financial year that a company's Public Interest
+// Score is calculated from (regulation 26(2)). Amounts are in rand.
+type PublicInterestFacts struct {
+	AverageEmployees      int     `json:"average_employees"`
+	ThirdPartyLiabilities float64 `json:"third_party_liabilities"`
+	Turnover              float64 `json:"turnover"`
+	// BeneficialInterestHolders counts the individuals with a beneficial interest in the
+	// company's securities, or the members of a non-profit company.
+	BeneficialInterestHolders int `json:"beneficial_interest_holders"`
+}
+
+// PublicInterestScore is a company's score and the points each figure contributed to it.
+type PublicInterestScore struct {
+	Employees          int `json:"employees"`
+	Liabilities        int `json:"liabilities"`
+	Turnover           int `json:"turnover"`
+	BeneficialInterest int `json:"beneficial_interest"`
+	Total              int `json:"total"`
+}
+
+// CalculatePublicInterestScore scores a point per employee, per R1 million or part of third party
+// liabilities and of turnover, and per beneficial interest holder.
+func CalculatePublicInterestScore(f PublicInterestFacts) PublicInterestScore {
+	s := PublicInterestScore{
+		Employees:          max(f.AverageEmployees, 0),
+		Liabilities:        pointsPerMillion(f.ThirdPartyLiabilities),
+		Turnover:           pointsPerMillion(f.Turnover),
+		BeneficialInterest: max(f.BeneficialInterestHolders, 0),
+	}
+	s.Total = s.Employees + s.Liabilities + s.Turnover + s.BeneficialInterest
+	return s
+}
+
+func pointsPerMillion(amount float64) int {
+	if amount <= 0 {
+		return 0
+	}
+	return int(math.Ceil(amount / 1_000_000))
+}
+
+// AFSObligationFacts describe a company's financial year for DetermineAFSObligation.
+type AFSObligationFacts struct {
+	FinancialYearEnd time.Time           `json:"financial_year_end"`
+	PublicInterest   PublicInterestFacts `json:"public_interest"`
+	// IndependentlyCompiled is set when an independent accounting professional compiled the
+	// financial statements rather than the company itself.
+	IndependentlyCompiled bool `json:"independently_compiled"`
+	// OwnerManaged is set when every shareholder of the company is also a director.
+	OwnerManaged    bool    `json:"owner_managed"`
+	FiduciaryAssets float64 `json:"fiduciary_assets"`
+}
+
+// ValidateAFSObligationFacts returns the problems with the facts as customer-facing messages.
+func ValidateAFSObligationFacts(f AFSObligationFacts) []string {
+	var problems []string
+	if f.FinancialYearEnd.IsZero() {
+		problems = append(problems, "the financial year end is required")
+	}
+	if f.PublicInterest.AverageEmployees < 0 {
+		problems = append(problems, "the average number of employees can't be negative")
+	}
+	if f.PublicInterest.ThirdPartyLiabilities < 0 {
+		problems = append(problems, "third party liabilities can't be negative")
+	}
+	if f.PublicInterest.Turnover < 0 {
+		problems = append(problems, "turnover can't be negative")
+	}
+	if f.PublicInterest.BeneficialInterestHolders < 0 {
+		problems = append(problems, "the number of beneficial interest holders can't be negative")
+	}
+	if f.FiduciaryAssets < 0 {
+		problems = append(problems, "fiduciary assets can't be negative")
+	}
+	return problems
+}
+
+// AFSObligation is how a company's annual financial statements must be assured and what it
+// lodges with its annual return.
+type AFSObligation struct {
+	CompanyType         string              `json:"company_type"`
+	FinancialYearEnd    time.Time           `json:"financial_year_end"`
+	PublicInterestScore PublicInterestScore `json:"public_interest_score"`
+	Assurance           string              `json:"assurance"`
+	// Reviewer is who may perform an independent review.
+	Reviewer string `json:"reviewer,omitempty"`
+	Lodge    string `json:"lodge"`
+	Reason   string `json:"reason"`
+}
+
+// DetermineAFSObligation applies regulations 28 and 29 and section 30(2A) to a company of the
+// given type. Companies that are audited lodge their AFS with the annual return; all others
+// lodge a financial accountability supplement.
+func DetermineAFSObligation(companyType string, f AFSObligationFacts) AFSObligation {
+	score := CalculatePublicInterestScore(f.PublicInterest)
+	o := AFSObligation{CompanyType: companyType, FinancialYearEnd: f.FinancialYearEnd, PublicInterestScore: score, Assurance: AFSAudit}
+	switch {
+	case companyType == CompanyTypePublic || companyType == CompanyTypeStateOwned:
+		o.Reason = "public and state-owned companies must be audited"
+	case f.FiduciaryAssets > FiduciaryAssetsAuditThreshold:
+		o.Reason = fmt.Sprintf("the company holds more than R%s in assets in a fiduciary capacity", formatRand(FiduciaryAssetsAuditThreshold))
+	case score.Total >= PublicInterestScoreAuditThreshold:
+		o.Reason = fmt.Sprintf("a Public Interest Score of %d is %d or more", score.Total, PublicInterestScoreAuditThreshold)
+	case score.Total >= PublicInterestScoreReviewThreshold && !f.IndependentlyCompiled:
+		o.Reason = fmt.Sprintf("a Public Interest Score of %d with internally compiled financial statements", score.Total)
+	case f.OwnerManaged && (companyType == CompanyTypePrivate || companyType == CompanyTypePersonalLiability):
+		o.Assurance = AFSExempt
+		o.Reason = "every shareholder is a director, so the company is exempt from audit and review"
+	case score.Total >= PublicInterestScoreReviewThreshold:
+		o.Assurance, o.Reviewer = AFSIndependentReview, "registered auditor or chartered accountant"
+		o.Reason = fmt.Sprintf("a Public Interest Score of %d with independently compiled financial statements", score.Total)
+	default:
+		o.Assurance, o.Reviewer = AFSIndependentReview, "independent accounting professional"
+		o.Reason = fmt.Sprintf("a Public Interest Score of %d is below %d", score.Total, PublicInterestScoreReviewThreshold)
+	}
+
+	o.Lodge = AFSLodgeAccountabilitySupplement
+	if o.Assurance == AFSAudit {
+		o.Lodge = AFSLodgeFinancialStatements
+	}
+	return o
+}
+
+// AnnualReturnFields are the fields the obligation adds to an annual return's filing data.
+func (o AFSObligation) AnnualReturnFields() map[string]interface{} {
+	return map[string]interface{}{
+		"public_interest_score": o.PublicInterestScore.Total,
+		"afs_assurance":         o.Assurance,
+		"financial_statements":  o.Lodge,
+		"financial_year_end":    o.FinancialYearEnd.Format("2006-01-02"),
+	}
+}
+
+// AFSSubmissionDueDate is when audited financial statements for the year ending on
+// financialYearEnd must be lodged: with the first annual return due after they have to be
+// prepared. annualReturnDue is any annual return due date of the company; when it is not known
+// the statements are due when they have to be prepared.
+func AFSSubmissionDueDate(financialYearEnd, annualReturnDue time.Time) time.Time {
+	prepared := financialYearEnd.AddDate(0, AFSPreparationMonths, 0)
+	if annualReturnDue.IsZero() {
+		return prepared
+	}
+	due := annualReturnDue
+	for due.Before(prepared) {
+		due = due.AddDate(1, 0, 0)
+	}
+	for !due.AddDate(-1, 0, 0).Before(prepared) {
+		due = due.AddDate(-1, 0, 0)
+	}
+	return due
+}
+
+// AFSSubmissionDeadline returns the afs_submission deadline of an obligation. Companies that
+// lodge a financial accountability supplement file it as part of the annual return and have
+// no separate deadline.
+func AFSSubmissionDeadline(o AFSObligation, annualReturnDue time.Time) (ComplianceDeadline, bool) {
+	if o.Lodge != AFSLodgeFinancialStatements {
+		return ComplianceDeadline{}, false
+	}
+	return ComplianceDeadline{DeadlineType: "afs_submission", DueDate: AFSSubmissionDueDate(o.FinancialYearEnd, annualReturnDue)}, true
+}
+
+// AnnualReturnAFSObligation determines the obligation from the FieldPublicInterest facts in an
+// annual return's filing data. It returns nil when the data has none.
+func AnnualReturnAFSObligation(regNumber string, data map[string]interface{}) (*AFSObligation, error) {
+	raw, ok := data[FieldPublicInterest]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	var facts AFSObligationFacts
+	encoded, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(encoded, &facts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FieldPublicInterest, err)
+	}
+	if problems := ValidateAFSObligationFacts(facts); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid %s: %s", FieldPublicInterest, problems[0])
+	}
+	companyType, err := CompanyTypeFromRegNumber(regNumber)
+	if err != nil {
+		return nil, err
+	}
+	obligation := DetermineAFSObligation(companyType, facts)
+	return &obligation, nil
+}
+
+// RecordAFSObligation stores a company's obligation for a financial year in afs_obligations
+// and brings its afs_submission deadline for that year in line with it. The deadline is lodged
+// with the company's earliest open annual return.
+func RecordAFSObligation(ctx context.Context, db *sql.DB, userID, regNumber string, facts AFSObligationFacts, o AFSObligation) (*ComplianceDeadline, error) {
+	encoded, err := json.Marshal(facts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal facts: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO afs_obligations (company_reg_number, financial_year_end, public_interest_score, assurance, lodge, facts)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (company_reg_number, financial_year_end) DO UPDATE SET
+			public_interest_score = EXCLUDED.public_interest_score, assurance = EXCLUDED.assurance,
+			lodge = EXCLUDED.lodge, facts = EXCLUDED.facts, updated_at = NOW()
+	`, regNumber, o.FinancialYearEnd, o.PublicInterestScore.Total, o.Assurance, o.Lodge, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record AFS obligation: %w", err)
+	}
+
+	var annualReturnDue sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT MIN(due_date) FROM compliance_deadlines
+		WHERE company_reg_number = $1 AND deadline_type = 'annual_return' AND status IN ('pending', 'overdue')
+	`, regNumber).Scan(&annualReturnDue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load annual return deadline: %w", err)
+	}
+
+	// A recalculation can change whether the year's statements are lodged at all.
+	dueDate := AFSSubmissionDueDate(o.FinancialYearEnd, annualReturnDue.Time)
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM compliance_deadlines
+		WHERE company_reg_number = $1 AND deadline_type = 'afs_submission' AND status = 'pending' AND due_date = $2
+	`, regNumber, dueDate)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := AFSSubmissionDeadline(o, annualReturnDue.Time)
+	if ok {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO compliance_deadlines (user_id, company_reg_number, deadline_type, due_date, status)
+			VALUES ($1, $2, $3, $4, 'pending')
+		`, userID, regNumber, deadline.DeadlineType, deadline.DueDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AFS deadline: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &deadline, nil
+}
+
+// LatestAFSObligation loads the obligation recorded for a company's most recent financial year.
+// It returns nil when none has been recorded.
+func LatestAFSObligation(ctx context.Context, db *sql.DB, regNumber string) (*AFSObligation, error) {
+	var o AFSObligation
+	var encoded []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT financial_year_end, assurance, lodge, facts FROM afs_obligations
+		WHERE company_reg_number = $1 ORDER BY financial_year_end DESC LIMIT 1
+	`, regNumber).Scan(&o.FinancialYearEnd, &o.Assurance, &o.Lodge, &encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AFS obligation: %w", err)
+	}
+	var facts AFSObligationFacts
+	if err := json.Unmarshal(encoded, &facts); err != nil {
+		return nil, fmt.Errorf("failed to decode AFS obligation facts: %w", err)
+	}
+	o.CompanyType, _ = CompanyTypeFromRegNumber(regNumber)
+	o.PublicInterestScore = CalculatePublicInterestScore(facts.PublicInterest)
+	return &o, nil
+}
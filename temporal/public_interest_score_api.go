@@ -0,0 +1,57 @@
+package temporal
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AFSObligationRequest is the body of POST /afs-obligations.
+type AFSObligationRequest struct {
+	UserID           string `json:"user_id"`
+	CompanyRegNumber string `json:"company_reg_number"`
+	AFSObligationFacts
+}
+
+// AFSObligationResponse is returned by POST /afs-obligations.
+type AFSObligationResponse struct {
+	Obligation AFSObligation `json:"obligation"`
+	// Deadline is the afs_submission deadline created for audited financial statements.
+	Deadline *ComplianceDeadline `json:"deadline,omitempty"`
+}
+
+func (s *APIServer) registerAFSObligationRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /afs-obligations", requireInternalAPIKey(s.recordAFSObligationHandler))
+}
+
+// recordAFSObligationHandler calculates a company's Public Interest Score for a financial year,
+// decides between audit and independent review and records the result. Later annual returns
+// lodge the AFS or FAS it calls for.
+func (s *APIServer) recordAFSObligationHandler(w http.ResponseWriter, r *http.Request) {
+	var req AFSObligationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.UserID == "" || req.CompanyRegNumber == "" {
+		writeError(w, http.StatusBadRequest, "user_id and company_reg_number are required")
+		return
+	}
+	companyType, err := CompanyTypeFromRegNumber(req.CompanyRegNumber)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if problems := ValidateAFSObligationFacts(req.AFSObligationFacts); len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+
+	obligation := DetermineAFSObligation(companyType, req.AFSObligationFacts)
+	deadline, err := RecordAFSObligation(r.Context(), s.DB, req.UserID, req.CompanyRegNumber, req.AFSObligationFacts, obligation)
+	if err != nil {
+		log.Printf("Error recording AFS obligation for %s: %s", req.CompanyRegNumber, err)
+		writeError(w, http.StatusInternalServerError, "Unable to record AFS obligation")
+		return
+	}
+	writeJSON(w, http.StatusOK, AFSObligationResponse{Obligation: obligation, Deadline: deadline})
+}
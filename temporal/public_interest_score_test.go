@@ -0,0 +1,106 @@
+package temporal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculatePublicInterestScore(t *testing.T) {
+	score := CalculatePublicInterestScore(PublicInterestFacts{
+		AverageEmployees:          40,
+		ThirdPartyLiabilities:     2_500_000,
+		Turnover:                  12_000_000,
+		BeneficialInterestHolders: 3,
+	})
+	assert.Equal(t, PublicInterestScore{Employees: 40, Liabilities: 3, Turnover: 12, BeneficialInterest: 3, Total: 58}, score)
+
+	assert.Equal(t, 1, CalculatePublicInterestScore(PublicInterestFacts{Turnover: 1}).Total)
+	assert.Equal(t, 0, CalculatePublicInterestScore(PublicInterestFacts{}).Total)
+}
+
+func TestDetermineAFSObligation(t *testing.T) {
+	fye := time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC)
+	facts := func(employees int, independent, ownerManaged bool) AFSObligationFacts {
+		return AFSObligationFacts{
+			FinancialYearEnd:      fye,
+			PublicInterest:        PublicInterestFacts{AverageEmployees: employees},
+			IndependentlyCompiled: independent,
+			OwnerManaged:          ownerManaged,
+		}
+	}
+	tests := []struct {
+		name        string
+		companyType string
+		facts       AFSObligationFacts
+		assurance   string
+		lodge       string
+	}{
+		{"public company", CompanyTypePublic, facts(5, true, false), AFSAudit, AFSLodgeFinancialStatements},
+		{"fiduciary assets", CompanyTypePrivate, AFSObligationFacts{FinancialYearEnd: fye, FiduciaryAssets: 6_000_000}, AFSAudit, AFSLodgeFinancialStatements},
+		{"score of 350", CompanyTypePrivate, facts(350, true, true), AFSAudit, AFSLodgeFinancialStatements},
+		{"score of 100 compiled internally", CompanyTypePrivate, facts(100, false, false), AFSAudit, AFSLodgeFinancialStatements},
+		{"score of 100 compiled independently", CompanyTypePrivate, facts(100, true, false), AFSIndependentReview, AFSLodgeAccountabilitySupplement},
+		{"owner managed", CompanyTypePrivate, facts(120, true, true), AFSExempt, AFSLodgeAccountabilitySupplement},
+		{"owner managed non-profit", CompanyTypeNonProfit, facts(20, false, true), AFSIndependentReview, AFSLodgeAccountabilitySupplement},
+		{"small company", CompanyTypePrivate, facts(20, false, false), AFSIndependentReview, AFSLodgeAccountabilitySupplement},
+	}
+	for _, tt := range tests {
+		o := DetermineAFSObligation(tt.companyType, tt.facts)
+		assert.Equal(t, tt.assurance, o.Assurance, tt.name)
+		assert.Equal(t, tt.lodge, o.Lodge, tt.name)
+		assert.NotEmpty(t, o.Reason, tt.name)
+	}
+
+	o := DetermineAFSObligation(CompanyTypePrivate, facts(150, true, false))
+	assert.Equal(t, "registered auditor or chartered accountant", o.Reviewer)
+	assert.Equal(t, map[string]interface{}{
+		"public_interest_score": 150,
+		"afs_assurance":         AFSIndependentReview,
+		"financial_statements":  AFSLodgeAccountabilitySupplement,
+		"financial_year_end":    "2026-02-28",
+	}, o.AnnualReturnFields())
+}
+
+func TestAFSSubmissionDeadline(t *testing.T) {
+	fye := time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC)
+	audited := AFSObligation{FinancialYearEnd: fye, Lodge: AFSLodgeFinancialStatements}
+
+	// Statements prepared by 28 August are lodged with the annual return due after that.
+	deadline, ok := AFSSubmissionDeadline(audited, time.Date(2026, time.May, 12, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, "afs_submission", deadline.DeadlineType)
+	assert.Equal(t, time.Date(2027, time.May, 12, 0, 0, 0, 0, time.UTC), deadline.DueDate)
+
+	deadline, _ = AFSSubmissionDeadline(audited, time.Date(2029, time.October, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), deadline.DueDate)
+
+	deadline, _ = AFSSubmissionDeadline(audited, time.Time{})
+	assert.Equal(t, time.Date(2026, time.August, 28, 0, 0, 0, 0, time.UTC), deadline.DueDate)
+
+	_, ok = AFSSubmissionDeadline(AFSObligation{FinancialYearEnd: fye, Lodge: AFSLodgeAccountabilitySupplement}, time.Time{})
+	assert.False(t, ok)
+}
+
+func TestAnnualReturnAFSObligation(t *testing.T) {
+	o, err := AnnualReturnAFSObligation("2020/123456/07", map[string]interface{}{"company_name": "Acme"})
+	require.NoError(t, err)
+	assert.Nil(t, o)
+
+	data := map[string]interface{}{
+		FieldPublicInterest: map[string]interface{}{
+			"financial_year_end": "2026-02-28T00:00:00Z",
+			"public_interest":    map[string]interface{}{"average_employees": 90, "turnover": 25_000_000.0},
+		},
+	}
+	o, err = AnnualReturnAFSObligation("2020/123456/07", data)
+	require.NoError(t, err)
+	assert.Equal(t, 115, o.PublicInterestScore.Total)
+	assert.Equal(t, AFSAudit, o.Assurance)
+
+	data[FieldPublicInterest] = map[string]interface{}{"public_interest": map[string]interface{}{"average_employees": 5}}
+	_, err = AnnualReturnAFSObligation("2020/123456/07", data)
+	assert.ErrorContains(t, err, "financial year end is required")
+}
@@ -0,0 +1,252 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReinstatementApprovalTimeout is how long the customer has to accept a catch-up plan.
+const ReinstatementApprovalTimeout = 7 * 24 * time.Hour
+
+// ReinstatementInput is the input for ReinstatementWorkflow.
+type ReinstatementInput struct {
+	UserID    string `json:"user_id"`
+	CompanyID string `json:"company_id"`
+	IsUrgent  bool   `json:"is_urgent,omitempty"`
+}
+
+// CatchUpFilingResult is how one filing of a catch-up plan went.
+type CatchUpFilingResult struct {
+	CatchUpFiling
+	TransactionID   string `json:"transaction_id,omitempty"`
+	FilingReference string `json:"filing_reference,omitempty"`
+	// Outcome is CIPC's decision, or "failed" when the filing was not submitted.
+	Outcome      string `json:"outcome,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// ReinstatementResult is the result of ReinstatementWorkflow.
+type ReinstatementResult struct {
+	Success bool   `json:"success"`
+	Status  string `json:"status"`
+	// CompanyStatus is the company's status when the workflow finished.
+	CompanyStatus string                `json:"company_status"`
+	Amount        float64               `json:"amount,omitempty"`
+	Filings       []CatchUpFilingResult `json:"filings,omitempty"`
+}
+
+// ReinstatementWorkflow restores a company that is being, or has been, deregistered for not
+// filing annual returns. It quotes the catch-up plan, and once the customer replies FILE it files
+// a reinstatement application if the company has been deregistered and then every outstanding
+// annual return. Each filing is charged and submitted like any other pay-as-you-go filing and
+// followed until CIPC decides on it.
+func ReinstatementWorkflow(ctx workflow.Context, input ReinstatementInput) (*ReinstatementResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting ReinstatementWorkflow", "CompanyID", input.CompanyID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 2,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	// Step 1: Reassess the company
+	var change CompanyStatusChange
+	if err := workflow.ExecuteActivity(ctx, RefreshCompanyStatusActivity, input.CompanyID).Get(ctx, &change); err != nil {
+		return nil, fmt.Errorf("failed to assess company status: %w", err)
+	}
+	result := &ReinstatementResult{CompanyStatus: change.Assessment.Status}
+	plan := CatchUpPlan(change.Assessment)
+	if len(plan) == 0 {
+		notifyUser(ctx, input.UserID, fmt.Sprintf("✅ *%s is in good standing*\n\nThere are no outstanding annual returns to catch up on.", change.Name))
+		result.Success, result.Status = true, "nothing_outstanding"
+		return result, nil
+	}
+
+	// Step 2: Quote the plan and wait for the customer to accept it
+	quotes := map[string]*FilingFeeQuote{}
+	lines := make([]string, 0, len(plan))
+	for _, filing := range plan {
+		quote, ok := quotes[filing.ServiceType]
+		if !ok {
+			if err := workflow.ExecuteActivity(ctx, QuoteFilingFeeActivity, input.UserID, filing.ServiceType, input.IsUrgent).Get(ctx, &quote); err != nil {
+				return nil, fmt.Errorf("failed to quote %s: %w", filing.ServiceType, err)
+			}
+			if quote == nil {
+				return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("%s is not priced", filing.ServiceType), "ServiceNotPriced", nil)
+			}
+			quotes[filing.ServiceType] = quote
+		}
+		result.Amount += quote.Amount
+		lines = append(lines, fmt.Sprintf("• %s: R%.2f", capitalise(filing.Description()), quote.Amount))
+	}
+	notifyUser(ctx, input.UserID, fmt.Sprintf("📋 *Getting %s back into good standing*\n\n%s.\n\n%s\n\nTotal: R%.2f\n\nReply 'FILE' and we'll file them in this order; you'll pay for each filing as it goes.",
+		change.Name, capitalise(change.Assessment.Reason), strings.Join(lines, "\n"), result.Amount))
+	conversationID := openConversation(ctx, ConversationPrompt{
+		UserID:      input.UserID,
+		Awaiting:    ConversationAwaitingFile,
+		Description: fmt.Sprintf("Catch-up filings for %s (reply FILE)", change.RegistrationNumber),
+		ExpiresAt:   workflow.Now(ctx).Add(ReinstatementApprovalTimeout),
+	})
+	approved, _ := workflow.GetSignalChannel(ctx, FileConfirmSignalName).ReceiveWithTimeout(ctx, ReinstatementApprovalTimeout, nil)
+	endConversation(ctx, conversationID)
+	if !approved {
+		logger.Info("Customer did not accept the catch-up plan", "CompanyID", input.CompanyID)
+		result.Status = "not_approved"
+		return result, nil
+	}
+
+	// Step 3: File the plan. Annual returns can only be filed once a deregistered company has
+	// been reinstated, so a rejected filing stops the rest.
+	for _, filing := range plan {
+		filed, err := fileCatchUp(ctx, input, change, filing)
+		if err != nil {
+			return nil, err
+		}
+		result.Filings = append(result.Filings, *filed)
+		if filed.Outcome != CIPCOutcomeApproved {
+			break
+		}
+	}
+
+	// Step 4: Reassess the company and tell the customer where it stands
+	if err := workflow.ExecuteActivity(ctx, RefreshCompanyStatusActivity, input.CompanyID).Get(ctx, &change); err != nil {
+		return nil, fmt.Errorf("failed to reassess company status: %w", err)
+	}
+	result.CompanyStatus = change.Assessment.Status
+	last := result.Filings[len(result.Filings)-1]
+	switch last.Outcome {
+	case CIPCOutcomeApproved:
+		result.Success, result.Status = true, "completed"
+		notifyUser(ctx, input.UserID, CompanyStatusAlertMessage(change))
+	case CIPCOutcomePending:
+		result.Status = "awaiting_cipc"
+		notifyUser(ctx, input.UserID, fmt.Sprintf("⏳ *CIPC hasn't decided on the %s for %s yet*\n\nReference: %s\n\nReply 'HELP' and we'll follow it up with CIPC.",
+			last.Description(), change.Name, last.FilingReference))
+	case CIPCOutcomeRejected:
+		result.Status = "rejected"
+		notifyUser(ctx, input.UserID, fmt.Sprintf("❌ *CIPC rejected the %s for %s*\n\nReference: %s\nReason: %s\n\nReply 'HELP' and we'll sort it out with you.",
+			last.Description(), change.Name, last.FilingReference, last.ErrorMessage))
+	default:
+		result.Status = "failed"
+	}
+	return result, nil
+}
+
+// fileCatchUp charges for and files one filing of a catch-up plan and follows it until CIPC
+// decides on it. An approved filing is recorded against the company.
+func fileCatchUp(ctx workflow.Context, input ReinstatementInput, company CompanyStatusChange, filing CatchUpFiling) (*CatchUpFilingResult, error) {
+	filed := &CatchUpFilingResult{CatchUpFiling: filing}
+	filingData := map[string]interface{}{
+		"company_id":   company.CompanyID,
+		"company_name": company.Name,
+	}
+	if filing.ServiceType == "annual_return" {
+		filingData["deadline_id"] = filing.DeadlineID
+		filingData["annual_return_due_date"] = filing.DueDate.Format("2006-01-02")
+	}
+	txInput := CreatePaygTransactionInput{
+		UserID:      input.UserID,
+		ServiceType: filing.ServiceType,
+		IsUrgent:    input.IsUrgent,
+		FilingData:  filingData,
+	}
+	if err := workflow.ExecuteActivity(ctx, CreatePaygTransactionActivity, txInput).Get(ctx, &filed.TransactionID); err != nil {
+		return nil, fmt.Errorf("failed to create %s transaction: %w", filing.ServiceType, err)
+	}
+
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "filing-" + strings.ReplaceAll(filing.ServiceType, "_", "-") + "-" + filed.TransactionID,
+		TaskQueue:  FilingTaskQueue(input.IsUrgent),
+	}
+	var result FilingWorkflowResult
+	err := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), CombinedFilingWorkflow, FilingWorkflowInput{
+		TransactionID:    filed.TransactionID,
+		UserID:           input.UserID,
+		ServiceType:      filing.ServiceType,
+		FilingData:       filingData,
+		CompanyRegNumber: company.RegistrationNumber,
+		IsUrgent:         input.IsUrgent,
+		CallerFollowsUp:  true,
+	}).Get(ctx, &result)
+	if err != nil {
+		return nil, fmt.Errorf("%s filing failed: %w", filing.ServiceType, err)
+	}
+	if !result.Success {
+		filed.Outcome, filed.ErrorMessage = "failed", result.ErrorMessage
+		return filed, nil
+	}
+	filed.FilingReference = result.FilingReference
+
+	var decision CIPCFilingOutcome
+	ccwo := workflow.ChildWorkflowOptions{
+		WorkflowID: "cipc-confirmation-" + filed.TransactionID,
+	}
+	err = workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, ccwo), CIPCConfirmationWorkflow, CIPCConfirmationInput{
+		TransactionID:    filed.TransactionID,
+		UserID:           input.UserID,
+		ServiceType:      filing.ServiceType,
+		CompanyRegNumber: company.RegistrationNumber,
+		Reference:        result.FilingReference,
+		Silent:           true,
+	}).Get(ctx, &decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to follow up %s with CIPC: %w", filing.ServiceType, err)
+	}
+	filed.Outcome, filed.ErrorMessage = decision.Status, decision.RejectionReason
+	if decision.Status != CIPCOutcomeApproved {
+		return filed, nil
+	}
+	if err := workflow.ExecuteActivity(ctx, RecordCatchUpFilingActivity, company.CompanyID, filing).Get(ctx, nil); err != nil {
+		return nil, fmt.Errorf("failed to record approved %s: %w", filing.ServiceType, err)
+	}
+	return filed, nil
+}
+
+// RefreshCompanyStatusActivity reassesses a company's status and records any change. Telling
+// the customer is left to the caller.
+func RefreshCompanyStatusActivity(ctx context.Context, companyID string) (*CompanyStatusChange, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return RefreshCompanyStatus(ctx, db, companyID, time.Now())
+}
+
+// RecordCatchUpFilingActivity records a catch-up filing CIPC approved: the annual return's
+// deadline is completed, and a reinstated company is back in business on CIPC's records.
+func RecordCatchUpFilingActivity(ctx context.Context, companyID string, filing CatchUpFiling) error {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	switch filing.ServiceType {
+	case "annual_return":
+		_, err = db.ExecContext(ctx, `
+			UPDATE compliance_deadlines SET status = 'completed' WHERE id = $1
+		`, filing.DeadlineID)
+	case ReinstatementServiceType:
+		_, err = db.ExecContext(ctx, `
+			UPDATE companies SET cipc_status = 'In Business', updated_at = NOW() WHERE id = $1
+		`, companyID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", filing.ServiceType, err)
+	}
+	return nil
+}
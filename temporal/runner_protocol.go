@@ -0,0 +1,277 @@
+package temporal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// RunnerProtocolVersion is the version of the JSON-lines protocol spoken with cipc_runner.py.
+// The runner rejects requests with a version it does not understand.
+const RunnerProtocolVersion = 1
+
+// Event types emitted by the runner, one JSON object per stdout line.
+const (
+	RunnerEventProgress = "progress"
+	RunnerEventArtifact = "artifact"
+	RunnerEventResult   = "result"
+	RunnerEventError    = "error"
+)
+
+// Actions the runner can perform for a request.
+const (
+	// RunnerActionFile logs in, completes the forms and submits the filing.
+	RunnerActionFile = "file"
+	// RunnerActionVerify checks whether an earlier attempt's submission reached CIPC, without submitting.
+	RunnerActionVerify = "verify"
+	// RunnerActionStatus looks up the outcome of a submitted filing on CIPC.
+	RunnerActionStatus = "status"
+)
+
+// Error codes reported by the runner. Known codes decide whether an error is retried; for any
+// other code the runner's own retryable flag is used.
+const (
+	RunnerErrPortalUnavailable  = "portal_unavailable"
+	RunnerErrPortalTimeout      = "portal_timeout"
+	RunnerErrSessionExpired     = "session_expired"
+	RunnerErrInvalidCredentials = "invalid_credentials"
+	RunnerErrOTPRejected        = "otp_rejected"
+	RunnerErrValidationFailed   = "validation_failed"
+	RunnerErrDuplicateFiling    = "duplicate_submission"
+	RunnerErrUnsupportedService = "unsupported_service"
+	RunnerErrProtocol           = "protocol_error"
+	RunnerErrCrashed            = "runner_crashed"
+	RunnerErrCancelled          = "cancelled"
+	RunnerErrNotSubmitted       = "not_submitted"
+)
+
+var transientRunnerErrors = map[string]bool{
+	RunnerErrPortalUnavailable: true,
+	RunnerErrPortalTimeout:     true,
+	RunnerErrSessionExpired:    true,
+	RunnerErrCrashed:           true,
+	RunnerErrCancelled:         true,
+}
+
+var permanentRunnerErrors = map[string]bool{
+	RunnerErrInvalidCredentials: true,
+	RunnerErrOTPRejected:        true,
+	RunnerErrValidationFailed:   true,
+	RunnerErrDuplicateFiling:    true,
+	RunnerErrUnsupportedService: true,
+	RunnerErrProtocol:           true,
+	RunnerErrNotSubmitted:       true,
+}
+
+// runnerCancelGrace is how long the runner gets to close its browser after SIGTERM before it is killed.
+const runnerCancelGrace = 10 * time.Second
+
+// RunnerRequest is written to the runner's stdin as a single JSON line.
+type RunnerRequest struct {
+	ProtocolVersion int                    `json:"v"`
+	RequestID       string                 `json:"request_id"`
+	ServiceType     string                 `json:"service_type"`
+	ClientData      map[string]interface{} `json:"client_data"`
+	Action          string                 `json:"action,omitempty"`
+	// Resume carries the last checkpoint of a previous attempt, if any.
+	Resume *FilingCheckpoint `json:"resume,omitempty"`
+}
+
+// RunnerArtifact is a file produced during a run, such as a screenshot or a CIPC receipt.
+type RunnerArtifact struct {
+	Kind        string `json:"kind"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+}
+
+// RunnerArtifactConfirmation is the kind of the confirmation CIPC issues for an approved filing.
+const RunnerArtifactConfirmation = "cipc_confirmation"
+
+// RunnerError is a typed failure reported by the runner.
+type RunnerError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (e *RunnerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// RunnerEvent is one line of runner output.
+type RunnerEvent struct {
+	ProtocolVersion int                    `json:"v"`
+	Type            string                 `json:"type"`
+	Step            string                 `json:"step,omitempty"`
+	Message         string                 `json:"message,omitempty"`
+	Details         map[string]interface{} `json:"details,omitempty"`
+	Artifact        *RunnerArtifact        `json:"artifact,omitempty"`
+	Result          *FilingResult          `json:"result,omitempty"`
+	Error           *RunnerError           `json:"error,omitempty"`
+}
+
+// RunnerOutcome is everything a run produced.
+type RunnerOutcome struct {
+	Result    FilingResult     `json:"result"`
+	Artifacts []RunnerArtifact `json:"artifacts,omitempty"`
+}
+
+// RunnerClient starts cipc_runner.py in JSON-lines mode and decodes its event stream.
+type RunnerClient struct {
+	Python     string
+	ScriptPath string
+	// OnEvent, if set, is called for every progress and artifact event as it arrives.
+	OnEvent func(RunnerEvent)
+	// OnLog, if set, receives stdout lines that are not protocol events and all stderr output.
+	OnLog func(line string)
+}
+
+// NewRunnerClient returns a client for the runner configured in the environment.
+func NewRunnerClient() *RunnerClient {
+	return &RunnerClient{Python: getRunnerPython(), ScriptPath: getRunnerScriptPath()}
+}
+
+// Run executes one request. Cancelling ctx sends SIGTERM to the runner and kills it after a grace
+// period. Failures are returned as Temporal application errors typed by runner error code, marked
+// non-retryable when the runner says the problem is permanent.
+func (c *RunnerClient) Run(ctx context.Context, req RunnerRequest) (*RunnerOutcome, error) {
+	req.ProtocolVersion = RunnerProtocolVersion
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal runner request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, c.Python, c.ScriptPath, "--jsonl")
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = runnerCancelGrace
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, temporal.NewApplicationErrorWithCause("failed to start CIPC runner", RunnerErrCrashed, err)
+	}
+
+	outcome, streamErr := ReadRunnerEvents(stdout, c.OnEvent, c.OnLog)
+	// Drain anything left so the process can exit even if the stream was malformed.
+	_, _ = io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+
+	if c.OnLog != nil {
+		for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+			if line != "" {
+				c.OnLog(line)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var runnerErr *RunnerError
+	if errors.As(streamErr, &runnerErr) {
+		return nil, runnerApplicationError(runnerErr)
+	}
+	if streamErr != nil {
+		return nil, runnerApplicationError(&RunnerError{Code: RunnerErrProtocol, Message: streamErr.Error()})
+	}
+	if waitErr != nil && outcome == nil {
+		return nil, runnerApplicationError(&RunnerError{Code: RunnerErrCrashed, Message: waitErr.Error()})
+	}
+	if outcome == nil {
+		return nil, runnerApplicationError(&RunnerError{Code: RunnerErrProtocol, Message: "runner exited without a result"})
+	}
+	return outcome, nil
+}
+
+// ReadRunnerEvents decodes a runner event stream until a result or error event. Lines that are not
+// JSON objects are passed to onLog rather than breaking the parse. A runner-reported failure is
+// returned as a *RunnerError.
+func ReadRunnerEvents(r io.Reader, onEvent func(RunnerEvent), onLog func(string)) (*RunnerOutcome, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var artifacts []RunnerArtifact
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event RunnerEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil || event.Type == "" {
+			if onLog != nil {
+				onLog(line)
+			}
+			continue
+		}
+		if event.ProtocolVersion != RunnerProtocolVersion {
+			return nil, fmt.Errorf("runner speaks protocol version %d, expected %d", event.ProtocolVersion, RunnerProtocolVersion)
+		}
+
+		switch event.Type {
+		case RunnerEventProgress:
+			if onEvent != nil {
+				onEvent(event)
+			}
+		case RunnerEventArtifact:
+			if event.Artifact != nil {
+				artifacts = append(artifacts, *event.Artifact)
+			}
+			if onEvent != nil {
+				onEvent(event)
+			}
+		case RunnerEventResult:
+			if event.Result == nil {
+				return nil, fmt.Errorf("result event without a result")
+			}
+			return &RunnerOutcome{Result: *event.Result, Artifacts: artifacts}, nil
+		case RunnerEventError:
+			if event.Error == nil {
+				return nil, fmt.Errorf("error event without an error")
+			}
+			return nil, event.Error
+		default:
+			if onLog != nil {
+				onLog(line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read runner output: %w", err)
+	}
+	return nil, nil
+}
+
+// runnerApplicationError converts a runner error into a Temporal application error so the
+// activity retry policy can tell transient portal trouble from permanent rejections.
+func runnerApplicationError(e *RunnerError) error {
+	retryable := e.Retryable
+	if transientRunnerErrors[e.Code] {
+		retryable = true
+	}
+	if permanentRunnerErrors[e.Code] {
+		retryable = false
+	}
+	if !retryable {
+		return temporal.NewNonRetryableApplicationError(e.Message, e.Code, nil)
+	}
+	return temporal.NewApplicationError(e.Message, e.Code)
+}
@@ -0,0 +1,111 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestReadRunnerEvents_ToleratesNoise(t *testing.T) {
+	stream := strings.Join([]string{
+		`DevTools listening on ws://127.0.0.1:9222`,
+		`{"v":1,"type":"progress","step":"logged_in","message":"Opened session"}`,
+		`{"v":1,"type":"artifact","artifact":{"kind":"screenshot","path":"/tmp/a.png","content_type":"image/png"}}`,
+		`{"not":"an event"}`,
+		`{"v":1,"type":"result","result":{"status":"success","reference_number":"AR20250101"}}`,
+	}, "\n")
+
+	var events []RunnerEvent
+	var logs []string
+	outcome, err := ReadRunnerEvents(strings.NewReader(stream), func(e RunnerEvent) { events = append(events, e) }, func(l string) { logs = append(logs, l) })
+
+	require.NoError(t, err)
+	require.NotNil(t, outcome)
+	assert.Equal(t, "AR20250101", outcome.Result.ReferenceNumber)
+	assert.Len(t, outcome.Artifacts, 1)
+	assert.Len(t, events, 2)
+	assert.Len(t, logs, 2)
+}
+
+func TestReadRunnerEvents_ReturnsTypedError(t *testing.T) {
+	stream := `{"v":1,"type":"error","error":{"code":"otp_rejected","message":"OTP invalid"}}`
+
+	_, err := ReadRunnerEvents(strings.NewReader(stream), nil, nil)
+
+	var runnerErr *RunnerError
+	require.True(t, errors.As(err, &runnerErr))
+	assert.Equal(t, RunnerErrOTPRejected, runnerErr.Code)
+}
+
+func TestReadRunnerEvents_RejectsOtherProtocolVersions(t *testing.T) {
+	_, err := ReadRunnerEvents(strings.NewReader(`{"v":2,"type":"result","result":{}}`), nil, nil)
+	assert.ErrorContains(t, err, "protocol version 2")
+}
+
+func TestRunnerApplicationError_Retryability(t *testing.T) {
+	var appErr *temporal.ApplicationError
+
+	require.True(t, errors.As(runnerApplicationError(&RunnerError{Code: RunnerErrPortalTimeout}), &appErr))
+	assert.False(t, appErr.NonRetryable())
+
+	require.True(t, errors.As(runnerApplicationError(&RunnerError{Code: RunnerErrValidationFailed, Retryable: true}), &appErr))
+	assert.True(t, appErr.NonRetryable())
+	assert.Equal(t, RunnerErrValidationFailed, appErr.Type())
+
+	require.True(t, errors.As(runnerApplicationError(&RunnerError{Code: "captcha_changed"}), &appErr))
+	assert.True(t, appErr.NonRetryable())
+}
+
+// fakeRunner writes a shell script that stands in for cipc_runner.py.
+func fakeRunner(t *testing.T, script string) *RunnerClient {
+	path := filepath.Join(t.TempDir(), "runner.sh")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755))
+	return &RunnerClient{Python: "sh", ScriptPath: path}
+}
+
+func TestRunnerClient_Run(t *testing.T) {
+	runner := fakeRunner(t, `read request
+echo "noise on stderr" >&2
+echo '{"v":1,"type":"progress","step":"submitted"}'
+echo '{"v":1,"type":"result","result":{"status":"success","reference_number":"BO1"}}'
+`)
+	var logs []string
+	runner.OnLog = func(line string) { logs = append(logs, line) }
+
+	outcome, err := runner.Run(context.Background(), RunnerRequest{ServiceType: "beneficial_ownership"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "BO1", outcome.Result.ReferenceNumber)
+	assert.Equal(t, []string{"noise on stderr"}, logs)
+}
+
+func TestRunnerClient_Run_Crash(t *testing.T) {
+	runner := fakeRunner(t, "exit 3\n")
+
+	_, err := runner.Run(context.Background(), RunnerRequest{ServiceType: "annual_return"})
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, RunnerErrCrashed, appErr.Type())
+	assert.False(t, appErr.NonRetryable())
+}
+
+func TestRunnerClient_Run_Cancellation(t *testing.T) {
+	runner := fakeRunner(t, "exec sleep 30\n")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := runner.Run(ctx, RunnerRequest{ServiceType: "annual_return"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
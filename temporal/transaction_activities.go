@@ -0,0 +1,115 @@
+package temporal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// CreatePaygTransactionInput describes a pay-as-you-go filing the user has asked us to perform.
+type CreatePaygTransactionInput struct {
+	UserID      string                 `json:"user_id"`
+	ServiceType string                 `json:"service_type"`
+	IsUrgent    bool                   `json:"is_urgent"`
+	FilingData  map[string]interface{} `json:"filing_data"`
+}
+
+// CreatePaygTransactionActivity prices a service from pricing_config and records a pending
+// payg_transactions row, returning its ID.
+func CreatePaygTransactionActivity(ctx context.Context, input CreatePaygTransactionInput) (string, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Creating PAYG transaction", "user_id", input.UserID, "service_type", input.ServiceType)
+
+	filingData, err := json.Marshal(input.FilingData)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filing data: %w", err)
+	}
+
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	quote, err := priceFiling(ctx, db, input.ServiceType, input.IsUrgent)
+	if err != nil {
+		return "", err
+	}
+
+	var transactionID string
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO payg_transactions (user_id, service_type, amount, status, urgency_fee, filing_data)
+		VALUES ($1, $2, $3, 'pending', $4, $5)
+		RETURNING id
+	`, input.UserID, input.ServiceType, quote.Amount, input.IsUrgent, filingData).Scan(&transactionID)
+	if err != nil {
+		return "", err
+	}
+
+	return transactionID, nil
+}
+
+// FilingFeeQuote is what a pay-as-you-go filing costs.
+type FilingFeeQuote struct {
+	ServiceType string  `json:"service_type"`
+	BasePrice   float64 `json:"base_price"`
+	UrgencyFee  float64 `json:"urgency_fee,omitempty"`
+	Amount      float64 `json:"amount"`
+	// AvailableCredit is the customer's unredeemed account credit.
+	AvailableCredit float64 `json:"available_credit,omitempty"`
+}
+
+// ErrServiceNotPriced is returned for services without a pricing_config row.
+var ErrServiceNotPriced = errors.New("no pricing configured for this service")
+
+// priceFiling prices a service from pricing_config.
+func priceFiling(ctx context.Context, db *sql.DB, serviceType string, urgent bool) (*FilingFeeQuote, error) {
+	var basePrice, urgencyMultiplier float64
+	err := db.QueryRowContext(ctx, `
+		SELECT base_price, urgency_multiplier FROM pricing_config WHERE service_type = $1
+	`, serviceType).Scan(&basePrice, &urgencyMultiplier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotPriced, serviceType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pricing for %s: %w", serviceType, err)
+	}
+
+	quote := &FilingFeeQuote{ServiceType: serviceType, BasePrice: basePrice, Amount: basePrice}
+	if urgent {
+		quote.Amount = basePrice * urgencyMultiplier
+		quote.UrgencyFee = quote.Amount - basePrice
+	}
+	return quote, nil
+}
+
+// QuoteFilingFeeActivity prices a filing without creating a transaction and reports the
+// customer's available account credit. It returns nil for services that are not offered.
+func QuoteFilingFeeActivity(ctx context.Context, userID, serviceType string, urgent bool) (*FilingFeeQuote, error) {
+	db, err := sql.Open("pgx", getDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	quote, err := priceFiling(ctx, db, serviceType, urgent)
+	if errors.Is(err, ErrServiceNotPriced) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" {
+		err = db.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(amount), 0) FROM account_credits WHERE user_id = $1 AND redeemed_at IS NULL
+		`, userID).Scan(&quote.AvailableCredit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load account credit: %w", err)
+		}
+	}
+	return quote, nil
+}
@@ -0,0 +1,171 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// InboundWhatsAppRequest is posted by the WhatsApp bridge for every message a user sends.
+type InboundWhatsAppRequest struct {
+	From    string `json:"from"`
+	Message string `json:"message"`
+	// MediaURL is set when the message carries an attachment; Message is then its caption.
+	MediaURL string `json:"media_url,omitempty"`
+}
+
+// InboundWhatsAppResponse says what the router did with a message.
+type InboundWhatsAppResponse struct {
+	Action     string `json:"action"`
+	WorkflowID string `json:"workflow_id,omitempty"`
+}
+
+func (s *APIServer) registerWhatsAppRoutes(mux *http.ServeMux) {
+	// Only the bridge may report inbound messages: the sender's number is taken on its word.
+	mux.HandleFunc("POST /whatsapp/inbound", requireInternalAPIKey(s.inboundWhatsAppHandler))
+}
+
+func (s *APIServer) inboundWhatsAppHandler(w http.ResponseWriter, r *http.Request) {
+	var req InboundWhatsAppRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.From == "" {
+		writeError(w, http.StatusBadRequest, "from is required")
+		return
+	}
+
+	msg := ClassifyInboundMessage(req.Message)
+	if req.MediaURL != "" {
+		msg = ClassifyInboundMedia(req.MediaURL, req.Message)
+	}
+	resp, err := s.routeInboundMessage(r.Context(), req.From, msg)
+	if err != nil {
+		log.Printf("Error routing WhatsApp message from %s: %s", req.From, err)
+		writeError(w, http.StatusInternalServerError, "Unable to route message")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// routeInboundMessage delivers one message to the workflow waiting on it, starts one, or replies.
+func (s *APIServer) routeInboundMessage(ctx context.Context, phone string, msg InboundMessage) (*InboundWhatsAppResponse, error) {
+	contexts, err := ListOpenConversations(ctx, s.DB, phone)
+	if err != nil {
+		return nil, err
+	}
+	decision := RouteInboundMessage(msg, contexts, time.Now())
+	resp := &InboundWhatsAppResponse{Action: decision.Action}
+
+	switch decision.Action {
+	case RouteSignal:
+		resp.WorkflowID = decision.Context.WorkflowID
+		err = s.Temporal.SignalWorkflow(ctx, decision.Context.WorkflowID, decision.Context.RunID, decision.Name, decision.Payload)
+		if err != nil {
+			return s.handleStaleConversation(ctx, phone, decision, err)
+		}
+
+	case RouteUpdate:
+		resp.WorkflowID = decision.Context.WorkflowID
+		reply, err := s.updateConversation(ctx, decision)
+		if err != nil {
+			return s.handleStaleConversation(ctx, phone, decision, err)
+		}
+		if err := postWhatsAppMessage(ctx, phone, reply); err != nil {
+			return nil, err
+		}
+
+	case RouteSignalWithStart:
+		resp.WorkflowID = "onboarding-" + phone
+		_, err = s.Temporal.SignalWithStartWorkflow(ctx, resp.WorkflowID, decision.Name, decision.Payload,
+			client.StartWorkflowOptions{ID: resp.WorkflowID, TaskQueue: TaskQueue}, OnboardingWorkflow, phone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start onboarding: %w", err)
+		}
+
+	case RouteReply:
+		if err := postWhatsAppMessage(ctx, phone, decision.Reply); err != nil {
+			return nil, err
+		}
+
+	case RouteStatus:
+		reply, err := s.filingStatusReply(ctx, phone)
+		if err != nil {
+			return nil, err
+		}
+		if err := postWhatsAppMessage(ctx, phone, reply); err != nil {
+			return nil, err
+		}
+
+	case RouteCheck:
+		reply, err := s.filingCheckReply(ctx, phone, msg.Text)
+		if err != nil {
+			return nil, err
+		}
+		if err := postWhatsAppMessage(ctx, phone, reply); err != nil {
+			return nil, err
+		}
+
+	case RouteAI:
+		resp.WorkflowID = fmt.Sprintf("ai-whatsapp-%s-%d", phone, time.Now().UnixNano())
+		_, err = s.Temporal.ExecuteWorkflow(ctx, client.StartWorkflowOptions{ID: resp.WorkflowID, TaskQueue: TaskQueue},
+			AIWhatsAppWorkflow, phone, msg.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start AI workflow: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// updateConversation sends an Update and turns its outcome, including a validator rejection,
+// into the reply for the user.
+func (s *APIServer) updateConversation(ctx context.Context, decision RouteDecision) (string, error) {
+	var args []interface{}
+	if decision.Payload != nil {
+		args = append(args, decision.Payload)
+	}
+	handle, err := s.Temporal.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   decision.Context.WorkflowID,
+		RunID:        decision.Context.RunID,
+		UpdateName:   decision.Name,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err == nil {
+		var result OTPUpdateResult
+		if err = handle.Get(ctx, &result); err == nil {
+			return "✅ " + result.Message, nil
+		}
+	}
+
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		// Rejected by the workflow's validator; the workflow is still waiting.
+		return "⚠️ " + appErr.Message(), nil
+	}
+	return "", err
+}
+
+// handleStaleConversation closes a conversation whose workflow has already finished and tells the
+// user, so the next reply is routed afresh.
+func (s *APIServer) handleStaleConversation(ctx context.Context, phone string, decision RouteDecision, err error) (*InboundWhatsAppResponse, error) {
+	var notFound *serviceerror.NotFound
+	if !errors.As(err, &notFound) {
+		return nil, err
+	}
+	if err := closeConversation(ctx, s.DB, decision.Context.ID); err != nil {
+		return nil, err
+	}
+	reply := "That request has already finished or expired. Send 'START' if you'd like to begin again."
+	if err := postWhatsAppMessage(ctx, phone, reply); err != nil {
+		return nil, err
+	}
+	return &InboundWhatsAppResponse{Action: RouteReply}, nil
+}
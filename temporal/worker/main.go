@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/tls"
 	"log"
+	"time"
 
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -11,6 +12,10 @@ import (
 )
 
 func main() {
+	if err := temporal.ValidateConfig(); err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+
 	// The client and worker are heavyweight objects that should be created once per process.
 	// Initialize client connection
 	clientOptions := client.Options{
@@ -25,7 +30,10 @@ func main() {
 	}
 	defer c.Close()
 
-	w := worker.New(c, "CIPC_TASK_QUEUE", worker.Options{})
+	w := worker.New(c, "CIPC_TASK_QUEUE", worker.Options{
+		// Give in-flight CIPC filings time to checkpoint before the worker exits.
+		WorkerStopTimeout: 30 * time.Second,
+	})
 
 	// Register the Onboarding workflow and its activities
 	w.RegisterWorkflow(temporal.OnboardingWorkflow)
@@ -40,8 +48,35 @@ func main() {
 	w.RegisterActivity(temporal.ExtractDocumentDataActivity)
 	w.RegisterActivity(temporal.RequestOTPActivity)
 	w.RegisterActivity(temporal.SubmitToCIPCActivity)
+	w.RegisterActivity(temporal.RecordFilingWorkflowActivity)
+	w.RegisterActivity(temporal.CompensateFilingActivity)
+	w.RegisterActivity(temporal.SettleRetriedFilingActivity)
 	w.RegisterActivity(temporal.UpdateUserRecordsActivity)
 	w.RegisterActivity(temporal.SendWhatsAppMessageActivity) // Generic message activity
+	w.RegisterActivity(temporal.EscalateUrgentFilingActivity)
+	w.RegisterActivity(temporal.CreditUrgencyFeeActivity)
+	w.RegisterActivity(temporal.RecordFilingSLAActivity)
+
+	// Register the automated (CIPC Runner) filing workflow and its activities
+	w.RegisterWorkflow(temporal.AutomatedFilingWorkflow)
+	w.RegisterActivity(temporal.ExecuteAutomatedFilingActivity)
+	w.RegisterActivity(temporal.UpdateFilingRecordsActivity)
+	w.RegisterActivity(temporal.SendFilingSubmittedActivity)
+	w.RegisterActivity(temporal.AlertOperationsTeamActivity)
+	w.RegisterActivity(temporal.ExpireOpsTaskActivity)
+	w.RegisterActivity(temporal.CanaryRolloutActivity)
+	w.RegisterActivity(temporal.RecordCanaryOutcomeActivity)
+	w.RegisterActivity(temporal.RouteToManualFilingActivity)
+
+	// Register the CIPC confirmation workflow that follows submitted filings
+	w.RegisterWorkflow(temporal.CIPCConfirmationWorkflow)
+	w.RegisterActivity(temporal.RecordCIPCSubmissionActivity)
+	w.RegisterActivity(temporal.PollCIPCFilingActivity)
+	w.RegisterActivity(temporal.RecordCIPCOutcomeActivity)
+	w.RegisterActivity(temporal.OpenUndecidedFilingTaskActivity)
+
+	// Register the filing batch workflow that fans out CombinedFilingWorkflow children
+	w.RegisterWorkflow(temporal.FilingBatchWorkflow)
 
 	// Register the Payment Recovery workflow and its activities
 	w.RegisterWorkflow(temporal.PaymentRecoveryWorkflow)
@@ -57,6 +92,113 @@ func main() {
 	w.RegisterActivity(temporal.CheckUpcomingDeadlinesActivity)
 	w.RegisterActivity(temporal.SendDeadlineNotificationActivity)
 
+	// Register beneficial ownership activities
+	w.RegisterActivity(temporal.CalculateBeneficialOwnershipActivity)
+	w.RegisterWorkflow(temporal.BeneficialOwnershipChangeWorkflow)
+	w.RegisterActivity(temporal.LoadBeneficialOwnershipSnapshotActivity)
+	w.RegisterActivity(temporal.CreateBeneficialOwnershipDeadlineActivity)
+	w.RegisterActivity(temporal.RecordBeneficialOwnershipSubmittedActivity)
+	w.RegisterActivity(temporal.CreatePaygTransactionActivity)
+	w.RegisterActivity(temporal.SendWhatsAppActivity)
+
+	// Register the director amendment (CoR39) workflow and its activities
+	w.RegisterWorkflow(temporal.DirectorAmendmentWorkflow)
+	w.RegisterActivity(temporal.LoadCompanyDirectorsActivity)
+	w.RegisterActivity(temporal.ImportCompanyDirectorsActivity)
+	w.RegisterActivity(temporal.RecordDirectorAmendmentFilingActivity)
+	w.RegisterActivity(temporal.ApplyDirectorAmendmentActivity)
+
+	// Register the document collection workflow and its activities
+	w.RegisterWorkflow(temporal.DocumentCollectionWorkflow)
+	w.RegisterActivity(temporal.StoreCollectedDocumentActivity)
+	w.RegisterActivity(temporal.EscalateDocumentCollectionActivity)
+	w.RegisterActivity(temporal.CloseDocumentCollectionTaskActivity)
+
+	// Register the pre-submission dry run and the compliance check it runs
+	w.RegisterWorkflow(temporal.FilingDryRunWorkflow)
+	w.RegisterWorkflow(temporal.ComplianceCheckWorkflow)
+	w.RegisterActivity(temporal.PerformComplianceCheckActivity)
+	w.RegisterActivity(temporal.LoadFilingDocumentsActivity)
+	w.RegisterActivity(temporal.QuoteFilingFeeActivity)
+
+	// Register the name reservation workflow and its activities
+	w.RegisterWorkflow(temporal.NameReservationWorkflow)
+	w.RegisterActivity(temporal.LoadRegisteredNamesActivity)
+	w.RegisterActivity(temporal.RecordNameReservationActivity)
+
+	// Register the company registration (CoR14.1) workflow and its activities
+	w.RegisterWorkflow(temporal.CompanyRegistrationWorkflow)
+	w.RegisterActivity(temporal.LoadReservedCompanyNameActivity)
+	w.RegisterActivity(temporal.OnboardRegisteredCompanyActivity)
+
+	// Register the B-BBEE sworn affidavit workflow and its activities
+	w.RegisterWorkflow(temporal.BBEEAffidavitWorkflow)
+	w.RegisterActivity(temporal.GenerateBBEEAffidavitActivity)
+
+	// Register the AFS submission workflow and its XBRL activity
+	w.RegisterWorkflow(temporal.AFSSubmissionWorkflow)
+	w.RegisterActivity(temporal.GenerateXBRLPackageActivity)
+
+	// Register the deregistration risk check and the reinstatement workflow
+	w.RegisterWorkflow(temporal.ReinstatementWorkflow)
+	w.RegisterActivity(temporal.CheckDeregistrationRiskActivity)
+	w.RegisterActivity(temporal.SendCompanyStatusAlertsActivity)
+	w.RegisterActivity(temporal.RefreshCompanyStatusActivity)
+	w.RegisterActivity(temporal.RecordCatchUpFilingActivity)
+
+	// Register the conversation activities used to route WhatsApp replies
+	w.RegisterActivity(temporal.OpenConversationActivity)
+	w.RegisterActivity(temporal.CloseConversationActivity)
+
+	// Activities that open a CIPC portal session run on their own queue, a few at a time per worker
+	portalWorker := worker.New(c, temporal.CIPCPortalTaskQueue, worker.Options{
+		MaxConcurrentActivityExecutionSize: temporal.PortalSessionsPerWorker(),
+		WorkerStopTimeout:                  30 * time.Second,
+	})
+	portalWorker.RegisterActivity(temporal.ExecuteAutomatedFilingActivity)
+	portalWorker.RegisterActivity(temporal.SubmitToCIPCActivity)
+	portalWorker.RegisterActivity(temporal.PollCIPCFilingActivity)
+	portalWorker.RegisterActivity(temporal.SubmitNameReservationActivity)
+	portalWorker.RegisterActivity(temporal.PollNameReservationActivity)
+	if err := portalWorker.Start(); err != nil {
+		log.Fatalln("Unable to start portal worker", err)
+	}
+	defer portalWorker.Stop()
+
+	// Urgent filings run on their own queue, so they never wait behind routine work
+	urgentWorker := worker.New(c, temporal.UrgentTaskQueue, worker.Options{
+		WorkerStopTimeout: 30 * time.Second,
+	})
+	urgentWorker.RegisterWorkflow(temporal.CombinedFilingWorkflow)
+	urgentWorker.RegisterActivity(temporal.RecordFilingWorkflowActivity)
+	urgentWorker.RegisterActivity(temporal.ValidatePaymentActivity)
+	urgentWorker.RegisterActivity(temporal.ExtractDocumentDataActivity)
+	urgentWorker.RegisterActivity(temporal.RequestOTPActivity)
+	urgentWorker.RegisterActivity(temporal.UpdateUserRecordsActivity)
+	urgentWorker.RegisterActivity(temporal.CompensateFilingActivity)
+	urgentWorker.RegisterActivity(temporal.SettleRetriedFilingActivity)
+	urgentWorker.RegisterActivity(temporal.SendWhatsAppMessageActivity)
+	urgentWorker.RegisterActivity(temporal.OpenConversationActivity)
+	urgentWorker.RegisterActivity(temporal.CloseConversationActivity)
+	urgentWorker.RegisterActivity(temporal.EscalateUrgentFilingActivity)
+	urgentWorker.RegisterActivity(temporal.CreditUrgencyFeeActivity)
+	urgentWorker.RegisterActivity(temporal.RecordFilingSLAActivity)
+	if err := urgentWorker.Start(); err != nil {
+		log.Fatalln("Unable to start urgent worker", err)
+	}
+	defer urgentWorker.Stop()
+
+	// Serve the ops and filing API alongside the worker
+	api, err := temporal.NewAPIServer(c)
+	if err != nil {
+		log.Fatalln("Unable to create API server", err)
+	}
+	go func() {
+		if err := api.ListenAndServe(); err != nil {
+			log.Fatalf("HTTP API failed: %s", err)
+		}
+	}()
+
 	log.Println("Worker starting...")
 	err = w.Run(worker.InterruptCh())
 	if err != nil {
@@ -15,10 +15,29 @@ import (
 
 // --- Signal Structs ---
 type DocumentSignal struct {
+	// DocumentID is the document's ID in the vault, when it was uploaded there.
+	DocumentID   string
 	DocumentURL  string
 	DocumentType string
 }
 
+// DocumentSignalName is the signal a workflow receives a DocumentSignal on.
+const DocumentSignalName = "document-uploaded"
+
+// CIPCOutcomeSignal carries CIPC's final decision on a submitted filing.
+type CIPCOutcomeSignal struct {
+	Reference       string
+	Approved        bool
+	RejectionReason string
+	// ApprovedName is the name CIPC reserved, for name reservations.
+	ApprovedName string
+	// RegistrationNumber is the number CIPC issued, for company registrations.
+	RegistrationNumber string
+}
+
+// CIPCOutcomeSignalName is the signal a workflow receives a CIPCOutcomeSignal on.
+const CIPCOutcomeSignalName = "cipc-outcome"
+
 // --- Helper for Internal API Calls ---
 func CallInternalAPI(ctx context.Context, to, message string) error {
     // In a real implementation, this would make an authenticated API call
@@ -0,0 +1,644 @@
+package temporal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Namespaces of an XBRL instance document lodged with CIPC.
+const (
+	xbrliNamespace    = "http://www.xbrl.org/2003/instance"
+	linkNamespace     = "http://www.xbrl.org/2003/linkbase"
+	xlinkNamespace    = "http://www.w3.org/1999/xlink"
+	iso4217Namespace  = "http://www.xbrl.org/2003/iso4217"
+	ifrsFullNamespace = "https://xbrl.ifrs.org/taxonomy/2023-03-23/ifrs-full"
+	cipcCANamespace   = "http://xbrl.cipc.co.za/taxonomy/cipc-ca"
+)
+
+// CIPCTaxonomyEntryPoint is the schema the instance documents we generate refer to.
+const CIPCTaxonomyEntryPoint = "http://xbrl.cipc.co.za/taxonomy/cipc-ca/2023-03-23/cipc-ca-ifrs-smes-entry-point.xsd"
+
+// Vault document types of a generated XBRL instance and its PDF preview.
+const (
+	DocumentTypeXBRLInstance = "xbrl_instance"
+	DocumentTypeXBRLPreview  = "xbrl_preview"
+)
+
+// Data types, period types and statements of the taxonomy concepts we map to.
+const (
+	XBRLTypeMonetary = "monetary"
+	XBRLTypeString   = "string"
+	XBRLTypeDate     = "date"
+
+	XBRLPeriodInstant  = "instant"
+	XBRLPeriodDuration = "duration"
+
+	XBRLStatementEntity            = "entity"
+	XBRLStatementFinancialPosition = "financial_position"
+	XBRLStatementProfitOrLoss      = "profit_or_loss"
+)
+
+// Contexts of a generated instance: the financial year and its last day.
+const (
+	xbrlDurationContext = "CurrentYearDuration"
+	xbrlInstantContext  = "CurrentYearInstant"
+)
+
+// XBRLConcept is an element of the CIPC taxonomy.
+type XBRLConcept struct {
+	Prefix     string `json:"prefix"`
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	PeriodType string `json:"period_type"`
+	// Balance is "debit" or "credit" for monetary concepts. It decides the sign trial balance
+	// lines are reported with.
+	Balance   string `json:"balance,omitempty"`
+	Statement string `json:"statement"`
+}
+
+// QName is the concept's prefixed name, e.g. ifrs-full:Revenue.
+func (c XBRLConcept) QName() string {
+	return c.Prefix + ":" + c.Name
+}
+
+func monetaryConcept(name, label, balance, statement string) XBRLConcept {
+	periodType := XBRLPeriodInstant
+	if statement == XBRLStatementProfitOrLoss {
+		periodType = XBRLPeriodDuration
+	}
+	return XBRLConcept{Prefix: "ifrs-full", Name: name, Label: label, Type: XBRLTypeMonetary, PeriodType: periodType, Balance: balance, Statement: statement}
+}
+
+// cipcTaxonomy is the part of the CIPC taxonomy small companies report on, in presentation order.
+var cipcTaxonomy = []XBRLConcept{
+	{Prefix: "cipc-ca", Name: "FullRegisteredNameOfCompany", Label: "Registered name", Type: XBRLTypeString, PeriodType: XBRLPeriodDuration, Statement: XBRLStatementEntity},
+	{Prefix: "cipc-ca", Name: "RegistrationNumberOfCompany", Label: "Registration number", Type: XBRLTypeString, PeriodType: XBRLPeriodDuration, Statement: XBRLStatementEntity},
+	{Prefix: "ifrs-full", Name: "DateOfEndOfReportingPeriod2013", Label: "Financial year end", Type: XBRLTypeDate, PeriodType: XBRLPeriodDuration, Statement: XBRLStatementEntity},
+	{Prefix: "ifrs-full", Name: "DescriptionOfPresentationCurrency", Label: "Presentation currency", Type: XBRLTypeString, PeriodType: XBRLPeriodDuration, Statement: XBRLStatementEntity},
+
+	monetaryConcept("PropertyPlantAndEquipment", "Property, plant and equipment", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("IntangibleAssetsOtherThanGoodwill", "Intangible assets", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("NoncurrentAssets", "Total non-current assets", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("Inventories", "Inventories", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("TradeAndOtherCurrentReceivables", "Trade and other receivables", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("CashAndCashEquivalents", "Cash and cash equivalents", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("CurrentAssets", "Total current assets", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("Assets", "Total assets", "debit", XBRLStatementFinancialPosition),
+	monetaryConcept("IssuedCapital", "Share capital", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("RetainedEarnings", "Retained earnings", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("Equity", "Total equity", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("LongtermBorrowings", "Long-term borrowings", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("NoncurrentLiabilities", "Total non-current liabilities", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("TradeAndOtherCurrentPayables", "Trade and other payables", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("CurrentTaxLiabilitiesCurrent", "Current tax payable", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("ShorttermBorrowings", "Short-term borrowings", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("CurrentLiabilities", "Total current liabilities", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("Liabilities", "Total liabilities", "credit", XBRLStatementFinancialPosition),
+	monetaryConcept("EquityAndLiabilities", "Total equity and liabilities", "credit", XBRLStatementFinancialPosition),
+
+	monetaryConcept("Revenue", "Revenue", "credit", XBRLStatementProfitOrLoss),
+	monetaryConcept("CostOfSales", "Cost of sales", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("GrossProfit", "Gross profit", "credit", XBRLStatementProfitOrLoss),
+	monetaryConcept("OtherIncome", "Other income", "credit", XBRLStatementProfitOrLoss),
+	monetaryConcept("DistributionCosts", "Distribution costs", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("AdministrativeExpense", "Administrative expenses", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("OtherExpenseByFunction", "Other expenses", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("FinanceIncome", "Finance income", "credit", XBRLStatementProfitOrLoss),
+	monetaryConcept("FinanceCosts", "Finance costs", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("ProfitLossBeforeTax", "Profit before tax", "credit", XBRLStatementProfitOrLoss),
+	monetaryConcept("IncomeTaxExpenseContinuingOperations", "Income tax expense", "debit", XBRLStatementProfitOrLoss),
+	monetaryConcept("ProfitLoss", "Profit for the year", "credit", XBRLStatementProfitOrLoss),
+}
+
+var xbrlConcepts = func() map[string]XBRLConcept {
+	concepts := map[string]XBRLConcept{}
+	for _, c := range cipcTaxonomy {
+		concepts[c.Name] = c
+	}
+	return concepts
+}()
+
+// LookupXBRLConcept finds a concept by its name, with or without its prefix.
+func LookupXBRLConcept(name string) (XBRLConcept, bool) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		c, ok := xbrlConcepts[name[i+1:]]
+		return c, ok && c.Prefix == name[:i]
+	}
+	c, ok := xbrlConcepts[name]
+	return c, ok
+}
+
+type xbrlCalculationItem struct {
+	concept string
+	weight  float64
+}
+
+type xbrlCalculation struct {
+	total string
+	items []xbrlCalculationItem
+}
+
+func calculation(total string, items ...string) xbrlCalculation {
+	c := xbrlCalculation{total: total}
+	for _, item := range items {
+		weight := 1.0
+		if strings.HasPrefix(item, "-") {
+			item, weight = item[1:], -1
+		}
+		c.items = append(c.items, xbrlCalculationItem{concept: item, weight: weight})
+	}
+	return c
+}
+
+// xbrlCalculations are the taxonomy's calculation relationships, in the order totals are worked
+// out. Totals are always calculated, never mapped from the trial balance.
+var xbrlCalculations = []xbrlCalculation{
+	calculation("GrossProfit", "Revenue", "-CostOfSales"),
+	calculation("ProfitLossBeforeTax", "GrossProfit", "OtherIncome", "-DistributionCosts", "-AdministrativeExpense", "-OtherExpenseByFunction", "FinanceIncome", "-FinanceCosts"),
+	calculation("ProfitLoss", "ProfitLossBeforeTax", "-IncomeTaxExpenseContinuingOperations"),
+	calculation("NoncurrentAssets", "PropertyPlantAndEquipment", "IntangibleAssetsOtherThanGoodwill"),
+	calculation("CurrentAssets", "Inventories", "TradeAndOtherCurrentReceivables", "CashAndCashEquivalents"),
+	calculation("Assets", "NoncurrentAssets", "CurrentAssets"),
+	calculation("Equity", "IssuedCapital", "RetainedEarnings"),
+	calculation("NoncurrentLiabilities", "LongtermBorrowings"),
+	calculation("CurrentLiabilities", "TradeAndOtherCurrentPayables", "CurrentTaxLiabilitiesCurrent", "ShorttermBorrowings"),
+	calculation("Liabilities", "NoncurrentLiabilities", "CurrentLiabilities"),
+	calculation("EquityAndLiabilities", "Equity", "Liabilities"),
+}
+
+var xbrlTotals = func() map[string]bool {
+	totals := map[string]bool{}
+	for _, c := range xbrlCalculations {
+		totals[c.total] = true
+	}
+	return totals
+}()
+
+// xbrlTolerance is how far apart two amounts may be, for rounding, and still agree.
+const xbrlTolerance = 0.01
+
+// TrialBalanceLine is an account of the trial balance and the taxonomy concept it is reported
+// under.
+type TrialBalanceLine struct {
+	AccountCode string  `json:"account_code"`
+	AccountName string  `json:"account_name"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+	Concept     string  `json:"concept"`
+}
+
+// FinancialStatements are a company's financial year as its trial balance before closing
+// entries: the year's profit is added to the retained earnings mapped from it.
+type FinancialStatements struct {
+	CompanyName        string             `json:"company_name"`
+	RegistrationNumber string             `json:"registration_number"`
+	PeriodStart        time.Time          `json:"period_start"`
+	PeriodEnd          time.Time          `json:"period_end"`
+	Currency           string             `json:"currency,omitempty"`
+	TrialBalance       []TrialBalanceLine `json:"trial_balance"`
+}
+
+// XBRLInstance is a company's financial statements reported against the CIPC taxonomy.
+type XBRLInstance struct {
+	CompanyName        string    `json:"company_name"`
+	RegistrationNumber string    `json:"registration_number"`
+	PeriodStart        time.Time `json:"period_start"`
+	PeriodEnd          time.Time `json:"period_end"`
+	Currency           string    `json:"currency"`
+	// Amounts holds every mapped and calculated monetary concept by name.
+	Amounts map[string]float64 `json:"amounts"`
+}
+
+// BuildXBRLInstance maps the trial balance onto the taxonomy and calculates the totals. It returns
+// the problems that stop the statements from being reported, as customer-facing messages.
+func BuildXBRLInstance(fs FinancialStatements) (*XBRLInstance, []string) {
+	var problems []string
+	if strings.TrimSpace(fs.CompanyName) == "" {
+		problems = append(problems, "the company name is required")
+	}
+	if _, err := CompanyTypeFromRegNumber(fs.RegistrationNumber); err != nil {
+		problems = append(problems, err.Error())
+	}
+	switch {
+	case fs.PeriodStart.IsZero() || fs.PeriodEnd.IsZero():
+		problems = append(problems, "the start and end of the financial year are required")
+	case !fs.PeriodEnd.After(fs.PeriodStart):
+		problems = append(problems, "the financial year must end after it starts")
+	case fs.PeriodEnd.After(fs.PeriodStart.AddDate(1, 3, 0)):
+		problems = append(problems, "a financial year can't be longer than 15 months")
+	}
+	if len(fs.TrialBalance) == 0 {
+		problems = append(problems, "the trial balance is empty")
+	}
+
+	instance := &XBRLInstance{
+		CompanyName:        strings.TrimSpace(fs.CompanyName),
+		RegistrationNumber: strings.TrimSpace(fs.RegistrationNumber),
+		PeriodStart:        fs.PeriodStart,
+		PeriodEnd:          fs.PeriodEnd,
+		Currency:           fs.Currency,
+		Amounts:            map[string]float64{},
+	}
+	if instance.Currency == "" {
+		instance.Currency = "ZAR"
+	}
+
+	var debits, credits float64
+	for _, line := range fs.TrialBalance {
+		account := strings.TrimSpace(line.AccountCode + " " + line.AccountName)
+		debits += line.Debit
+		credits += line.Credit
+		concept, ok := LookupXBRLConcept(line.Concept)
+		switch {
+		case line.Debit < 0 || line.Credit < 0:
+			problems = append(problems, fmt.Sprintf("account %s has a negative debit or credit", account))
+			continue
+		case strings.TrimSpace(line.Concept) == "":
+			problems = append(problems, fmt.Sprintf("account %s isn't mapped to a taxonomy element", account))
+			continue
+		case !ok:
+			problems = append(problems, fmt.Sprintf("account %s is mapped to %s, which isn't in the CIPC taxonomy", account, line.Concept))
+			continue
+		case concept.Type != XBRLTypeMonetary:
+			problems = append(problems, fmt.Sprintf("account %s is mapped to %s, which isn't an amount", account, concept.QName()))
+			continue
+		case xbrlTotals[concept.Name]:
+			problems = append(problems, fmt.Sprintf("account %s is mapped to %s, which is a total; map it to one of its items", account, concept.QName()))
+			continue
+		}
+		amount := line.Debit - line.Credit
+		if concept.Balance == "credit" {
+			amount = -amount
+		}
+		instance.Amounts[concept.Name] += amount
+	}
+	if math.Abs(debits-credits) > xbrlTolerance {
+		problems = append(problems, fmt.Sprintf("the trial balance doesn't balance: debits are %.2f and credits are %.2f", debits, credits))
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	for _, c := range xbrlCalculations {
+		if c.total == "Equity" {
+			// Close the year's profit into retained earnings before equity is totalled.
+			instance.Amounts["RetainedEarnings"] += instance.Amounts["ProfitLoss"]
+		}
+		var total float64
+		for _, item := range c.items {
+			total += item.weight * instance.Amounts[item.concept]
+		}
+		instance.Amounts[c.total] = total
+	}
+	for name, amount := range instance.Amounts {
+		instance.Amounts[name] = math.Round(amount*100) / 100
+	}
+	return instance, nil
+}
+
+type xbrlDocument struct {
+	XMLName      xml.Name `xml:"xbrli:xbrl"`
+	XMLNSXbrli   string   `xml:"xmlns:xbrli,attr"`
+	XMLNSLink    string   `xml:"xmlns:link,attr"`
+	XMLNSXlink   string   `xml:"xmlns:xlink,attr"`
+	XMLNSIso4217 string   `xml:"xmlns:iso4217,attr"`
+	XMLNSIfrs    string   `xml:"xmlns:ifrs-full,attr"`
+	XMLNSCipc    string   `xml:"xmlns:cipc-ca,attr"`
+	SchemaRef    struct {
+		Type string `xml:"xlink:type,attr"`
+		Href string `xml:"xlink:href,attr"`
+	} `xml:"link:schemaRef"`
+	Contexts []xbrlContextElement `xml:"xbrli:context"`
+	Unit     struct {
+		ID      string `xml:"id,attr"`
+		Measure string `xml:"xbrli:measure"`
+	} `xml:"xbrli:unit"`
+	Facts []xbrlFactElement
+}
+
+type xbrlContextElement struct {
+	ID     string `xml:"id,attr"`
+	Entity struct {
+		Identifier struct {
+			Scheme string `xml:"scheme,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"xbrli:identifier"`
+	} `xml:"xbrli:entity"`
+	Period struct {
+		Instant   string `xml:"xbrli:instant,omitempty"`
+		StartDate string `xml:"xbrli:startDate,omitempty"`
+		EndDate   string `xml:"xbrli:endDate,omitempty"`
+	} `xml:"xbrli:period"`
+}
+
+type xbrlFactElement struct {
+	XMLName    xml.Name
+	ContextRef string `xml:"contextRef,attr"`
+	UnitRef    string `xml:"unitRef,attr,omitempty"`
+	Decimals   string `xml:"decimals,attr,omitempty"`
+	Value      string `xml:",chardata"`
+}
+
+// xbrlEntityScheme identifies companies by their CIPC registration number.
+const xbrlEntityScheme = "http://www.cipc.co.za"
+
+// Marshal renders the instance document.
+func (i *XBRLInstance) Marshal() ([]byte, error) {
+	doc := xbrlDocument{
+		XMLNSXbrli:   xbrliNamespace,
+		XMLNSLink:    linkNamespace,
+		XMLNSXlink:   xlinkNamespace,
+		XMLNSIso4217: iso4217Namespace,
+		XMLNSIfrs:    ifrsFullNamespace,
+		XMLNSCipc:    cipcCANamespace,
+	}
+	doc.SchemaRef.Type, doc.SchemaRef.Href = "simple", CIPCTaxonomyEntryPoint
+
+	duration, instant := xbrlContextElement{ID: xbrlDurationContext}, xbrlContextElement{ID: xbrlInstantContext}
+	for _, c := range []*xbrlContextElement{&duration, &instant} {
+		c.Entity.Identifier.Scheme, c.Entity.Identifier.Value = xbrlEntityScheme, i.RegistrationNumber
+	}
+	duration.Period.StartDate, duration.Period.EndDate = i.PeriodStart.Format("2006-01-02"), i.PeriodEnd.Format("2006-01-02")
+	instant.Period.Instant = i.PeriodEnd.Format("2006-01-02")
+	doc.Contexts = []xbrlContextElement{duration, instant}
+	doc.Unit.ID, doc.Unit.Measure = i.Currency, "iso4217:"+i.Currency
+
+	entity := map[string]string{
+		"FullRegisteredNameOfCompany":       i.CompanyName,
+		"RegistrationNumberOfCompany":       i.RegistrationNumber,
+		"DateOfEndOfReportingPeriod2013":    i.PeriodEnd.Format("2006-01-02"),
+		"DescriptionOfPresentationCurrency": i.Currency,
+	}
+	for _, c := range cipcTaxonomy {
+		fact := xbrlFactElement{XMLName: xml.Name{Local: c.QName()}, ContextRef: xbrlDurationContext}
+		if c.PeriodType == XBRLPeriodInstant {
+			fact.ContextRef = xbrlInstantContext
+		}
+		if c.Type != XBRLTypeMonetary {
+			fact.Value = entity[c.Name]
+			doc.Facts = append(doc.Facts, fact)
+			continue
+		}
+		amount, ok := i.Amounts[c.Name]
+		if !ok {
+			continue
+		}
+		fact.UnitRef, fact.Decimals, fact.Value = i.Currency, "2", strconv.FormatFloat(amount, 'f', 2, 64)
+		doc.Facts = append(doc.Facts, fact)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode XBRL instance: %w", err)
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// xbrlPrefixes maps the namespaces facts may be reported in to their taxonomy prefix.
+var xbrlPrefixes = map[string]string{
+	ifrsFullNamespace: "ifrs-full",
+	cipcCANamespace:   "cipc-ca",
+}
+
+// xbrlRequiredConcepts must be reported in every instance lodged with CIPC.
+var xbrlRequiredConcepts = []string{
+	"FullRegisteredNameOfCompany", "RegistrationNumberOfCompany", "DateOfEndOfReportingPeriod2013",
+	"DescriptionOfPresentationCurrency", "Assets", "Liabilities", "Equity", "ProfitLoss",
+}
+
+// ValidateXBRLInstance checks an instance document against the taxonomy the way CIPC's validator
+// does before accepting it: the schema reference, contexts and units; that every fact is a known
+// concept reported for the right period type with a well-formed value; that the required
+// concepts are present; and that totals agree with their calculation items and the statement of
+// financial position balances. It returns the problems found.
+func ValidateXBRLInstance(data []byte) []string {
+	var problems []string
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	type rawElement struct {
+		XMLName  xml.Name
+		Attrs    []xml.Attr `xml:",any,attr"`
+		Value    string     `xml:",chardata"`
+		Children []struct {
+			XMLName  xml.Name
+			Value    string `xml:",chardata"`
+			Children []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	}
+	var root struct {
+		XMLName  xml.Name
+		Elements []rawElement `xml:",any"`
+	}
+	if err := decoder.Decode(&root); err != nil {
+		return []string{fmt.Sprintf("the instance document isn't well-formed XML: %s", err)}
+	}
+	if root.XMLName.Space != xbrliNamespace || root.XMLName.Local != "xbrl" {
+		return []string{"the root element must be xbrli:xbrl"}
+	}
+	attr := func(e rawElement, space, local string) string {
+		for _, a := range e.Attrs {
+			if a.Name.Local == local && (space == "" || a.Name.Space == space) {
+				return a.Value
+			}
+		}
+		return ""
+	}
+
+	contexts := map[string]string{}
+	units := map[string]bool{}
+	schemaRefs := 0
+	type fact struct {
+		concept  XBRLConcept
+		context  string
+		unit     string
+		decimals string
+		value    string
+	}
+	var facts []fact
+	for _, e := range root.Elements {
+		switch {
+		case e.XMLName.Space == linkNamespace && e.XMLName.Local == "schemaRef":
+			schemaRefs++
+			if href := attr(e, xlinkNamespace, "href"); href != CIPCTaxonomyEntryPoint {
+				problems = append(problems, fmt.Sprintf("the schema reference %q isn't the CIPC taxonomy entry point", href))
+			}
+		case e.XMLName.Space == xbrliNamespace && e.XMLName.Local == "context":
+			id := attr(e, "", "id")
+			periodType, identified := "", false
+			for _, child := range e.Children {
+				switch child.XMLName.Local {
+				case "entity":
+					for _, grandchild := range child.Children {
+						identified = identified || (grandchild.XMLName.Local == "identifier" && strings.TrimSpace(grandchild.Value) != "")
+					}
+				case "period":
+					for _, grandchild := range child.Children {
+						if _, err := time.Parse("2006-01-02", strings.TrimSpace(grandchild.Value)); err != nil {
+							problems = append(problems, fmt.Sprintf("context %s has an invalid %s", id, grandchild.XMLName.Local))
+						}
+						switch grandchild.XMLName.Local {
+						case "instant":
+							periodType = XBRLPeriodInstant
+						case "endDate":
+							periodType = XBRLPeriodDuration
+						}
+					}
+				}
+			}
+			if id == "" || periodType == "" || !identified {
+				problems = append(problems, fmt.Sprintf("context %q needs an id, an entity identifier and a period", id))
+				continue
+			}
+			contexts[id] = periodType
+		case e.XMLName.Space == xbrliNamespace && e.XMLName.Local == "unit":
+			units[attr(e, "", "id")] = true
+		default:
+			prefix, ok := xbrlPrefixes[e.XMLName.Space]
+			concept, known := LookupXBRLConcept(prefix + ":" + e.XMLName.Local)
+			if !ok || !known {
+				problems = append(problems, fmt.Sprintf("%s isn't an element of the CIPC taxonomy", e.XMLName.Local))
+				continue
+			}
+			facts = append(facts, fact{concept: concept, context: attr(e, "", "contextRef"), unit: attr(e, "", "unitRef"),
+				decimals: attr(e, "", "decimals"), value: strings.TrimSpace(e.Value)})
+		}
+	}
+	if schemaRefs != 1 {
+		problems = append(problems, "the instance must have exactly one schema reference")
+	}
+
+	amounts := map[string]float64{}
+	reported := map[string]bool{}
+	for _, f := range facts {
+		name := f.concept.QName()
+		periodType, ok := contexts[f.context]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s refers to unknown context %q", name, f.context))
+			continue
+		case periodType != f.concept.PeriodType:
+			problems = append(problems, fmt.Sprintf("%s must be reported for a %s period", name, f.concept.PeriodType))
+		}
+		key := f.concept.Name + "@" + f.context
+		if reported[key] {
+			problems = append(problems, fmt.Sprintf("%s is reported more than once", name))
+		}
+		reported[key] = true
+		reported[f.concept.Name] = true
+
+		if f.concept.Type != XBRLTypeMonetary && f.unit != "" {
+			problems = append(problems, fmt.Sprintf("%s isn't an amount and can't have a unit", name))
+		}
+		switch f.concept.Type {
+		case XBRLTypeMonetary:
+			if !units[f.unit] {
+				problems = append(problems, fmt.Sprintf("%s refers to unknown unit %q", name, f.unit))
+			}
+			if _, err := strconv.Atoi(f.decimals); err != nil && f.decimals != "INF" {
+				problems = append(problems, fmt.Sprintf("%s needs a decimals attribute", name))
+			}
+			amount, err := strconv.ParseFloat(f.value, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s has the invalid amount %q", name, f.value))
+				continue
+			}
+			amounts[f.concept.Name] = amount
+		case XBRLTypeDate:
+			if _, err := time.Parse("2006-01-02", f.value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s has the invalid date %q", name, f.value))
+			}
+		case XBRLTypeString:
+			if f.value == "" {
+				problems = append(problems, fmt.Sprintf("%s is empty", name))
+			}
+		}
+	}
+	for _, name := range xbrlRequiredConcepts {
+		if !reported[name] {
+			problems = append(problems, fmt.Sprintf("%s is required", xbrlConcepts[name].QName()))
+		}
+	}
+
+	var inconsistent []string
+	for _, c := range xbrlCalculations {
+		total, ok := amounts[c.total]
+		if !ok {
+			continue
+		}
+		var sum float64
+		for _, item := range c.items {
+			sum += item.weight * amounts[item.concept]
+		}
+		if math.Abs(total-sum) > xbrlTolerance {
+			inconsistent = append(inconsistent, fmt.Sprintf("%s is %.2f but its items add up to %.2f", xbrlConcepts[c.total].QName(), total, sum))
+		}
+	}
+	sort.Strings(inconsistent)
+	problems = append(problems, inconsistent...)
+	if math.Abs(amounts["Assets"]-amounts["EquityAndLiabilities"]) > xbrlTolerance {
+		problems = append(problems, fmt.Sprintf("total assets of %.2f don't equal total equity and liabilities of %.2f", amounts["Assets"], amounts["EquityAndLiabilities"]))
+	}
+	return problems
+}
+
+// formatXBRLAmount formats an amount for the preview, with negative amounts in brackets.
+func formatXBRLAmount(amount float64) string {
+	cents := fmt.Sprintf("%.2f", math.Abs(amount))
+	text := formatRand(math.Trunc(math.Abs(amount))) + cents[len(cents)-3:]
+	if amount < 0 {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// RenderXBRLPreview renders the instance as a PDF the customer can check before it is lodged:
+// the statement of financial position and the statement of profit or loss, with totals in bold.
+func RenderXBRLPreview(i *XBRLInstance) []byte {
+	doc := NewPDFDocument()
+	doc.Heading(14, i.CompanyName)
+	doc.Paragraph(10, fmt.Sprintf("Registration number %s", i.RegistrationNumber))
+	doc.Paragraph(10, fmt.Sprintf("Annual financial statements for the year ended %s (%s to %s). Amounts in %s.",
+		i.PeriodEnd.Format("2 January 2006"), i.PeriodStart.Format("2 January 2006"), i.PeriodEnd.Format("2 January 2006"), i.Currency))
+
+	sections := []struct {
+		statement string
+		title     string
+	}{
+		{XBRLStatementFinancialPosition, "Statement of financial position as at " + i.PeriodEnd.Format("2 January 2006")},
+		{XBRLStatementProfitOrLoss, "Statement of profit or loss for the year ended " + i.PeriodEnd.Format("2 January 2006")},
+	}
+	for _, section := range sections {
+		doc.Space(12)
+		doc.Heading(12, section.title)
+		for _, c := range cipcTaxonomy {
+			amount, ok := i.Amounts[c.Name]
+			if c.Statement != section.statement || !ok {
+				continue
+			}
+			line := fmt.Sprintf("%s: %s", c.Label, formatXBRLAmount(amount))
+			if xbrlTotals[c.Name] {
+				doc.Heading(10, line)
+			} else {
+				doc.Paragraph(10, line)
+			}
+		}
+	}
+
+	doc.Space(12)
+	doc.Paragraph(8, "This preview was generated from the XBRL instance document that will be lodged with CIPC. Check it against your signed annual financial statements before you approve the submission.")
+	return doc.Bytes()
+}
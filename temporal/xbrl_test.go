@@ -0,0 +1,220 @@
+package temporal
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// testFinancialStatements is a balanced trial balance before closing entries: a profit of
+// 90 000 closes into retained earnings of 199 900, and assets of 300 000 equal equity of
+// 200 000 plus liabilities of 100 000.
+func testFinancialStatements() FinancialStatements {
+	return FinancialStatements{
+		CompanyName:        "Umoya Coffee (Pty) Ltd",
+		RegistrationNumber: "2020/123456/07",
+		PeriodStart:        time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:          time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC),
+		TrialBalance: []TrialBalanceLine{
+			{AccountCode: "1000", AccountName: "Bank", Debit: 150_000, Concept: "CashAndCashEquivalents"},
+			{AccountCode: "1100", AccountName: "Debtors", Debit: 50_000, Concept: "TradeAndOtherCurrentReceivables"},
+			{AccountCode: "1500", AccountName: "Equipment", Debit: 100_000, Concept: "ifrs-full:PropertyPlantAndEquipment"},
+			{AccountCode: "2000", AccountName: "Creditors", Credit: 40_000, Concept: "TradeAndOtherCurrentPayables"},
+			{AccountCode: "2500", AccountName: "Bank loan", Credit: 60_000, Concept: "LongtermBorrowings"},
+			{AccountCode: "3000", AccountName: "Share capital", Credit: 100, Concept: "IssuedCapital"},
+			{AccountCode: "3100", AccountName: "Retained earnings", Credit: 109_900, Concept: "RetainedEarnings"},
+			{AccountCode: "4000", AccountName: "Sales", Credit: 500_000, Concept: "Revenue"},
+			{AccountCode: "5000", AccountName: "Purchases", Debit: 300_000, Concept: "CostOfSales"},
+			{AccountCode: "6000", AccountName: "Salaries", Debit: 100_000, Concept: "AdministrativeExpense"},
+			{AccountCode: "6500", AccountName: "Interest", Debit: 6_000, Concept: "FinanceCosts"},
+			{AccountCode: "7000", AccountName: "Income tax", Debit: 4_000, Concept: "IncomeTaxExpenseContinuingOperations"},
+		},
+	}
+}
+
+func TestBuildXBRLInstance(t *testing.T) {
+	instance, problems := BuildXBRLInstance(testFinancialStatements())
+	require.Empty(t, problems)
+	assert.Equal(t, "ZAR", instance.Currency)
+	assert.Equal(t, 200_000.0, instance.Amounts["GrossProfit"])
+	assert.Equal(t, 90_000.0, instance.Amounts["ProfitLoss"])
+	assert.Equal(t, 199_900.0, instance.Amounts["RetainedEarnings"])
+	assert.Equal(t, 300_000.0, instance.Amounts["Assets"])
+	assert.Equal(t, 300_000.0, instance.Amounts["EquityAndLiabilities"])
+	assert.Equal(t, 0.0, instance.Amounts["NoncurrentAssets"]-instance.Amounts["PropertyPlantAndEquipment"])
+	_, mapped := instance.Amounts["Inventories"]
+	assert.False(t, mapped)
+
+	fs := testFinancialStatements()
+	fs.TrialBalance[0].Debit = 149_000
+	fs.TrialBalance[1].Concept = "TradeReceivables"
+	fs.TrialBalance[2].Concept = "Assets"
+	fs.TrialBalance[3].Concept = ""
+	_, problems = BuildXBRLInstance(fs)
+	assert.Equal(t, []string{
+		"account 1100 Debtors is mapped to TradeReceivables, which isn't in the CIPC taxonomy",
+		"account 1500 Equipment is mapped to ifrs-full:Assets, which is a total; map it to one of its items",
+		"account 2000 Creditors isn't mapped to a taxonomy element",
+		"the trial balance doesn't balance: debits are 709000.00 and credits are 710000.00",
+	}, problems)
+}
+
+func TestXBRLInstanceRoundTrip(t *testing.T) {
+	instance, problems := BuildXBRLInstance(testFinancialStatements())
+	require.Empty(t, problems)
+	data, err := instance.Marshal()
+	require.NoError(t, err)
+
+	text := string(data)
+	assert.Contains(t, text, `<link:schemaRef xlink:type="simple" xlink:href="`+CIPCTaxonomyEntryPoint+`">`)
+	assert.Contains(t, text, `<ifrs-full:Revenue contextRef="CurrentYearDuration" unitRef="ZAR" decimals="2">500000.00</ifrs-full:Revenue>`)
+	assert.Contains(t, text, `<ifrs-full:Assets contextRef="CurrentYearInstant" unitRef="ZAR" decimals="2">300000.00</ifrs-full:Assets>`)
+	assert.Contains(t, text, `<cipc-ca:RegistrationNumberOfCompany contextRef="CurrentYearDuration">2020/123456/07</cipc-ca:RegistrationNumberOfCompany>`)
+	assert.Empty(t, ValidateXBRLInstance(data))
+
+	mimeType, err := DetectDocumentType(data)
+	require.NoError(t, err)
+	assert.Equal(t, "text/xml", mimeType)
+}
+
+func TestValidateXBRLInstance(t *testing.T) {
+	instance, _ := BuildXBRLInstance(testFinancialStatements())
+	data, err := instance.Marshal()
+	require.NoError(t, err)
+
+	tampered := strings.Replace(string(data), ">300000.00</ifrs-full:Assets>", ">310000.00</ifrs-full:Assets>", 1)
+	tampered = strings.Replace(tampered, `<ifrs-full:Revenue contextRef="CurrentYearDuration"`, `<ifrs-full:Revenue contextRef="CurrentYearInstant"`, 1)
+	tampered = strings.Replace(tampered, `<ifrs-full:CostOfSales contextRef="CurrentYearDuration" unitRef="ZAR"`, `<ifrs-full:CostOfSales contextRef="CurrentYearDuration" unitRef="USD"`, 1)
+	tampered = strings.Replace(tampered, "<cipc-ca:RegistrationNumberOfCompany", "<cipc-ca:CompanyNumber", 1)
+	tampered = strings.Replace(tampered, "</cipc-ca:RegistrationNumberOfCompany>", "</cipc-ca:CompanyNumber>", 1)
+	assert.Equal(t, []string{
+		"CompanyNumber isn't an element of the CIPC taxonomy",
+		"ifrs-full:Revenue must be reported for a duration period",
+		`ifrs-full:CostOfSales refers to unknown unit "USD"`,
+		"cipc-ca:RegistrationNumberOfCompany is required",
+		"ifrs-full:Assets is 310000.00 but its items add up to 300000.00",
+		"total assets of 310000.00 don't equal total equity and liabilities of 300000.00",
+	}, ValidateXBRLInstance([]byte(tampered)))
+
+	assert.Equal(t, []string{"the root element must be xbrli:xbrl"}, ValidateXBRLInstance([]byte(`<?xml version="1.0"?><xbrl/>`)))
+}
+
+func TestRenderXBRLPreview(t *testing.T) {
+	instance, _ := BuildXBRLInstance(testFinancialStatements())
+	text, err := ExtractPDFText(RenderXBRLPreview(instance))
+	require.NoError(t, err)
+	assert.Contains(t, text, "Statement of financial position as at 28 February 2026")
+	assert.Contains(t, text, "Total assets: 300 000.00")
+	assert.Contains(t, text, "Retained earnings: 199 900.00")
+	assert.Contains(t, text, "Cost of sales: 300 000.00")
+	assert.Contains(t, text, "Profit for the year: 90 000.00")
+	assert.NotContains(t, text, "Inventories")
+
+	assert.Equal(t, "(1 250.50)", formatXBRLAmount(-1250.5))
+}
+
+// AFSSubmissionWorkflowTestSuite tests AFSSubmissionWorkflow.
+type AFSSubmissionWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env      *testsuite.TestWorkflowEnvironment
+	messages []string
+}
+
+// TestAFSSubmissionWorkflowTestSuite runs the test suite.
+func TestAFSSubmissionWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(AFSSubmissionWorkflowTestSuite))
+}
+
+// SetupTest sets up the test environment before each test.
+func (s *AFSSubmissionWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.messages = nil
+	s.env.OnActivity(SendWhatsAppActivity, mock.Anything, "+27721234567", mock.Anything).Return(
+		func(_ context.Context, _ string, message string) error {
+			s.messages = append(s.messages, message)
+			return nil
+		}).Maybe()
+	s.env.OnActivity(OpenConversationActivity, mock.Anything, mock.Anything).Return("conversation-1", nil).Maybe()
+	s.env.OnActivity(CloseConversationActivity, mock.Anything, "conversation-1").Return(nil).Maybe()
+}
+
+// AfterTest asserts that all mocks were called as expected.
+func (s *AFSSubmissionWorkflowTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *AFSSubmissionWorkflowTestSuite) input() AFSSubmissionInput {
+	return AFSSubmissionInput{
+		UserID:           "user-1",
+		PhoneNumber:      "+27721234567",
+		CompanyID:        "company-1",
+		CompanyRegNumber: "2020/123456/07",
+		Statements:       testFinancialStatements(),
+	}
+}
+
+func (s *AFSSubmissionWorkflowTestSuite) result() AFSSubmissionResult {
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result AFSSubmissionResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+// Test_FileReplyStartsFiling tests that approving the preview charges for and files the XBRL
+// instance.
+func (s *AFSSubmissionWorkflowTestSuite) Test_FileReplyStartsFiling() {
+	s.env.OnActivity(GenerateXBRLPackageActivity, mock.Anything, "company-1", mock.Anything).Return(
+		&XBRLPackage{InstanceDocumentID: "doc-xbrl", PreviewDocumentID: "doc-preview", PreviewURL: "https://example.test/doc-preview"}, nil).Once()
+	s.env.OnActivity(CreatePaygTransactionActivity, mock.Anything, mock.MatchedBy(func(in CreatePaygTransactionInput) bool {
+		return in.ServiceType == AFSSubmissionServiceType && in.FilingData["xbrl_document_id"] == "doc-xbrl"
+	})).Return("tx-1", nil).Once()
+	s.env.OnWorkflow(CombinedFilingWorkflow, mock.Anything, mock.Anything).Return(&FilingWorkflowResult{Success: true}, nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FileConfirmSignalName, true)
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(AFSSubmissionWorkflow, s.input())
+
+	result := s.result()
+	s.True(result.Success)
+	s.Equal("filing-afs-submission-tx-1", result.FilingID)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "Total assets: R300 000.00")
+	s.Contains(s.messages[0], "https://example.test/doc-preview")
+}
+
+// Test_NoReplyIsNotCharged tests that an unapproved preview is never charged or filed.
+func (s *AFSSubmissionWorkflowTestSuite) Test_NoReplyIsNotCharged() {
+	s.env.OnActivity(GenerateXBRLPackageActivity, mock.Anything, "company-1", mock.Anything).Return(
+		&XBRLPackage{InstanceDocumentID: "doc-xbrl", PreviewDocumentID: "doc-preview"}, nil).Once()
+
+	s.env.ExecuteWorkflow(AFSSubmissionWorkflow, s.input())
+
+	result := s.result()
+	s.False(result.Success)
+	s.Equal("not_approved", result.Status)
+}
+
+// Test_InvalidTrialBalance tests that problems with the trial balance are sent to the customer.
+func (s *AFSSubmissionWorkflowTestSuite) Test_InvalidTrialBalance() {
+	input := s.input()
+	input.CompanyRegNumber = "2021/654321/07"
+
+	s.env.ExecuteWorkflow(AFSSubmissionWorkflow, input)
+
+	result := s.result()
+	s.Equal("invalid", result.Status)
+	s.Equal([]string{"the financial statements are for 2020/123456/07, not 2021/654321/07"}, result.Problems)
+	s.Require().Len(s.messages, 1)
+	s.Contains(s.messages[0], "not 2021/654321/07")
+}